@@ -0,0 +1,333 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"errors"
+	"github.com/spf13/cast"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// GroupStats is statistics of records in the same group.
+type GroupStats struct {
+	// Key is the value of group by field, empty if field is missing in records.
+	Key string `json:"key"`
+	// Count is number of records.
+	Count int64 `json:"count"`
+	// ErrorCount is number of records with error.
+	ErrorCount int64 `json:"errorCount"`
+	// Percentiles of elapsed time.
+	P50 time.Duration `json:"p50"`
+	P90 time.Duration `json:"p90"`
+	P99 time.Duration `json:"p99"`
+	Max time.Duration `json:"max"`
+	// ResCodes is number of records by resCode.
+	ResCodes map[string]int64 `json:"resCodes"`
+	// Errors is number of errors sorted by count in descending order.
+	Errors []*KeyCount `json:"errors"`
+	// Timing is totals of timers by timer name.
+	Timing map[string]*TimerStats `json:"timing"`
+	// elapsed time of records in nanoseconds, it is a reservoir if records are sampled
+	elapsed []int64
+	// max elapsed time of records in nanoseconds
+	maxElapsed int64
+	// errors indexed by key
+	errorIndex map[string]*KeyCount
+}
+
+// KeyCount is a key with count.
+type KeyCount struct {
+	Key   string `json:"key"`
+	Count int64  `json:"count"`
+}
+
+// TimerStats is totals of a timer.
+type TimerStats struct {
+	Count     int64 `json:"count"`
+	ElapsedMs int64 `json:"elapsedMs"`
+}
+
+// TopErrors returns at most n errors with the largest count.
+func (stats *GroupStats) TopErrors(n int) []*KeyCount {
+	if n < 0 || n > len(stats.Errors) {
+		return stats.Errors
+	}
+
+	return stats.Errors[:n]
+}
+
+// Max number of elapsed time of events kept by each group in order to estimate percentiles, see Aggregator.AddView().
+const aggregateReservoirSize = 1024
+
+// Aggregator aggregates records by group.
+//
+// Percentiles of records added by Add() are exact, which is used by Aggregate() to read logs offline.
+// Events added by AddView() are sampled, see AddView().
+//
+// Aggregator is thread safe, so that it could be updated by events finished concurrently, see WithAggregator().
+// Use ReportAndReset() to report statistics by window in a long-running service.
+type Aggregator struct {
+	groupBy string
+	groups  map[string]*GroupStats
+	random  *rand.Rand
+	lock    sync.Mutex
+}
+
+// NewAggregator creates a new aggregator which groups records by field with path.
+// Please refer Record.Value() for format of path, operation will be used if path is empty.
+func NewAggregator(groupBy string) *Aggregator {
+	return &Aggregator{
+		groupBy: getDefaultIfEmptyString(groupBy, operationKey),
+		groups:  make(map[string]*GroupStats),
+		random:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Add record into aggregator, elapsed time of every record is kept in order to calculate exact percentiles.
+func (agg *Aggregator) Add(record *Record) {
+	agg.add(record, nil)
+}
+
+// AddView adds finished event into aggregator, it is called by Event.Finish() with WithAggregator().
+//
+// Percentiles are exact until a group has 1024 events, and estimated with a uniform sample of 1024 events after,
+// so that memory of Aggregator in a long-running service is bounded by number of groups. Max is always exact.
+func (agg *Aggregator) AddView(view EventView) {
+	if view == nil {
+		return
+	}
+
+	agg.add(recordOf(view), agg.random)
+}
+
+// Add record into aggregator, elapsed time is sampled with random if not nil.
+func (agg *Aggregator) add(record *Record, random *rand.Rand) {
+	if record == nil {
+		return
+	}
+
+	key := ""
+	if val, ok := record.Value(agg.groupBy); ok {
+		key = cast.ToString(val)
+	}
+
+	agg.lock.Lock()
+	defer agg.lock.Unlock()
+
+	stats, ok := agg.groups[key]
+	if !ok {
+		stats = &GroupStats{
+			Key:        key,
+			ResCodes:   make(map[string]int64),
+			Errors:     make([]*KeyCount, 0),
+			Timing:     make(map[string]*TimerStats),
+			elapsed:    make([]int64, 0),
+			errorIndex: make(map[string]*KeyCount),
+		}
+		agg.groups[key] = stats
+	}
+
+	stats.Count++
+	stats.addElapsed(record.ElapsedNano, random)
+	stats.ResCodes[record.ResCode]++
+
+	if record.HasError() {
+		stats.ErrorCount++
+	}
+
+	for k, v := range record.Errors {
+		stats.addError(k, v)
+	}
+
+	for k, v := range record.Timing {
+		name, field := splitTimingKey(k)
+		if len(name) < 1 {
+			continue
+		}
+
+		timer, ok := stats.Timing[name]
+		if !ok {
+			timer = &TimerStats{}
+			stats.Timing[name] = timer
+		}
+
+		switch field {
+		case timingElapsedMs:
+			timer.ElapsedMs += v
+		case timingCount:
+			timer.Count += v
+		}
+	}
+}
+
+// Report returns statistics of groups sorted by count in descending order.
+// Statistics are copied, so that they are not affected by records added after.
+func (agg *Aggregator) Report() []*GroupStats {
+	agg.lock.Lock()
+	defer agg.lock.Unlock()
+
+	return agg.report()
+}
+
+// Reset removes all groups.
+func (agg *Aggregator) Reset() {
+	agg.lock.Lock()
+	defer agg.lock.Unlock()
+
+	agg.groups = make(map[string]*GroupStats)
+}
+
+// ReportAndReset returns statistics like Report() and removes all groups at the same time,
+// so that no record is missing or reported twice between windows.
+func (agg *Aggregator) ReportAndReset() []*GroupStats {
+	agg.lock.Lock()
+	defer agg.lock.Unlock()
+
+	res := agg.report()
+	agg.groups = make(map[string]*GroupStats)
+
+	return res
+}
+
+// Returns statistics of groups sorted by count in descending order, lock should be held.
+func (agg *Aggregator) report() []*GroupStats {
+	res := make([]*GroupStats, 0, len(agg.groups))
+
+	for _, group := range agg.groups {
+		stats := group.copy()
+
+		elapsed := make([]int64, len(group.elapsed))
+		copy(elapsed, group.elapsed)
+		sort.Slice(elapsed, func(i, j int) bool { return elapsed[i] < elapsed[j] })
+
+		stats.P50 = time.Duration(percentile(elapsed, 50))
+		stats.P90 = time.Duration(percentile(elapsed, 90))
+		stats.P99 = time.Duration(percentile(elapsed, 99))
+		stats.Max = time.Duration(group.maxElapsed)
+
+		sort.SliceStable(stats.Errors, func(i, j int) bool {
+			if stats.Errors[i].Count != stats.Errors[j].Count {
+				return stats.Errors[i].Count > stats.Errors[j].Count
+			}
+			return stats.Errors[i].Key < stats.Errors[j].Key
+		})
+
+		res = append(res, stats)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Count != res[j].Count {
+			return res[i].Count > res[j].Count
+		}
+		return res[i].Key < res[j].Key
+	})
+
+	return res
+}
+
+// Aggregate reads all records from reader and returns statistics grouped by field with path.
+// Malformed events are skipped.
+func Aggregate(reader *Reader, groupBy string) ([]*GroupStats, error) {
+	agg := NewAggregator(groupBy)
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return agg.Report(), nil
+		}
+
+		var parseErr *ParseError
+		if errors.As(err, &parseErr) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		agg.Add(record)
+	}
+}
+
+// Returns a copy of statistics without elapsed time of records and error index.
+func (stats *GroupStats) copy() *GroupStats {
+	res := &GroupStats{
+		Key:        stats.Key,
+		Count:      stats.Count,
+		ErrorCount: stats.ErrorCount,
+		ResCodes:   make(map[string]int64, len(stats.ResCodes)),
+		Errors:     make([]*KeyCount, 0, len(stats.Errors)),
+		Timing:     make(map[string]*TimerStats, len(stats.Timing)),
+	}
+
+	for k, v := range stats.ResCodes {
+		res.ResCodes[k] = v
+	}
+
+	for i := range stats.Errors {
+		kc := *stats.Errors[i]
+		res.Errors = append(res.Errors, &kc)
+	}
+
+	for k, v := range stats.Timing {
+		timer := *v
+		res.Timing[k] = &timer
+	}
+
+	return res
+}
+
+// Add elapsed time and update max elapsed time, elapsed time is added into reservoir with algorithm R if random is not nil.
+func (stats *GroupStats) addElapsed(elapsed int64, random *rand.Rand) {
+	if stats.Count < 2 || elapsed > stats.maxElapsed {
+		stats.maxElapsed = elapsed
+	}
+
+	if random == nil || len(stats.elapsed) < aggregateReservoirSize {
+		stats.elapsed = append(stats.elapsed, elapsed)
+		return
+	}
+
+	// replace a random one with probability of size/count, Count includes current record
+	if i := random.Int63n(stats.Count); i < aggregateReservoirSize {
+		stats.elapsed[i] = elapsed
+	}
+}
+
+// Add count of error.
+func (stats *GroupStats) addError(key string, count int64) {
+	if kc, ok := stats.errorIndex[key]; ok {
+		kc.Count += count
+		return
+	}
+
+	kc := &KeyCount{Key: key, Count: count}
+	stats.errorIndex[key] = kc
+	stats.Errors = append(stats.Errors, kc)
+}
+
+// Returns percentile of sorted values with nearest-rank method.
+func percentile(sorted []int64, p float64) int64 {
+	if len(sorted) < 1 {
+		return 0
+	}
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+
+	return sorted[rank-1]
+}
@@ -0,0 +1,249 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Create a record with operation and elapsed time.
+func newAggregateRecord(operation, resCode string, elapsed time.Duration) *Record {
+	record := newRecord()
+	record.Operation = operation
+	record.ResCode = resCode
+	record.ElapsedNano = elapsed.Nanoseconds()
+	return record
+}
+
+func TestAggregator_WithEmptyInput(t *testing.T) {
+	assert.Empty(t, NewAggregator("").Report())
+}
+
+func TestAggregator_Add_WithNilRecord(t *testing.T) {
+	agg := NewAggregator("")
+	agg.Add(nil)
+	assert.Empty(t, agg.Report())
+}
+
+func TestAggregator_AddView_HappyCase(t *testing.T) {
+	agg := NewAggregator("")
+	agg.AddView(nil)
+
+	event := NewEventFactory(WithQuietMode(true)).CreateEvent()
+	event.SetOperation("ut-operation")
+	event.SetResCode("OK")
+	agg.AddView(event.(*eventZap))
+
+	report := agg.Report()
+	assert.Len(t, report, 1)
+	assert.Equal(t, "ut-operation", report[0].Key)
+	assert.Equal(t, int64(1), report[0].ResCodes["OK"])
+}
+
+func TestAggregator_WithConcurrentAdd(t *testing.T) {
+	agg := NewAggregator("")
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				record := newAggregateRecord("ut-operation", "OK", time.Millisecond)
+				record.Errors["ut-err"] = 1
+				agg.Add(record)
+				agg.Report()
+			}
+		}()
+	}
+	wg.Wait()
+
+	report := agg.Report()
+	assert.Equal(t, int64(1000), report[0].Count)
+	assert.Equal(t, int64(1000), report[0].Errors[0].Count)
+}
+
+func TestAggregator_Report_WithCopiedStats(t *testing.T) {
+	agg := NewAggregator("")
+	record := newAggregateRecord("ut-operation", "OK", time.Millisecond)
+	record.Errors["ut-err"] = 1
+	record.Timing["ut-timer.count"] = 1
+	agg.Add(record)
+
+	report := agg.Report()
+	agg.Add(record)
+
+	assert.Equal(t, int64(1), report[0].Count)
+	assert.Equal(t, int64(1), report[0].ResCodes["OK"])
+	assert.Equal(t, int64(1), report[0].Errors[0].Count)
+	assert.Equal(t, int64(1), report[0].Timing["ut-timer"].Count)
+	assert.Equal(t, int64(2), agg.Report()[0].Count)
+}
+
+func TestAggregator_AddView_WithBoundedMemory(t *testing.T) {
+	agg := NewAggregator("")
+
+	for i := 1; i <= 100000; i++ {
+		agg.AddView(newAggregateRecord("ut-operation", "OK", time.Duration(i)*time.Microsecond))
+	}
+
+	assert.Len(t, agg.groups["ut-operation"].elapsed, aggregateReservoirSize)
+
+	report := agg.Report()
+	assert.Equal(t, int64(100000), report[0].Count)
+	assert.Equal(t, 100*time.Millisecond, report[0].Max)
+	// estimated with uniform sample
+	assert.InDelta(t, 50*time.Millisecond, report[0].P50, float64(10*time.Millisecond))
+	assert.InDelta(t, 99*time.Millisecond, report[0].P99, float64(5*time.Millisecond))
+}
+
+func TestAggregator_Add_WithExactPercentiles(t *testing.T) {
+	agg := NewAggregator("")
+
+	for i := 1; i <= 10000; i++ {
+		agg.Add(newAggregateRecord("ut-operation", "OK", time.Duration(i)*time.Microsecond))
+	}
+
+	assert.Len(t, agg.groups["ut-operation"].elapsed, 10000)
+
+	report := agg.Report()
+	assert.Equal(t, 5*time.Millisecond, report[0].P50)
+	assert.Equal(t, 9900*time.Microsecond, report[0].P99)
+	assert.Equal(t, 10*time.Millisecond, report[0].Max)
+}
+
+func TestAggregator_ReportAndReset(t *testing.T) {
+	agg := NewAggregator("")
+	agg.Add(newAggregateRecord("ut-operation", "OK", time.Millisecond))
+
+	report := agg.ReportAndReset()
+	assert.Len(t, report, 1)
+	assert.Empty(t, agg.Report())
+
+	agg.Add(newAggregateRecord("ut-operation", "OK", 2*time.Millisecond))
+	report = agg.Report()
+	assert.Equal(t, int64(1), report[0].Count)
+	assert.Equal(t, 2*time.Millisecond, report[0].Max)
+
+	agg.Reset()
+	assert.Empty(t, agg.Report())
+}
+
+func TestAggregator_Report_HappyCase(t *testing.T) {
+	agg := NewAggregator("")
+
+	for i := 1; i <= 100; i++ {
+		agg.Add(newAggregateRecord("op-a", "OK", time.Duration(i)*time.Millisecond))
+	}
+
+	failed := newAggregateRecord("op-b", "Fail", time.Second)
+	failed.Errors["err-a"] = 2
+	failed.Errors["err-b"] = 1
+	failed.Timing["t1.elapsedMs"] = 10
+	failed.Timing["t1.count"] = 2
+	agg.Add(failed)
+	agg.Add(failed)
+
+	report := agg.Report()
+	assert.Len(t, report, 2)
+
+	a := report[0]
+	assert.Equal(t, "op-a", a.Key)
+	assert.Equal(t, int64(100), a.Count)
+	assert.Equal(t, 50*time.Millisecond, a.P50)
+	assert.Equal(t, 90*time.Millisecond, a.P90)
+	assert.Equal(t, 99*time.Millisecond, a.P99)
+	assert.Equal(t, 100*time.Millisecond, a.Max)
+	assert.Equal(t, int64(100), a.ResCodes["OK"])
+	assert.Zero(t, a.ErrorCount)
+
+	b := report[1]
+	assert.Equal(t, "op-b", b.Key)
+	assert.Equal(t, int64(2), b.Count)
+	assert.Equal(t, int64(2), b.ErrorCount)
+	assert.Equal(t, time.Second, b.Max)
+	assert.Equal(t, int64(2), b.ResCodes["Fail"])
+	assert.Equal(t, []*KeyCount{{Key: "err-a", Count: 4}, {Key: "err-b", Count: 2}}, b.Errors)
+	assert.Equal(t, []*KeyCount{{Key: "err-a", Count: 4}}, b.TopErrors(1))
+	assert.Len(t, b.TopErrors(-1), 2)
+	assert.Equal(t, &TimerStats{Count: 4, ElapsedMs: 20}, b.Timing["t1"])
+}
+
+func TestAggregator_WithPairKey(t *testing.T) {
+	agg := NewAggregator("pairs.tenant")
+
+	record := newAggregateRecord("op", "OK", 0)
+	record.Pairs["tenant"] = "acme"
+	agg.Add(record)
+	agg.Add(newAggregateRecord("op", "OK", 0))
+
+	report := agg.Report()
+	assert.Len(t, report, 2)
+	assert.Equal(t, "", report[0].Key)
+	assert.Equal(t, "acme", report[1].Key)
+}
+
+func TestAggregate_HappyCase(t *testing.T) {
+	buf := &bytes.Buffer{}
+	writeFullEvent(buf, CONSOLE)
+	buf.WriteString(scopeDelimiter + "\nelapsedNano=invalid\n" + eoe + "\n")
+	writeFullEvent(buf, JSON)
+
+	report, err := Aggregate(NewReader(buf), "")
+	assert.Nil(t, err)
+	assert.Len(t, report, 1)
+	assert.Equal(t, "ut-op", report[0].Key)
+	assert.Equal(t, int64(2), report[0].Count)
+	assert.Equal(t, 10*time.Millisecond, report[0].P99)
+	assert.Equal(t, &TimerStats{Count: 2, ElapsedMs: 10}, report[0].Timing["ut-timer"])
+}
+
+func TestAggregate_WithReadError(t *testing.T) {
+	_, err := Aggregate(NewReader(&errReader{}), "")
+	assert.NotNil(t, err)
+}
+
+func TestAggregate_WithWrappedParseError(t *testing.T) {
+	report, err := Aggregate(NewReader(&wrappedParseErrReader{}), "")
+	assert.Nil(t, err)
+	assert.Empty(t, report)
+}
+
+func TestPercentile(t *testing.T) {
+	assert.Zero(t, percentile(nil, 50))
+	assert.Equal(t, int64(1), percentile([]int64{1}, 0))
+	assert.Equal(t, int64(2), percentile([]int64{1, 2, 3, 4}, 50))
+	assert.Equal(t, int64(4), percentile([]int64{1, 2, 3, 4}, 100))
+}
+
+// io.Reader which always fails.
+type errReader struct{}
+
+// Read returns error.
+func (reader *errReader) Read([]byte) (int, error) {
+	return 0, assert.AnError
+}
+
+// Reader which returns wrapped ParseError once.
+type wrappedParseErrReader struct {
+	done bool
+}
+
+// Read returns wrapped ParseError, EOF after.
+func (reader *wrappedParseErrReader) Read([]byte) (int, error) {
+	if reader.done {
+		return 0, io.EOF
+	}
+
+	reader.done = true
+	return 0, fmt.Errorf("ut: %w", &ParseError{Line: 1, Err: assert.AnError})
+}
@@ -0,0 +1,329 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	defaultAsyncQueueSize = 1024
+	defaultAsyncWorkers   = 1
+)
+
+// OverflowPolicy decides what to do if queue of AsyncQueue is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock blocks Event.Finish() until queue has space, it is the default policy.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropNewest drops event being finished.
+	OverflowDropNewest
+	// OverflowDropOldest drops the oldest event in queue.
+	OverflowDropOldest
+)
+
+// AsyncOption will be passed into NewAsyncQueue to override default behavior of queue.
+type AsyncOption func(*AsyncQueue)
+
+// WithAsyncQueueSize overrides max number of events in queue, default is 1024.
+func WithAsyncQueueSize(size int) AsyncOption {
+	return func(queue *AsyncQueue) {
+		if size > 0 {
+			queue.size = size
+		}
+	}
+}
+
+// WithAsyncWorkers overrides number of background workers, default is 1.
+// Events may be written out of order with multiple workers.
+func WithAsyncWorkers(workers int) AsyncOption {
+	return func(queue *AsyncQueue) {
+		if workers > 0 {
+			queue.workers = workers
+		}
+	}
+}
+
+// WithAsyncOverflow overrides policy if queue is full, default is OverflowBlock.
+func WithAsyncOverflow(policy OverflowPolicy) AsyncOption {
+	return func(queue *AsyncQueue) {
+		queue.policy = policy
+	}
+}
+
+// AsyncQueue encodes and writes events with background workers, see WithAsync().
+//
+// Call Close() on shutdown in order to drain queue. AsyncQueue is thread safe.
+type AsyncQueue struct {
+	// number of events dropped, kept as first field for 64 bits alignment of atomic operations
+	dropped int64
+	jobs    chan *asyncJob
+	size    int
+	workers int
+	policy  OverflowPolicy
+	// lock of jobs channel, held for writing while closing
+	lock   sync.RWMutex
+	closed bool
+	wg     sync.WaitGroup
+	// sequence of events in queue or being written in order of adding
+	pending     []uint64
+	pendingLock sync.Mutex
+	// events written out of order, removed once events added before are written
+	done    map[uint64]bool
+	nextSeq uint64
+	waiters []*flushWaiter
+}
+
+// Flush() waiting for events with sequence less than seq.
+type flushWaiter struct {
+	seq  uint64
+	done chan struct{}
+}
+
+// NewAsyncQueue creates a new queue and starts background workers.
+func NewAsyncQueue(opts ...AsyncOption) *AsyncQueue {
+	queue := &AsyncQueue{
+		size:    defaultAsyncQueueSize,
+		workers: defaultAsyncWorkers,
+		done:    make(map[uint64]bool),
+	}
+
+	for i := range opts {
+		opts[i](queue)
+	}
+
+	queue.jobs = make(chan *asyncJob, queue.size)
+
+	queue.wg.Add(queue.workers)
+	for i := 0; i < queue.workers; i++ {
+		go queue.work()
+	}
+
+	return queue
+}
+
+// WithAsync writes events with AsyncQueue in Event.Finish() instead of the calling goroutine.
+//
+// Event is copied in Event.Finish() and encoded, written into logger and exported by background workers
+// of queue. Sampling, WithSlowOrErrorOnly(), redaction, aggregation and child rollup are still done in
+// Event.Finish(). Pooled event is released after it was copied, so that it is safe to use with
+// WithReleaseOnFinish(). Events are written synchronously after queue was closed.
+func WithAsync(queue *AsyncQueue) EventOption {
+	return func(event Event) {
+		switch v := event.(type) {
+		case *eventZap:
+			v.async = queue
+		case *eventThreadSafe:
+			v.delegate.async = queue
+		}
+	}
+}
+
+// Dropped returns number of events dropped since queue was full.
+func (queue *AsyncQueue) Dropped() int64 {
+	return atomic.LoadInt64(&queue.dropped)
+}
+
+// Len returns number of events in queue.
+func (queue *AsyncQueue) Len() int {
+	return len(queue.jobs)
+}
+
+// Flush waits until events added before are written, ctx.Err() would be returned if ctx is done before.
+// Events added after Flush() was called are not waited.
+func (queue *AsyncQueue) Flush(ctx context.Context) error {
+	queue.pendingLock.Lock()
+	if queue.oldestPending() >= queue.nextSeq {
+		queue.pendingLock.Unlock()
+		return nil
+	}
+	waiter := &flushWaiter{seq: queue.nextSeq, done: make(chan struct{})}
+	queue.waiters = append(queue.waiters, waiter)
+	queue.pendingLock.Unlock()
+
+	select {
+	case <-waiter.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting events and waits until events in queue are written.
+// Events finished after are written synchronously.
+func (queue *AsyncQueue) Close() error {
+	queue.lock.Lock()
+	if !queue.closed {
+		queue.closed = true
+		close(queue.jobs)
+	}
+	queue.lock.Unlock()
+
+	queue.wg.Wait()
+	return nil
+}
+
+// Add copy of job into queue, false would be returned if queue was closed.
+func (queue *AsyncQueue) enqueue(job asyncJob) bool {
+	// event may be modified or released after, so queued job works on a copy
+	job.view = snapshotOf(job.view)
+	return queue.add(&job)
+}
+
+// Add job into queue, false would be returned if queue was closed.
+func (queue *AsyncQueue) add(job *asyncJob) bool {
+	queue.lock.RLock()
+	defer queue.lock.RUnlock()
+
+	if queue.closed {
+		return false
+	}
+
+	queue.addPending(job)
+
+	switch queue.policy {
+	case OverflowDropNewest:
+		select {
+		case queue.jobs <- job:
+		default:
+			queue.drop(job)
+		}
+	case OverflowDropOldest:
+		for {
+			select {
+			case queue.jobs <- job:
+				return true
+			default:
+			}
+
+			// workers may take the oldest one at the same time
+			select {
+			case oldest := <-queue.jobs:
+				queue.drop(oldest)
+			default:
+			}
+		}
+	default:
+		queue.jobs <- job
+	}
+
+	return true
+}
+
+// Write jobs until queue was closed.
+func (queue *AsyncQueue) work() {
+	defer queue.wg.Done()
+
+	for job := range queue.jobs {
+		job.run()
+		queue.donePending(job)
+	}
+}
+
+// Count dropped job.
+func (queue *AsyncQueue) drop(job *asyncJob) {
+	atomic.AddInt64(&queue.dropped, 1)
+	queue.donePending(job)
+}
+
+// Assign sequence to job and add it into pending jobs.
+func (queue *AsyncQueue) addPending(job *asyncJob) {
+	queue.pendingLock.Lock()
+	defer queue.pendingLock.Unlock()
+
+	job.seq = queue.nextSeq
+	queue.nextSeq++
+	queue.pending = append(queue.pending, job.seq)
+}
+
+// Remove job from pending jobs, and notify Flush() if jobs added before it was called are done.
+func (queue *AsyncQueue) donePending(job *asyncJob) {
+	queue.pendingLock.Lock()
+	defer queue.pendingLock.Unlock()
+
+	// jobs may be done out of order with multiple workers
+	queue.done[job.seq] = true
+	for len(queue.pending) > 0 && queue.done[queue.pending[0]] {
+		delete(queue.done, queue.pending[0])
+		queue.pending = queue.pending[1:]
+	}
+
+	oldest := queue.oldestPending()
+	waiters := queue.waiters[:0]
+	for _, waiter := range queue.waiters {
+		if waiter.seq <= oldest {
+			close(waiter.done)
+		} else {
+			waiters = append(waiters, waiter)
+		}
+	}
+	queue.waiters = waiters
+}
+
+// Returns sequence of the oldest pending job, or sequence of next job if there is no pending job.
+func (queue *AsyncQueue) oldestPending() uint64 {
+	if len(queue.pending) > 0 {
+		return queue.pending[0]
+	}
+
+	return queue.nextSeq
+}
+
+// Output of finished event.
+type asyncJob struct {
+	// sequence in queue
+	seq      uint64
+	view     EventView
+	encoder  Encoder
+	logger   *zap.Logger
+	sink     Sink
+	exporter *OtlpFileExporter
+}
+
+// Encode and write event into logger or sink, and exporter.
+func (job *asyncJob) run() {
+	if job.encoder != nil {
+		msg, fields := job.encoder.Encode(job.view)
+		job.logger.Info(msg, fields...)
+	}
+
+	// errors are kept by sink, see Sink.Write()
+	if job.sink != nil {
+		job.sink.Write(job.view)
+	}
+
+	// errors will be returned by Flush() and Close() of exporter
+	if job.exporter != nil {
+		job.exporter.Export(job.view)
+	}
+}
+
+// Copy of finished event which could be written after event was modified or released.
+type eventSnapshot struct {
+	*Record
+	payloads []zap.Field
+	sections *renderedSections
+}
+
+// Returns copy of view, payloads are copied as zap.Field in order to keep their order and types.
+func snapshotOf(view EventView) EventView {
+	record := recordWithoutPayloadsOf(view)
+
+	return &eventSnapshot{
+		Record:   record,
+		payloads: append([]zap.Field{}, view.ListPayloads()...),
+		sections: renderedSectionsOf(view),
+	}
+}
+
+// ListPayloads returns payloads of event.
+func (snapshot *eventSnapshot) ListPayloads() []zap.Field {
+	return snapshot.payloads
+}
@@ -0,0 +1,278 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Encoder which blocks until unblocked, writes operation only.
+type blockingEncoder struct {
+	started chan string
+	unblock chan struct{}
+}
+
+func newBlockingEncoder() *blockingEncoder {
+	return &blockingEncoder{
+		started: make(chan string, 100),
+		unblock: make(chan struct{}),
+	}
+}
+
+func (enc *blockingEncoder) Encode(view EventView) (string, []zap.Field) {
+	enc.started <- view.GetOperation()
+	<-enc.unblock
+	return view.GetOperation(), nil
+}
+
+// Finish event with operation.
+func finishAsyncEvent(fac *EventFactory, operation string) {
+	event := fac.CreateEvent()
+	event.SetOperation(operation)
+	event.Finish()
+}
+
+func TestWithAsync_HappyCase(t *testing.T) {
+	for _, ec := range []Encoding{CONSOLE, JSON} {
+		syncBuf, asyncBuf := &bytes.Buffer{}, &bytes.Buffer{}
+		queue := NewAsyncQueue()
+
+		for _, buf := range []*bytes.Buffer{syncBuf, asyncBuf} {
+			opts := []EventOption{WithZapLogger(newBufferLogger(buf, ec == JSON)), WithEncoding(ec)}
+			if buf == asyncBuf {
+				opts = append(opts, WithAsync(queue))
+			}
+			event := NewEventFactory(opts...).CreateEvent()
+			event.SetEventId("ut-event")
+			event.SetStartTime(time.Unix(0, 0))
+			event.SetEndTime(time.Unix(1, 0))
+			event.AddPayloads(zap.String("b", "ut"), zap.Int("a", 1), zap.Duration("c", time.Second))
+			event.AddPair("ut-key", "ut-value")
+			event.SetCounter("ut-counter", 1)
+			event.StartTimer("ut-timer")
+			event.EndTimer("ut-timer")
+			event.Finish()
+		}
+
+		assert.Nil(t, queue.Flush(context.Background()), ec.String())
+		// output is the same as synchronous one
+		assert.Equal(t, syncBuf.String(), asyncBuf.String(), ec.String())
+		assert.Nil(t, queue.Close())
+	}
+}
+
+func TestWithAsync_WithModifiedAndReleasedEvent(t *testing.T) {
+	buf := &bytes.Buffer{}
+	enc := newBlockingEncoder()
+	queue := NewAsyncQueue()
+
+	fac := NewEventFactory(
+		WithZapLogger(newBufferLogger(buf, false)),
+		WithEncoder(EncoderFunc(func(view EventView) (string, []zap.Field) {
+			enc.Encode(view)
+			return view.ListPairs()["ut-key"], view.ListPayloads()
+		})),
+		WithAsync(queue),
+		WithReleaseOnFinish(true))
+
+	event := fac.CreateEventPooled()
+	event.SetOperation("ut-operation")
+	event.AddPair("ut-key", "ut-value")
+	event.AddPayloads(zap.String("ut-payload", "ut-value"))
+	event.Finish()
+
+	// event was released and reused while being written
+	assert.Equal(t, "ut-operation", <-enc.started)
+	reused := fac.CreateEventPooled()
+	reused.AddPair("ut-key", "ut-reused")
+	close(enc.unblock)
+
+	assert.Nil(t, queue.Close())
+	assert.Contains(t, buf.String(), "ut-value")
+	assert.NotContains(t, buf.String(), "ut-reused")
+}
+
+func TestAsyncQueue_WithOverflowDropNewest(t *testing.T) {
+	buf := &bytes.Buffer{}
+	enc := newBlockingEncoder()
+	queue := NewAsyncQueue(WithAsyncQueueSize(1), WithAsyncOverflow(OverflowDropNewest))
+	fac := NewEventFactory(WithZapLogger(newBufferLogger(buf, false)), WithEncoder(enc), WithAsync(queue))
+
+	finishAsyncEvent(fac, "ut-first")
+	<-enc.started
+	finishAsyncEvent(fac, "ut-second")
+	finishAsyncEvent(fac, "ut-third")
+
+	assert.Equal(t, int64(1), queue.Dropped())
+	assert.Equal(t, 1, queue.Len())
+	close(enc.unblock)
+	assert.Nil(t, queue.Close())
+
+	assert.Contains(t, buf.String(), "ut-first")
+	assert.Contains(t, buf.String(), "ut-second")
+	assert.NotContains(t, buf.String(), "ut-third")
+}
+
+func TestAsyncQueue_WithOverflowDropOldest(t *testing.T) {
+	buf := &bytes.Buffer{}
+	enc := newBlockingEncoder()
+	queue := NewAsyncQueue(WithAsyncQueueSize(1), WithAsyncOverflow(OverflowDropOldest))
+	fac := NewEventFactory(WithZapLogger(newBufferLogger(buf, false)), WithEncoder(enc), WithAsync(queue))
+
+	finishAsyncEvent(fac, "ut-first")
+	<-enc.started
+	finishAsyncEvent(fac, "ut-second")
+	finishAsyncEvent(fac, "ut-third")
+
+	assert.Equal(t, int64(1), queue.Dropped())
+	close(enc.unblock)
+	assert.Nil(t, queue.Close())
+
+	assert.Contains(t, buf.String(), "ut-first")
+	assert.NotContains(t, buf.String(), "ut-second")
+	assert.Contains(t, buf.String(), "ut-third")
+}
+
+func TestAsyncQueue_WithOverflowBlock(t *testing.T) {
+	buf := &bytes.Buffer{}
+	enc := newBlockingEncoder()
+	queue := NewAsyncQueue(WithAsyncQueueSize(1))
+	fac := NewEventFactory(WithZapLogger(newBufferLogger(buf, false)), WithEncoder(enc), WithAsync(queue))
+
+	finishAsyncEvent(fac, "ut-first")
+	<-enc.started
+	finishAsyncEvent(fac, "ut-second")
+
+	finished := make(chan struct{})
+	go func() {
+		finishAsyncEvent(fac, "ut-third")
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		assert.Fail(t, "Finish() should be blocked")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(enc.unblock)
+	<-finished
+	assert.Nil(t, queue.Close())
+
+	assert.Equal(t, int64(0), queue.Dropped())
+	assert.Contains(t, buf.String(), "ut-third")
+}
+
+func TestAsyncQueue_Flush_WithTimeout(t *testing.T) {
+	enc := newBlockingEncoder()
+	queue := NewAsyncQueue()
+	fac := NewEventFactory(WithZapLogger(newBufferLogger(&bytes.Buffer{}, false)), WithEncoder(enc), WithAsync(queue))
+
+	// nothing to flush
+	assert.Nil(t, queue.Flush(context.Background()))
+
+	finishAsyncEvent(fac, "ut-first")
+	<-enc.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, queue.Flush(ctx))
+
+	close(enc.unblock)
+	assert.Nil(t, queue.Flush(context.Background()))
+	assert.Nil(t, queue.Close())
+}
+
+func TestAsyncQueue_Flush_WithConcurrentProducers(t *testing.T) {
+	written := int64(0)
+	queue := NewAsyncQueue(WithAsyncQueueSize(4), WithAsyncWorkers(2))
+	fac := NewEventFactory(
+		WithZapLogger(newBufferLogger(&bytes.Buffer{}, false)),
+		WithEncoder(EncoderFunc(func(view EventView) (string, []zap.Field) {
+			time.Sleep(time.Millisecond)
+			if view.GetOperation() == "ut-before" {
+				atomic.AddInt64(&written, 1)
+			}
+			return view.GetOperation(), nil
+		})),
+		WithAsync(queue))
+
+	for i := 0; i < 10; i++ {
+		finishAsyncEvent(fac, "ut-before")
+	}
+
+	// producers keep queue busy while flushing
+	stop := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					finishAsyncEvent(fac, "ut-after")
+				}
+			}
+		}()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.Nil(t, queue.Flush(ctx))
+	assert.Equal(t, int64(10), atomic.LoadInt64(&written))
+
+	close(stop)
+	wg.Wait()
+	assert.Nil(t, queue.Close())
+}
+
+func TestAsyncQueue_Close_WithFinishAfter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	queue := NewAsyncQueue(WithAsyncWorkers(4))
+	fac := NewEventFactory(WithZapLogger(newBufferLogger(buf, false)), WithAsync(queue))
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			finishAsyncEvent(fac, "ut-before")
+		}()
+	}
+	wg.Wait()
+
+	assert.Nil(t, queue.Close())
+	assert.Nil(t, queue.Close())
+
+	// written synchronously
+	finishAsyncEvent(fac, "ut-after")
+
+	records, err := Parse(buf)
+	assert.Nil(t, err)
+	assert.Len(t, records, 11)
+	assert.Equal(t, "ut-after", records[10].Operation)
+}
+
+func TestWithAsync_WithThreadSafeAndChild(t *testing.T) {
+	queue := NewAsyncQueue()
+	defer queue.Close()
+
+	event := NewEventFactory().CreateEventThreadSafe(WithAsync(queue))
+	assert.Equal(t, queue, event.(*eventThreadSafe).delegate.async)
+
+	child := NewEventFactory(WithAsync(queue)).CreateEvent().StartChild("ut-child")
+	assert.Equal(t, queue, child.(*eventZap).async)
+}
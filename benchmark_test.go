@@ -0,0 +1,143 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"errors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"io"
+	"testing"
+	"time"
+)
+
+// Create a factory which writes events with encoding into io.Discard.
+func newBenchmarkFactory(ec Encoding) *EventFactory {
+	config := zapcore.EncoderConfig{MessageKey: "msg", EncodeTime: zapcore.ISO8601TimeEncoder}
+	encoder := zapcore.NewConsoleEncoder(config)
+	if ec == JSON || ec == ECS {
+		encoder = zapcore.NewJSONEncoder(config)
+	}
+
+	logger := zap.New(zapcore.NewCore(encoder, zapcore.AddSync(io.Discard), zap.InfoLevel))
+	return NewEventFactory(
+		WithZapLogger(logger),
+		WithEncoding(ec),
+		WithServiceName("bench-service"),
+		WithServiceVersion("v0.0.1"),
+		WithEntryName("bench-entry"),
+		WithEntryKind("bench-kind"))
+}
+
+// Fill and finish an event like a typical gRPC interceptor does.
+func writeBenchmarkEvent(event Event, err error) {
+	event.SetStartTime(time.Now())
+	event.SetOperation("/v1/greeter")
+	event.SetRemoteAddr("10.0.0.1:1949")
+	event.SetTraceId("0af7651916cd43dd8448eb211c80319c")
+	event.AddPayloads(
+		zap.String("apiPath", "/v1/greeter"),
+		zap.String("apiMethod", "GET"),
+		zap.String("apiProtocol", "HTTP/1.1"),
+		zap.Int("resSize", 1024))
+	event.AddPair("tenant", "acme")
+	event.SetCounter("retries", 1)
+	event.StartTimer("db")
+	event.EndTimer("db")
+	event.AddErr(err)
+	event.SetResCode("200")
+	event.SetEndTime(time.Now())
+	event.Finish()
+}
+
+func benchmarkEncoding(b *testing.B, ec Encoding) {
+	fac := newBenchmarkFactory(ec)
+	err := errors.New("bench-err")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		writeBenchmarkEvent(fac.CreateEvent(), err)
+	}
+}
+
+func benchmarkPooledEncoding(b *testing.B, ec Encoding) {
+	fac := newBenchmarkFactory(ec)
+	err := errors.New("bench-err")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		event := fac.CreateEventPooled()
+		writeBenchmarkEvent(event, err)
+		event.Release()
+	}
+}
+
+func BenchmarkEvent_Finish_Console(b *testing.B) {
+	benchmarkEncoding(b, CONSOLE)
+}
+
+func BenchmarkEvent_Finish_Json(b *testing.B) {
+	benchmarkEncoding(b, JSON)
+}
+
+func BenchmarkEvent_Finish_Flatten(b *testing.B) {
+	benchmarkEncoding(b, FLATTEN)
+}
+
+func BenchmarkEvent_Finish_Logfmt(b *testing.B) {
+	benchmarkEncoding(b, LOGFMT)
+}
+
+func BenchmarkEvent_Finish_Ecs(b *testing.B) {
+	benchmarkEncoding(b, ECS)
+}
+
+func BenchmarkEvent_Finish_Otlp(b *testing.B) {
+	benchmarkEncoding(b, OTLP)
+}
+
+func BenchmarkEvent_Finish_Ncsa(b *testing.B) {
+	benchmarkEncoding(b, NCSA)
+}
+
+func BenchmarkEvent_Finish_Template(b *testing.B) {
+	benchmarkEncoding(b, TEMPLATE)
+}
+
+func BenchmarkEvent_Finish_ConsolePooled(b *testing.B) {
+	benchmarkPooledEncoding(b, CONSOLE)
+}
+
+func BenchmarkEvent_Finish_JsonPooled(b *testing.B) {
+	benchmarkPooledEncoding(b, JSON)
+}
+
+func BenchmarkEncoder_Encode_Console(b *testing.B) {
+	benchmarkEncoder(b, CONSOLE)
+}
+
+func BenchmarkEncoder_Encode_Json(b *testing.B) {
+	benchmarkEncoder(b, JSON)
+}
+
+// Benchmark Encode() only without creating event.
+func benchmarkEncoder(b *testing.B, ec Encoding) {
+	event := newBenchmarkFactory(ec).CreateEvent().(*eventZap)
+	event.SetOperation("/v1/greeter")
+	event.AddPayloads(zap.String("apiPath", "/v1/greeter"), zap.Int("resSize", 1024))
+	event.AddPair("tenant", "acme")
+	event.AddErr(errors.New("bench-err"))
+	event.SetEndTime(time.Now())
+	encoder := encoderOf(ec)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		encoder.Encode(event)
+	}
+}
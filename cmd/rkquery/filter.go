@@ -0,0 +1,138 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/rookie-ninja/rk-query/v2"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Flag which could be repeated or separated by comma.
+type stringList []string
+
+// String returns joined values.
+func (list *stringList) String() string {
+	return strings.Join(*list, ",")
+}
+
+// Set appends values separated by comma.
+func (list *stringList) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); len(v) > 0 {
+			*list = append(*list, v)
+		}
+	}
+
+	return nil
+}
+
+// Returns true if list is empty or value is in the list.
+func (list stringList) matches(value string) bool {
+	if len(list) < 1 {
+		return true
+	}
+
+	for i := range list {
+		if list[i] == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Filter of records, values of the same flag are OR-ed and different flags are AND-ed.
+type filter struct {
+	operations stringList
+	resCodes   stringList
+	ids        stringList
+	eventIds   stringList
+	traceIds   stringList
+	requestIds stringList
+	minElapsed time.Duration
+	hasError   string
+	errorFlag  *bool
+}
+
+// Register flags of filter.
+func (f *filter) register(fs *flag.FlagSet) {
+	fs.Var(&f.operations, "operation", "operation glob pattern, e.g. /v1/*")
+	fs.Var(&f.resCodes, "res-code", "response code")
+	fs.Var(&f.ids, "id", "any of event id, trace id or request id")
+	fs.Var(&f.eventIds, "event-id", "event id")
+	fs.Var(&f.traceIds, "trace-id", "trace id")
+	fs.Var(&f.requestIds, "request-id", "request id")
+	fs.DurationVar(&f.minElapsed, "min-elapsed", 0, "minimum elapsed time, e.g. 100ms")
+	fs.StringVar(&f.hasError, "has-error", "", "true for events with error only, false for events without error")
+}
+
+// Validate flags of filter.
+func (f *filter) validate() error {
+	for i := range f.operations {
+		if _, err := path.Match(f.operations[i], ""); err != nil {
+			return fmt.Errorf("invalid operation pattern %q: %v", f.operations[i], err)
+		}
+	}
+
+	if len(f.hasError) > 0 {
+		val, err := strconv.ParseBool(f.hasError)
+		if err != nil {
+			return fmt.Errorf("invalid has-error %q: %v", f.hasError, err)
+		}
+		f.errorFlag = &val
+	}
+
+	return nil
+}
+
+// Is record matches all conditions?
+func (f *filter) match(record *rkquery.Record) bool {
+	if !f.matchOperation(record.Operation) {
+		return false
+	}
+
+	if !f.resCodes.matches(record.ResCode) ||
+		!f.eventIds.matches(record.EventId) ||
+		!f.traceIds.matches(record.TraceId) ||
+		!f.requestIds.matches(record.RequestId) {
+		return false
+	}
+
+	if len(f.ids) > 0 &&
+		!f.ids.matches(record.EventId) && !f.ids.matches(record.TraceId) && !f.ids.matches(record.RequestId) {
+		return false
+	}
+
+	if record.Elapsed() < f.minElapsed {
+		return false
+	}
+
+	if f.errorFlag != nil && *f.errorFlag != record.HasError() {
+		return false
+	}
+
+	return true
+}
+
+// Is operation matches any of patterns?
+func (f *filter) matchOperation(operation string) bool {
+	if len(f.operations) < 1 {
+		return true
+	}
+
+	for i := range f.operations {
+		if ok, _ := path.Match(f.operations[i], operation); ok {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+// Create a filter with flags.
+func newTestFilter(t *testing.T, args ...string) *filter {
+	fs := flag.NewFlagSet("ut", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+
+	f := &filter{}
+	f.register(fs)
+	assert.Nil(t, fs.Parse(args))
+	assert.Nil(t, f.validate())
+
+	return f
+}
+
+func TestStringList_Set(t *testing.T) {
+	list := stringList{}
+	assert.Nil(t, list.Set("a, b,,"))
+	assert.Nil(t, list.Set("c"))
+	assert.Equal(t, stringList{"a", "b", "c"}, list)
+	assert.Equal(t, "a,b,c", list.String())
+}
+
+func TestFilter_Match_WithEmptyFilter(t *testing.T) {
+	assert.True(t, newTestFilter(t).match(newTestRecord("/v1/a", "OK", 0)))
+}
+
+func TestFilter_Match_WithOperation(t *testing.T) {
+	f := newTestFilter(t, "-operation", "/v1/*")
+	assert.True(t, f.match(newTestRecord("/v1/a", "OK", 0)))
+	assert.False(t, f.match(newTestRecord("/v2/a", "OK", 0)))
+}
+
+func TestFilter_Match_WithResCode(t *testing.T) {
+	f := newTestFilter(t, "-res-code", "500,503")
+	assert.True(t, f.match(newTestRecord("/v1/a", "503", 0)))
+	assert.False(t, f.match(newTestRecord("/v1/a", "200", 0)))
+}
+
+func TestFilter_Match_WithIds(t *testing.T) {
+	record := newTestRecord("/v1/a", "OK", 0)
+	record.TraceId = "ut-trace"
+	record.RequestId = "ut-request"
+
+	assert.True(t, newTestFilter(t, "-id", "ut-request").match(record))
+	assert.True(t, newTestFilter(t, "-id", "ut-trace").match(record))
+	assert.False(t, newTestFilter(t, "-id", "unknown").match(record))
+	assert.True(t, newTestFilter(t, "-trace-id", "ut-trace").match(record))
+	assert.False(t, newTestFilter(t, "-request-id", "ut-trace").match(record))
+	assert.True(t, newTestFilter(t, "-event-id", record.EventId).match(record))
+}
+
+func TestFilter_Match_WithMinElapsed(t *testing.T) {
+	f := newTestFilter(t, "-min-elapsed", "100ms")
+	assert.True(t, f.match(newTestRecord("/v1/a", "OK", time.Second)))
+	assert.False(t, f.match(newTestRecord("/v1/a", "OK", time.Millisecond)))
+}
+
+func TestFilter_Match_WithHasError(t *testing.T) {
+	record := newTestRecord("/v1/a", "OK", 0)
+
+	assert.False(t, newTestFilter(t, "-has-error", "true").match(record))
+	assert.True(t, newTestFilter(t, "-has-error", "false").match(record))
+
+	record.Errors["ut-err"] = 1
+	assert.True(t, newTestFilter(t, "-has-error", "true").match(record))
+	assert.False(t, newTestFilter(t, "-has-error", "false").match(record))
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/rookie-ninja/rk-query/v2"
+)
+
+const defaultIndexPath = "rkquery.idx"
+
+// Index events in files by eventId, traceId and requestId.
+func runIndex(ctx context.Context, args []string, env *cmdEnv) error {
+	fs := flag.NewFlagSet("index", flag.ContinueOnError)
+	fs.SetOutput(env.stderr)
+
+	path := fs.String("index", defaultIndexPath, "path of index file, it will be created if missing")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() < 1 {
+		return fmt.Errorf("missing files to index")
+	}
+
+	index, err := rkquery.OpenIndex(*path)
+	if err != nil {
+		return err
+	}
+
+	return index.Update(fs.Args()...)
+}
+
+// Print events whose eventId, traceId or requestId equals to ids.
+func runLookup(ctx context.Context, args []string, env *cmdEnv) error {
+	fs := flag.NewFlagSet("lookup", flag.ContinueOnError)
+	fs.SetOutput(env.stderr)
+
+	path := fs.String("index", defaultIndexPath, "path of index file built by index command")
+	update := fs.Bool("update", false, "update index with indexed files before lookup")
+	o := &output{}
+	o.register(fs)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() < 1 {
+		return fmt.Errorf("missing id")
+	}
+
+	ec, opts, err := o.parse(env.stdout)
+	if err != nil {
+		return err
+	}
+
+	index, err := rkquery.OpenIndex(*path)
+	if err != nil {
+		return err
+	}
+
+	if *update {
+		if err := index.Update(index.Files()...); err != nil {
+			return err
+		}
+	}
+
+	out := newPrinter(env.stdout, ec, opts...)
+	found := false
+	for _, id := range fs.Args() {
+		records, err := index.Lookup(id)
+		if err != nil {
+			return err
+		}
+
+		for i := range records {
+			out.print(records[i])
+		}
+		found = found || len(records) > 0
+	}
+
+	if !found {
+		return fmt.Errorf("no event found")
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"github.com/rookie-ninja/rk-query/v2"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRun_IndexAndLookup(t *testing.T) {
+	record := newTestRecord("/v1/a", "OK", time.Millisecond)
+	record.RequestId = "ut-request"
+	path := writeRecords(t, rkquery.JSON, newTestRecord("/v1/b", "OK", time.Millisecond), record)
+	indexPath := filepath.Join(t.TempDir(), "query.idx")
+
+	code, _, stderr := runCommand([]string{"index", "-index", indexPath, path}, "")
+	assert.Equal(t, 0, code, stderr)
+
+	code, stdout, _ := runCommand([]string{"lookup", "-index", indexPath, "-encoding", "json", "ut-request"}, "")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "/v1/a")
+	assert.NotContains(t, stdout, "/v1/b")
+
+	code, stdout, _ = runCommand([]string{"lookup", "-index", indexPath, "-update", "/v1/b-event"}, "")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "/v1/b")
+
+	code, _, stderr = runCommand([]string{"lookup", "-index", indexPath, "missing"}, "")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "no event found")
+}
+
+func TestRun_IndexAndLookup_WithInvalidArgs(t *testing.T) {
+	code, _, _ := runCommand([]string{"index"}, "")
+	assert.Equal(t, 1, code)
+
+	code, _, _ = runCommand([]string{"index", "-index", filepath.Join(t.TempDir(), "query.idx"), "missing.log"}, "")
+	assert.Equal(t, 1, code)
+
+	code, _, _ = runCommand([]string{"lookup"}, "")
+	assert.Equal(t, 1, code)
+
+	code, _, _ = runCommand([]string{"lookup", "-encoding", "unknown", "id"}, "")
+	assert.Equal(t, 1, code)
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+// Command rkquery reads query logs written by rk-query in any encoding.
+//
+// Usage:
+//
+//	rkquery <command> [flags] [file ...]
+//	rkquery query [flags] <query> [file ...]
+//
+// Files could be plain text or gzip compressed, stdin would be used if no file or - was provided.
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+)
+
+// Standard streams used by commands, replaced in unit test.
+type cmdEnv struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// Sub command of rkquery.
+type command struct {
+	usage string
+	run   func(ctx context.Context, args []string, env *cmdEnv) error
+}
+
+var commands = map[string]*command{
+	"index": {
+		usage: "index events in files by eventId, traceId and requestId for lookup",
+		run:   runIndex,
+	},
+	"lookup": {
+		usage: "print events with eventId, traceId or requestId from index",
+		run:   runLookup,
+	},
+	"query": {
+		usage: "run SQL like query over events, e.g. rkquery query 'SELECT operation, count() GROUP BY operation'",
+		run:   runQuery,
+	},
+	"stats": {
+		usage: "print per group latency percentiles, resCode distribution, top errors and timer totals",
+		run:   runStats,
+	},
+	"tail": {
+		usage: "print events matching filters, follow files with -f",
+		run:   runTail,
+	},
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	os.Exit(run(ctx, os.Args[1:], &cmdEnv{
+		stdin:  os.Stdin,
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+	}))
+}
+
+// Run command and returns exit code.
+func run(ctx context.Context, args []string, env *cmdEnv) int {
+	if len(args) < 1 || args[0] == "-h" || args[0] == "help" {
+		printUsage(env.stderr)
+		return 2
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(env.stderr, "rkquery: unknown command %q\n", args[0])
+		printUsage(env.stderr)
+		return 2
+	}
+
+	if err := cmd.run(ctx, args[1:], env); err != nil {
+		fmt.Fprintf(env.stderr, "rkquery %s: %v\n", args[0], err)
+		return 1
+	}
+
+	return 0
+}
+
+// Print usage of all commands.
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: rkquery <command> [flags] [file ...]")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Commands:")
+
+	names := make([]string, 0, len(commands))
+	for k := range commands {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-8s %s\n", name, commands[name].usage)
+	}
+
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Run 'rkquery <command> -h' for flags of command.")
+}
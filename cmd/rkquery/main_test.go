@@ -0,0 +1,154 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"github.com/rookie-ninja/rk-query/v2"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Write records into a temp file with encoding and returns path of file.
+func writeRecords(t *testing.T, ec rkquery.Encoding, records ...*rkquery.Record) string {
+	buf := &bytes.Buffer{}
+	out := newPrinter(buf, ec)
+	for i := range records {
+		out.print(records[i])
+	}
+
+	path := filepath.Join(t.TempDir(), "query.log")
+	assert.Nil(t, ioutil.WriteFile(path, buf.Bytes(), 0644))
+
+	return path
+}
+
+// Create a new record for unit test.
+func newTestRecord(operation, resCode string, elapsed time.Duration) *rkquery.Record {
+	end := time.Now()
+	return &rkquery.Record{
+		StartTime:   end.Add(-elapsed),
+		EndTime:     end,
+		ElapsedNano: elapsed.Nanoseconds(),
+		EventId:     operation + "-event",
+		Operation:   operation,
+		ResCode:     resCode,
+		Errors:      map[string]int64{},
+		EventStatus: "Ended",
+	}
+}
+
+// Run command and returns stdout and stderr.
+func runCommand(args []string, stdin string) (int, string, string) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	code := run(context.Background(), args, &cmdEnv{
+		stdin:  strings.NewReader(stdin),
+		stdout: stdout,
+		stderr: stderr,
+	})
+
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun_WithoutCommand(t *testing.T) {
+	code, _, stderr := runCommand(nil, "")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "Usage")
+}
+
+func TestRun_WithUnknownCommand(t *testing.T) {
+	code, _, stderr := runCommand([]string{"unknown"}, "")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "unknown command")
+}
+
+func TestRun_Tail(t *testing.T) {
+	path := writeRecords(t, rkquery.CONSOLE,
+		newTestRecord("/v1/a", "OK", time.Millisecond),
+		newTestRecord("/v1/b", "Fail", time.Second))
+
+	code, stdout, _ := runCommand([]string{"tail", "-res-code", "Fail", "-encoding", "json", path}, "")
+	assert.Equal(t, 0, code)
+
+	records, err := rkquery.Parse(strings.NewReader(stdout))
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, "/v1/b", records[0].Operation)
+	assert.Equal(t, rkquery.JSON, records[0].Encoding)
+}
+
+func TestRun_Tail_WithStdin(t *testing.T) {
+	buf := &bytes.Buffer{}
+	newPrinter(buf, rkquery.FLATTEN).print(newTestRecord("/v1/a", "OK", time.Millisecond))
+
+	code, stdout, _ := runCommand([]string{"tail", "-encoding", "flatten"}, buf.String())
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "/v1/a")
+}
+
+func TestRun_Tail_WithInvalidFlags(t *testing.T) {
+	code, _, _ := runCommand([]string{"tail", "-encoding", "unknown"}, "")
+	assert.Equal(t, 1, code)
+
+	code, _, _ = runCommand([]string{"tail", "-has-error", "unknown"}, "")
+	assert.Equal(t, 1, code)
+
+	code, _, _ = runCommand([]string{"tail", "-operation", "["}, "")
+	assert.Equal(t, 1, code)
+
+	code, _, _ = runCommand([]string{"tail", "non-exist-file"}, "")
+	assert.Equal(t, 1, code)
+}
+
+func TestRun_Tail_WithTemplate(t *testing.T) {
+	path := writeRecords(t, rkquery.CONSOLE, newTestRecord("/v1/a", "OK", time.Millisecond))
+
+	code, stdout, _ := runCommand([]string{"tail", "-template", `{{.Operation}} {{.ResCode}} {{.ElapsedMs}}ms`, path}, "")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "/v1/a OK 1ms\n", stdout)
+
+	code, _, stderr := runCommand([]string{"tail", "-template", "{{.Operation", path}, "")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "invalid template")
+}
+
+func TestRun_Tail_WithPretty(t *testing.T) {
+	path := writeRecords(t, rkquery.CONSOLE, newTestRecord("/v1/a", "OK", time.Millisecond))
+
+	code, stdout, _ := runCommand([]string{"tail", "-pretty", "-encoding", "json", path}, "")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "operation   = /v1/a")
+	// stdout is not a terminal
+	assert.NotContains(t, stdout, "\x1b[")
+}
+
+func TestRun_Tail_WithColumns(t *testing.T) {
+	path := writeRecords(t, rkquery.CONSOLE,
+		newTestRecord("/v1/a", "OK", time.Millisecond),
+		newTestRecord("/v1/long/operation", "Fail", time.Second))
+
+	code, stdout, _ := runCommand([]string{"tail", "-columns", "operation:8,resCode", "-header", "10", path}, "")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "opera...    resCode\n/v1/a       OK\n/v1/l...    Fail\n", stdout)
+
+	code, _, stderr := runCommand([]string{"tail", "-columns", "operation:x", path}, "")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "invalid width")
+}
+
+func TestRun_Tail_WithMalformedEvent(t *testing.T) {
+	input := "------------------------------------------------------------------------\nelapsedNano=x\nEOE\n"
+
+	code, stdout, stderr := runCommand([]string{"tail"}, input)
+	assert.Equal(t, 0, code)
+	assert.Empty(t, stdout)
+	assert.Contains(t, stderr, "elapsedNano")
+}
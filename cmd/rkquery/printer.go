@@ -0,0 +1,119 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/rookie-ninja/rk-query/v2"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Prints records with encoding, it is thread safe.
+type printer struct {
+	factory *rkquery.EventFactory
+	lock    sync.Mutex
+}
+
+// Output flags of printer.
+type output struct {
+	encoding string
+	template string
+	pretty   bool
+	columns  stringList
+	header   int
+}
+
+// Register flags of output.
+func (o *output) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.encoding, "encoding", "console",
+		"output encoding, one of console, json, flatten, logfmt, ecs, otlp, template and ncsa")
+	fs.StringVar(&o.template, "template", "", "text/template of output, implies -encoding template")
+	fs.BoolVar(&o.pretty, "pretty", false, "colorized console output for terminals, implies -encoding console")
+	fs.Var(&o.columns, "columns", "columns of flatten output like operation,pairs.tenant:10, "+
+		"values are truncated to width if provided, implies -encoding flatten")
+	fs.IntVar(&o.header, "header", 0, "write header of flatten output every n events")
+}
+
+// Returns encoding and options of printer which writes to w.
+//
+// Pretty CONSOLE encoding is used if pretty is true, colors are enabled if w is a terminal.
+// TEMPLATE encoding is used if template is not empty and FLATTEN encoding is used if columns are provided.
+func (o *output) parse(w io.Writer) (rkquery.Encoding, []rkquery.EventOption, error) {
+	switch {
+	case o.pretty:
+		return rkquery.CONSOLE, []rkquery.EventOption{
+			rkquery.WithPrettyConsole(rkquery.WithPrettyWriter(w))}, nil
+	case len(o.template) > 0:
+		encoder, err := rkquery.NewTemplateEncoder(o.template)
+		if err != nil {
+			return rkquery.TEMPLATE, nil, fmt.Errorf("invalid template: %v", err)
+		}
+		return rkquery.TEMPLATE, []rkquery.EventOption{rkquery.WithEncoder(encoder)}, nil
+	case len(o.columns) > 0 || o.header > 0:
+		columns, err := parseColumns(o.columns)
+		if err != nil {
+			return rkquery.FLATTEN, nil, err
+		}
+		return rkquery.FLATTEN, []rkquery.EventOption{
+			rkquery.WithFlatten(rkquery.WithFlattenColumns(columns...), rkquery.WithFlattenHeader(o.header))}, nil
+	}
+
+	ec, err := parseEncoding(o.encoding)
+	return ec, nil, err
+}
+
+// Parse columns like path or path:width.
+func parseColumns(list stringList) ([]rkquery.FlattenColumn, error) {
+	res := make([]rkquery.FlattenColumn, 0, len(list))
+	for _, str := range list {
+		col := rkquery.FlattenColumn{Path: str}
+		if index := strings.LastIndex(str, ":"); index > 0 {
+			width, err := strconv.Atoi(str[index+1:])
+			if err != nil || width < 1 {
+				return nil, fmt.Errorf("invalid width of column %q", str)
+			}
+			col = rkquery.FlattenColumn{Path: str[:index], Width: width, Truncate: true}
+		}
+		res = append(res, col)
+	}
+
+	return res, nil
+}
+
+// Create a new printer which writes records to w.
+func newPrinter(w io.Writer, ec rkquery.Encoding, opts ...rkquery.EventOption) *printer {
+	config := zapcore.EncoderConfig{
+		MessageKey:     "msg",
+		EncodeTime:     zapcore.ISO8601TimeEncoder,
+		EncodeDuration: zapcore.StringDurationEncoder,
+	}
+
+	encoder := zapcore.NewConsoleEncoder(config)
+	if ec == rkquery.JSON || ec == rkquery.ECS {
+		encoder = zapcore.NewJSONEncoder(config)
+	}
+
+	logger := zap.New(zapcore.NewCore(encoder, zapcore.AddSync(w), zap.InfoLevel))
+
+	return &printer{
+		factory: rkquery.NewEventFactory(append([]rkquery.EventOption{
+			rkquery.WithZapLogger(logger), rkquery.WithEncoding(ec)}, opts...)...),
+	}
+}
+
+// Print record.
+func (p *printer) print(record *rkquery.Record) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.factory.CreateEventFromRecord(record).Finish()
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/rookie-ninja/rk-query/v2"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+const formatCsv = "csv"
+
+// Run query over events and print result.
+func runQuery(ctx context.Context, args []string, env *cmdEnv) error {
+	fs := flag.NewFlagSet("query", flag.ContinueOnError)
+	fs.SetOutput(env.stderr)
+
+	format := fs.String("format", formatTable, "output format, one of table, json and csv")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *format != formatTable && *format != formatJson && *format != formatCsv {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+
+	if fs.NArg() < 1 {
+		return fmt.Errorf("missing query")
+	}
+
+	query, err := rkquery.ParseQuery(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	executor := query.NewExecutor()
+
+	names := fs.Args()[1:]
+	if len(names) < 1 {
+		names = []string{stdinName}
+	}
+
+	for _, name := range names {
+		if err := scanSource(ctx, name, false, false, env, executor.Add); err != nil {
+			return err
+		}
+	}
+
+	res := executor.Result()
+
+	switch *format {
+	case formatJson:
+		return printQueryJson(env.stdout, res)
+	case formatCsv:
+		return printQueryCsv(env.stdout, res)
+	default:
+		printQueryTable(env.stdout, res)
+		return nil
+	}
+}
+
+// Print result as table, NULL is printed as -.
+func printQueryTable(w io.Writer, res *rkquery.QueryResult) {
+	writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	header := make([]string, len(res.Columns))
+	for i := range res.Columns {
+		header[i] = strings.ToUpper(res.Columns[i])
+	}
+	fmt.Fprintln(writer, strings.Join(header, "\t"))
+
+	for i, row := range res.StringRows() {
+		for j := range row {
+			if res.Rows[i][j] == nil {
+				row[j] = emptyCell
+			}
+		}
+		fmt.Fprintln(writer, strings.Join(row, "\t"))
+	}
+
+	writer.Flush()
+}
+
+// Print result as JSON array of objects keyed by column name.
+func printQueryJson(w io.Writer, res *rkquery.QueryResult) error {
+	rows := make([]map[string]interface{}, 0, len(res.Rows))
+	for _, row := range res.Rows {
+		obj := make(map[string]interface{}, len(res.Columns))
+		for i := range res.Columns {
+			obj[res.Columns[i]] = row[i]
+		}
+		rows = append(rows, obj)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(rows)
+}
+
+// Print result as CSV with header.
+func printQueryCsv(w io.Writer, res *rkquery.QueryResult) error {
+	writer := csv.NewWriter(w)
+
+	if err := writer.Write(res.Columns); err != nil {
+		return err
+	}
+
+	if err := writer.WriteAll(res.StringRows()); err != nil {
+		return err
+	}
+
+	return writer.Error()
+}
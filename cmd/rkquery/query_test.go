@@ -0,0 +1,64 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"github.com/rookie-ninja/rk-query/v2"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// Write records used by query tests.
+func writeQueryRecords(t *testing.T) string {
+	failed := newTestRecord("/v1/b", "Fail", time.Second)
+	failed.Pairs = map[string]string{"tenant": "acme"}
+
+	return writeRecords(t, rkquery.JSON,
+		newTestRecord("/v1/a", "OK", time.Millisecond),
+		newTestRecord("/v1/a", "OK", 2*time.Millisecond),
+		failed)
+}
+
+func TestRun_Query_WithTable(t *testing.T) {
+	code, stdout, _ := runCommand([]string{"query",
+		"SELECT operation, count(), max(pairs.tenant) GROUP BY operation ORDER BY 2 DESC", writeQueryRecords(t)}, "")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "OPERATION")
+	assert.Contains(t, stdout, "COUNT()")
+	assert.Regexp(t, `/v1/a\s+2\s+-`, stdout)
+	assert.Regexp(t, `/v1/b\s+1\s+acme`, stdout)
+}
+
+func TestRun_Query_WithJson(t *testing.T) {
+	code, stdout, _ := runCommand([]string{"query", "-format", "json",
+		`SELECT operation, elapsedMs AS ms WHERE resCode = "Fail"`, writeQueryRecords(t)}, "")
+	assert.Equal(t, 0, code)
+
+	rows := make([]map[string]interface{}, 0)
+	assert.Nil(t, json.Unmarshal([]byte(stdout), &rows))
+	assert.Equal(t, []map[string]interface{}{{"operation": "/v1/b", "ms": float64(1000)}}, rows)
+}
+
+func TestRun_Query_WithCsv(t *testing.T) {
+	code, stdout, _ := runCommand([]string{"query", "-format", "csv",
+		`SELECT operation, count() GROUP BY operation ORDER BY operation`, writeQueryRecords(t)}, "")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "operation,count()\n/v1/a,2\n/v1/b,1\n", stdout)
+}
+
+func TestRun_Query_WithInvalidArgs(t *testing.T) {
+	code, _, _ := runCommand([]string{"query"}, "")
+	assert.Equal(t, 1, code)
+
+	code, _, stderr := runCommand([]string{"query", "SELECT"}, "")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "rkquery query")
+
+	code, _, _ = runCommand([]string{"query", "-format", "unknown", "SELECT *"}, "")
+	assert.Equal(t, 1, code)
+}
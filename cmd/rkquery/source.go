@@ -0,0 +1,146 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	stdinName      = "-"
+	followInterval = 200 * time.Millisecond
+)
+
+// Open file with name, stdin would be used if name is -.
+//
+// Compressed files rotated by lumberjack could be read directly, but could not be followed.
+func openSource(ctx context.Context, name string, follow, fromStart bool, stdin io.Reader) (io.ReadCloser, error) {
+	if name == stdinName {
+		return ioutil.NopCloser(stdin), nil
+	}
+
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasSuffix(name, ".gz") {
+		reader, err := gzip.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+
+		return &gzipSource{Reader: reader, file: file}, nil
+	}
+
+	if !follow {
+		return file, nil
+	}
+
+	if !fromStart {
+		if _, err := file.Seek(0, io.SeekEnd); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	return &followReader{
+		ctx:      ctx,
+		name:     name,
+		file:     file,
+		interval: followInterval,
+	}, nil
+}
+
+// Closes both of gzip reader and file.
+type gzipSource struct {
+	*gzip.Reader
+	file *os.File
+}
+
+// Close gzip reader and underlying file.
+func (src *gzipSource) Close() error {
+	src.Reader.Close()
+	return src.file.Close()
+}
+
+// followReader keeps reading appended data from file until context is done.
+//
+// File will be reopened if it was rotated and will be read from beginning if it was truncated.
+type followReader struct {
+	ctx      context.Context
+	name     string
+	file     *os.File
+	offset   int64
+	interval time.Duration
+}
+
+// Read blocks until data is available or context is done.
+// io.EOF would be returned only after context is done.
+func (reader *followReader) Read(p []byte) (int, error) {
+	for {
+		n, err := reader.file.Read(p)
+		if n > 0 {
+			reader.offset += int64(n)
+			return n, nil
+		}
+
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+
+		reader.checkRotation()
+
+		select {
+		case <-reader.ctx.Done():
+			return 0, io.EOF
+		case <-time.After(reader.interval):
+		}
+	}
+}
+
+// Reopen file if it was rotated, rewind if it was truncated.
+func (reader *followReader) checkRotation() {
+	current, err := reader.file.Stat()
+	if err != nil {
+		return
+	}
+
+	latest, err := os.Stat(reader.name)
+	if err != nil {
+		return
+	}
+
+	if !os.SameFile(current, latest) {
+		file, err := os.Open(reader.name)
+		if err != nil {
+			return
+		}
+
+		reader.file.Close()
+		reader.file = file
+		reader.offset = 0
+		return
+	}
+
+	if latest.Size() < reader.offset {
+		if _, err := reader.file.Seek(0, io.SeekStart); err == nil {
+			reader.offset = 0
+		}
+	}
+}
+
+// Close underlying file.
+func (reader *followReader) Close() error {
+	return reader.file.Close()
+}
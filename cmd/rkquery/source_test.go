@@ -0,0 +1,102 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOpenSource_WithStdin(t *testing.T) {
+	src, err := openSource(context.Background(), stdinName, false, false, strings.NewReader("ut"))
+	assert.Nil(t, err)
+
+	bytes, _ := ioutil.ReadAll(src)
+	assert.Equal(t, "ut", string(bytes))
+	assert.Nil(t, src.Close())
+}
+
+func TestOpenSource_WithGzip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "query.log.gz")
+	file, _ := os.Create(path)
+	writer := gzip.NewWriter(file)
+	writer.Write([]byte("ut"))
+	writer.Close()
+	file.Close()
+
+	src, err := openSource(context.Background(), path, true, false, nil)
+	assert.Nil(t, err)
+
+	bytes, _ := ioutil.ReadAll(src)
+	assert.Equal(t, "ut", string(bytes))
+	assert.Nil(t, src.Close())
+}
+
+func TestOpenSource_WithInvalidGzip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "query.log.gz")
+	ioutil.WriteFile(path, []byte("not gzip"), 0644)
+
+	_, err := openSource(context.Background(), path, false, false, nil)
+	assert.NotNil(t, err)
+}
+
+func TestFollowReader_HappyCase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "query.log")
+	ioutil.WriteFile(path, []byte("old\n"), 0644)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	src, err := openSource(ctx, path, true, false, nil)
+	assert.Nil(t, err)
+	defer src.Close()
+	src.(*followReader).interval = time.Millisecond
+
+	// append after opening
+	file, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	file.WriteString("new\n")
+	file.Close()
+
+	buf := make([]byte, 16)
+	n, err := src.Read(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, "new\n", string(buf[:n]))
+
+	cancel()
+	_, err = src.Read(buf)
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestFollowReader_WithTruncateAndRotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "query.log")
+	ioutil.WriteFile(path, []byte("first\n"), 0644)
+
+	src, err := openSource(context.Background(), path, true, true, nil)
+	assert.Nil(t, err)
+	defer src.Close()
+	src.(*followReader).interval = time.Millisecond
+
+	buf := make([]byte, 16)
+	n, _ := src.Read(buf)
+	assert.Equal(t, "first\n", string(buf[:n]))
+
+	// truncate
+	ioutil.WriteFile(path, []byte("sec\n"), 0644)
+	n, _ = src.Read(buf)
+	assert.Equal(t, "sec\n", string(buf[:n]))
+
+	// rotate
+	assert.Nil(t, os.Rename(path, path+".1"))
+	ioutil.WriteFile(path, []byte("third\n"), 0644)
+	n, _ = src.Read(buf)
+	assert.Equal(t, "third\n", string(buf[:n]))
+}
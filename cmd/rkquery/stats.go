@@ -0,0 +1,199 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/rookie-ninja/rk-query/v2"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	formatTable = "table"
+	formatJson  = "json"
+	emptyCell   = "-"
+)
+
+var (
+	// Line breaks and tabs in keys would break rows and columns of table.
+	cellReplacer = strings.NewReplacer("\n", " ", "\r", " ", "\t", " ")
+)
+
+// Print per group latency percentiles, resCode distribution, top errors and timer totals.
+func runStats(ctx context.Context, args []string, env *cmdEnv) error {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	fs.SetOutput(env.stderr)
+
+	groupBy := fs.String("by", "operation", "field to group by, e.g. operation, resCode, pairs.tenant, payloads.apiPath")
+	top := fs.Int("top", 3, "number of top errors of each group")
+	format := fs.String("format", formatTable, "output format, one of table and json")
+	f := &filter{}
+	f.register(fs)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if err := f.validate(); err != nil {
+		return err
+	}
+
+	if *format != formatTable && *format != formatJson {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+
+	agg := rkquery.NewAggregator(*groupBy)
+	handler := func(record *rkquery.Record) {
+		if f.match(record) {
+			agg.Add(record)
+		}
+	}
+
+	names := fs.Args()
+	if len(names) < 1 {
+		names = []string{stdinName}
+	}
+
+	for _, name := range names {
+		if err := scanSource(ctx, name, false, false, env, handler); err != nil {
+			return err
+		}
+	}
+
+	report := agg.Report()
+	for i := range report {
+		report[i].Errors = report[i].TopErrors(*top)
+	}
+
+	if *format == formatJson {
+		encoder := json.NewEncoder(env.stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+
+	printStatsTable(env.stdout, *groupBy, report)
+	return nil
+}
+
+// Print report as tables of groups and timers.
+func printStatsTable(w io.Writer, groupBy string, report []*rkquery.GroupStats) {
+	writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(writer, "%s\tCOUNT\tERRORS\tP50\tP90\tP99\tMAX\tRES CODES\tTOP ERRORS\n", strings.ToUpper(groupBy))
+	for _, stats := range report {
+		errs := make([]string, 0, len(stats.Errors))
+		for _, e := range stats.Errors {
+			errs = append(errs, fmt.Sprintf("%s:%d", keyCell(e.Key), e.Count))
+		}
+
+		fmt.Fprintf(writer, "%s\t%d\t%d\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			keyCell(stats.Key),
+			stats.Count,
+			stats.ErrorCount,
+			durationCell(stats.P50),
+			durationCell(stats.P90),
+			durationCell(stats.P99),
+			durationCell(stats.Max),
+			resCodesCell(stats.ResCodes),
+			listCell(errs))
+	}
+	writer.Flush()
+
+	// timer totals
+	rows := make([]string, 0)
+	for _, stats := range report {
+		names := make([]string, 0, len(stats.Timing))
+		for k := range stats.Timing {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			timer := stats.Timing[name]
+			avg := time.Duration(0)
+			if timer.Count > 0 {
+				avg = time.Duration(timer.ElapsedMs) * time.Millisecond / time.Duration(timer.Count)
+			}
+
+			rows = append(rows, fmt.Sprintf("%s\t%s\t%d\t%s\t%s\n",
+				keyCell(stats.Key),
+				keyCell(name),
+				timer.Count,
+				durationCell(time.Duration(timer.ElapsedMs)*time.Millisecond),
+				durationCell(avg)))
+		}
+	}
+
+	if len(rows) < 1 {
+		return
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintf(writer, "%s\tTIMER\tCOUNT\tTOTAL\tAVG\n", strings.ToUpper(groupBy))
+	for i := range rows {
+		fmt.Fprint(writer, rows[i])
+	}
+	writer.Flush()
+}
+
+// Returns key in one line without tabs, placeholder is returned for empty key.
+func keyCell(key string) string {
+	if len(key) < 1 {
+		return emptyCell
+	}
+
+	return cellReplacer.Replace(key)
+}
+
+// Round duration for human reading.
+func durationCell(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond).String()
+	default:
+		return d.String()
+	}
+}
+
+// Join resCodes sorted by count in descending order.
+func resCodesCell(resCodes map[string]int64) string {
+	codes := make([]string, 0, len(resCodes))
+	for k := range resCodes {
+		codes = append(codes, k)
+	}
+
+	sort.Slice(codes, func(i, j int) bool {
+		if resCodes[codes[i]] != resCodes[codes[j]] {
+			return resCodes[codes[i]] > resCodes[codes[j]]
+		}
+		return codes[i] < codes[j]
+	})
+
+	res := make([]string, 0, len(codes))
+	for _, code := range codes {
+		res = append(res, fmt.Sprintf("%s:%d", keyCell(code), resCodes[code]))
+	}
+
+	return listCell(res)
+}
+
+// Join values with comma.
+func listCell(values []string) string {
+	if len(values) < 1 {
+		return emptyCell
+	}
+
+	return strings.Join(values, ",")
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"github.com/rookie-ninja/rk-query/v2"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun_Stats_WithTable(t *testing.T) {
+	failed := newTestRecord("/v1/b", "Fail", time.Second)
+	failed.Errors["ut-err"] = 1
+	failed.Timing = map[string]int64{"db.elapsedMs": 10, "db.count": 2}
+
+	path := writeRecords(t, rkquery.JSON,
+		newTestRecord("/v1/a", "OK", time.Millisecond),
+		newTestRecord("/v1/a", "OK", 2*time.Millisecond),
+		failed)
+
+	code, stdout, _ := runCommand([]string{"stats", path}, "")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "OPERATION")
+	assert.Contains(t, stdout, "/v1/a")
+	assert.Contains(t, stdout, "OK:2")
+	assert.Contains(t, stdout, "ut-err:1")
+	assert.Contains(t, stdout, "TIMER")
+	assert.Contains(t, stdout, "db")
+}
+
+func TestRun_Stats_WithJson(t *testing.T) {
+	record := newTestRecord("/v1/a", "OK", time.Millisecond)
+	record.Pairs = map[string]string{"tenant": "acme"}
+	path := writeRecords(t, rkquery.CONSOLE, record, newTestRecord("/v1/b", "OK", time.Millisecond))
+
+	code, stdout, _ := runCommand([]string{"stats", "-by", "pairs.tenant", "-format", "json", path}, "")
+	assert.Equal(t, 0, code)
+
+	report := make([]*rkquery.GroupStats, 0)
+	assert.Nil(t, json.Unmarshal([]byte(stdout), &report))
+	assert.Len(t, report, 2)
+	assert.Equal(t, "", report[0].Key)
+	assert.Equal(t, "acme", report[1].Key)
+	assert.Equal(t, time.Millisecond, report[1].Max)
+}
+
+func TestRun_Stats_WithInvalidFlags(t *testing.T) {
+	code, _, _ := runCommand([]string{"stats", "-format", "unknown"}, "")
+	assert.Equal(t, 1, code)
+
+	code, _, _ = runCommand([]string{"stats", "-has-error", "unknown"}, "")
+	assert.Equal(t, 1, code)
+}
+
+func TestRun_Stats_WithLineBreakInErrors(t *testing.T) {
+	failed := newTestRecord("/v1/b", "Fail", time.Second)
+	failed.Errors["ut-err\nline2\twith tab"] = 1
+	path := writeRecords(t, rkquery.JSON, failed)
+
+	code, stdout, _ := runCommand([]string{"stats", path}, "")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "ut-err line2 with tab:1")
+	// header and one row
+	assert.Len(t, strings.Split(strings.TrimSpace(stdout), "\n"), 2)
+}
+
+func TestKeyCell(t *testing.T) {
+	assert.Equal(t, emptyCell, keyCell(""))
+	assert.Equal(t, "a  b c", keyCell("a\r\nb\tc"))
+}
+
+func TestDurationCell(t *testing.T) {
+	assert.Equal(t, "1.235s", durationCell(1234567890*time.Nanosecond))
+	assert.Equal(t, "1.23ms", durationCell(1234567*time.Nanosecond))
+	assert.Equal(t, "1.234µs", durationCell(1234*time.Nanosecond))
+}
+
+func TestResCodesCell(t *testing.T) {
+	assert.Equal(t, "-", resCodesCell(map[string]int64{}))
+	assert.Equal(t, "OK:2,-:1,Fail:1", resCodesCell(map[string]int64{"OK": 2, "Fail": 1, "": 1}))
+}
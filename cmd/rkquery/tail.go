@@ -0,0 +1,125 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/rookie-ninja/rk-query/v2"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Print records matching filter.
+func runTail(ctx context.Context, args []string, env *cmdEnv) error {
+	fs := flag.NewFlagSet("tail", flag.ContinueOnError)
+	fs.SetOutput(env.stderr)
+
+	follow := fs.Bool("f", false, "follow events appended to files, reading starts from the end of files")
+	fromStart := fs.Bool("from-start", false, "read files from the beginning while following")
+	o := &output{}
+	o.register(fs)
+	f := &filter{}
+	f.register(fs)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if err := f.validate(); err != nil {
+		return err
+	}
+
+	ec, opts, err := o.parse(env.stdout)
+	if err != nil {
+		return err
+	}
+
+	out := newPrinter(env.stdout, ec, opts...)
+	handler := func(record *rkquery.Record) {
+		if f.match(record) {
+			out.print(record)
+		}
+	}
+
+	names := fs.Args()
+	if len(names) < 1 {
+		names = []string{stdinName}
+	}
+
+	// read files one by one in order to keep order of events
+	if !*follow {
+		for _, name := range names {
+			if err := scanSource(ctx, name, false, false, env, handler); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	wg := sync.WaitGroup{}
+	errs := make([]error, len(names))
+	for i := range names {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = scanSource(ctx, names[i], true, *fromStart, env, handler)
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range errs {
+		if errs[i] != nil {
+			return errs[i]
+		}
+	}
+
+	return nil
+}
+
+// Read all records from source and pass them to handler.
+// Malformed events will be reported to stderr.
+func scanSource(ctx context.Context, name string, follow, fromStart bool, env *cmdEnv, handler func(*rkquery.Record)) error {
+	src, err := openSource(ctx, name, follow, fromStart, env.stdin)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	reader := rkquery.NewReader(src)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+
+		var parseErr *rkquery.ParseError
+		if errors.As(err, &parseErr) {
+			fmt.Fprintf(env.stderr, "%s: %v\n", name, err)
+			continue
+		}
+
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+
+		handler(record)
+	}
+}
+
+// Parse encoding name strictly, unlike rkquery.ToEncoding().
+func parseEncoding(name string) (rkquery.Encoding, error) {
+	ec := rkquery.ToEncoding(name)
+	if ec.String() != strings.ToLower(name) {
+		return ec, fmt.Errorf("unknown encoding %q", name)
+	}
+
+	return ec, nil
+}
@@ -14,6 +14,7 @@ const (
 	startTimeKey = "startTime"
 	endTimeKey   = "endTime"
 	elapsedKey   = "elapsedNano"
+	elapsedMsKey = "elapsedMs"
 	timezoneKey  = "timezone"
 	// ************* App *************
 	serviceKey        = "service"
@@ -33,16 +34,24 @@ const (
 	eventIdKey   = "eventId"
 	traceIdKey   = "traceId"
 	requestIdKey = "requestId"
+	// ************* Children *************
+	parentEventIdKey = "parentEventId"
+	childrenKey      = "children"
 	// ************* Payloads *************
 	payloadsKey = "payloads"
 	// ************* Counters *************
-	countersKey = "counters"
+	countersKey   = "counters"
+	redactionsKey = "redactions"
 	// ************* Pairs *************
-	pairsKey       = "pairs"
-	resCodeKey     = "resCode"
-	operationKey   = "operation"
-	remoteAddrKey  = "remoteAddr"
-	eventStatusKey = "eventStatus"
-	timingKey      = "timing"
-	errKey         = "error"
+	pairsKey        = "pairs"
+	resCodeKey      = "resCode"
+	operationKey    = "operation"
+	remoteAddrKey   = "remoteAddr"
+	eventStatusKey  = "eventStatus"
+	timingKey       = "timing"
+	timingElapsedMs = "elapsedMs"
+	timingCount     = "count"
+	errKey          = "error"
+	sampleRateKey   = "sampleRate"
+	sampleReasonKey = "sampleReason"
 )
@@ -0,0 +1,93 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"context"
+	"go.uber.org/zap"
+)
+
+// Key of Event in context.Context, unexported in order to avoid collisions.
+type eventContextKey struct{}
+
+// Returned by FromContext() if there is no Event in context.
+var noopEvent Event = &eventNoop{}
+
+// NewContext returns a copy of ctx which carries event, event could be retrieved with FromContext().
+//
+// Event may be accessed by multiple goroutines through context, use EventFactory.CreateEventThreadSafe()
+// if so.
+func NewContext(ctx context.Context, event Event) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if event == nil {
+		return ctx
+	}
+
+	return context.WithValue(ctx, eventContextKey{}, event)
+}
+
+// FromContext returns Event carried by ctx, a noop event would be returned if missing.
+func FromContext(ctx context.Context) Event {
+	if ctx == nil {
+		return noopEvent
+	}
+
+	if event, ok := ctx.Value(eventContextKey{}).(Event); ok {
+		return event
+	}
+
+	return noopEvent
+}
+
+// StartChild starts a child event of Event in ctx, returns a copy of ctx which carries child event.
+// See Event.StartChild() for details.
+func StartChild(ctx context.Context, operation string) (context.Context, Event) {
+	child := FromContext(ctx).StartChild(operation)
+	return NewContext(ctx, child), child
+}
+
+// StartTimer starts timer of Event in ctx.
+func StartTimer(ctx context.Context, name string) {
+	FromContext(ctx).StartTimer(name)
+}
+
+// EndTimer ends timer of Event in ctx.
+func EndTimer(ctx context.Context, name string) {
+	FromContext(ctx).EndTimer(name)
+}
+
+// UpdateTimerMs updates timer of Event in ctx with time elapsed in milli seconds.
+func UpdateTimerMs(ctx context.Context, name string, elapsedMs int64) {
+	FromContext(ctx).UpdateTimerMs(name, elapsedMs)
+}
+
+// SetCounter sets counter of Event in ctx.
+func SetCounter(ctx context.Context, key string, value int64) {
+	FromContext(ctx).SetCounter(key, value)
+}
+
+// IncCounter increases counter of Event in ctx.
+func IncCounter(ctx context.Context, key string, delta int64) {
+	FromContext(ctx).IncCounter(key, delta)
+}
+
+// AddPair adds value with key in pairs of Event in ctx.
+func AddPair(ctx context.Context, key, value string) {
+	FromContext(ctx).AddPair(key, value)
+}
+
+// AddPayloads adds payloads into Event in ctx.
+func AddPayloads(ctx context.Context, fields ...zap.Field) {
+	FromContext(ctx).AddPayloads(fields...)
+}
+
+// AddErr adds error into Event in ctx.
+func AddErr(ctx context.Context, err error) {
+	FromContext(ctx).AddErr(err)
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"testing"
+	"time"
+)
+
+func TestNewContext_HappyCase(t *testing.T) {
+	event := NewEventFactory().CreateEvent(WithQuietMode(true))
+	ctx := NewContext(context.Background(), event)
+
+	assert.Equal(t, event, FromContext(ctx))
+}
+
+func TestNewContext_WithNilEvent(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, ctx, NewContext(ctx, nil))
+}
+
+func TestNewContext_WithNilContext(t *testing.T) {
+	event := NewEventFactory().CreateEvent(WithQuietMode(true))
+	var nilCtx context.Context
+	ctx := NewContext(nilCtx, event)
+
+	assert.Equal(t, event, FromContext(ctx))
+}
+
+func TestFromContext_WithMissingEvent(t *testing.T) {
+	assert.IsType(t, &eventNoop{}, FromContext(context.Background()))
+	var nilCtx context.Context
+	assert.IsType(t, &eventNoop{}, FromContext(nilCtx))
+
+	// helpers should not panic without event
+	assert.NotPanics(t, func() {
+		ctx := context.Background()
+		StartTimer(ctx, "ut-timer")
+		EndTimer(ctx, "ut-timer")
+		IncCounter(ctx, "ut-counter", 1)
+		AddPair(ctx, "ut-key", "ut-value")
+		StartChild(ctx, "ut-child")
+	})
+}
+
+func TestContextHelpers_HappyCase(t *testing.T) {
+	event := NewEventFactory().CreateEvent(WithQuietMode(true))
+	event.SetStartTime(time.Now())
+	ctx := NewContext(context.Background(), event)
+
+	StartTimer(ctx, "ut-timer")
+	EndTimer(ctx, "ut-timer")
+	UpdateTimerMs(ctx, "ut-timer", 5)
+	SetCounter(ctx, "ut-counter", 1)
+	IncCounter(ctx, "ut-counter", 2)
+	AddPair(ctx, "ut-key", "ut-value")
+	AddPayloads(ctx, zap.String("ut-payload", "ut-value"))
+	AddErr(ctx, errors.New("ut-err"))
+
+	assert.True(t, event.GetTimeElapsedMs("ut-timer") >= 5)
+	assert.Equal(t, int64(3), event.GetCounter("ut-counter"))
+	assert.Equal(t, "ut-value", event.GetValueFromPair("ut-key"))
+	assert.Len(t, event.ListPayloads(), 1)
+	assert.Equal(t, int64(1), event.GetErrCount(errors.New("ut-err")))
+}
+
+func TestStartChild_WithContext(t *testing.T) {
+	event := NewEventFactory().CreateEvent(WithQuietMode(true))
+	ctx, child := StartChild(NewContext(context.Background(), event), "ut-child")
+
+	assert.Equal(t, child, FromContext(ctx))
+	assert.Equal(t, event.GetEventId(), child.GetParentEventId())
+	assert.Equal(t, "ut-child", child.GetOperation())
+}
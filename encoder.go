@@ -0,0 +1,621 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventView is a read-only view of event passed to Encoder.
+//
+// Maps and slices returned by EventView should not be modified.
+type EventView interface {
+	// ************* Time *************
+
+	// GetStartTime returns start time of event.
+	GetStartTime() time.Time
+
+	// GetEndTime returns end time of event.
+	GetEndTime() time.Time
+
+	// GetTimeZone returns time zone of event.
+	GetTimeZone() string
+
+	// ************* Identity *************
+
+	// GetEventId returns event id of event.
+	GetEventId() string
+
+	// GetTraceId returns trace id of event.
+	GetTraceId() string
+
+	// GetRequestId returns request id of event.
+	GetRequestId() string
+
+	// GetParentEventId returns event id of parent event, empty if event is not a child event.
+	GetParentEventId() string
+
+	// ************* Service *************
+
+	// GetServiceName returns service name of event.
+	GetServiceName() string
+
+	// GetServiceVersion returns service version of event.
+	GetServiceVersion() string
+
+	// GetEntryName returns entry name of event.
+	GetEntryName() string
+
+	// GetEntryKind returns entry kind of event.
+	GetEntryKind() string
+
+	// ************* Sections *************
+
+	// ListEnv returns env of machine which event was created on.
+	ListEnv() map[string]string
+
+	// ListPayloads returns payloads of event.
+	ListPayloads() []zap.Field
+
+	// ListErrors returns errors and count of each error.
+	ListErrors() map[string]int64
+
+	// ListCounters returns counters of event.
+	ListCounters() map[string]int64
+
+	// ListPairs returns pairs of event.
+	ListPairs() map[string]string
+
+	// ListTimings returns timers flattened into keys like name.elapsedMs and name.count.
+	ListTimings() map[string]int64
+
+	// ListChildren returns child events rolled up into event, see WithChildRollup().
+	ListChildren() []EventView
+
+	// ************* Event *************
+
+	// GetOperation returns operation of event.
+	GetOperation() string
+
+	// GetRemoteAddr returns remote address of event.
+	GetRemoteAddr() string
+
+	// GetResCode returns response code of event.
+	GetResCode() string
+
+	// GetEventStatus returns event status of event.
+	GetEventStatus() eventStatus
+}
+
+// Encoder encodes event into message and fields which will be written to zap logger by Event.Finish().
+type Encoder interface {
+	// Encode returns message and fields of event.
+	Encode(EventView) (string, []zap.Field)
+}
+
+// EncoderFunc is an adapter to allow the use of ordinary functions as Encoder.
+type EncoderFunc func(EventView) (string, []zap.Field)
+
+// Encode calls f(view).
+func (f EncoderFunc) Encode(view EventView) (string, []zap.Field) {
+	return f(view)
+}
+
+// Registered encoders, index of encoder is the value of Encoding.
+var encoders = &encoderRegistry{
+	names: []string{"console", "json", "flatten", "logfmt", "ecs", "otlp", "template", "ncsa"},
+	encoders: []Encoder{
+		EncoderFunc(encodeConsole),
+		EncoderFunc(encodeJson),
+		EncoderFunc(encodeFlatten),
+		EncoderFunc(encodeLogfmt),
+		EncoderFunc(encodeEcs),
+		EncoderFunc(encodeOtlp),
+		defaultTemplateEncoder(),
+		NewNcsaEncoder(),
+	},
+}
+
+// Encoders with names.
+type encoderRegistry struct {
+	names    []string
+	encoders []Encoder
+	lock     sync.RWMutex
+}
+
+// RegisterEncoding registers encoder with name and returns Encoding of it.
+//
+// Name is case insensitive, encoder registered with the same name will be replaced including built-in ones.
+// Registered Encoding could be resolved with ToEncoding() and used with WithEncoding().
+//
+// RegisterEncoding panics if name is empty or encoder is nil.
+func RegisterEncoding(name string, encoder Encoder) Encoding {
+	name = strings.ToLower(name)
+	if len(name) < 1 || encoder == nil {
+		panic("rkquery: RegisterEncoding with empty name or nil encoder")
+	}
+
+	encoders.lock.Lock()
+	defer encoders.lock.Unlock()
+
+	for i := range encoders.names {
+		if encoders.names[i] == name {
+			encoders.encoders[i] = encoder
+			return Encoding(i)
+		}
+	}
+
+	encoders.names = append(encoders.names, name)
+	encoders.encoders = append(encoders.encoders, encoder)
+	return Encoding(len(encoders.names) - 1)
+}
+
+// Returns encoder of Encoding, nil would be returned if Encoding is not registered.
+func encoderOf(ec Encoding) Encoder {
+	encoders.lock.RLock()
+	defer encoders.lock.RUnlock()
+
+	if ec < 0 || int(ec) >= len(encoders.encoders) {
+		return nil
+	}
+
+	return encoders.encoders[ec]
+}
+
+// Returns name of Encoding, empty string would be returned if Encoding is not registered.
+func encodingName(ec Encoding) string {
+	encoders.lock.RLock()
+	defer encoders.lock.RUnlock()
+
+	if ec < 0 || int(ec) >= len(encoders.names) {
+		return ""
+	}
+
+	return encoders.names[ec]
+}
+
+// Returns Encoding with name, false would be returned if name is not registered.
+func encodingOf(name string) (Encoding, bool) {
+	encoders.lock.RLock()
+	defer encoders.lock.RUnlock()
+
+	name = strings.ToLower(name)
+	for i := range encoders.names {
+		if encoders.names[i] == name {
+			return Encoding(i), true
+		}
+	}
+
+	return CONSOLE, false
+}
+
+// ************* Built-in encoders *************
+
+// Encode event into FLATTEN format.
+func encodeFlatten(view EventView) (string, []zap.Field) {
+	buf := bufferPool.Get()
+	defer buf.Free()
+
+	// columns are separated with four spaces which is the same as tabwriter with padding of 4 for single line
+	const separator = "    "
+
+	// timestamp
+	buf.AppendTime(view.GetEndTime(), flattenTimeLayout)
+
+	// res code
+	buf.AppendString(separator + "[")
+	buf.AppendString(getDefaultIfEmptyString(view.GetResCode(), "[X]"))
+	buf.AppendString("]")
+
+	// elapsed
+	buf.AppendString(separator)
+	buf.AppendInt(view.GetEndTime().Sub(view.GetStartTime()).Milliseconds())
+	buf.AppendString("ms")
+
+	// operation, method, protocol and remote addr
+	operation, method, protocol := flattenEntryOf(view)
+	for _, cell := range [...]string{operation, method, protocol, view.GetRemoteAddr()} {
+		buf.AppendString(separator)
+		buf.AppendString(getDefaultIfEmptyString(cell, "[X]"))
+	}
+
+	// ids
+	buf.AppendString(separator + "[")
+	switch eventId, traceId := view.GetEventId(), view.GetTraceId(); {
+	case len(eventId) > 0 && len(traceId) > 0:
+		buf.AppendString(eventId)
+		buf.AppendByte(',')
+		buf.AppendString(traceId)
+	case len(eventId) > 0:
+		buf.AppendString(eventId)
+	case len(traceId) > 0:
+		buf.AppendString(traceId)
+	default:
+		buf.AppendString("[X]")
+	}
+	buf.AppendString("]")
+
+	return buf.String(), nil
+}
+
+// Returns operation, method and protocol of FLATTEN encoding.
+func flattenEntryOf(view EventView) (operation, method, protocol string) {
+	// API method
+	// distinguish restful API and gRPC
+	var grpcMethod, grpcServer, grpcType, apiPath, apiMethod, apiProtocol *zap.Field
+	payloads := view.ListPayloads()
+	for i := range payloads {
+		field := &payloads[i]
+		switch field.Key {
+		case "grpcMethod":
+			grpcMethod = field
+		case "grpcServer":
+			grpcServer = field
+		case "grpcType":
+			grpcType = field
+		case "apiPath":
+			apiPath = field
+		case "apiMethod":
+			apiMethod = field
+		case "apiProtocol":
+			apiProtocol = field
+		}
+	}
+
+	if grpcMethod != nil && grpcServer != nil {
+		return grpcMethod.String, grpcServer.String, fieldString(grpcType)
+	}
+
+	if apiPath != nil && apiMethod != nil {
+		return apiPath.String, apiMethod.String, fieldString(apiProtocol)
+	}
+
+	return view.GetOperation(), view.GetEntryName(), view.GetEntryKind()
+}
+
+// Encode event into CONSOLE format.
+func encodeConsole(view EventView) (string, []zap.Field) {
+	return encodeConsoleWithSchema(view, defaultSchema)
+}
+
+// Encode event into CONSOLE format with schema.
+func encodeConsoleWithSchema(view EventView, s *schema) (string, []zap.Field) {
+	buf := bufferPool.Get()
+	defer buf.Free()
+
+	buf.AppendString(scopeDelimiter + "\n")
+
+	// We would expect bellow format of event data as RK format.
+	// ------------------------------------------------------------------------
+	// endTime=2021-06-13T00:24:20.256315+08:00
+	// startTime=2021-06-13T00:24:19.251056+08:00
+	// elapsedNano=1005258286
+	// timezone=CST
+	// ids={"eventId":"6a2f84a8-a09a-42dc-bc9e-cabc7977345d"}
+	// service={"serviceName":"serviceName","serviceVersion":"v0.0.1","entryName":"entry-example","entryKind":"example"}
+	// env={"arch":"amd64","hostname":"lark.local","localIP":"localhost","realm":"rk","region":"ap-guangzhou","az":"ap-guangzhou-1","domain":"beta","os":"darwin"}
+	// payloads={"f1":"f2","t2":"2021-06-13T00:24:20.256276+08:00"}
+	// error={"my error":1}
+	// counters={"count":1}
+	// pairs={"key":"value"}
+	// timing={"t1.count":1,"t1.elapsed_ms":1005}
+	// remoteAddr=localhost
+	// operation=op
+	// resCode=200
+	// eventStatus=Ended
+	// EOE
+
+	// ************* Time *************
+	if s.has(endTimeKey) {
+		appendConsoleKey(buf, s.key(endTimeKey))
+		s.appendTime(buf, view.GetEndTime())
+		buf.AppendByte('\n')
+	}
+	if s.has(startTimeKey) {
+		appendConsoleKey(buf, s.key(startTimeKey))
+		s.appendTime(buf, view.GetStartTime())
+		buf.AppendByte('\n')
+	}
+	if s.has(elapsedKey) {
+		appendConsoleKey(buf, s.elapsedKey())
+		s.appendElapsed(buf, view.GetEndTime().Sub(view.GetStartTime()))
+		buf.AppendByte('\n')
+	}
+	if s.has(timezoneKey) {
+		appendConsoleLine(buf, s.key(timezoneKey), view.GetTimeZone())
+	}
+
+	// ************* Ids *************
+	if s.has(idsKey) {
+		appendConsoleKey(buf, s.key(idsKey))
+		appendIds(buf, view)
+		buf.AppendByte('\n')
+	}
+
+	// ************* Service *************
+	if s.has(serviceKey) {
+		appendConsoleKey(buf, s.key(serviceKey))
+		buf.AppendString(renderedSectionsOf(view).renderedService(view).json)
+		buf.AppendByte('\n')
+	}
+
+	// ************* Env *************
+	if s.has(envKey) {
+		appendConsoleKey(buf, s.key(envKey))
+		buf.AppendString(renderedSectionsOf(view).renderedEnv(view.ListEnv()).json)
+		buf.AppendByte('\n')
+	}
+
+	// ************* Payloads *************
+	if s.has(payloadsKey) {
+		appendConsoleKey(buf, s.key(payloadsKey))
+		appendEventSection(buf, view, payloadsKey)
+		buf.AppendByte('\n')
+	}
+
+	// ************* Error *************
+	// error section is written only if there is any error
+	if s.has(errKey) && hasErrors(view) {
+		appendConsoleKey(buf, s.key(errKey))
+		appendEventSection(buf, view, errKey)
+		buf.AppendByte('\n')
+	}
+
+	// ************* Counter *************
+	if s.has(countersKey) {
+		appendConsoleKey(buf, s.key(countersKey))
+		appendEventSection(buf, view, countersKey)
+		buf.AppendByte('\n')
+	}
+
+	// ************* Pairs *************
+	if s.has(pairsKey) {
+		appendConsoleKey(buf, s.key(pairsKey))
+		appendEventSection(buf, view, pairsKey)
+		buf.AppendByte('\n')
+	}
+
+	// ************* Timing *************
+	if s.has(timingKey) {
+		appendConsoleKey(buf, s.key(timingKey))
+		appendEventSection(buf, view, timingKey)
+		buf.AppendByte('\n')
+	}
+
+	// ************* Children *************
+	// children section is written only if there is any child rolled up
+	if children := view.ListChildren(); s.has(childrenKey) && len(children) > 0 {
+		appendConsoleKey(buf, s.key(childrenKey))
+		appendChildren(buf, children)
+		buf.AppendByte('\n')
+	}
+
+	// ************* Event *************
+	if s.has(remoteAddrKey) {
+		appendConsoleLine(buf, s.key(remoteAddrKey), view.GetRemoteAddr())
+	}
+	if s.has(operationKey) {
+		appendConsoleLine(buf, s.key(operationKey), view.GetOperation())
+	}
+	if s.has(resCodeKey) && len(view.GetResCode()) > 0 {
+		appendConsoleLine(buf, s.key(resCodeKey), view.GetResCode())
+	}
+	if s.has(eventStatusKey) {
+		appendConsoleLine(buf, s.key(eventStatusKey), view.GetEventStatus().String())
+	}
+
+	buf.AppendString(eoe)
+	return buf.String(), nil
+}
+
+// Append key of line in CONSOLE format.
+func appendConsoleKey(buf *buffer.Buffer, key string) {
+	buf.AppendString(key)
+	buf.AppendByte('=')
+}
+
+// Append line of key and value in CONSOLE format.
+func appendConsoleLine(buf *buffer.Buffer, key, value string) {
+	buf.AppendString(key)
+	buf.AppendByte('=')
+	buf.AppendString(value)
+	buf.AppendByte('\n')
+}
+
+// Encode event into JSON format.
+func encodeJson(view EventView) (string, []zap.Field) {
+	return encodeJsonWithSchema(view, defaultSchema)
+}
+
+// Encode event into JSON format with schema.
+func encodeJsonWithSchema(view EventView, s *schema) (string, []zap.Field) {
+	// We would expect bellow format of event data as JSON format.
+	//{
+	//	"endTime":"2021-06-13T00:24:21.261+0800",
+	//	"startTime":"2021-06-13T00:24:20.257+0800",
+	//	"elapsedNano":1004326112,
+	//	"timezone":"CST",
+	//	"ids":{
+	//	    "eventId":"72a59682-230f-4ba2-a9fc-e99a031e4d8c",
+	//		"requestId":"",
+	//		"traceId":""
+	//  },
+	//	"service":{
+	//	    "serviceName":"serviceName",
+	//		"serviceVersion":"unknown",
+	//		"entryName":"unknown",
+	//		"entryKind":"unknown"
+	//  },
+	//	"env":{
+	//	    "arch":"amd64",
+	//		"hostname":"lark.local",
+	//      "localIP":"localhost"
+	//		"realm":"*",
+	//		"region":"*",
+	//		"az":"*",
+	//		"domain":"*",
+	//		"os":"darwin"
+	//  },
+	//	"payloads":{
+	//	    "f1":"f2",
+	//		"t2":"2021-06-13T00:24:21.261768+08:00"
+	//  },
+	//	"error":{
+	//	    "my error":1
+	//  },
+	//	"counters":{
+	//	    "count":1
+	//  },
+	//	"pairs":{
+	//	    "key":"value"
+	//  },
+	//	"timing":{
+	//	    "t1.count":1,
+	//		"t1.elapsed_ms":1004
+	//  },
+	//	"remoteAddr":"localhost",
+	//	"operation":"op",
+	//	"eventStatus":"Ended",
+	//	"resCode":"200"
+	//}
+	fields := make([]zap.Field, 0, 16)
+
+	// ************* Time *************
+	if s.has(endTimeKey) {
+		fields = append(fields, s.timeField(endTimeKey, view.GetEndTime()))
+	}
+	if s.has(startTimeKey) {
+		fields = append(fields, s.timeField(startTimeKey, view.GetStartTime()))
+	}
+	if s.has(elapsedKey) {
+		fields = append(fields, s.elapsedField(view.GetEndTime().Sub(view.GetStartTime())))
+	}
+	if s.has(timezoneKey) {
+		fields = append(fields, zap.String(s.key(timezoneKey), view.GetTimeZone()))
+	}
+
+	// ************* Sections *************
+	// sections are written by zap encoder directly with sorted keys, service and env are rendered once by factory
+	if s.has(idsKey) {
+		fields = append(fields, zap.Object(s.key(idsKey), sectionMarshalerOf(view, idsKey)))
+	}
+	if s.has(serviceKey) {
+		fields = append(fields, zap.Object(s.key(serviceKey), renderedSectionsOf(view).renderedService(view)))
+	}
+	if s.has(envKey) {
+		fields = append(fields, zap.Object(s.key(envKey), renderedSectionsOf(view).renderedEnv(view.ListEnv())))
+	}
+	if s.has(payloadsKey) {
+		fields = append(fields, payloadsFieldOf(s.key(payloadsKey), view))
+	}
+	for _, key := range [...]string{errKey, countersKey, pairsKey, timingKey} {
+		if s.has(key) {
+			fields = append(fields, zap.Object(s.key(key), sectionMarshalerOf(view, key)))
+		}
+	}
+	if children := view.ListChildren(); s.has(childrenKey) && len(children) > 0 {
+		fields = append(fields, zap.Array(s.key(childrenKey), childrenMarshaler(children)))
+	}
+
+	// ************* Event *************
+	if s.has(remoteAddrKey) {
+		fields = append(fields, zap.String(s.key(remoteAddrKey), view.GetRemoteAddr()))
+	}
+	if s.has(operationKey) {
+		fields = append(fields, zap.String(s.key(operationKey), view.GetOperation()))
+	}
+	if s.has(eventStatusKey) {
+		fields = append(fields, zap.String(s.key(eventStatusKey), view.GetEventStatus().String()))
+	}
+
+	// resCode
+	if s.has(resCodeKey) && len(view.GetResCode()) > 0 {
+		fields = append(fields, zap.String(s.key(resCodeKey), view.GetResCode()))
+	}
+
+	return "", fields
+}
+
+// Append non empty ids of event as JSON object with sorted keys.
+func appendIds(buf *buffer.Buffer, view EventView) {
+	buf.AppendByte('{')
+	first := true
+	for _, id := range [...][2]string{
+		{eventIdKey, view.GetEventId()},
+		{parentEventIdKey, view.GetParentEventId()},
+		{requestIdKey, view.GetRequestId()},
+		{traceIdKey, view.GetTraceId()},
+	} {
+		if len(id[1]) < 1 {
+			continue
+		}
+		if !first {
+			buf.AppendByte(',')
+		}
+		first = false
+		appendJsonString(buf, id[0])
+		buf.AppendByte(':')
+		appendJsonString(buf, id[1])
+	}
+	buf.AppendByte('}')
+}
+
+// Returns non empty ids of event.
+func idsOf(view EventView) map[string]string {
+	res := make(map[string]string)
+	if len(view.GetEventId()) > 0 {
+		res[eventIdKey] = view.GetEventId()
+	}
+
+	if len(view.GetTraceId()) > 0 {
+		res[traceIdKey] = view.GetTraceId()
+	}
+
+	if len(view.GetRequestId()) > 0 {
+		res[requestIdKey] = view.GetRequestId()
+	}
+
+	if len(view.GetParentEventId()) > 0 {
+		res[parentEventIdKey] = view.GetParentEventId()
+	}
+
+	return res
+}
+
+// Returns service section of event.
+func serviceOf(view EventView) map[string]string {
+	return map[string]string{
+		serviceNameKey:    view.GetServiceName(),
+		serviceVersionKey: view.GetServiceVersion(),
+		entryNameKey:      view.GetEntryName(),
+		entryKindKey:      view.GetEntryKind(),
+	}
+}
+
+// Returns payloads of event as map.
+func payloadsOf(view EventView) map[string]interface{} {
+	enc := zapcore.NewMapObjectEncoder()
+	payloads := view.ListPayloads()
+	for i := range payloads {
+		payloads[i].AddTo(enc)
+	}
+
+	return enc.Fields
+}
+
+// Returns string value of field, empty string would be returned if field is nil.
+func fieldString(field *zap.Field) string {
+	if field == nil {
+		return ""
+	}
+
+	return field.String
+}
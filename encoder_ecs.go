@@ -0,0 +1,219 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"go.uber.org/zap"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ecsVersion = "8.11.0"
+	// ************* ECS fields *************
+	ecsTimestampKey      = "@timestamp"
+	ecsVersionKey        = "ecs.version"
+	ecsEventKindKey      = "event.kind"
+	ecsEventIdKey        = "event.id"
+	ecsEventActionKey    = "event.action"
+	ecsEventStartKey     = "event.start"
+	ecsEventEndKey       = "event.end"
+	ecsEventDurationKey  = "event.duration"
+	ecsEventTimezoneKey  = "event.timezone"
+	ecsEventOutcomeKey   = "event.outcome"
+	ecsServiceNameKey    = "service.name"
+	ecsServiceVersionKey = "service.version"
+	ecsServiceTypeKey    = "service.type"
+	ecsHostHostnameKey   = "host.hostname"
+	ecsHostIpKey         = "host.ip"
+	ecsHostOsKey         = "host.os.platform"
+	ecsHostArchKey       = "host.architecture"
+	ecsTraceIdKey        = "trace.id"
+	ecsRequestIdKey      = "http.request.id"
+	ecsMethodKey         = "http.request.method"
+	ecsStatusCodeKey     = "http.response.status_code"
+	ecsUrlPathKey        = "url.path"
+	ecsUserAgentKey      = "user_agent.original"
+	ecsClientAddressKey  = "client.address"
+	ecsClientIpKey       = "client.ip"
+	ecsClientPortKey     = "client.port"
+	ecsErrorMessageKey   = "error.message"
+	ecsLabelsKey         = "labels"
+	// ************* Custom fields of rk *************
+	ecsEntryNameKey   = "rk.entryName"
+	ecsResCodeKey     = "rk.resCode"
+	ecsStatusKey      = "rk.eventStatus"
+	ecsEnvKey         = "rk.env"
+	ecsPayloadsKey    = "rk.payloads"
+	ecsErrorsKey      = "rk.errors"
+	ecsCountersKey    = "rk.counters"
+	ecsTimingKey      = "rk.timing"
+	ecsOutcomeSuccess = "success"
+	ecsOutcomeFailure = "failure"
+	ecsOutcomeUnknown = "unknown"
+)
+
+// Keys of env and ECS keys of host in the order written.
+var ecsHostKeys = [...][2]string{
+	{hostnameKey, ecsHostHostnameKey},
+	{localIpKey, ecsHostIpKey},
+	{goosKey, ecsHostOsKey},
+	{goArchKey, ecsHostArchKey},
+}
+
+// Encode event into fields of Elastic Common Schema.
+//
+// Fields of event are mapped onto ECS names like event.duration, event.outcome, service.name, host.hostname,
+// trace.id, error.message, client.address and http.response.status_code.
+// Pairs are written as labels, other fields without ECS mapping like counters and timing are written
+// under custom namespace of rk.
+//
+// Times are formatted with RFC3339, so logger should not use @timestamp as time key.
+func encodeEcs(view EventView) (string, []zap.Field) {
+	fields := []zap.Field{
+		zap.String(ecsTimestampKey, view.GetStartTime().Format(time.RFC3339Nano)),
+		zap.String(ecsVersionKey, ecsVersion),
+		// ************* Event *************
+		zap.String(ecsEventKindKey, "event"),
+		zap.String(ecsEventIdKey, view.GetEventId()),
+		zap.String(ecsEventActionKey, view.GetOperation()),
+		zap.String(ecsEventStartKey, view.GetStartTime().Format(time.RFC3339Nano)),
+		zap.String(ecsEventEndKey, view.GetEndTime().Format(time.RFC3339Nano)),
+		zap.Int64(ecsEventDurationKey, view.GetEndTime().Sub(view.GetStartTime()).Nanoseconds()),
+		zap.String(ecsEventTimezoneKey, view.GetTimeZone()),
+		zap.String(ecsEventOutcomeKey, ecsOutcome(view)),
+		// ************* Service *************
+		zap.String(ecsServiceNameKey, view.GetServiceName()),
+		zap.String(ecsEntryNameKey, view.GetEntryName()),
+	}
+	if len(view.GetServiceVersion()) > 0 {
+		fields = append(fields, zap.String(ecsServiceVersionKey, view.GetServiceVersion()))
+	}
+	if len(view.GetEntryKind()) > 0 {
+		fields = append(fields, zap.String(ecsServiceTypeKey, view.GetEntryKind()))
+	}
+
+	// ************* Host *************
+	// written in fixed order, other env are written as rk.env
+	env := make(map[string]string)
+	for k, v := range view.ListEnv() {
+		env[k] = v
+	}
+	for _, key := range ecsHostKeys {
+		if v, ok := env[key[0]]; ok {
+			fields = append(fields, zap.String(key[1], v))
+			delete(env, key[0])
+		}
+	}
+
+	// ************* Ids *************
+	if len(view.GetTraceId()) > 0 {
+		fields = append(fields, zap.String(ecsTraceIdKey, view.GetTraceId()))
+	}
+	if len(view.GetRequestId()) > 0 {
+		fields = append(fields, zap.String(ecsRequestIdKey, view.GetRequestId()))
+	}
+
+	// ************* HTTP *************
+	payloads := payloadsOf(view)
+	if method, ok := payloads["apiMethod"].(string); ok && len(method) > 0 {
+		fields = append(fields, zap.String(ecsMethodKey, method))
+	}
+	if path, ok := payloads["apiPath"].(string); ok && len(path) > 0 {
+		fields = append(fields, zap.String(ecsUrlPathKey, path))
+	}
+	if agent, ok := payloads["userAgent"].(string); ok && len(agent) > 0 {
+		fields = append(fields, zap.String(ecsUserAgentKey, agent))
+	}
+	if code, ok := httpStatusCode(view.GetResCode()); ok {
+		fields = append(fields, zap.Int(ecsStatusCodeKey, code))
+	}
+
+	// ************* Client *************
+	if addr := view.GetRemoteAddr(); len(addr) > 0 {
+		fields = append(fields, zap.String(ecsClientAddressKey, addr))
+		if host, port, err := net.SplitHostPort(addr); err == nil && net.ParseIP(host) != nil {
+			fields = append(fields, zap.String(ecsClientIpKey, host))
+			if p, err := strconv.Atoi(port); err == nil {
+				fields = append(fields, zap.Int(ecsClientPortKey, p))
+			}
+		} else if net.ParseIP(addr) != nil {
+			fields = append(fields, zap.String(ecsClientIpKey, addr))
+		}
+	}
+
+	// ************* Error *************
+	if errs := view.ListErrors(); len(errs) > 0 {
+		messages := make([]string, 0, len(errs))
+		for k := range errs {
+			messages = append(messages, k)
+		}
+		sort.Strings(messages)
+
+		fields = append(fields,
+			zap.String(ecsErrorMessageKey, strings.Join(messages, "; ")),
+			zap.Any(ecsErrorsKey, errs))
+	}
+
+	// ************* Custom *************
+	if len(view.GetResCode()) > 0 {
+		fields = append(fields, zap.String(ecsResCodeKey, view.GetResCode()))
+	}
+
+	fields = append(fields,
+		zap.String(ecsStatusKey, view.GetEventStatus().String()),
+		zap.Any(ecsLabelsKey, view.ListPairs()),
+		zap.Any(ecsCountersKey, view.ListCounters()),
+		zap.Any(ecsTimingKey, view.ListTimings()),
+		zap.Any(ecsPayloadsKey, payloads))
+
+	if len(env) > 0 {
+		fields = append(fields, zap.Any(ecsEnvKey, env))
+	}
+
+	return "", fields
+}
+
+// Returns event.outcome of event.
+//
+// Event with errors or failed response code is failure, HTTP status code >= 400 and gRPC code other than OK
+// are treated as failed response code.
+func ecsOutcome(view EventView) string {
+	if len(view.ListErrors()) > 0 {
+		return ecsOutcomeFailure
+	}
+
+	resCode := view.GetResCode()
+	if len(resCode) < 1 {
+		return ecsOutcomeUnknown
+	}
+
+	if code, err := strconv.Atoi(resCode); err == nil {
+		if code >= 400 {
+			return ecsOutcomeFailure
+		}
+		return ecsOutcomeSuccess
+	}
+
+	if strings.EqualFold(resCode, "OK") {
+		return ecsOutcomeSuccess
+	}
+
+	return ecsOutcomeFailure
+}
+
+// Returns HTTP status code if response code is a valid one.
+func httpStatusCode(resCode string) (int, bool) {
+	code, err := strconv.Atoi(resCode)
+	if err != nil || code < 100 || code > 599 {
+		return 0, false
+	}
+
+	return code, true
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Encode record into ECS and decode it as JSON object.
+func encodeEcsObject(t *testing.T, record *Record) map[string]interface{} {
+	buf := &bytes.Buffer{}
+	msg, fields := encodeEcs(record)
+	newBufferLogger(buf, true).Info(msg, fields...)
+
+	res := make(map[string]interface{})
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &res))
+	return res
+}
+
+func TestEncodeEcs_HappyCase(t *testing.T) {
+	record := newRecord()
+	record.StartTime = time.Date(2021, 6, 13, 0, 0, 0, 0, time.UTC)
+	record.EndTime = record.StartTime.Add(time.Second)
+	record.EventId = "ut-event"
+	record.TraceId = "ut-trace"
+	record.RequestId = "ut-request"
+	record.ServiceName = "ut-service"
+	record.ServiceVersion = "v0.0.1"
+	record.EntryKind = "ut-kind"
+	record.Env = map[string]string{hostnameKey: "ut-host", localIpKey: "10.0.0.1", domainKey: "prod"}
+	record.Payloads["apiPath"] = "/v1/a"
+	record.Payloads["apiMethod"] = "GET"
+	record.Errors["err-b"] = 1
+	record.Errors["err-a"] = 1
+	record.Pairs["tenant"] = "acme"
+	record.Counters["hits"] = 2
+	record.RemoteAddr = "10.0.0.2:1949"
+	record.Operation = "ut-op"
+	record.ResCode = "200"
+	record.EventStatus = Ended.String()
+
+	obj := encodeEcsObject(t, record)
+	assert.Equal(t, "2021-06-13T00:00:00Z", obj["@timestamp"])
+	assert.Equal(t, ecsVersion, obj["ecs.version"])
+	assert.Equal(t, "ut-event", obj["event.id"])
+	assert.Equal(t, "ut-op", obj["event.action"])
+	assert.Equal(t, float64(time.Second), obj["event.duration"])
+	assert.Equal(t, "failure", obj["event.outcome"])
+	assert.Equal(t, "ut-service", obj["service.name"])
+	assert.Equal(t, "v0.0.1", obj["service.version"])
+	assert.Equal(t, "ut-kind", obj["service.type"])
+	assert.Equal(t, "ut-host", obj["host.hostname"])
+	assert.Equal(t, "10.0.0.1", obj["host.ip"])
+	assert.Equal(t, "ut-trace", obj["trace.id"])
+	assert.Equal(t, "ut-request", obj["http.request.id"])
+	assert.Equal(t, "GET", obj["http.request.method"])
+	assert.Equal(t, "/v1/a", obj["url.path"])
+	assert.Equal(t, float64(200), obj["http.response.status_code"])
+	assert.Equal(t, "10.0.0.2:1949", obj["client.address"])
+	assert.Equal(t, "10.0.0.2", obj["client.ip"])
+	assert.Equal(t, float64(1949), obj["client.port"])
+	assert.Equal(t, "err-a; err-b", obj["error.message"])
+	assert.Equal(t, map[string]interface{}{"tenant": "acme"}, obj["labels"])
+	assert.Equal(t, map[string]interface{}{"hits": float64(2)}, obj["rk.counters"])
+	assert.Equal(t, map[string]interface{}{"domain": "prod"}, obj["rk.env"])
+}
+
+func TestEncodeEcs_WithoutOptionalFields(t *testing.T) {
+	record := newRecord()
+	record.RemoteAddr = "localhost"
+	record.ResCode = "OK"
+
+	obj := encodeEcsObject(t, record)
+	assert.Equal(t, "success", obj["event.outcome"])
+	assert.Equal(t, "localhost", obj["client.address"])
+	assert.NotContains(t, obj, "client.ip")
+	assert.NotContains(t, obj, "trace.id")
+	assert.NotContains(t, obj, "http.response.status_code")
+	assert.NotContains(t, obj, "error.message")
+	assert.NotContains(t, obj, "rk.env")
+	assert.NotContains(t, obj, "service.version")
+	assert.NotContains(t, obj, "service.type")
+}
+
+func TestEncodeEcs_WithHostInFixedOrder(t *testing.T) {
+	record := newRecord()
+	record.Env = map[string]string{goArchKey: "amd64", goosKey: "linux", localIpKey: "10.0.0.1", hostnameKey: "ut-host"}
+
+	for i := 0; i < 10; i++ {
+		_, fields := encodeEcs(record)
+		keys := make([]string, 0)
+		for _, field := range fields {
+			if strings.HasPrefix(field.Key, "host.") {
+				keys = append(keys, field.Key)
+			}
+		}
+		assert.Equal(t, []string{ecsHostHostnameKey, ecsHostIpKey, ecsHostOsKey, ecsHostArchKey}, keys)
+	}
+}
+
+func TestEcsOutcome(t *testing.T) {
+	record := newRecord()
+	assert.Equal(t, "unknown", ecsOutcome(record))
+
+	record.ResCode = "OK"
+	assert.Equal(t, "success", ecsOutcome(record))
+
+	record.ResCode = "NotFound"
+	assert.Equal(t, "failure", ecsOutcome(record))
+
+	record.ResCode = "302"
+	assert.Equal(t, "success", ecsOutcome(record))
+
+	record.ResCode = "503"
+	assert.Equal(t, "failure", ecsOutcome(record))
+
+	record.ResCode = "200"
+	record.Errors["ut-err"] = 1
+	assert.Equal(t, "failure", ecsOutcome(record))
+}
+
+func TestHttpStatusCode(t *testing.T) {
+	code, ok := httpStatusCode("404")
+	assert.True(t, ok)
+	assert.Equal(t, 404, code)
+
+	_, ok = httpStatusCode("OK")
+	assert.False(t, ok)
+
+	_, ok = httpStatusCode("0")
+	assert.False(t, ok)
+}
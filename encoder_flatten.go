@@ -0,0 +1,266 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"go.uber.org/zap"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	flattenTruncated     = "..."
+	defaultFlattenMargin = 4
+)
+
+var (
+	// Replaces line breaks and tabs in values, so that event is written in one line.
+	flattenReplacer = strings.NewReplacer("\n", " ", "\r", " ", "\t", " ")
+)
+
+// FlattenColumn is a column of FLATTEN encoding configured with WithFlattenColumns().
+type FlattenColumn struct {
+	// Path of field, same as path of Record.Value(), e.g. operation, resCode, elapsedMs,
+	// pairs.tenant, payloads.apiPath and timing.db.elapsedMs.
+	Path string
+	// Header of column, Path would be used if empty.
+	Header string
+	// Width of column, values are padded to fixed width if Width > 0.
+	// Otherwise, width grows to the longest value written so far.
+	Width int
+	// Truncate values longer than Width.
+	Truncate bool
+	// Prefix and Suffix are written around value, e.g. [ and ] around resCode, they are included in Width.
+	Prefix string
+	Suffix string
+	// value overrides Path, it is used by default columns only
+	value func(*Record) string
+}
+
+// Columns used if WithFlattenColumns() was not provided, same as FLATTEN encoding, so that events could be read by Reader.
+var defaultFlattenColumns = []FlattenColumn{
+	{Path: endTimeKey},
+	{Path: resCodeKey, Prefix: "[", Suffix: "]"},
+	{Path: elapsedMsKey, Suffix: "ms"},
+	{Path: operationKey, value: func(record *Record) string {
+		operation, _, _ := flattenEntryOf(record)
+		return operation
+	}},
+	{Path: entryNameKey, Header: "method", value: func(record *Record) string {
+		_, method, _ := flattenEntryOf(record)
+		return method
+	}},
+	{Path: entryKindKey, Header: "protocol", value: func(record *Record) string {
+		_, _, protocol := flattenEntryOf(record)
+		return protocol
+	}},
+	{Path: remoteAddrKey},
+	{Path: idsKey, Header: "ids", Prefix: "[", Suffix: "]", value: func(record *Record) string {
+		if len(record.EventId) > 0 && len(record.TraceId) > 0 {
+			return record.EventId + "," + record.TraceId
+		}
+		return record.EventId + record.TraceId
+	}},
+}
+
+// FlattenOption will be passed into NewFlattenEncoder to override default behavior of encoder.
+type FlattenOption func(*flattenEncoder)
+
+// WithFlattenColumns overrides columns, default columns are the same as FLATTEN encoding which are endTime, [resCode],
+// elapsedMs with ms, operation, method, protocol, remoteAddr and [eventId,traceId].
+func WithFlattenColumns(columns ...FlattenColumn) FlattenOption {
+	return func(encoder *flattenEncoder) {
+		if len(columns) > 0 {
+			encoder.columns = append([]FlattenColumn{}, columns...)
+		}
+	}
+}
+
+// WithFlattenHeader writes header row before the first event and every n events, header is disabled if n < 1.
+func WithFlattenHeader(n int) FlattenOption {
+	return func(encoder *flattenEncoder) {
+		encoder.headerEvery = n
+	}
+}
+
+// WithFlattenMargin overrides number of spaces between columns, default is 4.
+func WithFlattenMargin(margin int) FlattenOption {
+	return func(encoder *flattenEncoder) {
+		if margin > 0 {
+			encoder.margin = margin
+		}
+	}
+}
+
+// Encodes event into one row of table with configured columns.
+type flattenEncoder struct {
+	columns     []FlattenColumn
+	headerEvery int
+	margin      int
+	// widths of columns, width of column without fixed width grows with values
+	widths []int
+	count  int
+	lock   sync.Mutex
+}
+
+// NewFlattenEncoder creates an Encoder which writes events as rows of table with configured columns.
+//
+// Columns are aligned across events, since width of each column is fixed or grows to the longest value.
+// Width of column without fixed width never shrinks, so a single long value widens the column for all events
+// written after, use Width with Truncate in order to bound it.
+// Missing values are written as [X]. Encoder could be shared by events, rows are aligned only if events
+// are written with the same encoder.
+//
+// Events written with default columns could be read by Reader as FLATTEN encoding.
+func NewFlattenEncoder(opts ...FlattenOption) Encoder {
+	encoder := &flattenEncoder{
+		columns: defaultFlattenColumns,
+		margin:  defaultFlattenMargin,
+	}
+
+	for i := range opts {
+		opts[i](encoder)
+	}
+
+	encoder.widths = make([]int, len(encoder.columns))
+	for i := range encoder.columns {
+		encoder.widths[i] = encoder.columns[i].Width
+	}
+
+	return encoder
+}
+
+// Encode returns row of event with header row if needed.
+func (encoder *flattenEncoder) Encode(view EventView) (string, []zap.Field) {
+	record := recordOf(view)
+
+	values := make([]string, len(encoder.columns))
+	for i := range encoder.columns {
+		values[i] = encoder.columns[i].render(record)
+	}
+
+	encoder.lock.Lock()
+	defer encoder.lock.Unlock()
+
+	// grow widths before header, so header is aligned with the current row at least
+	for i := range values {
+		if width := utf8.RuneCountInString(values[i]); encoder.columns[i].Width < 1 && width > encoder.widths[i] {
+			encoder.widths[i] = width
+		}
+	}
+
+	builder := &strings.Builder{}
+	if encoder.headerEvery > 0 && encoder.count%encoder.headerEvery == 0 {
+		headers := make([]string, len(encoder.columns))
+		for i := range encoder.columns {
+			headers[i] = encoder.columns[i].truncate(encoder.columns[i].header())
+		}
+		encoder.writeRow(builder, headers)
+		builder.WriteByte('\n')
+	}
+	encoder.count++
+
+	encoder.writeRow(builder, values)
+	return builder.String(), nil
+}
+
+// Write values padded to width of columns, trailing spaces are omitted.
+func (encoder *flattenEncoder) writeRow(builder *strings.Builder, values []string) {
+	for i := range values {
+		width := utf8.RuneCountInString(values[i])
+		if encoder.columns[i].Width < 1 && width > encoder.widths[i] {
+			encoder.widths[i] = width
+		}
+
+		builder.WriteString(values[i])
+		if i < len(values)-1 {
+			padding := encoder.widths[i] - width
+			if padding < 0 {
+				padding = 0
+			}
+			builder.WriteString(strings.Repeat(" ", padding+encoder.margin))
+		}
+	}
+}
+
+// Returns header of column.
+func (col *FlattenColumn) header() string {
+	if len(col.Header) > 0 {
+		return col.Header
+	}
+
+	return col.Path
+}
+
+// Returns value of column in one line surrounded with prefix and suffix, value is truncated if needed.
+func (col *FlattenColumn) render(record *Record) string {
+	var value string
+	if col.value != nil {
+		value = getDefaultIfEmptyString(flattenReplacer.Replace(col.value(record)), flattenEmpty)
+	} else {
+		value = flattenValue(record, col.Path)
+	}
+
+	if len(col.Prefix) < 1 && len(col.Suffix) < 1 {
+		return col.truncate(value)
+	}
+
+	if col.Truncate && col.Width > 0 {
+		width := col.Width - utf8.RuneCountInString(col.Prefix) - utf8.RuneCountInString(col.Suffix)
+		if width < 0 {
+			width = 0
+		}
+		value = truncateTo(value, width)
+	}
+
+	return col.Prefix + value + col.Suffix
+}
+
+// Truncate value to width of column if needed.
+func (col *FlattenColumn) truncate(value string) string {
+	if !col.Truncate || col.Width < 1 {
+		return value
+	}
+
+	return truncateTo(value, col.Width)
+}
+
+// Truncate value to width, the last characters are replaced with ... if there is enough room.
+func truncateTo(value string, width int) string {
+	if utf8.RuneCountInString(value) <= width {
+		return value
+	}
+
+	runes := []rune(value)
+	if width <= len(flattenTruncated) {
+		return string(runes[:width])
+	}
+
+	return string(runes[:width-len(flattenTruncated)]) + flattenTruncated
+}
+
+// Returns string value of field in one line.
+func flattenValue(record *Record, path string) string {
+	val, ok := record.Value(path)
+	if !ok {
+		return flattenEmpty
+	}
+
+	var str string
+	if t, ok := val.(time.Time); ok {
+		str = t.Format(flattenTimeLayout)
+	} else {
+		str = formatValue(val)
+	}
+
+	if len(str) < 1 {
+		return flattenEmpty
+	}
+
+	return flattenReplacer.Replace(str)
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Create a record with operation and tenant.
+func newFlattenRecord(operation, tenant string) *Record {
+	record := newRecord()
+	record.StartTime = time.Date(2021, 6, 13, 0, 0, 0, 0, time.UTC)
+	record.EndTime = record.StartTime.Add(5 * time.Millisecond)
+	record.ElapsedNano = (5 * time.Millisecond).Nanoseconds()
+	record.Operation = operation
+	record.ResCode = "200"
+	if len(tenant) > 0 {
+		record.Pairs["tenant"] = tenant
+	}
+	record.Timing["db.elapsedMs"] = 3
+	return record
+}
+
+func TestNewFlattenEncoder_WithColumns(t *testing.T) {
+	encoder := NewFlattenEncoder(WithFlattenColumns(
+		FlattenColumn{Path: "operation", Width: 8, Truncate: true},
+		FlattenColumn{Path: "pairs.tenant", Header: "tenant"},
+		FlattenColumn{Path: "timing.db.elapsedMs", Header: "db"},
+	), WithFlattenMargin(2))
+
+	first, fields := encoder.Encode(newFlattenRecord("/v1/a", "acme-corporation"))
+	assert.Empty(t, fields)
+	assert.Equal(t, "/v1/a     acme-corporation  3", first)
+
+	// width of tenant column was grown by previous event
+	second, _ := encoder.Encode(newFlattenRecord("/v1/long/operation", ""))
+	assert.Equal(t, "/v1/l...  [X]               3", second)
+}
+
+func TestNewFlattenEncoder_WithHeader(t *testing.T) {
+	encoder := NewFlattenEncoder(WithFlattenColumns(
+		FlattenColumn{Path: "resCode", Width: 7},
+		FlattenColumn{Path: "elapsedMs"},
+	), WithFlattenHeader(2))
+
+	rows := make([]string, 0)
+	for i := 0; i < 3; i++ {
+		msg, _ := encoder.Encode(newFlattenRecord("/v1/a", ""))
+		rows = append(rows, strings.Split(msg, "\n")...)
+	}
+
+	assert.Equal(t, []string{
+		"resCode    elapsedMs",
+		"200        5",
+		"200        5",
+		"resCode    elapsedMs",
+		"200        5",
+	}, rows)
+}
+
+func TestNewFlattenEncoder_WithDefaultColumns(t *testing.T) {
+	encoder := NewFlattenEncoder()
+
+	first := newFlattenRecord("/v1/a", "")
+	first.Payloads["apiPath"] = "/v1/users"
+	first.Payloads["apiMethod"] = "GET"
+	first.Payloads["apiProtocol"] = "HTTP/1.1"
+	first.RemoteAddr = "localhost"
+	first.EventId = "ut-event"
+	first.TraceId = "ut-trace"
+
+	// same as FLATTEN encoding
+	msg, _ := encoder.Encode(first)
+	expected, _ := encodeFlatten(first)
+	assert.Equal(t, expected, msg)
+	assert.Equal(t, "2021-06-13T00:00:00.005Z    [200]    5ms    /v1/users    GET    HTTP/1.1    localhost    [ut-event,ut-trace]", msg)
+
+	second := newFlattenRecord("/v1/b", "")
+	second.ResCode = ""
+	next, _ := encoder.Encode(second)
+	assert.Equal(t, "2021-06-13T00:00:00.005Z    [[X]]    5ms    /v1/b        [X]    [X]         [X]          [[X]]", next)
+
+	// readable by Reader
+	records, err := ParseFlatten(strings.NewReader(msg + "\n" + next + "\n"))
+	assert.Nil(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, "/v1/users", records[0].Operation)
+	assert.Equal(t, "GET", records[0].EntryName)
+	assert.Equal(t, "ut-trace", records[0].TraceId)
+	assert.Equal(t, "", records[1].ResCode)
+	assert.Equal(t, "/v1/b", records[1].Operation)
+}
+
+func TestFlattenColumn_WithPrefixAndSuffix(t *testing.T) {
+	col := &FlattenColumn{Path: "operation", Width: 8, Truncate: true, Prefix: "[", Suffix: "]"}
+	assert.Equal(t, "[/v1...]", col.render(newFlattenRecord("/v1/long/operation", "")))
+	assert.Equal(t, "[/v1/a]", col.render(newFlattenRecord("/v1/a", "")))
+}
+
+func TestFlattenColumn_Truncate(t *testing.T) {
+	col := &FlattenColumn{Width: 3, Truncate: true}
+	assert.Equal(t, "abc", col.truncate("abcdef"))
+	assert.Equal(t, "ab", col.truncate("ab"))
+
+	col.Truncate = false
+	assert.Equal(t, "abcdef", col.truncate("abcdef"))
+}
+
+func TestWithFlatten_HappyCase(t *testing.T) {
+	buf := &bytes.Buffer{}
+	fac := NewEventFactory(
+		WithZapLogger(newBufferLogger(buf, false)),
+		WithFlatten(WithFlattenColumns(FlattenColumn{Path: "operation"}, FlattenColumn{Path: "resCode"})))
+
+	for _, op := range []string{"/v1/long/operation", "/v1/a"} {
+		event := fac.CreateEvent()
+		event.SetOperation(op)
+		event.SetResCode("OK")
+		event.Finish()
+	}
+
+	assert.Equal(t, FLATTEN, fac.CreateEvent().(*eventZap).encoding)
+	assert.Equal(t, "/v1/long/operation    OK\n/v1/a                 OK\n", buf.String())
+}
@@ -0,0 +1,798 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"encoding/json"
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// Buffers shared by encoders, buffer should be returned with Free() after use.
+var bufferPool = buffer.NewPool()
+
+// Slices of keys shared by encoders in order to sort keys of sections without allocation.
+var keysPool = sync.Pool{
+	New: func() interface{} {
+		keys := make([]string, 0, 16)
+		return &keys
+	},
+}
+
+// ************* Append style JSON writer *************
+
+// Hex digits used to escape characters.
+const hexDigits = "0123456789abcdef"
+
+// Append s as JSON string, output is the same as encoding/json which escapes HTML characters as well.
+func appendJsonString(buf *buffer.Buffer, s string) {
+	buf.AppendByte('"')
+
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' && b != '<' && b != '>' && b != '&' {
+				i++
+				continue
+			}
+
+			buf.AppendString(s[start:i])
+			switch b {
+			case '"', '\\':
+				buf.AppendByte('\\')
+				buf.AppendByte(b)
+			case '\n':
+				buf.AppendString(`\n`)
+			case '\r':
+				buf.AppendString(`\r`)
+			case '\t':
+				buf.AppendString(`\t`)
+			default:
+				buf.AppendString(`\u00`)
+				buf.AppendByte(hexDigits[b>>4])
+				buf.AppendByte(hexDigits[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+
+		c, size := utf8.DecodeRuneInString(s[i:])
+		if c == utf8.RuneError && size == 1 {
+			buf.AppendString(s[start:i])
+			buf.AppendString("\ufffd")
+			i += size
+			start = i
+			continue
+		}
+
+		// U+2028 and U+2029 are escaped by encoding/json
+		if c == '\u2028' || c == '\u2029' {
+			buf.AppendString(s[start:i])
+			buf.AppendString(`\u202`)
+			buf.AppendByte(hexDigits[c&0xF])
+			i += size
+			start = i
+			continue
+		}
+
+		i += size
+	}
+
+	buf.AppendString(s[start:])
+	buf.AppendByte('"')
+}
+
+// Append float64 as JSON number, output is the same as encoding/json.
+func appendJsonFloat(buf *buffer.Buffer, f float64) bool {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return false
+	}
+
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+
+	var scratch [32]byte
+	b := strconv.AppendFloat(scratch[:0], f, format, -1, 64)
+	if format == 'e' {
+		// clean up e-09 to e-9
+		if n := len(b); n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	buf.Write(b)
+
+	return true
+}
+
+// Append value of section as JSON, values not supported are marshaled with encoding/json.
+func appendJsonValue(buf *buffer.Buffer, val interface{}) bool {
+	switch v := val.(type) {
+	case nil:
+		buf.AppendString("null")
+	case string:
+		appendJsonString(buf, v)
+	case bool:
+		buf.AppendBool(v)
+	case int64:
+		buf.AppendInt(v)
+	case int:
+		buf.AppendInt(int64(v))
+	case int32:
+		buf.AppendInt(int64(v))
+	case uint64:
+		buf.AppendUint(v)
+	case float64:
+		return appendJsonFloat(buf, v)
+	case time.Duration:
+		buf.AppendInt(int64(v))
+	case time.Time:
+		buf.AppendByte('"')
+		buf.AppendTime(v, time.RFC3339Nano)
+		buf.AppendByte('"')
+	case map[string]interface{}:
+		return appendJsonObject(buf, v)
+	default:
+		bytes, err := json.Marshal(v)
+		if err != nil {
+			return false
+		}
+		buf.Write(bytes)
+	}
+
+	return true
+}
+
+// Append map as JSON object with sorted keys, false would be returned if any value could not be marshaled.
+func appendJsonObject(buf *buffer.Buffer, m map[string]interface{}) bool {
+	keys := keysPool.Get().(*[]string)
+	defer putKeys(keys)
+	for k := range m {
+		*keys = append(*keys, k)
+	}
+	sort.Strings(*keys)
+
+	buf.AppendByte('{')
+	for i, k := range *keys {
+		if i > 0 {
+			buf.AppendByte(',')
+		}
+		appendJsonString(buf, k)
+		buf.AppendByte(':')
+		if !appendJsonValue(buf, m[k]) {
+			return false
+		}
+	}
+	buf.AppendByte('}')
+
+	return true
+}
+
+// Append map as JSON object with sorted keys.
+func appendJsonStrings(buf *buffer.Buffer, m map[string]string) {
+	keys := keysPool.Get().(*[]string)
+	defer putKeys(keys)
+	for k := range m {
+		*keys = append(*keys, k)
+	}
+	sort.Strings(*keys)
+
+	buf.AppendByte('{')
+	for i, k := range *keys {
+		if i > 0 {
+			buf.AppendByte(',')
+		}
+		appendJsonString(buf, k)
+		buf.AppendByte(':')
+		appendJsonString(buf, m[k])
+	}
+	buf.AppendByte('}')
+}
+
+// Append map as JSON object with sorted keys.
+func appendJsonInts(buf *buffer.Buffer, m map[string]int64) {
+	keys := keysPool.Get().(*[]string)
+	defer putKeys(keys)
+	for k := range m {
+		*keys = append(*keys, k)
+	}
+	sort.Strings(*keys)
+
+	buf.AppendByte('{')
+	for i, k := range *keys {
+		if i > 0 {
+			buf.AppendByte(',')
+		}
+		appendJsonString(buf, k)
+		buf.AppendByte(':')
+		buf.AppendInt(m[k])
+	}
+	buf.AppendByte('}')
+}
+
+// Append section as JSON object, {} would be appended if section could not be marshaled.
+func appendSection(buf *buffer.Buffer, section interface{}) {
+	start := buf.Len()
+
+	ok := true
+	switch v := section.(type) {
+	case map[string]string:
+		appendJsonStrings(buf, v)
+	case map[string]int64:
+		appendJsonInts(buf, v)
+	case map[string]interface{}:
+		ok = appendJsonObject(buf, v)
+	default:
+		ok = appendJsonValue(buf, v)
+	}
+
+	if !ok {
+		truncateBuffer(buf, start)
+		buf.AppendString("{}")
+	}
+}
+
+// Truncate buffer to length of n.
+func truncateBuffer(buf *buffer.Buffer, n int) {
+	bytes := buf.Bytes()[:n]
+	buf.Reset()
+	buf.Write(bytes)
+}
+
+// Return slice of keys into pool.
+func putKeys(keys *[]string) {
+	*keys = (*keys)[:0]
+	keysPool.Put(keys)
+}
+
+// ************* Pre-rendered sections *************
+
+// Service and env sections of events created by the same factory, which are rendered once and rarely change.
+// Sections are cached by factory, so that factories with different service or env do not evict each other.
+type renderedSections struct {
+	// env of current machine when factory was created, shared by events without custom env
+	env *renderedEnvSection
+	// last rendered service section
+	service atomic.Value
+}
+
+// Create sections with env of current machine rendered.
+func newRenderedSections() *renderedSections {
+	return &renderedSections{
+		env: newRenderedEnvSection(map[string]string{
+			hostnameKey: hostname,
+			localIpKey:  localIp,
+			domainKey:   domain,
+			goosKey:     goos,
+			goArchKey:   goArch,
+		}),
+	}
+}
+
+// Rendered service section.
+type renderedServiceSection struct {
+	serviceName    string
+	serviceVersion string
+	entryName      string
+	entryKind      string
+	json           string
+}
+
+// Render service section of event.
+func newRenderedServiceSection(view EventView) *renderedServiceSection {
+	buf := bufferPool.Get()
+	defer buf.Free()
+	appendJsonStrings(buf, serviceOf(view))
+
+	return &renderedServiceSection{
+		serviceName:    view.GetServiceName(),
+		serviceVersion: view.GetServiceVersion(),
+		entryName:      view.GetEntryName(),
+		entryKind:      view.GetEntryKind(),
+		json:           buf.String(),
+	}
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaler, keys are written in sorted order.
+func (section *renderedServiceSection) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString(entryKindKey, section.entryKind)
+	enc.AddString(entryNameKey, section.entryName)
+	enc.AddString(serviceNameKey, section.serviceName)
+	enc.AddString(serviceVersionKey, section.serviceVersion)
+
+	return nil
+}
+
+// Rendered env section, env should not be modified after rendered.
+type renderedEnvSection struct {
+	env  map[string]string
+	keys []string
+	json string
+}
+
+// Render env section, env is copied.
+func newRenderedEnvSection(env map[string]string) *renderedEnvSection {
+	section := &renderedEnvSection{
+		env:  make(map[string]string, len(env)),
+		keys: make([]string, 0, len(env)),
+	}
+	for k, v := range env {
+		section.env[k] = v
+		section.keys = append(section.keys, k)
+	}
+	sort.Strings(section.keys)
+
+	buf := bufferPool.Get()
+	defer buf.Free()
+	appendJsonStrings(buf, section.env)
+	section.json = buf.String()
+
+	return section
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaler, keys are written in sorted order.
+func (section *renderedEnvSection) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for _, k := range section.keys {
+		enc.AddString(k, section.env[k])
+	}
+
+	return nil
+}
+
+// Returns cached sections of factory which created event, nil would be returned if view is not an event.
+func renderedSectionsOf(view EventView) *renderedSections {
+	switch v := view.(type) {
+	case *eventZap:
+		return v.sections
+	case *eventSnapshot:
+		return v.sections
+	case *redactedView:
+		return renderedSectionsOf(v.EventView)
+	case *sampledView:
+		return renderedSectionsOf(v.EventView)
+	}
+
+	return nil
+}
+
+// Returns rendered service section of event, cached section would be reused if service of event is not changed.
+func (sections *renderedSections) renderedService(view EventView) *renderedServiceSection {
+	if sections == nil {
+		return newRenderedServiceSection(view)
+	}
+
+	if last, ok := sections.service.Load().(*renderedServiceSection); ok &&
+		last.serviceName == view.GetServiceName() &&
+		last.serviceVersion == view.GetServiceVersion() &&
+		last.entryName == view.GetEntryName() &&
+		last.entryKind == view.GetEntryKind() {
+		return last
+	}
+
+	rendered := newRenderedServiceSection(view)
+	sections.service.Store(rendered)
+
+	return rendered
+}
+
+// Returns rendered env section, env of factory would be reused if env is not customized.
+func (sections *renderedSections) renderedEnv(env map[string]string) *renderedEnvSection {
+	if sections != nil && equalStrings(sections.env.env, env) {
+		return sections.env
+	}
+
+	return newRenderedEnvSection(env)
+}
+
+// Returns true if maps contain the same keys and values.
+func equalStrings(left, right map[string]string) bool {
+	if len(left) != len(right) {
+		return false
+	}
+
+	for k, v := range left {
+		if other, ok := right[k]; !ok || other != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ************* Sections of event *************
+
+// Returns marshaler of ids, error, counters, pairs or timing section of view.
+// Sections of event are marshaled directly from event without copying them into maps.
+func sectionMarshalerOf(view EventView, key string) zapcore.ObjectMarshaler {
+	event, ok := view.(*eventZap)
+
+	switch key {
+	case idsKey:
+		if ok {
+			return (*idsMarshaler)(event)
+		}
+		return stringsMarshaler(idsOf(view))
+	case errKey:
+		if ok {
+			return (*fieldsMarshaler)(event.errors)
+		}
+		return intsMarshaler(view.ListErrors())
+	case countersKey:
+		if ok {
+			return (*fieldsMarshaler)(event.counters)
+		}
+		return intsMarshaler(view.ListCounters())
+	case pairsKey:
+		if ok {
+			return (*fieldsMarshaler)(event.pairs)
+		}
+		return stringsMarshaler(view.ListPairs())
+	case timingKey:
+		if ok {
+			return (*timingsMarshaler)(event)
+		}
+		return intsMarshaler(view.ListTimings())
+	}
+
+	return nil
+}
+
+// Returns payloads field of view, plain payloads of event are marshaled directly without copying them into map.
+func payloadsFieldOf(key string, view EventView) zap.Field {
+	if event, ok := view.(*eventZap); ok && isPlainPayloads(event.payloads) {
+		return zap.Object(key, (*payloadsMarshaler)(event))
+	}
+
+	return zap.Any(key, payloadsOf(view))
+}
+
+// Append payloads, error, counters, pairs or timing section of view as JSON object with sorted keys.
+// Sections of event are written directly from event without copying them into maps.
+func appendEventSection(buf *buffer.Buffer, view EventView, key string) {
+	event, ok := view.(*eventZap)
+
+	switch key {
+	case payloadsKey:
+		if payloads := view.ListPayloads(); isPlainPayloads(payloads) {
+			appendJsonPayloads(buf, payloads)
+			return
+		}
+		appendSection(buf, payloadsOf(view))
+	case errKey:
+		if ok {
+			appendJsonObject(buf, event.errors.Fields)
+			return
+		}
+		appendJsonInts(buf, view.ListErrors())
+	case countersKey:
+		if ok {
+			appendJsonObject(buf, event.counters.Fields)
+			return
+		}
+		appendJsonInts(buf, view.ListCounters())
+	case pairsKey:
+		if ok {
+			appendJsonObject(buf, event.pairs.Fields)
+			return
+		}
+		appendJsonStrings(buf, view.ListPairs())
+	case timingKey:
+		if ok {
+			appendJsonTimings(buf, event)
+			return
+		}
+		appendJsonInts(buf, view.ListTimings())
+	}
+}
+
+// Returns true if any error was added into view.
+func hasErrors(view EventView) bool {
+	if event, ok := view.(*eventZap); ok {
+		return len(event.errors.Fields) > 0
+	}
+
+	return len(view.ListErrors()) > 0
+}
+
+// Append timings of event as JSON object with sorted keys.
+func appendJsonTimings(buf *buffer.Buffer, event *eventZap) {
+	timings := timingsOf(event)
+	defer putTimings(timings)
+
+	buf.AppendByte('{')
+	for i := range timings.timings {
+		if i > 0 {
+			buf.AppendByte(',')
+		}
+		appendJsonString(buf, timings.timings[i].key)
+		buf.AppendByte(':')
+		buf.AppendInt(timings.timings[i].value)
+	}
+	buf.AppendByte('}')
+}
+
+// Returns true if payloads are all plain values which could be written without zapcore.MapObjectEncoder.
+func isPlainPayloads(payloads []zap.Field) bool {
+	for i := range payloads {
+		switch payloads[i].Type {
+		case zapcore.StringType, zapcore.BoolType,
+			zapcore.Int64Type, zapcore.Int32Type, zapcore.Int16Type, zapcore.Int8Type,
+			zapcore.Uint64Type, zapcore.Uint32Type, zapcore.Uint16Type, zapcore.Uint8Type:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// Append plain payloads as JSON object with sorted keys.
+// The last one of payloads with the same key is written, which is the same as payloadsOf().
+func appendJsonPayloads(buf *buffer.Buffer, payloads []zap.Field) {
+	sorted := sortedPayloadsOf(payloads)
+	defer putPayloads(sorted)
+
+	buf.AppendByte('{')
+	first := true
+	for i, field := range sorted.fields {
+		if sorted.overridden(i) {
+			continue
+		}
+		if !first {
+			buf.AppendByte(',')
+		}
+		first = false
+
+		appendJsonString(buf, field.Key)
+		buf.AppendByte(':')
+		switch field.Type {
+		case zapcore.StringType:
+			appendJsonString(buf, field.String)
+		case zapcore.BoolType:
+			buf.AppendBool(field.Integer == 1)
+		case zapcore.Uint64Type, zapcore.Uint32Type, zapcore.Uint16Type, zapcore.Uint8Type:
+			buf.AppendUint(uint64(field.Integer))
+		default:
+			buf.AppendInt(field.Integer)
+		}
+	}
+	buf.AppendByte('}')
+}
+
+// Payloads sorted by key, payloads with the same key are kept in order.
+type sortedPayloads struct {
+	fields []*zap.Field
+}
+
+// Len implements sort.Interface.
+func (p *sortedPayloads) Len() int {
+	return len(p.fields)
+}
+
+// Less implements sort.Interface.
+func (p *sortedPayloads) Less(i, j int) bool {
+	return p.fields[i].Key < p.fields[j].Key
+}
+
+// Swap implements sort.Interface.
+func (p *sortedPayloads) Swap(i, j int) {
+	p.fields[i], p.fields[j] = p.fields[j], p.fields[i]
+}
+
+// Returns true if payload at index i is overridden by the next one with the same key.
+func (p *sortedPayloads) overridden(i int) bool {
+	return i+1 < len(p.fields) && p.fields[i+1].Key == p.fields[i].Key
+}
+
+// Payloads shared by encoders in order to sort payloads of event without allocation.
+var payloadsPool = sync.Pool{
+	New: func() interface{} {
+		return &sortedPayloads{fields: make([]*zap.Field, 0, 8)}
+	},
+}
+
+// Returns payloads sorted by key, payloads should be returned with putPayloads() after use.
+func sortedPayloadsOf(payloads []zap.Field) *sortedPayloads {
+	res := payloadsPool.Get().(*sortedPayloads)
+	for i := range payloads {
+		res.fields = append(res.fields, &payloads[i])
+	}
+	sort.Stable(res)
+
+	return res
+}
+
+// Return payloads into pool.
+func putPayloads(payloads *sortedPayloads) {
+	for i := range payloads.fields {
+		payloads.fields[i] = nil
+	}
+	payloads.fields = payloads.fields[:0]
+	payloadsPool.Put(payloads)
+}
+
+// Key and value in timing section.
+type timing struct {
+	key   string
+	value int64
+}
+
+// Timings sorted by key.
+type sortedTimings struct {
+	timings []timing
+}
+
+// Len implements sort.Interface.
+func (t *sortedTimings) Len() int {
+	return len(t.timings)
+}
+
+// Less implements sort.Interface.
+func (t *sortedTimings) Less(i, j int) bool {
+	return t.timings[i].key < t.timings[j].key
+}
+
+// Swap implements sort.Interface.
+func (t *sortedTimings) Swap(i, j int) {
+	t.timings[i], t.timings[j] = t.timings[j], t.timings[i]
+}
+
+// Timings shared by encoders in order to sort timings of event without allocation.
+var timingsPool = sync.Pool{
+	New: func() interface{} {
+		return &sortedTimings{timings: make([]timing, 0, 8)}
+	},
+}
+
+// Returns timings of event sorted by key, timings should be returned with putTimings() after use.
+func timingsOf(event *eventZap) *sortedTimings {
+	res := timingsPool.Get().(*sortedTimings)
+	for _, tracker := range event.tracker {
+		res.timings = tracker.appendTimings(res.timings)
+	}
+	sort.Sort(res)
+
+	return res
+}
+
+// Return timings into pool.
+func putTimings(timings *sortedTimings) {
+	for i := range timings.timings {
+		timings.timings[i] = timing{}
+	}
+	timings.timings = timings.timings[:0]
+	timingsPool.Put(timings)
+}
+
+// ************* zap ObjectMarshaler *************
+
+// Writes map[string]string with sorted keys into zap encoder.
+type stringsMarshaler map[string]string
+
+// MarshalLogObject implements zapcore.ObjectMarshaler.
+func (m stringsMarshaler) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	keys := keysPool.Get().(*[]string)
+	defer putKeys(keys)
+	for k := range m {
+		*keys = append(*keys, k)
+	}
+	sort.Strings(*keys)
+
+	for _, k := range *keys {
+		enc.AddString(k, m[k])
+	}
+
+	return nil
+}
+
+// Writes map[string]int64 with sorted keys into zap encoder.
+type intsMarshaler map[string]int64
+
+// MarshalLogObject implements zapcore.ObjectMarshaler.
+func (m intsMarshaler) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	keys := keysPool.Get().(*[]string)
+	defer putKeys(keys)
+	for k := range m {
+		*keys = append(*keys, k)
+	}
+	sort.Strings(*keys)
+
+	for _, k := range *keys {
+		enc.AddInt64(k, m[k])
+	}
+
+	return nil
+}
+
+// Writes errors, counters or pairs of event with sorted keys into zap encoder without copying them into map.
+type fieldsMarshaler zapcore.MapObjectEncoder
+
+// MarshalLogObject implements zapcore.ObjectMarshaler.
+func (m *fieldsMarshaler) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	keys := keysPool.Get().(*[]string)
+	defer putKeys(keys)
+	for k := range m.Fields {
+		*keys = append(*keys, k)
+	}
+	sort.Strings(*keys)
+
+	for _, k := range *keys {
+		switch v := m.Fields[k].(type) {
+		case string:
+			enc.AddString(k, v)
+		case int64:
+			enc.AddInt64(k, v)
+		default:
+			if err := enc.AddReflected(k, v); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Writes timings of event with sorted keys into zap encoder.
+type timingsMarshaler eventZap
+
+// MarshalLogObject implements zapcore.ObjectMarshaler.
+func (m *timingsMarshaler) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	timings := timingsOf((*eventZap)(m))
+	defer putTimings(timings)
+
+	for i := range timings.timings {
+		enc.AddInt64(timings.timings[i].key, timings.timings[i].value)
+	}
+
+	return nil
+}
+
+// Writes non empty ids of event with sorted keys into zap encoder.
+type idsMarshaler eventZap
+
+// MarshalLogObject implements zapcore.ObjectMarshaler.
+func (m *idsMarshaler) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for _, id := range [...][2]string{
+		{eventIdKey, m.eventId},
+		{parentEventIdKey, m.parentEventId},
+		{requestIdKey, m.requestId},
+		{traceIdKey, m.traceId},
+	} {
+		if len(id[1]) > 0 {
+			enc.AddString(id[0], id[1])
+		}
+	}
+
+	return nil
+}
+
+// Writes plain payloads of event with sorted keys into zap encoder, see isPlainPayloads().
+type payloadsMarshaler eventZap
+
+// MarshalLogObject implements zapcore.ObjectMarshaler.
+func (m *payloadsMarshaler) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	sorted := sortedPayloadsOf(m.payloads)
+	defer putPayloads(sorted)
+
+	for i, field := range sorted.fields {
+		if !sorted.overridden(i) {
+			field.AddTo(enc)
+		}
+	}
+
+	return nil
+}
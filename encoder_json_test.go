@@ -0,0 +1,174 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestAppendJsonString_SameAsEncodingJson(t *testing.T) {
+	for _, str := range []string{
+		"",
+		"plain",
+		"quote\" and \\backslash",
+		"<html> & </html>",
+		"control\n\r\t\x00\x1f",
+		"line\u2028separator\u2029",
+		"invalid\xffutf8\xc3",
+		"unicode \u4e2d\u6587",
+	} {
+		buf := bufferPool.Get()
+		appendJsonString(buf, str)
+
+		expected, _ := json.Marshal(str)
+		assert.Equal(t, string(expected), buf.String(), str)
+		buf.Free()
+	}
+}
+
+func TestAppendJsonFloat_SameAsEncodingJson(t *testing.T) {
+	for _, f := range []float64{0, 1, -1.5, 1e-7, 1e-9, 1e20, 1e21, 123456.789, math.MaxFloat64} {
+		buf := bufferPool.Get()
+		assert.True(t, appendJsonFloat(buf, f))
+
+		expected, _ := json.Marshal(f)
+		assert.Equal(t, string(expected), buf.String())
+		buf.Free()
+	}
+
+	buf := bufferPool.Get()
+	defer buf.Free()
+	assert.False(t, appendJsonFloat(buf, math.NaN()))
+	assert.False(t, appendJsonFloat(buf, math.Inf(1)))
+}
+
+func TestAppendSection_SameAsEncodingJson(t *testing.T) {
+	sections := []interface{}{
+		map[string]string{"b": "<v>", "a": "v\n"},
+		map[string]int64{"b": 1, "a": -2},
+		map[string]interface{}{
+			"str":      "v",
+			"int":      int64(1),
+			"float":    1.5,
+			"bool":     true,
+			"nil":      nil,
+			"duration": time.Second,
+			"time":     time.Date(2021, 6, 13, 8, 0, 0, 1, time.UTC),
+			"slice":    []interface{}{"a", 1},
+			"nested":   map[string]interface{}{"b": "c"},
+		},
+	}
+
+	for i := range sections {
+		buf := bufferPool.Get()
+		appendSection(buf, sections[i])
+
+		expected, _ := json.Marshal(sections[i])
+		assert.Equal(t, string(expected), buf.String())
+		buf.Free()
+	}
+}
+
+func TestAppendSection_WithUnsupportedValue(t *testing.T) {
+	buf := bufferPool.Get()
+	defer buf.Free()
+
+	buf.AppendString("payloads=")
+	appendSection(buf, map[string]interface{}{"a": "v", "b": math.NaN()})
+	assert.Equal(t, "payloads={}", buf.String())
+}
+
+func TestStringsMarshaler_SortedKeys(t *testing.T) {
+	enc := zapcore.NewJSONEncoder(zapcore.EncoderConfig{})
+	buf, err := enc.EncodeEntry(zapcore.Entry{}, []zap.Field{
+		zap.Object("strings", stringsMarshaler{"b": "2", "a": "1"}),
+		zap.Object("ints", intsMarshaler{"b": 2, "a": 1}),
+	})
+	assert.Nil(t, err)
+	defer buf.Free()
+
+	assert.Equal(t, `{"strings":{"a":"1","b":"2"},"ints":{"a":1,"b":2}}`+"\n", buf.String())
+}
+
+func TestRenderedEnv_WithCustomEnv(t *testing.T) {
+	event := NewEventFactory().CreateEvent().(*eventZap)
+
+	// env of machine is rendered once by factory
+	assert.Same(t, event.sections.env, event.sections.renderedEnv(event.ListEnv()))
+	assert.Equal(t, event.sections.env.env, event.ListEnv())
+
+	rendered := event.sections.renderedEnv(map[string]string{"region": "r1"})
+	assert.Equal(t, `{"region":"r1"}`, rendered.json)
+	assert.NotSame(t, event.sections.env, rendered)
+
+	// not cached without sections
+	var none *renderedSections
+	assert.Equal(t, `{"region":"r1"}`, none.renderedEnv(map[string]string{"region": "r1"}).json)
+}
+
+func TestEncodeJson_WithSectionsOfEvent(t *testing.T) {
+	event := NewEventFactory(WithServiceName("ut-service")).CreateEvent().(*eventZap)
+	event.SetStartTime(time.Now())
+	event.SetTraceId("ut-trace")
+	event.AddPayloads(zap.String("b", "v"), zap.Int("a", -1), zap.Bool("c", true), zap.Uint("d", 2), zap.String("b", "w"))
+	event.AddPair("b", "2")
+	event.AddPair("a", "<1>")
+	event.SetCounter("ut-counter", 1)
+	event.AddErr(errors.New("ut-err"))
+	event.StartTimer("ut-timer.b")
+	event.EndTimer("ut-timer.b")
+	event.UpdateTimerMs("ut-timer", 5)
+	event.StartTimer("ut-open")
+	event.SetEndTime(time.Now())
+
+	// sections written directly from event are the same as the ones copied into maps
+	for _, ec := range []Encoding{CONSOLE, JSON} {
+		expected := encodeToLine(encoderOf(ec), recordOf(event))
+		actual := encodeToLine(encoderOf(ec), event)
+		assert.Equal(t, expected, actual, ec.String())
+		assert.Contains(t, actual, "ut-open-open-1", ec.String())
+		assert.Contains(t, actual, `"a":-1,"b":"w","c":true,"d":2`, ec.String())
+	}
+}
+
+func TestRenderedSections_WithMultipleFactories(t *testing.T) {
+	first := NewEventFactory(WithServiceName("ut-first")).CreateEvent().(*eventZap)
+	second := NewEventFactory(WithServiceName("ut-second")).CreateEventPooled().(*eventZap)
+	assert.NotSame(t, first.sections, second.sections)
+
+	for i := 0; i < 2; i++ {
+		assert.Contains(t, renderedSectionsOf(first).renderedService(first).json, "ut-first")
+		assert.Contains(t, renderedSectionsOf(second).renderedService(second).json, "ut-second")
+	}
+
+	// factories do not evict each other
+	assert.Contains(t, first.sections.service.Load().(*renderedServiceSection).json, "ut-first")
+	assert.Contains(t, second.sections.service.Load().(*renderedServiceSection).json, "ut-second")
+
+	// sections are shared with children and wrapped views
+	child := first.StartChild("ut-child").(*eventZap)
+	assert.Same(t, first.sections, child.sections)
+	assert.Same(t, first.sections, renderedSectionsOf(sampledViewOf(first, SamplingDecision{Sampled: true})))
+	assert.Same(t, first.sections, renderedSectionsOf(snapshotOf(first)))
+}
+
+// Encode view into a line like built-in sinks.
+func encodeToLine(encoder Encoder, view EventView) string {
+	buf := bufferPool.Get()
+	defer buf.Free()
+
+	msg, fields := encoder.Encode(view)
+	appendLine(buf, msg, fields)
+
+	return buf.String()
+}
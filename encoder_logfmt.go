@@ -0,0 +1,176 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"encoding/json"
+	"fmt"
+	"go.uber.org/zap"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	// Suffixes of error.N.message and error.N.count.
+	logfmtErrMessageSuffix = ".message"
+	logfmtErrCountSuffix   = ".count"
+)
+
+// Encode event into LOGFMT format.
+//
+// Event is written in one line of key=value pairs, keys in sections are flattened with dot like
+// ids.eventId, env.hostname, payloads.request.id, timing.t1.elapsedMs and pairs.key.
+// Errors are written as error.0.message and error.0.count, so that messages are kept as values.
+// Characters which are not allowed in logfmt keys are replaced with underscore.
+func encodeLogfmt(view EventView) (string, []zap.Field) {
+	builder := &strings.Builder{}
+
+	// We would expect bellow format of event data as LOGFMT format.
+	// endTime=2021-06-13T00:24:20.256315+08:00 startTime=2021-06-13T00:24:19.251056+08:00 elapsedNano=1005258286
+	// timezone=CST ids.eventId=6a2f84a8-a09a-42dc-bc9e-cabc7977345d service.serviceName=serviceName ...
+	// env.hostname=lark.local payloads.f1=f2 error.0.message="my error" error.0.count=1 counters.count=1 pairs.key=value
+	// timing.t1.count=1 timing.t1.elapsedMs=1005 remoteAddr=localhost operation=op resCode=200 eventStatus=Ended
+
+	// ************* Time *************
+	writeLogfmt(builder, endTimeKey, view.GetEndTime().Format(time.RFC3339Nano))
+	writeLogfmt(builder, startTimeKey, view.GetStartTime().Format(time.RFC3339Nano))
+	writeLogfmt(builder, elapsedKey, strconv.FormatInt(view.GetEndTime().Sub(view.GetStartTime()).Nanoseconds(), 10))
+	writeLogfmt(builder, timezoneKey, view.GetTimeZone())
+
+	// ************* Sections *************
+	writeLogfmtStrings(builder, idsKey, idsOf(view))
+	writeLogfmtStrings(builder, serviceKey, serviceOf(view))
+	writeLogfmtStrings(builder, envKey, view.ListEnv())
+	writeLogfmtObject(builder, payloadsKey, payloadsOf(view))
+	writeLogfmtErrors(builder, view.ListErrors())
+	writeLogfmtInts(builder, countersKey, view.ListCounters())
+	writeLogfmtStrings(builder, pairsKey, view.ListPairs())
+	writeLogfmtInts(builder, timingKey, view.ListTimings())
+
+	// ************* Event *************
+	writeLogfmt(builder, remoteAddrKey, view.GetRemoteAddr())
+	writeLogfmt(builder, operationKey, view.GetOperation())
+	if len(view.GetResCode()) > 0 {
+		writeLogfmt(builder, resCodeKey, view.GetResCode())
+	}
+	writeLogfmt(builder, eventStatusKey, view.GetEventStatus().String())
+
+	return builder.String(), nil
+}
+
+// Write key=value pair, value will be quoted if needed.
+func writeLogfmt(builder *strings.Builder, key, value string) {
+	if builder.Len() > 0 {
+		builder.WriteByte(' ')
+	}
+
+	builder.WriteString(toLogfmtKey(key))
+	builder.WriteByte('=')
+
+	if needLogfmtQuote(value) {
+		builder.WriteString(strconv.Quote(value))
+	} else {
+		builder.WriteString(value)
+	}
+}
+
+// Write string values in section sorted by key.
+func writeLogfmtStrings(builder *strings.Builder, section string, m map[string]string) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		writeLogfmt(builder, section+"."+k, m[k])
+	}
+}
+
+// Write int values in section sorted by key.
+func writeLogfmtInts(builder *strings.Builder, section string, m map[string]int64) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		writeLogfmt(builder, section+"."+k, strconv.FormatInt(m[k], 10))
+	}
+}
+
+// Write errors sorted by message as error.N.message and error.N.count.
+func writeLogfmtErrors(builder *strings.Builder, errs map[string]int64) {
+	keys := make([]string, 0, len(errs))
+	for k := range errs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for i, k := range keys {
+		prefix := errKey + "." + strconv.Itoa(i)
+		writeLogfmt(builder, prefix+logfmtErrMessageSuffix, k)
+		writeLogfmt(builder, prefix+logfmtErrCountSuffix, strconv.FormatInt(errs[k], 10))
+	}
+}
+
+// Write values in section sorted by key, nested objects are flattened with dot.
+func writeLogfmtObject(builder *strings.Builder, section string, m map[string]interface{}) {
+	for _, k := range sortedKeys(m) {
+		key := section + "." + k
+
+		switch v := m[k].(type) {
+		case map[string]interface{}:
+			writeLogfmtObject(builder, key, v)
+		case string:
+			writeLogfmt(builder, key, v)
+		case time.Time:
+			writeLogfmt(builder, key, v.Format(time.RFC3339Nano))
+		case fmt.Stringer, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+			writeLogfmt(builder, key, fmt.Sprint(v))
+		default:
+			bytes, err := json.Marshal(v)
+			if err != nil {
+				writeLogfmt(builder, key, fmt.Sprint(v))
+				continue
+			}
+			writeLogfmt(builder, key, string(bytes))
+		}
+	}
+}
+
+// Replace characters which are not allowed in key with underscore.
+func toLogfmtKey(key string) string {
+	if len(key) < 1 {
+		return "_"
+	}
+
+	return strings.Map(func(r rune) rune {
+		if r == '=' || r == '"' || unicode.IsSpace(r) || !unicode.IsPrint(r) {
+			return '_'
+		}
+		return r
+	}, key)
+}
+
+// Value should be quoted if it is empty or contains space, equal sign, quote or control characters.
+func needLogfmtQuote(value string) bool {
+	if len(value) < 1 {
+		return true
+	}
+
+	for _, r := range value {
+		if r == '=' || r == '"' || r == '\\' || unicode.IsSpace(r) || !unicode.IsPrint(r) {
+			return true
+		}
+	}
+
+	return false
+}
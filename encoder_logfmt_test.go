@@ -0,0 +1,57 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncodeLogfmt_HappyCase(t *testing.T) {
+	record := newRecord()
+	record.StartTime = time.Date(2021, 6, 13, 0, 0, 0, 0, time.UTC)
+	record.EndTime = record.StartTime.Add(time.Second)
+	record.EventId = "ut-event"
+	record.Env["hostname"] = "ut-host"
+	record.Payloads["msg"] = `say "hi"`
+	record.Payloads["nested"] = map[string]interface{}{"id": int64(1)}
+	record.Payloads["list"] = []interface{}{"a", "b"}
+	record.Payloads["empty"] = ""
+	record.Errors["my error"] = 2
+	record.Pairs["key"] = "a=b"
+	record.Timing["t1.elapsedMs"] = 5
+	record.Operation = "ut-op"
+	record.EventStatus = Ended.String()
+
+	msg, fields := encodeLogfmt(record)
+	assert.Nil(t, fields)
+	assert.NotContains(t, msg, "\n")
+	assert.True(t, strings.HasPrefix(msg, "endTime=2021-06-13T00:00:01Z startTime=2021-06-13T00:00:00Z elapsedNano=1000000000 "))
+	assert.Contains(t, msg, " ids.eventId=ut-event ")
+	assert.Contains(t, msg, " service.entryKind=\"\" ")
+	assert.Contains(t, msg, " env.hostname=ut-host ")
+	assert.Contains(t, msg, ` payloads.empty="" payloads.list="[\"a\",\"b\"]" payloads.msg="say \"hi\"" payloads.nested.id=1 `)
+	assert.Contains(t, msg, ` error.0.message="my error" error.0.count=2 `)
+	assert.Contains(t, msg, ` pairs.key="a=b" `)
+	assert.Contains(t, msg, " timing.t1.elapsedMs=5 ")
+	assert.True(t, strings.HasSuffix(msg, " operation=ut-op eventStatus=Ended"))
+}
+
+func TestToLogfmtKey(t *testing.T) {
+	assert.Equal(t, "_", toLogfmtKey(""))
+	assert.Equal(t, "a_b_c_d", toLogfmtKey("a b=c\"d"))
+	assert.Equal(t, "a.b-c", toLogfmtKey("a.b-c"))
+}
+
+func TestNeedLogfmtQuote(t *testing.T) {
+	assert.True(t, needLogfmtQuote(""))
+	assert.True(t, needLogfmtQuote("a b"))
+	assert.True(t, needLogfmtQuote("a\nb"))
+	assert.True(t, needLogfmtQuote(`a\b`))
+	assert.False(t, needLogfmtQuote("/v1/a?b"))
+}
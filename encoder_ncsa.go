@@ -0,0 +1,181 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"github.com/spf13/cast"
+	"go.uber.org/zap"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	ncsaTimeLayout = "02/Jan/2006:15:04:05 -0700"
+	ncsaEmpty      = "-"
+
+	// payload keys of HTTP request
+	ncsaMethodKey    = "apiMethod"
+	ncsaPathKey      = "apiPath"
+	ncsaQueryKey     = "apiQuery"
+	ncsaProtocolKey  = "apiProtocol"
+	ncsaUserAgentKey = "userAgent"
+	ncsaRefererKey   = "referer"
+	ncsaUserKey      = "user"
+	ncsaResSizeKey   = "resSize"
+)
+
+// NcsaOption will be passed into NewNcsaEncoder to append optional fields.
+type NcsaOption func(*ncsaEncoder)
+
+// WithNcsaDuration appends elapsed time in microseconds, same as %D of Apache.
+func WithNcsaDuration() NcsaOption {
+	return func(encoder *ncsaEncoder) {
+		encoder.duration = true
+	}
+}
+
+// WithNcsaRequestId appends request id, - would be written if request id is empty.
+func WithNcsaRequestId() NcsaOption {
+	return func(encoder *ncsaEncoder) {
+		encoder.requestId = true
+	}
+}
+
+// Encodes event in NCSA combined log format.
+type ncsaEncoder struct {
+	duration  bool
+	requestId bool
+}
+
+// NewNcsaEncoder creates an Encoder which writes event in NCSA combined log format as message.
+//
+// host ident authuser [startTime] "method path?query protocol" status bytes "referer" "user-agent"
+//
+// Fields are read from remoteAddr, resCode and payloads of apiMethod, apiPath, apiQuery, apiProtocol, resSize,
+// referer, userAgent and user. Operation is used as path if apiPath is missing. Missing fields and resCode
+// which is not a HTTP status code are written as -.
+func NewNcsaEncoder(opts ...NcsaOption) Encoder {
+	encoder := &ncsaEncoder{}
+	for i := range opts {
+		opts[i](encoder)
+	}
+
+	return encoder
+}
+
+// Encode returns event in NCSA combined log format.
+func (encoder *ncsaEncoder) Encode(view EventView) (string, []zap.Field) {
+	payloads := payloadsOf(view)
+	builder := &strings.Builder{}
+
+	// host ident authuser
+	builder.WriteString(ncsaHost(view.GetRemoteAddr()))
+	builder.WriteString(" - ")
+	builder.WriteString(ncsaToken(ncsaPayload(payloads, ncsaUserKey)))
+
+	// [time]
+	builder.WriteString(" [")
+	builder.WriteString(view.GetStartTime().Format(ncsaTimeLayout))
+	builder.WriteString("] ")
+
+	// "request"
+	path := ncsaPayload(payloads, ncsaPathKey)
+	if len(path) < 1 {
+		path = view.GetOperation()
+	}
+	if query := ncsaPayload(payloads, ncsaQueryKey); len(query) > 0 {
+		path += "?" + strings.TrimPrefix(query, "?")
+	}
+	// request line is split by spaces, so that it should always have three tokens
+	writeNcsaQuoted(builder, strings.Join([]string{
+		ncsaToken(strings.ReplaceAll(ncsaPayload(payloads, ncsaMethodKey), " ", "")),
+		ncsaToken(strings.ReplaceAll(path, " ", "%20")),
+		ncsaToken(strings.ReplaceAll(ncsaPayload(payloads, ncsaProtocolKey), " ", "")),
+	}, " "))
+
+	// status
+	builder.WriteByte(' ')
+	if code, ok := httpStatusCode(view.GetResCode()); ok {
+		builder.WriteString(strconv.Itoa(code))
+	} else {
+		builder.WriteString(ncsaEmpty)
+	}
+
+	// bytes
+	builder.WriteByte(' ')
+	if size, err := cast.ToInt64E(payloads[ncsaResSizeKey]); err == nil && size > 0 {
+		builder.WriteString(strconv.FormatInt(size, 10))
+	} else {
+		builder.WriteString(ncsaEmpty)
+	}
+
+	// "referer" "user-agent"
+	builder.WriteByte(' ')
+	writeNcsaQuoted(builder, ncsaToken(ncsaPayload(payloads, ncsaRefererKey)))
+	builder.WriteByte(' ')
+	writeNcsaQuoted(builder, ncsaToken(ncsaPayload(payloads, ncsaUserAgentKey)))
+
+	// optional fields
+	if encoder.duration {
+		builder.WriteByte(' ')
+		builder.WriteString(strconv.FormatInt(view.GetEndTime().Sub(view.GetStartTime()).Microseconds(), 10))
+	}
+
+	if encoder.requestId {
+		builder.WriteByte(' ')
+		builder.WriteString(ncsaToken(strings.ReplaceAll(view.GetRequestId(), " ", "")))
+	}
+
+	return builder.String(), nil
+}
+
+// Returns host of remote address without port.
+func ncsaHost(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+
+	return ncsaToken(strings.ReplaceAll(addr, " ", ""))
+}
+
+// Returns string value of payload.
+func ncsaPayload(payloads map[string]interface{}, key string) string {
+	if val, ok := payloads[key]; ok && val != nil {
+		return cast.ToString(val)
+	}
+
+	return ""
+}
+
+// Returns - if str is empty.
+func ncsaToken(str string) string {
+	if len(str) < 1 {
+		return ncsaEmpty
+	}
+
+	return str
+}
+
+// Write quoted string, quote, backslash and control characters are escaped like Apache does.
+func writeNcsaQuoted(builder *strings.Builder, str string) {
+	builder.WriteByte('"')
+	for i := 0; i < len(str); i++ {
+		c := str[i]
+		switch {
+		case c == '"' || c == '\\':
+			builder.WriteByte('\\')
+			builder.WriteByte(c)
+		case c < 0x20 || c == 0x7f:
+			builder.WriteString(`\x`)
+			builder.WriteString(strconv.FormatInt(int64(c)>>4, 16))
+			builder.WriteString(strconv.FormatInt(int64(c)&0xf, 16))
+		default:
+			builder.WriteByte(c)
+		}
+	}
+	builder.WriteByte('"')
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Create a HTTP record.
+func newNcsaRecord() *Record {
+	record := newRecord()
+	record.StartTime = time.Date(2021, 6, 13, 10, 20, 30, 0, time.FixedZone("CST", 8*3600))
+	record.EndTime = record.StartTime.Add(1500 * time.Microsecond)
+	record.RemoteAddr = "10.0.0.1:1949"
+	record.RequestId = "ut-request"
+	record.Operation = "/v1/a"
+	record.ResCode = "200"
+	record.Payloads[ncsaMethodKey] = "GET"
+	record.Payloads[ncsaPathKey] = "/v1/a"
+	record.Payloads[ncsaQueryKey] = "k=v"
+	record.Payloads[ncsaProtocolKey] = "HTTP/1.1"
+	record.Payloads[ncsaResSizeKey] = int64(1024)
+	record.Payloads[ncsaUserAgentKey] = `curl/7.64.1 "ut"`
+	return record
+}
+
+func TestEncodeNcsa_HappyCase(t *testing.T) {
+	msg, fields := encoderOf(NCSA).Encode(newNcsaRecord())
+	assert.Equal(t, `10.0.0.1 - - [13/Jun/2021:10:20:30 +0800] "GET /v1/a?k=v HTTP/1.1" 200 1024 "-" "curl/7.64.1 \"ut\""`, msg)
+	assert.Empty(t, fields)
+}
+
+func TestEncodeNcsa_WithOptions(t *testing.T) {
+	msg, _ := NewNcsaEncoder(WithNcsaDuration(), WithNcsaRequestId()).Encode(newNcsaRecord())
+	assert.True(t, strings.HasSuffix(msg, `"curl/7.64.1 \"ut\"" 1500 ut-request`))
+
+	record := newNcsaRecord()
+	record.RequestId = ""
+	msg, _ = NewNcsaEncoder(WithNcsaRequestId()).Encode(record)
+	assert.True(t, strings.HasSuffix(msg, " -"))
+}
+
+func TestEncodeNcsa_WithSpacesInRequest(t *testing.T) {
+	record := newNcsaRecord()
+	record.Payloads[ncsaPathKey] = "/x y"
+	record.Payloads[ncsaQueryKey] = "k=a b"
+	record.Payloads[ncsaProtocolKey] = "HTTP/1.1 "
+
+	msg, _ := encoderOf(NCSA).Encode(record)
+	assert.Contains(t, msg, `"GET /x%20y?k=a%20b HTTP/1.1"`)
+
+	// request line has three tokens
+	request := strings.Split(msg, `"`)[1]
+	assert.Len(t, strings.Split(request, " "), 3)
+}
+
+func TestEncodeNcsa_WithNonHttpEvent(t *testing.T) {
+	record := newRecord()
+	record.StartTime = time.Date(2021, 6, 13, 10, 20, 30, 0, time.UTC)
+	record.Operation = "ut-op"
+	record.ResCode = "OK"
+
+	msg, _ := encoderOf(NCSA).Encode(record)
+	assert.Equal(t, `- - - [13/Jun/2021:10:20:30 +0000] "- ut-op -" - - "-" "-"`, msg)
+}
+
+func TestNcsaHost(t *testing.T) {
+	assert.Equal(t, "10.0.0.1", ncsaHost("10.0.0.1:1949"))
+	assert.Equal(t, "::1", ncsaHost("[::1]:1949"))
+	assert.Equal(t, "localhost", ncsaHost("localhost"))
+	assert.Equal(t, "-", ncsaHost(""))
+}
+
+func TestWriteNcsaQuoted(t *testing.T) {
+	builder := &strings.Builder{}
+	writeNcsaQuoted(builder, "a\"b\\c\nd")
+	assert.Equal(t, `"a\"b\\c\x0ad"`, builder.String())
+}
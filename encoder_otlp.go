@@ -0,0 +1,354 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go.uber.org/zap"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	otlpScopeName = "github.com/rookie-ninja/rk-query"
+	// ************* Severity *************
+	otlpSeverityInfo  = 9
+	otlpSeverityWarn  = 13
+	otlpSeverityError = 17
+	// ************* Resource attributes *************
+	otlpServiceNameKey    = "service.name"
+	otlpServiceVersionKey = "service.version"
+	otlpHostNameKey       = "host.name"
+	otlpHostIpKey         = "host.ip"
+	otlpHostArchKey       = "host.arch"
+	otlpOsTypeKey         = "os.type"
+	otlpEntryNameKey      = "rk.entryName"
+	otlpEntryKindKey      = "rk.entryKind"
+	otlpEnvPrefix         = "rk.env."
+	// ************* Log attributes *************
+	otlpEventIdKey    = "rk.eventId"
+	otlpTraceIdKey    = "rk.traceId"
+	otlpRequestIdKey  = "rk.requestId"
+	otlpParentIdKey   = "rk.parentEventId"
+	otlpElapsedKey    = "rk.elapsedNano"
+	otlpTimezoneKey   = "rk.timezone"
+	otlpOperationKey  = "rk.operation"
+	otlpResCodeKey    = "rk.resCode"
+	otlpStatusKey     = "rk.eventStatus"
+	otlpClientAddrKey = "client.address"
+	otlpStatusCodeKey = "http.response.status_code"
+	otlpPayloadsKey   = "rk.payloads"
+	otlpErrorsKey     = "rk.errors"
+	otlpCountersKey   = "rk.counters"
+	otlpPairsKey      = "rk.pairs"
+	otlpTimingKey     = "rk.timing"
+)
+
+var (
+	// gRPC codes caused by client which are mapped to WARN.
+	otlpWarnCodes = map[string]bool{
+		"Canceled":           true,
+		"InvalidArgument":    true,
+		"NotFound":           true,
+		"AlreadyExists":      true,
+		"PermissionDenied":   true,
+		"Unauthenticated":    true,
+		"FailedPrecondition": true,
+		"OutOfRange":         true,
+	}
+)
+
+// ************* OTLP/JSON data model *************
+
+// Logs in OTLP/JSON, same as ExportLogsServiceRequest.
+type otlpLogsData struct {
+	ResourceLogs []*otlpResourceLogs `json:"resourceLogs"`
+}
+
+// Logs of the same resource.
+type otlpResourceLogs struct {
+	Resource  *otlpResource    `json:"resource"`
+	ScopeLogs []*otlpScopeLogs `json:"scopeLogs"`
+}
+
+// Resource which produces logs.
+type otlpResource struct {
+	Attributes []*otlpKeyValue `json:"attributes"`
+}
+
+// Logs of instrumentation scope.
+type otlpScopeLogs struct {
+	Scope      *otlpScope       `json:"scope"`
+	LogRecords []*otlpLogRecord `json:"logRecords"`
+}
+
+// Instrumentation scope.
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+// LogRecord in OTLP/JSON, 64 bits integers are encoded as string and ids are encoded as hex.
+type otlpLogRecord struct {
+	TimeUnixNano         string          `json:"timeUnixNano"`
+	ObservedTimeUnixNano string          `json:"observedTimeUnixNano"`
+	SeverityNumber       int             `json:"severityNumber"`
+	SeverityText         string          `json:"severityText"`
+	Body                 *otlpAnyValue   `json:"body"`
+	Attributes           []*otlpKeyValue `json:"attributes"`
+	TraceId              string          `json:"traceId,omitempty"`
+	SpanId               string          `json:"spanId,omitempty"`
+}
+
+// Attribute.
+type otlpKeyValue struct {
+	Key   string        `json:"key"`
+	Value *otlpAnyValue `json:"value"`
+}
+
+// Value of attribute, only one of fields is set.
+type otlpAnyValue struct {
+	StringValue *string         `json:"stringValue,omitempty"`
+	BoolValue   *bool           `json:"boolValue,omitempty"`
+	IntValue    *string         `json:"intValue,omitempty"`
+	DoubleValue *float64        `json:"doubleValue,omitempty"`
+	ArrayValue  *otlpArrayValue `json:"arrayValue,omitempty"`
+	KvlistValue *otlpKvlist     `json:"kvlistValue,omitempty"`
+}
+
+// Array of values.
+type otlpArrayValue struct {
+	Values []*otlpAnyValue `json:"values"`
+}
+
+// List of attributes.
+type otlpKvlist struct {
+	Values []*otlpKeyValue `json:"values"`
+}
+
+// Encode event into OTLP/JSON.
+//
+// Each event is written as a complete ExportLogsServiceRequest with one LogRecord, so logger should write
+// message only in order to be ingested by otlpjsonfile receiver of OpenTelemetry collector.
+// Use OtlpFileExporter to write events in batch without logger.
+func encodeOtlp(view EventView) (string, []zap.Field) {
+	data := &otlpLogsData{
+		ResourceLogs: []*otlpResourceLogs{{
+			Resource: &otlpResource{Attributes: otlpResourceOf(view)},
+			ScopeLogs: []*otlpScopeLogs{{
+				Scope:      &otlpScope{Name: otlpScopeName},
+				LogRecords: []*otlpLogRecord{otlpLogRecordOf(view)},
+			}},
+		}},
+	}
+
+	bytes, err := json.Marshal(data)
+	if err != nil {
+		return "{}", nil
+	}
+
+	return string(bytes), nil
+}
+
+// Returns resource attributes of event with service and env.
+func otlpResourceOf(view EventView) []*otlpKeyValue {
+	res := []*otlpKeyValue{
+		otlpString(otlpServiceNameKey, view.GetServiceName()),
+		otlpString(otlpServiceVersionKey, view.GetServiceVersion()),
+		otlpString(otlpEntryNameKey, view.GetEntryName()),
+		otlpString(otlpEntryKindKey, view.GetEntryKind()),
+	}
+
+	env := view.ListEnv()
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		switch k {
+		case hostnameKey:
+			res = append(res, otlpString(otlpHostNameKey, env[k]))
+		case localIpKey:
+			res = append(res, otlpString(otlpHostIpKey, env[k]))
+		case goArchKey:
+			res = append(res, otlpString(otlpHostArchKey, env[k]))
+		case goosKey:
+			res = append(res, otlpString(otlpOsTypeKey, env[k]))
+		default:
+			res = append(res, otlpString(otlpEnvPrefix+k, env[k]))
+		}
+	}
+
+	return res
+}
+
+// Returns LogRecord of event.
+func otlpLogRecordOf(view EventView) *otlpLogRecord {
+	severity, text := otlpSeverity(view)
+
+	record := &otlpLogRecord{
+		TimeUnixNano:         otlpTime(view.GetStartTime()),
+		ObservedTimeUnixNano: otlpTime(view.GetEndTime()),
+		SeverityNumber:       severity,
+		SeverityText:         text,
+		Body:                 otlpStringValue(view.GetOperation()),
+		TraceId:              otlpHexId(view.GetTraceId(), 16),
+		SpanId:               spanIdOfEventId(view.GetEventId()),
+	}
+
+	record.Attributes = []*otlpKeyValue{
+		otlpString(otlpEventIdKey, view.GetEventId()),
+		otlpString(otlpTraceIdKey, view.GetTraceId()),
+		otlpString(otlpRequestIdKey, view.GetRequestId()),
+		{Key: otlpElapsedKey, Value: otlpValueOf(view.GetEndTime().Sub(view.GetStartTime()).Nanoseconds())},
+		otlpString(otlpTimezoneKey, view.GetTimeZone()),
+		otlpString(otlpOperationKey, view.GetOperation()),
+		otlpString(otlpClientAddrKey, view.GetRemoteAddr()),
+		otlpString(otlpResCodeKey, view.GetResCode()),
+		otlpString(otlpStatusKey, view.GetEventStatus().String()),
+	}
+
+	if len(view.GetParentEventId()) > 0 {
+		record.Attributes = append(record.Attributes, otlpString(otlpParentIdKey, view.GetParentEventId()))
+	}
+
+	if code, ok := httpStatusCode(view.GetResCode()); ok {
+		record.Attributes = append(record.Attributes, &otlpKeyValue{Key: otlpStatusCodeKey, Value: otlpValueOf(code)})
+	}
+
+	record.Attributes = append(record.Attributes,
+		&otlpKeyValue{Key: otlpPayloadsKey, Value: otlpValueOf(payloadsOf(view))},
+		&otlpKeyValue{Key: otlpErrorsKey, Value: otlpValueOf(view.ListErrors())},
+		&otlpKeyValue{Key: otlpCountersKey, Value: otlpValueOf(view.ListCounters())},
+		&otlpKeyValue{Key: otlpPairsKey, Value: otlpValueOf(view.ListPairs())},
+		&otlpKeyValue{Key: otlpTimingKey, Value: otlpValueOf(view.ListTimings())})
+
+	return record
+}
+
+// Returns severity of event.
+//
+// Event with errors, HTTP status code >= 500 or gRPC code caused by server is ERROR,
+// HTTP status code >= 400 or gRPC code caused by client is WARN, others are INFO.
+func otlpSeverity(view EventView) (int, string) {
+	if len(view.ListErrors()) > 0 {
+		return otlpSeverityError, "ERROR"
+	}
+
+	resCode := view.GetResCode()
+	if code, err := strconv.Atoi(resCode); err == nil {
+		switch {
+		case code >= 500:
+			return otlpSeverityError, "ERROR"
+		case code >= 400:
+			return otlpSeverityWarn, "WARN"
+		}
+		return otlpSeverityInfo, "INFO"
+	}
+
+	switch {
+	case len(resCode) < 1 || strings.EqualFold(resCode, "OK"):
+		return otlpSeverityInfo, "INFO"
+	case otlpWarnCodes[resCode]:
+		return otlpSeverityWarn, "WARN"
+	}
+
+	return otlpSeverityError, "ERROR"
+}
+
+// Returns id as lower case hex if it is a valid hex id with size of bytes, dashes in UUID are ignored.
+// Empty string would be returned if id is invalid or all zero.
+func otlpHexId(id string, size int) string {
+	id = strings.ToLower(strings.ReplaceAll(id, "-", ""))
+	if len(id) != size*2 || strings.Count(id, "0") == len(id) {
+		return ""
+	}
+
+	if _, err := hex.DecodeString(id); err != nil {
+		return ""
+	}
+
+	return id
+}
+
+// Returns unix nano of time as string, zero time is encoded as 0.
+func otlpTime(t time.Time) string {
+	if t.IsZero() {
+		return "0"
+	}
+
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// Create string attribute.
+func otlpString(key, value string) *otlpKeyValue {
+	return &otlpKeyValue{Key: key, Value: otlpStringValue(value)}
+}
+
+// Create string value.
+func otlpStringValue(value string) *otlpAnyValue {
+	return &otlpAnyValue{StringValue: &value}
+}
+
+// Convert value into otlpAnyValue, value which is not supported is encoded as string.
+func otlpValueOf(val interface{}) *otlpAnyValue {
+	switch v := val.(type) {
+	case nil:
+		return &otlpAnyValue{}
+	case string:
+		return otlpStringValue(v)
+	case bool:
+		return &otlpAnyValue{BoolValue: &v}
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		str := fmt.Sprint(v)
+		return &otlpAnyValue{IntValue: &str}
+	case float32:
+		f := float64(v)
+		return &otlpAnyValue{DoubleValue: &f}
+	case float64:
+		return &otlpAnyValue{DoubleValue: &v}
+	case time.Time:
+		return otlpStringValue(v.Format(time.RFC3339Nano))
+	case []interface{}:
+		values := make([]*otlpAnyValue, 0, len(v))
+		for i := range v {
+			values = append(values, otlpValueOf(v[i]))
+		}
+		return &otlpAnyValue{ArrayValue: &otlpArrayValue{Values: values}}
+	case map[string]interface{}:
+		values := make([]*otlpKeyValue, 0, len(v))
+		for _, k := range sortedKeys(v) {
+			values = append(values, &otlpKeyValue{Key: k, Value: otlpValueOf(v[k])})
+		}
+		return &otlpAnyValue{KvlistValue: &otlpKvlist{Values: values}}
+	case map[string]string:
+		m := make(map[string]interface{}, len(v))
+		for k := range v {
+			m[k] = v[k]
+		}
+		return otlpValueOf(m)
+	case map[string]int64:
+		m := make(map[string]interface{}, len(v))
+		for k := range v {
+			m[k] = v[k]
+		}
+		return otlpValueOf(m)
+	case fmt.Stringer:
+		return otlpStringValue(v.String())
+	}
+
+	bytes, err := json.Marshal(val)
+	if err != nil {
+		return otlpStringValue(fmt.Sprint(val))
+	}
+
+	return otlpStringValue(string(bytes))
+}
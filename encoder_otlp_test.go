@@ -0,0 +1,128 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// Encode record into OTLP and decode it as logs data.
+func encodeOtlpData(t *testing.T, record *Record) *otlpLogsData {
+	msg, fields := encodeOtlp(record)
+	assert.Empty(t, fields)
+
+	res := &otlpLogsData{}
+	assert.Nil(t, json.Unmarshal([]byte(msg), res))
+	return res
+}
+
+// Returns value of attribute with key.
+func otlpAttr(attrs []*otlpKeyValue, key string) interface{} {
+	for _, attr := range attrs {
+		if attr.Key == key {
+			return otlpToInterface(attr.Value)
+		}
+	}
+
+	return nil
+}
+
+func TestEncodeOtlp_HappyCase(t *testing.T) {
+	record := newRecord()
+	record.StartTime = time.Unix(0, 1623542400000000000)
+	record.EndTime = record.StartTime.Add(time.Second)
+	record.EventId = "7b1d2e4c-9a3f-4e21-b6c8-5d0e9f1a2b3c"
+	record.TraceId = "0af76519-16cd-43dd-8448-eb211c80319c"
+	record.ServiceName = "ut-service"
+	record.ServiceVersion = "v0.0.1"
+	record.Env = map[string]string{hostnameKey: "ut-host", domainKey: "prod"}
+	record.Payloads["apiPath"] = "/v1/a"
+	record.Counters["hits"] = 2
+	record.Operation = "ut-op"
+	record.ResCode = "200"
+
+	data := encodeOtlpData(t, record)
+	assert.Len(t, data.ResourceLogs, 1)
+
+	resource := data.ResourceLogs[0].Resource.Attributes
+	assert.Equal(t, "ut-service", otlpAttr(resource, otlpServiceNameKey))
+	assert.Equal(t, "v0.0.1", otlpAttr(resource, otlpServiceVersionKey))
+	assert.Equal(t, "ut-host", otlpAttr(resource, otlpHostNameKey))
+	assert.Equal(t, "prod", otlpAttr(resource, otlpEnvPrefix+domainKey))
+
+	scope := data.ResourceLogs[0].ScopeLogs[0]
+	assert.Equal(t, otlpScopeName, scope.Scope.Name)
+	assert.Len(t, scope.LogRecords, 1)
+
+	log := scope.LogRecords[0]
+	assert.Equal(t, "1623542400000000000", log.TimeUnixNano)
+	assert.Equal(t, "1623542401000000000", log.ObservedTimeUnixNano)
+	assert.Equal(t, otlpSeverityInfo, log.SeverityNumber)
+	assert.Equal(t, "INFO", log.SeverityText)
+	assert.Equal(t, "ut-op", otlpToString(log.Body))
+	assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", log.TraceId)
+	assert.Equal(t, "7b1d2e4c9a3f4e21", log.SpanId)
+	assert.Equal(t, int64(time.Second), otlpAttr(log.Attributes, otlpElapsedKey))
+	assert.Equal(t, int64(200), otlpAttr(log.Attributes, otlpStatusCodeKey))
+	assert.Equal(t, map[string]interface{}{"apiPath": "/v1/a"}, otlpAttr(log.Attributes, otlpPayloadsKey))
+	assert.Equal(t, map[string]interface{}{"hits": int64(2)}, otlpAttr(log.Attributes, otlpCountersKey))
+}
+
+func TestEncodeOtlp_WithInvalidIds(t *testing.T) {
+	record := newRecord()
+	record.EventId = "ut-event"
+	record.TraceId = "00000000000000000000000000000000"
+
+	log := encodeOtlpData(t, record).ResourceLogs[0].ScopeLogs[0].LogRecords[0]
+	assert.Empty(t, log.TraceId)
+	assert.Empty(t, log.SpanId)
+	assert.Equal(t, "0", log.TimeUnixNano)
+	// original ids are kept in attributes
+	assert.Equal(t, "ut-event", otlpAttr(log.Attributes, otlpEventIdKey))
+}
+
+func TestOtlpSeverity(t *testing.T) {
+	cases := map[string]string{
+		"":                 "INFO",
+		"OK":               "INFO",
+		"302":              "INFO",
+		"404":              "WARN",
+		"503":              "ERROR",
+		"NotFound":         "WARN",
+		"Internal":         "ERROR",
+		"DeadlineExceeded": "ERROR",
+	}
+
+	for resCode, expected := range cases {
+		record := newRecord()
+		record.ResCode = resCode
+		_, text := otlpSeverity(record)
+		assert.Equal(t, expected, text, resCode)
+	}
+
+	// errors always make it ERROR
+	record := newRecord()
+	record.Errors["ut-err"] = 1
+	number, text := otlpSeverity(record)
+	assert.Equal(t, otlpSeverityError, number)
+	assert.Equal(t, "ERROR", text)
+}
+
+func TestOtlpValueOf(t *testing.T) {
+	bytes, err := json.Marshal(otlpValueOf(int64(1)))
+	assert.Nil(t, err)
+	// int64 is encoded as string in OTLP/JSON
+	assert.Equal(t, `{"intValue":"1"}`, string(bytes))
+
+	assert.Equal(t, true, otlpToInterface(otlpValueOf(true)))
+	assert.Equal(t, 1.5, otlpToInterface(otlpValueOf(float32(1.5))))
+	assert.Equal(t, []interface{}{"a", int64(1)}, otlpToInterface(otlpValueOf([]interface{}{"a", 1})))
+	assert.Equal(t, map[string]interface{}{"k": "v"}, otlpToInterface(otlpValueOf(map[string]string{"k": "v"})))
+	assert.Nil(t, otlpToInterface(otlpValueOf(nil)))
+}
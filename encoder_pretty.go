@@ -0,0 +1,331 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go.uber.org/zap"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	defaultPrettySlowTimer = 500 * time.Millisecond
+	minPrettyWidth         = 20
+	maxPrettyWidth         = 200
+
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiRed    = "\x1b[31m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+	ansiCyan   = "\x1b[36m"
+)
+
+// PrettyConsoleOption will be passed into NewPrettyConsoleEncoder to override default behavior of encoder.
+type PrettyConsoleOption func(*prettyConsoleEncoder)
+
+// WithPrettyWriter enables colors if w is a terminal, default is os.Stdout.
+// It should be the writer of zap logger which event is written to.
+func WithPrettyWriter(w io.Writer) PrettyConsoleOption {
+	return func(encoder *prettyConsoleEncoder) {
+		encoder.writer = w
+	}
+}
+
+// WithPrettyColor forces colors on or off regardless of writer and env variables.
+func WithPrettyColor(enabled bool) PrettyConsoleOption {
+	return func(encoder *prettyConsoleEncoder) {
+		encoder.color = &enabled
+	}
+}
+
+// WithPrettySlowTimer overrides threshold of timers to be highlighted, default is 500ms.
+func WithPrettySlowTimer(threshold time.Duration) PrettyConsoleOption {
+	return func(encoder *prettyConsoleEncoder) {
+		if threshold > 0 {
+			encoder.slowTimer = threshold
+		}
+	}
+}
+
+// WithPrettyWidth overrides width of terminal, default is COLUMNS env variable or 72.
+func WithPrettyWidth(width int) PrettyConsoleOption {
+	return func(encoder *prettyConsoleEncoder) {
+		if width > 0 {
+			encoder.width = width
+		}
+	}
+}
+
+// Encodes event into CONSOLE format for human.
+type prettyConsoleEncoder struct {
+	writer    io.Writer
+	color     *bool
+	slowTimer time.Duration
+	width     int
+	// resolved options
+	colorEnabled bool
+	delimiter    string
+}
+
+// NewPrettyConsoleEncoder creates an Encoder which writes CONSOLE format for terminals.
+//
+// Nested sections are written one key per line, resCode is colored by success or failure,
+// errors and slow timers are highlighted.
+//
+// Colors are resolved in order of WithPrettyColor(), NO_COLOR env variable, FORCE_COLOR env variable,
+// then enabled only if writer is a terminal. The delimiter line fits width of terminal.
+//
+// Output of pretty console encoder could not be read by Reader.
+func NewPrettyConsoleEncoder(opts ...PrettyConsoleOption) Encoder {
+	encoder := &prettyConsoleEncoder{
+		writer:    os.Stdout,
+		slowTimer: defaultPrettySlowTimer,
+	}
+
+	for i := range opts {
+		opts[i](encoder)
+	}
+
+	encoder.colorEnabled = prettyColorEnabled(encoder.color, encoder.writer)
+
+	width := encoder.width
+	if width < 1 {
+		width = prettyWidth()
+	}
+	encoder.delimiter = strings.Repeat("-", width)
+
+	return encoder
+}
+
+// Encode returns event in pretty CONSOLE format.
+func (encoder *prettyConsoleEncoder) Encode(view EventView) (string, []zap.Field) {
+	builder := &bytes.Buffer{}
+	builder.WriteString(encoder.delimiter + "\n")
+
+	elapsed := view.GetEndTime().Sub(view.GetStartTime())
+	encoder.writeValues(builder, "", [][2]string{
+		{endTimeKey, view.GetEndTime().Format(time.RFC3339Nano)},
+		{startTimeKey, view.GetStartTime().Format(time.RFC3339Nano)},
+		{elapsedKey, fmt.Sprintf("%d (%s)", elapsed.Nanoseconds(), elapsed)},
+		{timezoneKey, view.GetTimeZone()},
+	}, nil)
+
+	// ************* Sections *************
+	encoder.writeSection(builder, idsKey, stringsOf(idsOf(view)), "", nil)
+	encoder.writeSection(builder, serviceKey, stringsOf(serviceOf(view)), "", nil)
+	encoder.writeSection(builder, envKey, stringsOf(view.ListEnv()), "", nil)
+	encoder.writeSection(builder, payloadsKey, prettyPayloads(payloadsOf(view)), "", nil)
+	if errs := view.ListErrors(); len(errs) > 0 {
+		encoder.writeSection(builder, errKey, intsOf(errs), ansiRed, nil)
+	}
+	encoder.writeSection(builder, countersKey, intsOf(view.ListCounters()), "", nil)
+	encoder.writeSection(builder, pairsKey, stringsOf(view.ListPairs()), "", nil)
+
+	timings := view.ListTimings()
+	encoder.writeSection(builder, timingKey, intsOf(timings), "", func(k string) string {
+		if strings.HasSuffix(k, "."+timingElapsedMs) &&
+			time.Duration(timings[k])*time.Millisecond >= encoder.slowTimer {
+			return ansiYellow
+		}
+		return ""
+	})
+
+	if children := view.ListChildren(); len(children) > 0 {
+		builder.WriteString(encoder.paint(childrenKey+":", ansiBold))
+		builder.WriteString("\n")
+		encoder.writeChildren(builder, "  ", children)
+	}
+
+	// ************* Event *************
+	values := [][2]string{
+		{remoteAddrKey, view.GetRemoteAddr()},
+		{operationKey, view.GetOperation()},
+	}
+	if len(view.GetResCode()) > 0 {
+		values = append(values, [2]string{resCodeKey, view.GetResCode()})
+	}
+	values = append(values, [2]string{eventStatusKey, view.GetEventStatus().String()})
+
+	encoder.writeValues(builder, "", values, func(k string) string {
+		if k != resCodeKey {
+			return ""
+		}
+
+		if ecsOutcome(view) == ecsOutcomeSuccess {
+			return ansiGreen
+		}
+		return ansiRed
+	})
+
+	builder.WriteString(eoe)
+	return builder.String(), nil
+}
+
+// Write section header and values of section indented, empty section is written as header only.
+func (encoder *prettyConsoleEncoder) writeSection(builder *bytes.Buffer, key string, values [][2]string,
+	color string, colorOf func(string) string) {
+	builder.WriteString(encoder.paint(key+":", ansiBold+color))
+	builder.WriteString("\n")
+
+	if len(color) > 0 {
+		colorOf = func(string) string { return color }
+	}
+
+	encoder.writeValues(builder, "  ", values, colorOf)
+}
+
+// Write values one key per line with keys aligned, color of value is returned by colorOf.
+func (encoder *prettyConsoleEncoder) writeValues(builder *bytes.Buffer, indent string, values [][2]string,
+	colorOf func(string) string) {
+	keyWidth := 0
+	for i := range values {
+		if width := utf8.RuneCountInString(values[i][0]); width > keyWidth {
+			keyWidth = width
+		}
+	}
+
+	for i := range values {
+		key, value := values[i][0], values[i][1]
+		builder.WriteString(indent)
+		builder.WriteString(encoder.paint(key, ansiCyan))
+		builder.WriteString(strings.Repeat(" ", keyWidth-utf8.RuneCountInString(key)))
+		builder.WriteString(" = ")
+
+		color := ""
+		if colorOf != nil {
+			color = colorOf(key)
+		}
+		builder.WriteString(encoder.paint(value, color))
+		builder.WriteString("\n")
+	}
+}
+
+// Write children one per line with elapsed time, resCode and eventId, grandchildren are indented.
+func (encoder *prettyConsoleEncoder) writeChildren(builder *bytes.Buffer, indent string, children []EventView) {
+	for i := range children {
+		child := children[i]
+		summary := child.GetEndTime().Sub(child.GetStartTime()).String()
+		if len(child.GetResCode()) > 0 {
+			summary += " " + child.GetResCode()
+		}
+		summary += " " + child.GetEventId()
+
+		encoder.writeValues(builder, indent, [][2]string{{child.GetOperation(), summary}}, func(string) string {
+			if len(child.ListErrors()) > 0 {
+				return ansiRed
+			}
+			return ""
+		})
+
+		encoder.writeChildren(builder, indent+"  ", child.ListChildren())
+	}
+}
+
+// Wrap str with color if colors are enabled.
+func (encoder *prettyConsoleEncoder) paint(str, color string) string {
+	if !encoder.colorEnabled || len(color) < 1 {
+		return str
+	}
+
+	return color + str + ansiReset
+}
+
+// Returns whether colors should be enabled.
+func prettyColorEnabled(forced *bool, w io.Writer) bool {
+	if forced != nil {
+		return *forced
+	}
+
+	// https://no-color.org
+	if len(os.Getenv("NO_COLOR")) > 0 {
+		return false
+	}
+
+	if force := os.Getenv("FORCE_COLOR"); len(force) > 0 {
+		return force != "0" && !strings.EqualFold(force, "false")
+	}
+
+	return isTerminal(w)
+}
+
+// Returns width of terminal from COLUMNS env variable, length of default delimiter would be returned if missing.
+func prettyWidth() int {
+	width, err := strconv.Atoi(os.Getenv("COLUMNS"))
+	switch {
+	case err != nil || width < 1:
+		return len(scopeDelimiter)
+	case width < minPrettyWidth:
+		return minPrettyWidth
+	case width > maxPrettyWidth:
+		return maxPrettyWidth
+	}
+
+	return width
+}
+
+// Returns whether w is a terminal.
+func isTerminal(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	if !ok || file == nil {
+		return false
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// Returns sorted key and value pairs of payloads, string is written without quotes and others in JSON.
+func prettyPayloads(m map[string]interface{}) [][2]string {
+	res := make([][2]string, 0, len(m))
+	for _, k := range sortedKeys(m) {
+		value, ok := m[k].(string)
+		if !ok {
+			bytes, err := json.Marshal(m[k])
+			if err != nil {
+				bytes = []byte(fmt.Sprint(m[k]))
+			}
+			value = string(bytes)
+		}
+		res = append(res, [2]string{k, value})
+	}
+
+	return res
+}
+
+// Returns sorted key and value pairs.
+func stringsOf(m map[string]string) [][2]string {
+	res := make([][2]string, 0, len(m))
+	for k := range m {
+		res = append(res, [2]string{k, m[k]})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i][0] < res[j][0] })
+
+	return res
+}
+
+// Returns sorted key and value pairs.
+func intsOf(m map[string]int64) [][2]string {
+	res := make([][2]string, 0, len(m))
+	for k := range m {
+		res = append(res, [2]string{k, strconv.FormatInt(m[k], 10)})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i][0] < res[j][0] })
+
+	return res
+}
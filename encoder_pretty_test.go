@@ -0,0 +1,125 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Create a record with sections.
+func newPrettyRecord() *Record {
+	record := newRecord()
+	record.StartTime = time.Date(2021, 6, 13, 0, 0, 0, 0, time.UTC)
+	record.EndTime = record.StartTime.Add(time.Second)
+	record.EventId = "ut-event"
+	record.Payloads["f1"] = "v1"
+	record.Payloads["f2"] = int64(2)
+	record.Errors["ut-err"] = 1
+	record.Timing["fast.elapsedMs"] = 10
+	record.Timing["slow.elapsedMs"] = 800
+	record.Operation = "ut-op"
+	record.ResCode = "200"
+	record.EventStatus = Ended.String()
+	return record
+}
+
+func TestNewPrettyConsoleEncoder_WithoutColor(t *testing.T) {
+	encoder := NewPrettyConsoleEncoder(WithPrettyColor(false), WithPrettyWidth(30))
+
+	msg, fields := encoder.Encode(newPrettyRecord())
+	assert.Empty(t, fields)
+	assert.NotContains(t, msg, "\x1b[")
+
+	lines := strings.Split(msg, "\n")
+	assert.Equal(t, strings.Repeat("-", 30), lines[0])
+	assert.Equal(t, "elapsedNano = 1000000000 (1s)", lines[3])
+	assert.Contains(t, lines, "ids:")
+	assert.Contains(t, lines, "  eventId = ut-event")
+	assert.Contains(t, lines, "payloads:")
+	assert.Contains(t, lines, "  f1 = v1")
+	assert.Contains(t, lines, "  f2 = 2")
+	assert.Contains(t, lines, "  slow.elapsedMs = 800")
+	assert.Contains(t, lines, "resCode     = 200")
+	assert.Equal(t, eoe, lines[len(lines)-1])
+}
+
+func TestNewPrettyConsoleEncoder_WithColor(t *testing.T) {
+	encoder := NewPrettyConsoleEncoder(WithPrettyColor(true), WithPrettySlowTimer(100*time.Millisecond))
+
+	record := newPrettyRecord()
+	msg, _ := encoder.Encode(record)
+	assert.Contains(t, msg, ansiYellow+"800"+ansiReset)
+	assert.NotContains(t, msg, ansiYellow+"10"+ansiReset)
+	assert.Contains(t, msg, ansiRed+"1"+ansiReset)
+	// failed because of error
+	assert.Contains(t, msg, ansiRed+"200"+ansiReset)
+
+	record.Errors = map[string]int64{}
+	msg, _ = encoder.Encode(record)
+	assert.Contains(t, msg, ansiGreen+"200"+ansiReset)
+	assert.NotContains(t, msg, errKey+":")
+}
+
+func TestPrettyColorEnabled(t *testing.T) {
+	enabled := true
+	assert.True(t, prettyColorEnabled(&enabled, &bytes.Buffer{}))
+
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("FORCE_COLOR", "")
+	assert.False(t, prettyColorEnabled(nil, &bytes.Buffer{}))
+
+	t.Setenv("FORCE_COLOR", "1")
+	assert.True(t, prettyColorEnabled(nil, &bytes.Buffer{}))
+
+	t.Setenv("FORCE_COLOR", "0")
+	assert.False(t, prettyColorEnabled(nil, &bytes.Buffer{}))
+
+	// NO_COLOR wins
+	t.Setenv("FORCE_COLOR", "1")
+	t.Setenv("NO_COLOR", "1")
+	assert.False(t, prettyColorEnabled(nil, &bytes.Buffer{}))
+}
+
+func TestPrettyWidth(t *testing.T) {
+	t.Setenv("COLUMNS", "")
+	assert.Equal(t, len(scopeDelimiter), prettyWidth())
+
+	t.Setenv("COLUMNS", "100")
+	assert.Equal(t, 100, prettyWidth())
+
+	t.Setenv("COLUMNS", "1")
+	assert.Equal(t, minPrettyWidth, prettyWidth())
+
+	t.Setenv("COLUMNS", "1000")
+	assert.Equal(t, maxPrettyWidth, prettyWidth())
+}
+
+func TestIsTerminal(t *testing.T) {
+	assert.False(t, isTerminal(&bytes.Buffer{}))
+
+	file, err := os.CreateTemp(t.TempDir(), "ut")
+	assert.Nil(t, err)
+	defer file.Close()
+	assert.False(t, isTerminal(file))
+}
+
+func TestWithPrettyConsole_HappyCase(t *testing.T) {
+	buf := &bytes.Buffer{}
+	event := NewEventFactory(
+		WithZapLogger(newBufferLogger(buf, false)),
+		WithEncoding(JSON),
+		WithPrettyConsole(WithPrettyColor(false))).CreateEvent()
+	event.SetOperation("ut-op")
+	event.Finish()
+
+	assert.Equal(t, CONSOLE, event.(*eventZap).encoding)
+	assert.Contains(t, buf.String(), "operation   = ut-op")
+}
@@ -0,0 +1,204 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go.uber.org/zap"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+)
+
+// DefaultTemplate is the template used by TEMPLATE encoding if no template was provided with WithTemplate().
+const DefaultTemplate = `{{timeFormat "2006-01-02T15:04:05.000Z07:00" .StartTime}} {{.ServiceName}} ` +
+	`{{default "-" .RemoteAddr}} "{{.Operation}}" {{default "-" .ResCode}} {{duration .ElapsedNano}} {{default "-" .TraceId}}`
+
+// TemplateData is the data passed to template of TEMPLATE encoding.
+//
+// Timing is keyed by timer name and then count or elapsedMs, so timer could be referenced like
+// {{.Timing.db.elapsedMs}}.
+type TemplateData struct {
+	StartTime      time.Time
+	EndTime        time.Time
+	ElapsedNano    int64
+	ElapsedMs      int64
+	Timezone       string
+	EventId        string
+	TraceId        string
+	RequestId      string
+	ParentEventId  string
+	ServiceName    string
+	ServiceVersion string
+	EntryName      string
+	EntryKind      string
+	Operation      string
+	RemoteAddr     string
+	ResCode        string
+	EventStatus    string
+	Env            map[string]string
+	Payloads       map[string]interface{}
+	Errors         map[string]int64
+	Counters       map[string]int64
+	Pairs          map[string]string
+	Timing         map[string]map[string]int64
+}
+
+// Functions could be used in template.
+var templateFuncs = template.FuncMap{
+	// {{padLeft 8 .ResCode}}
+	"padLeft": func(width int, val interface{}) string {
+		str := templateString(val)
+		return strings.Repeat(" ", templatePadding(width, str)) + str
+	},
+	// {{padRight 20 .Operation}}
+	"padRight": func(width int, val interface{}) string {
+		str := templateString(val)
+		return str + strings.Repeat(" ", templatePadding(width, str))
+	},
+	// {{default "-" .TraceId}}
+	"default": func(def interface{}, val interface{}) interface{} {
+		if len(templateString(val)) < 1 {
+			return def
+		}
+		return val
+	},
+	// {{duration .ElapsedNano}}, accepts nanoseconds or time.Duration
+	"duration": func(val interface{}) (string, error) {
+		switch v := val.(type) {
+		case time.Duration:
+			return v.String(), nil
+		case int64:
+			return time.Duration(v).String(), nil
+		case int:
+			return time.Duration(v).String(), nil
+		}
+		return "", fmt.Errorf("duration of %T is not supported", val)
+	},
+	// {{timeFormat "2006-01-02" .StartTime}}
+	"timeFormat": func(layout string, t time.Time) string {
+		return t.Format(layout)
+	},
+	// {{json .Payloads}}
+	"json": func(val interface{}) (string, error) {
+		bytes, err := json.Marshal(val)
+		return string(bytes), err
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// Encodes event with text/template.
+type templateEncoder struct {
+	tmpl *template.Template
+}
+
+// NewTemplateEncoder creates an Encoder which renders event with text/template as message, see TemplateData
+// for fields could be referenced in template.
+//
+// Helper functions padLeft, padRight, default, duration, timeFormat, json, upper and lower are available.
+// Missing keys of maps are rendered as zero value.
+func NewTemplateEncoder(text string) (Encoder, error) {
+	tmpl, err := template.New("rkquery").Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	return &templateEncoder{tmpl: tmpl}, nil
+}
+
+// Encode renders event with template, error occurred while rendering is written as field of templateError.
+func (encoder *templateEncoder) Encode(view EventView) (string, []zap.Field) {
+	buf := &bytes.Buffer{}
+	if err := encoder.tmpl.Execute(buf, templateDataOf(view)); err != nil {
+		return buf.String(), []zap.Field{zap.String("templateError", err.Error())}
+	}
+
+	return buf.String(), nil
+}
+
+// Returns data of template.
+func templateDataOf(view EventView) *TemplateData {
+	elapsed := view.GetEndTime().Sub(view.GetStartTime())
+
+	data := &TemplateData{
+		StartTime:      view.GetStartTime(),
+		EndTime:        view.GetEndTime(),
+		ElapsedNano:    elapsed.Nanoseconds(),
+		ElapsedMs:      elapsed.Milliseconds(),
+		Timezone:       view.GetTimeZone(),
+		EventId:        view.GetEventId(),
+		TraceId:        view.GetTraceId(),
+		RequestId:      view.GetRequestId(),
+		ParentEventId:  view.GetParentEventId(),
+		ServiceName:    view.GetServiceName(),
+		ServiceVersion: view.GetServiceVersion(),
+		EntryName:      view.GetEntryName(),
+		EntryKind:      view.GetEntryKind(),
+		Operation:      view.GetOperation(),
+		RemoteAddr:     view.GetRemoteAddr(),
+		ResCode:        view.GetResCode(),
+		EventStatus:    view.GetEventStatus().String(),
+		Env:            view.ListEnv(),
+		Payloads:       payloadsOf(view),
+		Errors:         view.ListErrors(),
+		Counters:       view.ListCounters(),
+		Pairs:          view.ListPairs(),
+		Timing:         make(map[string]map[string]int64),
+	}
+
+	// name.elapsedMs and name.count, name may contain dots
+	for k, v := range view.ListTimings() {
+		index := strings.LastIndex(k, ".")
+		if index < 0 {
+			continue
+		}
+
+		name := k[:index]
+		if _, ok := data.Timing[name]; !ok {
+			data.Timing[name] = make(map[string]int64)
+		}
+		data.Timing[name][k[index+1:]] = v
+	}
+
+	return data
+}
+
+// Returns string value of template argument.
+func templateString(val interface{}) string {
+	switch v := val.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case fmt.Stringer:
+		return v.String()
+	}
+
+	return fmt.Sprint(val)
+}
+
+// Returns number of spaces needed to pad str to width.
+func templatePadding(width int, str string) int {
+	if padding := width - utf8.RuneCountInString(str); padding > 0 {
+		return padding
+	}
+
+	return 0
+}
+
+// Encoder of TEMPLATE with DefaultTemplate.
+func defaultTemplateEncoder() Encoder {
+	encoder, err := NewTemplateEncoder(DefaultTemplate)
+	if err != nil {
+		panic(err)
+	}
+
+	return encoder
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// Create a record with fields referenced by templates.
+func newTemplateRecord() *Record {
+	record := newRecord()
+	record.StartTime = time.Date(2021, 6, 13, 0, 0, 0, 0, time.UTC)
+	record.EndTime = record.StartTime.Add(1500 * time.Millisecond)
+	record.ServiceName = "ut-service"
+	record.Operation = "/v1/a"
+	record.ResCode = "200"
+	record.Pairs["tenant"] = "acme"
+	record.Payloads["size"] = int64(10)
+	record.Timing["db.query.elapsedMs"] = 5
+	record.Timing["db.query.count"] = 2
+	record.Timing["db.elapsedMs"] = 3
+	return record
+}
+
+func TestNewTemplateEncoder_HappyCase(t *testing.T) {
+	encoder, err := NewTemplateEncoder(`{{.Operation}} {{.ElapsedMs}} {{.Pairs.tenant}} {{.Timing.db.elapsedMs}} ` +
+		`{{index .Timing "db.query" "elapsedMs"}}/{{index .Timing "db.query" "count"}} {{json .Payloads}}`)
+	assert.Nil(t, err)
+
+	msg, fields := encoder.Encode(newTemplateRecord())
+	assert.Equal(t, `/v1/a 1500 acme 3 5/2 {"size":10}`, msg)
+	assert.Empty(t, fields)
+}
+
+func TestNewTemplateEncoder_WithHelpers(t *testing.T) {
+	encoder, err := NewTemplateEncoder(`[{{padRight 6 .Operation}}][{{padLeft 4 .ResCode}}][{{padLeft 1 .ResCode}}] ` +
+		`{{default "-" .TraceId}} {{default "-" .Pairs.missing}} {{duration .ElapsedNano}} ` +
+		`{{timeFormat "2006-01-02" .StartTime}} {{upper .ServiceName}} {{.Timing.missing.count}}`)
+	assert.Nil(t, err)
+
+	msg, _ := encoder.Encode(newTemplateRecord())
+	assert.Equal(t, "[/v1/a ][ 200][200] - - 1.5s 2021-06-13 UT-SERVICE 0", msg)
+}
+
+func TestNewTemplateEncoder_WithInvalidTemplate(t *testing.T) {
+	encoder, err := NewTemplateEncoder("{{.Operation")
+	assert.Nil(t, encoder)
+	assert.NotNil(t, err)
+
+	// error while executing
+	encoder, err = NewTemplateEncoder("{{duration .Operation}}")
+	assert.Nil(t, err)
+	_, fields := encoder.Encode(newTemplateRecord())
+	assert.Len(t, fields, 1)
+	assert.Equal(t, "templateError", fields[0].Key)
+}
+
+func TestWithTemplate_HappyCase(t *testing.T) {
+	buf := &bytes.Buffer{}
+	event := NewEventFactory(
+		WithZapLogger(newBufferLogger(buf, false)),
+		WithTemplate("{{.Operation}} {{.ResCode}}")).CreateEvent()
+	event.SetOperation("ut-op")
+	event.SetResCode("OK")
+	event.Finish()
+
+	assert.Equal(t, TEMPLATE, event.(*eventZap).encoding)
+	assert.Equal(t, "ut-op OK\n", buf.String())
+}
+
+func TestWithTemplate_WithInvalidTemplate(t *testing.T) {
+	assert.PanicsWithValue(t, `rkquery: WithTemplate("{{"): template: rkquery:1: unclosed action`, func() {
+		WithTemplate("{{")
+	})
+}
+
+func TestEncodeTemplate_WithDefaultTemplate(t *testing.T) {
+	record := newTemplateRecord()
+	record.TraceId = "ut-trace"
+
+	msg, fields := encoderOf(TEMPLATE).Encode(record)
+	assert.Equal(t, `2021-06-13T00:00:00.000Z ut-service - "/v1/a" 200 1.5s ut-trace`, msg)
+	assert.Empty(t, fields)
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"strings"
+	"testing"
+)
+
+// Encoder writes operation and resCode only.
+func encodeUt(view EventView) (string, []zap.Field) {
+	return view.GetOperation() + " " + view.GetResCode(), nil
+}
+
+func TestRegisterEncoding_HappyCase(t *testing.T) {
+	ec := RegisterEncoding("UT-Encoding", EncoderFunc(encodeUt))
+	assert.True(t, ec > FLATTEN)
+	assert.Equal(t, "ut-encoding", ec.String())
+	assert.Equal(t, ec, ToEncoding("ut-encoding"))
+	assert.Equal(t, ec, ToEncoding("UT-ENCODING"))
+
+	// register again with the same name
+	assert.Equal(t, ec, RegisterEncoding("ut-encoding", EncoderFunc(encodeUt)))
+
+	buf := &bytes.Buffer{}
+	event := NewEventFactory(WithZapLogger(newBufferLogger(buf, false)), WithEncoding(ec)).CreateEvent()
+	event.SetOperation("ut-op")
+	event.SetResCode("OK")
+	event.Finish()
+
+	assert.Equal(t, "ut-op OK\n", buf.String())
+}
+
+func TestRegisterEncoding_WithInvalidArgs(t *testing.T) {
+	assert.Panics(t, func() {
+		RegisterEncoding("", EncoderFunc(encodeUt))
+	})
+
+	assert.Panics(t, func() {
+		RegisterEncoding("ut-nil", nil)
+	})
+}
+
+func TestEncoding_String_WithUnknownEncoding(t *testing.T) {
+	assert.Equal(t, "UNKNOWN", Encoding(-1).String())
+	assert.Equal(t, "UNKNOWN", Encoding(1<<20).String())
+	assert.Equal(t, "flatten", FLATTEN.String())
+}
+
+func TestWithEncoding_WithUnknownEncoding(t *testing.T) {
+	event := NewEventFactory(WithEncoding(JSON), WithEncoding(Encoding(1<<20))).CreateEvent()
+	assert.Equal(t, JSON, event.(*eventZap).encoding)
+}
+
+func TestWithEncoder_HappyCase(t *testing.T) {
+	buf := &bytes.Buffer{}
+	event := NewEventFactory(
+		WithZapLogger(newBufferLogger(buf, true)),
+		WithEncoding(CONSOLE),
+		WithEncoder(EncoderFunc(func(view EventView) (string, []zap.Field) {
+			return "ut-msg", []zap.Field{zap.String("op", view.GetOperation())}
+		}))).CreateEventThreadSafe()
+	event.SetOperation("ut-op")
+	event.Finish()
+
+	assert.Equal(t, `{"msg":"ut-msg","op":"ut-op"}`+"\n", buf.String())
+}
+
+func TestEncoders_WithRecord(t *testing.T) {
+	for _, ec := range []Encoding{CONSOLE, JSON, FLATTEN, LOGFMT, ECS, OTLP} {
+		buf := &bytes.Buffer{}
+		writeFullEvent(buf, ec)
+		expected := buf.String()
+
+		record, err := NewReader(strings.NewReader(expected)).Read()
+		assert.Nil(t, err)
+
+		// encode record directly without event
+		buf.Reset()
+		msg, fields := encoderOf(ec).Encode(record)
+		newBufferLogger(buf, ec == JSON || ec == ECS).Info(msg, fields...)
+
+		decoded, err := NewReader(buf).Read()
+		assert.Nil(t, err, ec.String())
+		record.Offset = 0
+		decoded.Offset = 0
+		assert.Equal(t, record, decoded, ec.String())
+	}
+}
+
+func TestEncodeFlatten_WithPayloads(t *testing.T) {
+	record := newRecord()
+	record.Payloads["apiPath"] = "/v1/a"
+	record.Payloads["apiMethod"] = "GET"
+	msg, _ := encodeFlatten(record)
+	assert.Contains(t, msg, "/v1/a")
+	assert.Contains(t, msg, "GET")
+
+	record = newRecord()
+	record.Payloads["grpcMethod"] = "Login"
+	record.Payloads["grpcServer"] = "Greeter"
+	msg, _ = encodeFlatten(record)
+	assert.Contains(t, msg, "Login")
+	assert.Contains(t, msg, "Greeter")
+}
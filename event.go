@@ -60,6 +60,19 @@ type Event interface {
 	// SetRequestId set request id of current event.
 	SetRequestId(string)
 
+	// GetParentEventId returns event id of parent event, empty if current event is not a child event.
+	GetParentEventId() string
+
+	// SetParentEventId sets event id of parent event.
+	SetParentEventId(string)
+
+	// StartChild starts a child event with operation, which carries trace id, request id and
+	// configuration of current event, its own event id and event id of current event as parent event id.
+	//
+	// Child event is written separately with Finish(), or embedded into output of current event
+	// if WithChildRollup() was used.
+	StartChild(string) Event
+
 	// ************* Error *************
 
 	// AddErr function adds an error into event which could be printed with error.Error() function.
@@ -133,4 +146,8 @@ type Event interface {
 
 	// Sync flushes logger in buffer
 	Sync()
+
+	// Release returns event created by EventFactory.CreateEventPooled() into pool, event should not be used after.
+	// It is a noop for other events.
+	Release()
 }
@@ -0,0 +1,290 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"fmt"
+	"github.com/spf13/cast"
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+	"sync"
+	"time"
+)
+
+// WithChildRollup embeds child events started with Event.StartChild() into output of parent event
+// instead of writing them separately. It is inherited by child events, so grandchildren are embedded into children.
+//
+// Child event finished after parent event is written separately.
+// Children are written by CONSOLE and JSON encoding as an array in children section,
+// with eventId, operation, startTime, elapsedNano, resCode, eventStatus, error and nested children.
+func WithChildRollup(enabled bool) EventOption {
+	return func(event Event) {
+		switch v := event.(type) {
+		case *eventZap:
+			v.childRollup = enabled
+		case *eventThreadSafe:
+			v.delegate.childRollup = enabled
+		}
+	}
+}
+
+// Child events rolled up into parent event, children may be finished concurrently.
+type eventChildren struct {
+	views  []EventView
+	closed bool
+	lock   sync.Mutex
+}
+
+// Add finished child, false would be returned if parent was finished already.
+func (children *eventChildren) add(view EventView) bool {
+	children.lock.Lock()
+	defer children.lock.Unlock()
+
+	if children.closed {
+		return false
+	}
+
+	children.views = append(children.views, view)
+	return true
+}
+
+// Close children while finishing parent event, children finished after will be written separately.
+func (children *eventChildren) close() {
+	children.lock.Lock()
+	defer children.lock.Unlock()
+
+	children.closed = true
+}
+
+// Returns a copy of finished children.
+func (children *eventChildren) list() []EventView {
+	children.lock.Lock()
+	defer children.lock.Unlock()
+
+	if len(children.views) < 1 {
+		return nil
+	}
+
+	return append([]EventView{}, children.views...)
+}
+
+// StartChild starts a child event with operation, which carries trace id, request id and
+// configuration of current event, its own event id and event id of current event as parent event id.
+func (event *eventZap) StartChild(operation string) Event {
+	event.checkReleased()
+
+	child := &eventZap{
+		logger:         event.logger,
+		encoding:       event.encoding,
+		encoder:        event.encoder,
+		schema:         event.schema,
+		redactor:       event.redactor,
+		sampler:        event.sampler,
+		filter:         event.filter,
+		aggregator:     event.aggregator,
+		async:          event.async,
+		sink:           event.sink,
+		sections:       event.sections,
+		otlpExporter:   event.otlpExporter,
+		quietMode:      event.quietMode,
+		serviceName:    event.serviceName,
+		serviceVersion: event.serviceVersion,
+		entryName:      event.entryName,
+		entryKind:      event.entryKind,
+		eventId:        generateEventId(),
+		parentEventId:  event.eventId,
+		traceId:        event.traceId,
+		requestId:      event.requestId,
+		traceContext:   event.traceContext,
+		timeZone:       event.timeZone,
+		env:            event.env,
+		payloads:       make([]zap.Field, 0),
+		errors:         zapcore.NewMapObjectEncoder(),
+		operation:      operation,
+		remoteAddr:     "localhost",
+		status:         NotStarted,
+		pairs:          zapcore.NewMapObjectEncoder(),
+		counters:       zapcore.NewMapObjectEncoder(),
+		tracker:        make(map[string]*timeTracker),
+		childRollup:    event.childRollup,
+	}
+
+	// parent of child is current event instead of upstream span
+	child.traceContext.parentSpanId = ""
+
+	if event.childRollup {
+		if event.children == nil {
+			event.children = &eventChildren{}
+		}
+		child.rollupTo = event.children
+	}
+
+	child.SetStartTime(time.Now())
+
+	return child
+}
+
+// GetParentEventId returns event id of parent event, empty if current event is not a child event.
+func (event *eventZap) GetParentEventId() string {
+	event.checkReleased()
+
+	return event.parentEventId
+}
+
+// SetParentEventId sets event id of parent event.
+func (event *eventZap) SetParentEventId(id string) {
+	event.checkReleased()
+
+	event.parentEventId = id
+}
+
+// ListChildren returns child events rolled up into current event.
+func (event *eventZap) ListChildren() []EventView {
+	event.checkReleased()
+
+	if event.children == nil {
+		return nil
+	}
+
+	return event.children.list()
+}
+
+// ************* Encoding *************
+
+// Append children as JSON array.
+func appendChildren(buf *buffer.Buffer, children []EventView) {
+	buf.AppendByte('[')
+	for i := range children {
+		if i > 0 {
+			buf.AppendByte(',')
+		}
+		appendChild(buf, children[i])
+	}
+	buf.AppendByte(']')
+}
+
+// Append summary of child as JSON object.
+func appendChild(buf *buffer.Buffer, child EventView) {
+	buf.AppendString(`{"` + eventIdKey + `":`)
+	appendJsonString(buf, child.GetEventId())
+	buf.AppendString(`,"` + operationKey + `":`)
+	appendJsonString(buf, child.GetOperation())
+	buf.AppendString(`,"` + startTimeKey + `":"`)
+	buf.AppendTime(child.GetStartTime(), time.RFC3339Nano)
+	buf.AppendString(`","` + elapsedKey + `":`)
+	buf.AppendInt(child.GetEndTime().Sub(child.GetStartTime()).Nanoseconds())
+	if len(child.GetResCode()) > 0 {
+		buf.AppendString(`,"` + resCodeKey + `":`)
+		appendJsonString(buf, child.GetResCode())
+	}
+	buf.AppendString(`,"` + eventStatusKey + `":`)
+	appendJsonString(buf, child.GetEventStatus().String())
+	if errs := child.ListErrors(); len(errs) > 0 {
+		buf.AppendString(`,"` + errKey + `":`)
+		appendJsonInts(buf, errs)
+	}
+	if children := child.ListChildren(); len(children) > 0 {
+		buf.AppendString(`,"` + childrenKey + `":`)
+		appendChildren(buf, children)
+	}
+	buf.AppendByte('}')
+}
+
+// Writes children into zap encoder as array.
+type childrenMarshaler []EventView
+
+// MarshalLogArray implements zapcore.ArrayMarshaler.
+func (children childrenMarshaler) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for i := range children {
+		if err := enc.AppendObject(childMarshaler{children[i]}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Writes summary of child into zap encoder, keys are the same as appendChild().
+type childMarshaler struct {
+	EventView
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaler.
+func (child childMarshaler) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString(eventIdKey, child.GetEventId())
+	enc.AddString(operationKey, child.GetOperation())
+	enc.AddString(startTimeKey, child.GetStartTime().Format(time.RFC3339Nano))
+	enc.AddInt64(elapsedKey, child.GetEndTime().Sub(child.GetStartTime()).Nanoseconds())
+	if len(child.GetResCode()) > 0 {
+		enc.AddString(resCodeKey, child.GetResCode())
+	}
+	enc.AddString(eventStatusKey, child.GetEventStatus().String())
+	if errs := child.ListErrors(); len(errs) > 0 {
+		if err := enc.AddObject(errKey, intsMarshaler(errs)); err != nil {
+			return err
+		}
+	}
+	if children := child.ListChildren(); len(children) > 0 {
+		return enc.AddArray(childrenKey, childrenMarshaler(children))
+	}
+
+	return nil
+}
+
+// ************* Decoding *************
+
+// Decode children section written by appendChild() or childMarshaler.
+func decodeChildren(val interface{}) ([]*Record, error) {
+	if val == nil {
+		return nil, nil
+	}
+
+	items, ok := val.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("not an array")
+	}
+
+	res := make([]*Record, 0, len(items))
+	for i := range items {
+		fields, ok := items[i].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("child %d is not an object", i)
+		}
+
+		child := newRecord()
+		child.EventId = cast.ToString(fields[eventIdKey])
+		child.Operation = cast.ToString(fields[operationKey])
+		child.ResCode = cast.ToString(fields[resCodeKey])
+		child.EventStatus = cast.ToString(fields[eventStatusKey])
+		child.ElapsedNano = cast.ToInt64(fields[elapsedKey])
+
+		if start, ok := fields[startTimeKey]; ok {
+			t, err := parseJsonTime(start)
+			if err != nil {
+				return nil, fmt.Errorf("child %d: %v", i, err)
+			}
+			child.StartTime = t
+			child.EndTime = t.Add(child.Elapsed())
+		}
+
+		if errs, ok := fields[errKey].(map[string]interface{}); ok {
+			for k, v := range errs {
+				child.Errors[k] = cast.ToInt64(v)
+			}
+		}
+
+		children, err := decodeChildren(fields[childrenKey])
+		if err != nil {
+			return nil, err
+		}
+		child.Children = children
+
+		res = append(res, child)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,232 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Create parent event with logger writing into buffer.
+func newParentEvent(buf *bytes.Buffer, ec Encoding, opts ...EventOption) Event {
+	opts = append([]EventOption{
+		WithZapLogger(newBufferLogger(buf, ec == JSON)),
+		WithEncoding(ec),
+		WithServiceName("ut-service")}, opts...)
+
+	event := NewEventFactory(opts...).CreateEvent()
+	event.SetStartTime(time.Now())
+	event.SetOperation("ut-parent")
+	event.SetTraceId("ut-trace")
+	event.SetRequestId("ut-request")
+
+	return event
+}
+
+func TestEventZap_StartChild_HappyCase(t *testing.T) {
+	parent := newParentEvent(&bytes.Buffer{}, CONSOLE)
+	child := parent.StartChild("ut-child").(*eventZap)
+
+	assert.Equal(t, "ut-child", child.GetOperation())
+	assert.Equal(t, "ut-trace", child.GetTraceId())
+	assert.Equal(t, "ut-request", child.GetRequestId())
+	assert.Equal(t, parent.GetEventId(), child.GetParentEventId())
+	assert.NotEqual(t, parent.GetEventId(), child.GetEventId())
+	assert.NotEmpty(t, child.GetEventId())
+	assert.Equal(t, "ut-service", child.GetServiceName())
+	assert.Equal(t, InProgress, child.GetEventStatus())
+	assert.Nil(t, child.rollupTo)
+}
+
+func TestEventZap_StartChild_WithSeparateOutput(t *testing.T) {
+	buf := &bytes.Buffer{}
+	parent := newParentEvent(buf, CONSOLE)
+	child := parent.StartChild("ut-child")
+	child.Finish()
+	parent.Finish()
+
+	records, err := Parse(buf)
+	assert.Nil(t, err)
+	assert.Len(t, records, 2)
+
+	assert.Equal(t, "ut-child", records[0].Operation)
+	assert.Equal(t, parent.GetEventId(), records[0].ParentEventId)
+	assert.Equal(t, "ut-trace", records[0].TraceId)
+	val, ok := records[0].Value(parentEventIdKey)
+	assert.True(t, ok)
+	assert.Equal(t, parent.GetEventId(), val)
+	assert.Equal(t, "ut-parent", records[1].Operation)
+	assert.Empty(t, records[1].ParentEventId)
+	assert.Empty(t, records[1].Children)
+	assert.NotContains(t, buf.String(), childrenKey+"=")
+}
+
+func TestEventZap_StartChild_WithRollup(t *testing.T) {
+	for _, ec := range []Encoding{CONSOLE, JSON} {
+		buf := &bytes.Buffer{}
+		parent := newParentEvent(buf, ec, WithChildRollup(true))
+
+		first := parent.StartChild("ut-first")
+		grandchild := first.StartChild("ut-grandchild")
+		grandchild.SetResCode("OK")
+		grandchild.Finish()
+		first.AddErr(errors.New("ut-err"))
+		first.Finish()
+
+		second := parent.StartChild("ut-second")
+		second.SetEndTime(second.GetStartTime().Add(5 * time.Millisecond))
+		second.Finish()
+		parent.Finish()
+
+		records, err := Parse(buf)
+		assert.Nil(t, err, ec.String())
+		assert.Len(t, records, 1, ec.String())
+
+		children := records[0].Children
+		assert.Len(t, children, 2, ec.String())
+		assert.Equal(t, "ut-first", children[0].Operation)
+		assert.Equal(t, first.GetEventId(), children[0].EventId)
+		assert.Equal(t, int64(1), children[0].Errors["ut-err"])
+		assert.Equal(t, "Ended", children[0].EventStatus)
+		assert.Len(t, children[0].Children, 1)
+		assert.Equal(t, "ut-grandchild", children[0].Children[0].Operation)
+		assert.Equal(t, "OK", children[0].Children[0].ResCode)
+		assert.Equal(t, "ut-second", children[1].Operation)
+		assert.Equal(t, int64(5*time.Millisecond), children[1].ElapsedNano)
+		assert.True(t, second.GetStartTime().Equal(children[1].StartTime))
+	}
+}
+
+func TestEventZap_StartChild_WithChildFinishedAfterParent(t *testing.T) {
+	buf := &bytes.Buffer{}
+	parent := newParentEvent(buf, CONSOLE, WithChildRollup(true))
+	child := parent.StartChild("ut-child")
+	parent.Finish()
+	child.Finish()
+
+	records, err := Parse(buf)
+	assert.Nil(t, err)
+	assert.Len(t, records, 2)
+	assert.Empty(t, records[0].Children)
+	assert.Equal(t, "ut-child", records[1].Operation)
+	assert.Equal(t, parent.GetEventId(), records[1].ParentEventId)
+}
+
+func TestEventZap_StartChild_WithConcurrentChildren(t *testing.T) {
+	buf := &bytes.Buffer{}
+	parent := newParentEvent(buf, JSON, WithChildRollup(true))
+
+	children := make([]Event, 10)
+	for i := range children {
+		children[i] = parent.StartChild("ut-child")
+	}
+
+	wg := &sync.WaitGroup{}
+	for i := range children {
+		wg.Add(1)
+		go func(child Event) {
+			defer wg.Done()
+			child.Finish()
+		}(children[i])
+	}
+	wg.Wait()
+	parent.Finish()
+
+	records, err := Parse(buf)
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+	assert.Len(t, records[0].Children, 10)
+}
+
+func TestEventZap_StartChild_WithQuietParent(t *testing.T) {
+	buf := &bytes.Buffer{}
+	parent := newParentEvent(buf, CONSOLE, WithChildRollup(true), WithQuietMode(true))
+	parent.StartChild("ut-child").Finish()
+	parent.Finish()
+
+	assert.Empty(t, buf.String())
+	assert.Len(t, parent.(*eventZap).ListChildren(), 1)
+}
+
+func TestEventZap_StartChild_WithPrettyConsole(t *testing.T) {
+	buf := &bytes.Buffer{}
+	parent := newParentEvent(buf, CONSOLE, WithChildRollup(true), WithPrettyConsole(WithPrettyColor(false)))
+	child := parent.StartChild("ut-child")
+	child.SetResCode("OK")
+	child.Finish()
+	parent.Finish()
+
+	assert.Contains(t, buf.String(), "children:\n  ut-child = ")
+	assert.Contains(t, buf.String(), " OK "+child.GetEventId())
+}
+
+func TestEventThreadSafe_StartChild(t *testing.T) {
+	parent := NewEventFactory().CreateEventThreadSafe(WithChildRollup(true), WithQuietMode(true))
+	child := parent.StartChild("ut-child")
+
+	assert.IsType(t, &eventThreadSafe{}, child)
+	assert.Equal(t, parent.GetEventId(), child.GetParentEventId())
+	child.SetParentEventId("ut-parent")
+	assert.Equal(t, "ut-parent", child.GetParentEventId())
+}
+
+func TestEventNoop_StartChild(t *testing.T) {
+	event := NewEventFactory().CreateEventNoop()
+	child := event.StartChild("ut-child")
+
+	assert.Equal(t, event, child)
+	assert.Empty(t, child.GetParentEventId())
+}
+
+func TestEventFactory_CreateEventFromRecord_WithChildren(t *testing.T) {
+	buf := &bytes.Buffer{}
+	parent := newParentEvent(buf, JSON, WithChildRollup(true))
+	parent.StartChild("ut-child").Finish()
+	parent.Finish()
+
+	record, err := NewReader(strings.NewReader(buf.String())).Read()
+	assert.Nil(t, err)
+
+	out := &bytes.Buffer{}
+	NewEventFactory().CreateEventFromRecord(record, WithZapLogger(newBufferLogger(out, false))).Finish()
+
+	rewritten, err := NewReader(out).Read()
+	assert.Nil(t, err)
+	assert.Len(t, rewritten.Children, 1)
+	assert.Equal(t, "ut-child", rewritten.Children[0].Operation)
+
+	val, ok := rewritten.Value(childrenKey)
+	assert.True(t, ok)
+	assert.Len(t, val, 1)
+}
+
+func TestDecodeChildren_WithInvalidValue(t *testing.T) {
+	_, err := decodeChildren("ut")
+	assert.NotNil(t, err)
+
+	_, err = decodeChildren([]interface{}{"ut"})
+	assert.NotNil(t, err)
+
+	_, err = decodeChildren([]interface{}{map[string]interface{}{startTimeKey: "ut"}})
+	assert.NotNil(t, err)
+}
+
+func TestEventZap_StartChild_WithParentEventIdInEncodings(t *testing.T) {
+	for _, ec := range []Encoding{CONSOLE, JSON, LOGFMT, OTLP} {
+		buf := &bytes.Buffer{}
+		parent := newParentEvent(buf, ec)
+		parent.StartChild("ut-child").Finish()
+
+		record, err := NewReader(buf).Read()
+		assert.Nil(t, err, ec.String())
+		assert.Equal(t, parent.GetEventId(), record.ParentEventId, ec.String())
+	}
+}
@@ -6,6 +6,7 @@
 package rkquery
 
 import (
+	"fmt"
 	"github.com/google/uuid"
 	"github.com/rookie-ninja/rk-logger"
 	"go.uber.org/zap"
@@ -52,7 +53,7 @@ func WithZapLogger(logger *zap.Logger) EventOption {
 // WithEncoding override encoding in Event.
 func WithEncoding(ec Encoding) EventOption {
 	return func(event Event) {
-		if ec != JSON && ec != CONSOLE && ec != FLATTEN {
+		if encoderOf(ec) == nil {
 			return
 		}
 
@@ -65,6 +66,101 @@ func WithEncoding(ec Encoding) EventOption {
 	}
 }
 
+// WithEncoder override encoder in Event, encoding will be ignored if encoder is not nil.
+func WithEncoder(encoder Encoder) EventOption {
+	return func(event Event) {
+		switch v := event.(type) {
+		case *eventZap:
+			v.encoder = encoder
+		case *eventThreadSafe:
+			v.delegate.encoder = encoder
+		}
+	}
+}
+
+// WithTemplate renders event with text/template in TEMPLATE encoding, see NewTemplateEncoder() for details.
+// It panics if template could not be parsed like regexp.MustCompile(), use NewTemplateEncoder() and WithEncoder()
+// in order to handle the error.
+func WithTemplate(text string) EventOption {
+	// parse once since options are applied to every event
+	encoder, err := NewTemplateEncoder(text)
+	if err != nil {
+		panic(fmt.Sprintf("rkquery: WithTemplate(%q): %v", text, err))
+	}
+
+	return func(event Event) {
+		WithEncoding(TEMPLATE)(event)
+		WithEncoder(encoder)(event)
+	}
+}
+
+// WithPrettyConsole writes event in CONSOLE format for terminals, see NewPrettyConsoleEncoder() for details.
+func WithPrettyConsole(opts ...PrettyConsoleOption) EventOption {
+	// colors are resolved once since options are applied to every event
+	encoder := NewPrettyConsoleEncoder(opts...)
+
+	return func(event Event) {
+		WithEncoding(CONSOLE)(event)
+		WithEncoder(encoder)(event)
+	}
+}
+
+// WithFlatten writes event in FLATTEN format with configured columns, see NewFlattenEncoder() for details.
+func WithFlatten(opts ...FlattenOption) EventOption {
+	// encoder is shared by events in order to align columns across events
+	encoder := NewFlattenEncoder(opts...)
+
+	return func(event Event) {
+		WithEncoding(FLATTEN)(event)
+		WithEncoder(encoder)(event)
+	}
+}
+
+// WithSchema customizes keys, sections, duration unit and time layout of CONSOLE and JSON encoding.
+// Schema is ignored by other encodings and encoder passed by WithEncoder().
+//
+// Events written with schema other than the default one may not be read by Reader.
+func WithSchema(s Schema) EventOption {
+	// compile once since options are applied to every event
+	compiled := newSchema(s)
+
+	return func(event Event) {
+		switch v := event.(type) {
+		case *eventZap:
+			v.schema = compiled
+		case *eventThreadSafe:
+			v.delegate.schema = compiled
+		}
+	}
+}
+
+// WithOtlpExporter exports event into OtlpFileExporter in Event.Finish() in addition to logger.
+// Event will be exported even if quiet mode is on.
+func WithOtlpExporter(exporter *OtlpFileExporter) EventOption {
+	return func(event Event) {
+		switch v := event.(type) {
+		case *eventZap:
+			v.otlpExporter = exporter
+		case *eventThreadSafe:
+			v.delegate.otlpExporter = exporter
+		}
+	}
+}
+
+// WithAggregator adds every finished event into Aggregator in Event.Finish(), including events
+// in quiet mode, not sampled or suppressed by WithSlowOrErrorOnly(). Child events rolled up into parent
+// event are added as well.
+func WithAggregator(agg *Aggregator) EventOption {
+	return func(event Event) {
+		switch v := event.(type) {
+		case *eventZap:
+			v.aggregator = agg
+		case *eventThreadSafe:
+			v.delegate.aggregator = agg
+		}
+	}
+}
+
 // WithQuietMode turn on quiet mode which won't flush data to logger.
 func WithQuietMode(quietMode bool) EventOption {
 	return func(event Event) {
@@ -141,17 +237,19 @@ func WithPayloads(fields ...zap.Field) EventOption {
 
 // EventFactory is not thread safe!!!
 type EventFactory struct {
-	options []EventOption
+	options  []EventOption
+	sections *renderedSections
 }
 
 // NewEventFactory creates a new event factory with option.
 func NewEventFactory(option ...EventOption) *EventFactory {
+	domain = getDefaultIfEmptyString(os.Getenv("DOMAIN"), "*")
+
 	factory := &EventFactory{
-		options: option,
+		options:  option,
+		sections: newRenderedSections(),
 	}
 
-	domain = getDefaultIfEmptyString(os.Getenv("DOMAIN"), "*")
-
 	return factory
 }
 
@@ -179,23 +277,42 @@ func (factory *EventFactory) CreateEvent(options ...EventOption) Event {
 		pairs:          zapcore.NewMapObjectEncoder(),
 		counters:       zapcore.NewMapObjectEncoder(),
 		tracker:        make(map[string]*timeTracker),
+		sections:       factory.sections,
 	}
 
-	for i := range factory.options {
-		opt := factory.options[i]
-		opt(event)
-	}
+	factory.applyOptions(event, options)
 
-	for i := range options {
-		opt := options[i]
-		opt(event)
-	}
+	return event
+}
+
+// CreateEventPooled creates a new event from pool with options in order to reduce allocations.
+//
+// Event should be returned into pool with Event.Release() after Event.Finish() or WithReleaseOnFinish() should be used.
+// Released event must not be used anymore. Use -tags rkquery_debug to detect use of events after release.
+func (factory *EventFactory) CreateEventPooled(options ...EventOption) Event {
+	event := getPooledEvent()
+	event.sections = factory.sections
 
-	event.logger.Core().Sync()
+	factory.applyOptions(event, options)
 
 	return event
 }
 
+// CreateEventFromRecord creates a new event with fields decoded from Record.
+//
+// It is mainly used for writing decoded query logs again with different logger or encoding.
+// Fields in event will be overridden by record, so options should be used for logger and encoding.
+func (factory *EventFactory) CreateEventFromRecord(record *Record, options ...EventOption) Event {
+	event := factory.CreateEvent(options...).(*eventZap)
+
+	if record == nil {
+		return event
+	}
+
+	event.fromRecord(record)
+	return event
+}
+
 // CreateEventNoop creates a new noop event.
 func (factory *EventFactory) CreateEventNoop() Event {
 	return &eventNoop{}
@@ -210,6 +327,19 @@ func (factory *EventFactory) CreateEventThreadSafe(options ...EventOption) Event
 	}
 }
 
+// Apply options of factory and options of event in order.
+func (factory *EventFactory) applyOptions(event Event, options []EventOption) {
+	for i := range factory.options {
+		opt := factory.options[i]
+		opt(event)
+	}
+
+	for i := range options {
+		opt := options[i]
+		opt(event)
+	}
+}
+
 // Get hostname of current machine.
 func getHostName() string {
 	hostName, err := os.Hostname()
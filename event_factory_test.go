@@ -268,3 +268,24 @@ func TestGetDefaultIfEmptyString_WithNonEmptyOrigin(t *testing.T) {
 	res := getDefaultIfEmptyString("ut-origin", "ut-default")
 	assert.Equal(t, "ut-origin", res)
 }
+
+func TestEventFactory_CreateEventFromRecord_WithNilRecord(t *testing.T) {
+	event := NewEventFactory().CreateEventFromRecord(nil, WithOperation("ut-op"))
+	assert.NotNil(t, event)
+	assert.Equal(t, "ut-op", event.GetOperation())
+}
+
+func TestEventFactory_CreateEventFromRecord_HappyCase(t *testing.T) {
+	record := newRecord()
+	record.Operation = "ut-op"
+	record.Env["ut-env"] = "ut-value"
+	record.Timing["ut-timer.elapsedMs"] = 3
+	record.Timing["ut-timer.count"] = 2
+	record.EventStatus = Ended.String()
+
+	event := NewEventFactory().CreateEventFromRecord(record, WithOperation("overridden"))
+	assert.Equal(t, "ut-op", event.GetOperation())
+	assert.Equal(t, Ended, event.GetEventStatus())
+	assert.Equal(t, int64(3), event.GetTimeElapsedMs("ut-timer"))
+	assert.Equal(t, map[string]string{"ut-env": "ut-value"}, event.(*eventZap).ListEnv())
+}
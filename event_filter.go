@@ -0,0 +1,86 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// SlowOrErrorFilter decides which events are written by WithSlowOrErrorOnly().
+//
+// Event is written if it failed, has non-OK res code, or elapsed time is no less than threshold of operation.
+// Filter should not be modified after passed into WithSlowOrErrorOnly().
+type SlowOrErrorFilter struct {
+	// number of events suppressed, kept as first field for 64 bits alignment of atomic operations
+	suppressed int64
+	// Threshold of elapsed time of all operations, zero means slow events are not written.
+	Threshold time.Duration
+	// OperationThresholds overrides Threshold by operation.
+	OperationThresholds map[string]time.Duration
+	// IsError returns true if event failed, default is true if any error was added.
+	IsError func(view EventView) bool
+	// IsOkResCode returns true if res code is OK, default is true for empty, OK, 0 and numeric res code
+	// less than 400, case insensitive.
+	IsOkResCode func(resCode string) bool
+}
+
+// Suppressed returns number of events suppressed by filter.
+func (filter *SlowOrErrorFilter) Suppressed() int64 {
+	return atomic.LoadInt64(&filter.suppressed)
+}
+
+// Returns true if event should be written.
+func (filter *SlowOrErrorFilter) match(view EventView) bool {
+	if filter.IsError != nil {
+		if filter.IsError(view) {
+			return true
+		}
+	} else if len(view.ListErrors()) > 0 {
+		return true
+	}
+
+	if filter.IsOkResCode != nil {
+		if !filter.IsOkResCode(view.GetResCode()) {
+			return true
+		}
+	} else if !isOkResCode(view.GetResCode(), nil) {
+		return true
+	}
+
+	threshold, ok := filter.OperationThresholds[view.GetOperation()]
+	if !ok {
+		threshold = filter.Threshold
+	}
+
+	return threshold > 0 && view.GetEndTime().Sub(view.GetStartTime()) >= threshold
+}
+
+// WithSlowOrErrorOnly writes events which failed or were slow only, other events are suppressed in
+// Event.Finish(), neither written into logger nor exported. See SlowOrErrorFilter for details.
+//
+// Suppressed events are still added into Aggregator passed by WithAggregator() and rolled up into parent event,
+// use SlowOrErrorFilter.Suppressed() to get number of suppressed events.
+func WithSlowOrErrorOnly(filter *SlowOrErrorFilter) EventOption {
+	return func(event Event) {
+		switch v := event.(type) {
+		case *eventZap:
+			v.filter = filter
+		case *eventThreadSafe:
+			v.delegate.filter = filter
+		}
+	}
+}
+
+// Returns true if event should be written, number of suppressed events is increased otherwise.
+func (filter *SlowOrErrorFilter) allow(view EventView) bool {
+	if filter.match(view) {
+		return true
+	}
+
+	atomic.AddInt64(&filter.suppressed, 1)
+	return false
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// Finish event with operation, res code and elapsed time.
+func finishFilteredEvent(fac *EventFactory, operation, resCode string, elapsed time.Duration, err error) {
+	event := fac.CreateEvent()
+	event.SetOperation(operation)
+	event.SetResCode(resCode)
+	event.SetStartTime(time.Now())
+	event.SetEndTime(event.GetStartTime().Add(elapsed))
+	if err != nil {
+		event.AddErr(err)
+	}
+	event.Finish()
+}
+
+func TestWithSlowOrErrorOnly_HappyCase(t *testing.T) {
+	buf := &bytes.Buffer{}
+	filter := &SlowOrErrorFilter{
+		Threshold:           100 * time.Millisecond,
+		OperationThresholds: map[string]time.Duration{"ut-slow": time.Second, "ut-never": 0},
+	}
+	agg := NewAggregator("")
+	fac := NewEventFactory(
+		WithZapLogger(newBufferLogger(buf, true)),
+		WithEncoding(JSON),
+		WithSlowOrErrorOnly(filter),
+		WithAggregator(agg))
+
+	// suppressed
+	finishFilteredEvent(fac, "ut-fast", "OK", time.Millisecond, nil)
+	finishFilteredEvent(fac, "ut-slow", "200", 500*time.Millisecond, nil)
+	finishFilteredEvent(fac, "ut-never", "", time.Hour, nil)
+	// written
+	finishFilteredEvent(fac, "ut-fast", "OK", 200*time.Millisecond, nil)
+	finishFilteredEvent(fac, "ut-slow", "OK", 2*time.Second, nil)
+	finishFilteredEvent(fac, "ut-never", "", 0, errors.New("ut-err"))
+	finishFilteredEvent(fac, "ut-never", "500", 0, nil)
+
+	records, err := Parse(buf)
+	assert.Nil(t, err)
+	assert.Len(t, records, 4)
+	assert.Equal(t, int64(3), filter.Suppressed())
+
+	// suppressed events are aggregated
+	count := int64(0)
+	for _, stats := range agg.Report() {
+		count += stats.Count
+	}
+	assert.Equal(t, int64(7), count)
+}
+
+func TestWithSlowOrErrorOnly_WithPredicates(t *testing.T) {
+	buf := &bytes.Buffer{}
+	filter := &SlowOrErrorFilter{
+		IsError: func(view EventView) bool {
+			return view.ListPairs()["failed"] == "true"
+		},
+		IsOkResCode: func(resCode string) bool {
+			return resCode != "Declined"
+		},
+	}
+	fac := NewEventFactory(WithZapLogger(newBufferLogger(buf, false)), WithSlowOrErrorOnly(filter))
+
+	finishFilteredEvent(fac, "ut", "500", 0, errors.New("ut-err"))
+	finishFilteredEvent(fac, "ut", "Declined", 0, nil)
+
+	event := fac.CreateEvent()
+	event.AddPair("failed", "true")
+	event.Finish()
+
+	records, err := Parse(buf)
+	assert.Nil(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, int64(1), filter.Suppressed())
+}
+
+func TestWithSlowOrErrorOnly_WithSamplerAndRollup(t *testing.T) {
+	buf := &bytes.Buffer{}
+	filter := &SlowOrErrorFilter{}
+	sampled := 0
+	sampler := SamplerFunc(func(EventView) SamplingDecision {
+		sampled++
+		return SamplingDecision{Sampled: true, Rate: 1}
+	})
+
+	parent := newParentEvent(buf, CONSOLE, WithSlowOrErrorOnly(filter), WithSampler(sampler), WithChildRollup(true))
+	parent.StartChild("ut-child").Finish()
+	parent.SetResCode("500")
+	parent.Finish()
+
+	records, err := Parse(buf)
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+	// rolled up child is not suppressed
+	assert.Len(t, records[0].Children, 1)
+	assert.Equal(t, int64(0), filter.Suppressed())
+	assert.Equal(t, 1, sampled)
+
+	// suppressed events are not sampled
+	newParentEvent(buf, CONSOLE, WithSlowOrErrorOnly(filter), WithSampler(sampler)).Finish()
+	assert.Equal(t, int64(1), filter.Suppressed())
+	assert.Equal(t, 1, sampled)
+}
+
+func TestWithAggregator_WithQuietModeAndThreadSafe(t *testing.T) {
+	agg := NewAggregator("")
+	event := NewEventFactory().CreateEventThreadSafe(WithQuietMode(true), WithAggregator(agg))
+	event.SetOperation("ut-operation")
+	event.Finish()
+
+	report := agg.Report()
+	assert.Len(t, report, 1)
+	assert.Equal(t, "ut-operation", report[0].Key)
+
+	filter := &SlowOrErrorFilter{}
+	event = NewEventFactory().CreateEventThreadSafe(WithSlowOrErrorOnly(filter))
+	assert.Equal(t, filter, event.(*eventThreadSafe).delegate.filter)
+}
@@ -83,6 +83,21 @@ func (event *eventNoop) SetRequestId(string) {
 	// Noop
 }
 
+// GetParentEventId returns empty string.
+func (event *eventNoop) GetParentEventId() string {
+	return ""
+}
+
+// SetParentEventId sets event id of parent event.
+func (event *eventNoop) SetParentEventId(string) {
+	// Noop
+}
+
+// StartChild returns noop event.
+func (event *eventNoop) StartChild(string) Event {
+	return event
+}
+
 // ************* Error *************
 
 // AddErr function adds an error into event which could be printed with error.Error() function.
@@ -198,3 +213,8 @@ func (event *eventNoop) Finish() {
 func (event *eventNoop) Sync() {
 	// Noop
 }
+
+// Release returns pooled event into pool.
+func (event *eventNoop) Release() {
+	// Noop
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"github.com/rookie-ninja/rk-logger"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"sync"
+	"time"
+)
+
+// Events created by EventFactory.CreateEventPooled(), events are reset before put back.
+var eventPool = sync.Pool{
+	New: func() interface{} {
+		return &eventZap{
+			payloads: make([]zap.Field, 0, 8),
+			errors:   zapcore.NewMapObjectEncoder(),
+			pairs:    zapcore.NewMapObjectEncoder(),
+			counters: zapcore.NewMapObjectEncoder(),
+			tracker:  make(map[string]*timeTracker),
+		}
+	},
+}
+
+// WithReleaseOnFinish releases pooled event at the end of Event.Finish(), see EventFactory.CreateEventPooled().
+// It is ignored by events which are not pooled.
+func WithReleaseOnFinish(enabled bool) EventOption {
+	return func(event Event) {
+		switch v := event.(type) {
+		case *eventZap:
+			v.releaseOnFinish = enabled
+		case *eventThreadSafe:
+			v.delegate.releaseOnFinish = enabled
+		}
+	}
+}
+
+// Returns event from pool with default values.
+func getPooledEvent() *eventZap {
+	event := eventPool.Get().(*eventZap)
+
+	event.logger = rklogger.EventLogger
+	event.encoding = CONSOLE
+	event.eventId = generateEventId()
+	event.startTime = time.Now()
+	event.timeZone = getTimeZone()
+	event.remoteAddr = "localhost"
+	event.status = NotStarted
+	event.pooled = true
+	event.released = false
+
+	return event
+}
+
+// Release returns pooled event into pool, it is a noop for events which are not pooled or already released.
+func (event *eventZap) Release() {
+	if !event.pooled || event.released {
+		return
+	}
+
+	event.reset()
+	event.released = true
+
+	// released events are not reused in debug builds, so that any use after release could be detected
+	if !poolDebug {
+		eventPool.Put(event)
+	}
+}
+
+// Reset all fields of event, containers are cleared and kept for reuse.
+func (event *eventZap) reset() {
+	for i := range event.payloads {
+		event.payloads[i] = zap.Field{}
+	}
+	payloads := event.payloads[:0]
+
+	for k := range event.errors.Fields {
+		delete(event.errors.Fields, k)
+	}
+	for k := range event.pairs.Fields {
+		delete(event.pairs.Fields, k)
+	}
+	for k := range event.counters.Fields {
+		delete(event.counters.Fields, k)
+	}
+	for k := range event.tracker {
+		delete(event.tracker, k)
+	}
+
+	*event = eventZap{
+		payloads: payloads,
+		errors:   event.errors,
+		pairs:    event.pairs,
+		counters: event.counters,
+		tracker:  event.tracker,
+		pooled:   true,
+	}
+}
+
+// Panics if event was released in debug builds.
+func (event *eventZap) checkReleased() {
+	if poolDebug && event.released {
+		panic("rkquery: event is used after Release()")
+	}
+}
@@ -0,0 +1,12 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+//go:build !rkquery_debug
+// +build !rkquery_debug
+
+package rkquery
+
+// Detect use of pooled event after Release(), enabled with -tags rkquery_debug.
+const poolDebug = false
@@ -0,0 +1,122 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEventFactory_CreateEventPooled_HappyCase(t *testing.T) {
+	event := NewEventFactory(WithServiceName("ut-service")).CreateEventPooled(WithOperation("ut-op")).(*eventZap)
+	defer event.Release()
+
+	assert.True(t, event.pooled)
+	assert.False(t, event.released)
+	assert.NotEmpty(t, event.GetEventId())
+	assert.Equal(t, "ut-service", event.GetServiceName())
+	assert.Equal(t, "ut-op", event.GetOperation())
+	assert.Equal(t, "localhost", event.GetRemoteAddr())
+	assert.Equal(t, CONSOLE, event.encoding)
+	assert.Equal(t, NotStarted, event.GetEventStatus())
+	assert.False(t, event.GetStartTime().IsZero())
+}
+
+func TestEventZap_Release_HappyCase(t *testing.T) {
+	event := NewEventFactory().CreateEventPooled(WithQuietMode(true), WithEncoding(JSON)).(*eventZap)
+	event.SetStartTime(time.Now())
+	event.SetTraceId("ut-trace")
+	event.AddPayloads(zap.String("key", "value"))
+	event.AddErr(errors.New("ut-err"))
+	event.AddPair("key", "value")
+	event.SetCounter("key", 1)
+	event.UpdateTimerMs("ut-timer", 1)
+	event.Finish()
+
+	payloads := event.payloads[:1]
+	event.Release()
+
+	assert.True(t, event.released)
+	assert.True(t, event.pooled)
+	assert.False(t, event.quietMode)
+	assert.Equal(t, CONSOLE, event.encoding)
+	assert.Empty(t, event.traceId)
+	assert.Empty(t, event.payloads)
+	assert.Empty(t, event.errors.Fields)
+	assert.Empty(t, event.pairs.Fields)
+	assert.Empty(t, event.counters.Fields)
+	assert.Empty(t, event.tracker)
+	// payloads are cleared in order not to hold references
+	assert.Equal(t, zap.Field{}, payloads[0])
+}
+
+func TestEventZap_Release_WithEventNotPooled(t *testing.T) {
+	event := NewEventFactory().CreateEvent(WithQuietMode(true)).(*eventZap)
+	event.SetOperation("ut-op")
+	event.Release()
+
+	assert.False(t, event.released)
+	assert.Equal(t, "ut-op", event.GetOperation())
+}
+
+func TestEventZap_Release_Twice(t *testing.T) {
+	event := NewEventFactory().CreateEventPooled(WithQuietMode(true))
+
+	assert.NotPanics(t, func() {
+		event.Release()
+		event.Release()
+	})
+}
+
+func TestEventZap_Finish_WithReleaseOnFinish(t *testing.T) {
+	buf := &bytes.Buffer{}
+	event := NewEventFactory(
+		WithZapLogger(newBufferLogger(buf, false)),
+		WithReleaseOnFinish(true)).CreateEventPooled(WithOperation("ut-op")).(*eventZap)
+	event.Finish()
+
+	assert.True(t, event.released)
+	assert.Contains(t, buf.String(), "operation=ut-op")
+}
+
+func TestEventZap_Finish_WithReleaseOnFinishInQuietMode(t *testing.T) {
+	event := NewEventFactory(WithReleaseOnFinish(true)).CreateEventPooled(WithQuietMode(true)).(*eventZap)
+	event.Finish()
+
+	assert.True(t, event.released)
+}
+
+func TestEventZap_UseAfterRelease(t *testing.T) {
+	if !poolDebug {
+		t.Skip("use after release is detected with -tags rkquery_debug only")
+	}
+
+	event := NewEventFactory().CreateEventPooled(WithQuietMode(true))
+	event.Release()
+
+	assert.Panics(t, func() { event.SetOperation("ut-op") })
+	assert.Panics(t, func() { event.Finish() })
+	assert.Panics(t, func() { event.GetOperation() })
+	assert.Panics(t, func() { event.GetValueFromPair("ut-key") })
+	assert.Panics(t, func() { event.GetParentEventId() })
+}
+
+func TestEventThreadSafe_Release(t *testing.T) {
+	event := NewEventFactory().CreateEventPooled(WithQuietMode(true)).(*eventZap)
+	threadSafe := &eventThreadSafe{delegate: event, lock: &sync.Mutex{}}
+	threadSafe.Release()
+
+	assert.True(t, event.released)
+}
+
+func TestEventNoop_Release(t *testing.T) {
+	assert.NotPanics(t, func() { NewEventFactory().CreateEventNoop().Release() })
+}
@@ -123,6 +123,33 @@ func (event *eventThreadSafe) SetRequestId(id string) {
 	event.delegate.SetRequestId(id)
 }
 
+// GetParentEventId returns event id of parent event, empty if current event is not a child event.
+func (event *eventThreadSafe) GetParentEventId() string {
+	event.lock.Lock()
+	defer event.lock.Unlock()
+
+	return event.delegate.GetParentEventId()
+}
+
+// SetParentEventId sets event id of parent event.
+func (event *eventThreadSafe) SetParentEventId(id string) {
+	event.lock.Lock()
+	defer event.lock.Unlock()
+
+	event.delegate.SetParentEventId(id)
+}
+
+// StartChild starts a thread safe child event with operation.
+func (event *eventThreadSafe) StartChild(operation string) Event {
+	event.lock.Lock()
+	defer event.lock.Unlock()
+
+	return &eventThreadSafe{
+		delegate: event.delegate.StartChild(operation).(*eventZap),
+		lock:     &sync.Mutex{},
+	}
+}
+
 // ************* Error *************
 
 // AddErr function adds an error into event which could be printed with error.Error() function.
@@ -301,3 +328,11 @@ func (event *eventThreadSafe) Sync() {
 
 	event.delegate.Sync()
 }
+
+// Release returns pooled event into pool, event should not be used after.
+func (event *eventThreadSafe) Release() {
+	event.lock.Lock()
+	defer event.lock.Unlock()
+
+	event.delegate.Release()
+}
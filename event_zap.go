@@ -6,14 +6,9 @@
 package rkquery
 
 import (
-	"bytes"
-	"encoding/json"
-	"fmt"
 	"github.com/spf13/cast"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
-	"strings"
-	"text/tabwriter"
 	"time"
 )
 
@@ -43,7 +38,7 @@ func (status eventStatus) String() string {
 	return names[status]
 }
 
-// Encoding supported format of Console and JSON currently.
+// Encoding of event, custom encodings could be added with RegisterEncoding().
 type Encoding int
 
 const (
@@ -53,61 +48,77 @@ const (
 	JSON Encoding = 1
 	// FLATTEN format.
 	FLATTEN Encoding = 2
+	// LOGFMT format, event is written in one line of key=value pairs.
+	LOGFMT Encoding = 3
+	// ECS format, fields are mapped onto Elastic Common Schema, should be used with JSON logger.
+	ECS Encoding = 4
+	// OTLP format, event is written as OTLP/JSON ExportLogsServiceRequest, should be used with message only logger.
+	OTLP Encoding = 5
+	// TEMPLATE format, event is rendered with text/template, see WithTemplate().
+	TEMPLATE Encoding = 6
+	// NCSA format, HTTP event is written in NCSA combined log format, should be used with message only logger.
+	NCSA Encoding = 7
 )
 
 // String will return string value of Encoding types.
 func (ec Encoding) String() string {
-	names := [...]string{"console", "json", "flatten"}
-
-	// Please do not forget to change the boundary while adding a new config file types
-	if ec > FLATTEN || ec < CONSOLE {
-		return "UNKNOWN"
+	if name := encodingName(ec); len(name) > 0 {
+		return name
 	}
 
-	return names[ec]
+	return "UNKNOWN"
 }
 
-// ToEncoding returns Encoding type from string value.
+// ToEncoding returns Encoding type from string value including encodings registered with RegisterEncoding().
+// CONSOLE would be returned if encoding is unknown.
 func ToEncoding(f string) Encoding {
-	f = strings.ToLower(f)
-	switch f {
-	case "json":
-		return JSON
-	case "console":
-		return CONSOLE
-	case "flatten":
-		return FLATTEN
-	default:
-		return CONSOLE
-	}
-
-	return CONSOLE
+	ec, _ := encodingOf(f)
+	return ec
 }
 
 // It is not thread safe.
 type eventZap struct {
-	logger         *zap.Logger
-	encoding       Encoding
-	quietMode      bool
-	serviceName    string                    // Application
-	serviceVersion string                    // Application
-	entryName      string                    // Application
-	entryKind      string                    // Application
-	eventId        string                    // Ids
-	traceId        string                    // Ids
-	requestId      string                    // Ids
-	endTime        time.Time                 // Time
-	startTime      time.Time                 // Time
-	timeZone       string                    // Time
-	payloads       []zap.Field               // Payloads
-	errors         *zapcore.MapObjectEncoder // Error
-	operation      string                    // Event
-	remoteAddr     string                    // Event
-	resCode        string                    // Event
-	status         eventStatus               // Event
-	pairs          *zapcore.MapObjectEncoder // Event
-	counters       *zapcore.MapObjectEncoder // Event
-	tracker        map[string]*timeTracker   // Event
+	logger          *zap.Logger
+	encoding        Encoding
+	encoder         Encoder            // Overrides encoding if not nil
+	schema          *schema            // Customizes CONSOLE and JSON encoding if not nil
+	redactor        *redactor          // Redacts output if not nil
+	sampler         Sampler            // Samples output if not nil
+	filter          *SlowOrErrorFilter // Suppresses output of events neither failed nor slow if not nil
+	aggregator      *Aggregator        // Aggregates every finished event if not nil
+	async           *AsyncQueue        // Writes event with background workers if not nil
+	sink            Sink               // Overrides logger and encoding if not nil
+	sections        *renderedSections  // Pre-rendered sections cached by factory
+	traceContext    traceContext       // Extracted by Propagator, not written
+	otlpExporter    *OtlpFileExporter  // Exports event even in quiet mode
+	quietMode       bool
+	serviceName     string                    // Application
+	serviceVersion  string                    // Application
+	entryName       string                    // Application
+	entryKind       string                    // Application
+	eventId         string                    // Ids
+	traceId         string                    // Ids
+	requestId       string                    // Ids
+	parentEventId   string                    // Ids
+	endTime         time.Time                 // Time
+	startTime       time.Time                 // Time
+	timeZone        string                    // Time
+	env             map[string]string         // Env, nil means current machine
+	payloads        []zap.Field               // Payloads
+	errors          *zapcore.MapObjectEncoder // Error
+	operation       string                    // Event
+	remoteAddr      string                    // Event
+	resCode         string                    // Event
+	status          eventStatus               // Event
+	pairs           *zapcore.MapObjectEncoder // Event
+	counters        *zapcore.MapObjectEncoder // Event
+	tracker         map[string]*timeTracker   // Event
+	pooled          bool                      // Created by EventFactory.CreateEventPooled()
+	released        bool                      // Returned into pool
+	releaseOnFinish bool                      // Release pooled event in Finish()
+	childRollup     bool                      // Embed children into output
+	children        *eventChildren            // Children rolled up into event
+	rollupTo        *eventChildren            // Children of parent event which event is rolled up into
 }
 
 // ************* Time *************
@@ -115,18 +126,24 @@ type eventZap struct {
 // SetStartTime sets start timer of current event. This can be overridden by user.
 // We keep this function open in order to mock event during unit test.
 func (event *eventZap) SetStartTime(curr time.Time) {
+	event.checkReleased()
+
 	event.startTime = curr
 	event.status = InProgress
 }
 
 // GetStartTime Get start time of current event data.
 func (event *eventZap) GetStartTime() time.Time {
+	event.checkReleased()
+
 	return event.startTime
 }
 
 // SetEndTime sets end timer of current event. This can be overridden by user.
 // We keep this function open in order to mock event during unit test.
 func (event *eventZap) SetEndTime(curr time.Time) {
+	event.checkReleased()
+
 	if event.status != InProgress {
 		return
 	}
@@ -137,19 +154,86 @@ func (event *eventZap) SetEndTime(curr time.Time) {
 
 // GetEndTime returns end time of current event data.
 func (event *eventZap) GetEndTime() time.Time {
+	event.checkReleased()
+
 	return event.endTime
 }
 
+// GetTimeZone returns time zone of current event.
+func (event *eventZap) GetTimeZone() string {
+	event.checkReleased()
+
+	return event.timeZone
+}
+
+// ************* Service *************
+
+// GetServiceName returns service name of current event.
+func (event *eventZap) GetServiceName() string {
+	event.checkReleased()
+
+	return event.serviceName
+}
+
+// GetServiceVersion returns service version of current event.
+func (event *eventZap) GetServiceVersion() string {
+	event.checkReleased()
+
+	return event.serviceVersion
+}
+
+// GetEntryName returns entry name of current event.
+func (event *eventZap) GetEntryName() string {
+	event.checkReleased()
+
+	return event.entryName
+}
+
+// GetEntryKind returns entry kind of current event.
+func (event *eventZap) GetEntryKind() string {
+	event.checkReleased()
+
+	return event.entryKind
+}
+
+// ************* Env *************
+
+// ListEnv returns env of current event, env of current machine would be returned by default.
+func (event *eventZap) ListEnv() map[string]string {
+	event.checkReleased()
+
+	if event.env != nil {
+		return event.env
+	}
+
+	// env rendered by factory is shared by events and should not be modified
+	if event.sections != nil {
+		return event.sections.env.env
+	}
+
+	return map[string]string{
+		hostnameKey: hostname,
+		localIpKey:  localIp,
+		domainKey:   domain,
+		goosKey:     goos,
+		goArchKey:   goArch,
+	}
+}
+
 // ************* Payload *************
 
 // AddPayloads function add payload as zap.Field.
 // Payload could be anything with RPC requests or user event such as http request param.
 func (event *eventZap) AddPayloads(fields ...zap.Field) {
+	event.checkReleased()
+
 	event.payloads = append(event.payloads, fields...)
 }
 
 // ListPayloads will lists payloads.
 func (event *eventZap) ListPayloads() []zap.Field {
+	event.checkReleased()
+
 	return event.payloads
 }
 
@@ -157,6 +241,8 @@ func (event *eventZap) ListPayloads() []zap.Field {
 
 // GetEventId returns event id of current event.
 func (event *eventZap) GetEventId() string {
+	event.checkReleased()
+
 	return event.eventId
 }
 
@@ -164,26 +250,36 @@ func (event *eventZap) GetEventId() string {
 // A new event id would be created while event data was created from EventFactory.
 // User could override event id with this function.
 func (event *eventZap) SetEventId(id string) {
+	event.checkReleased()
+
 	event.eventId = id
 }
 
 // GetTraceId returns trace id of current event.
 func (event *eventZap) GetTraceId() string {
+	event.checkReleased()
+
 	return event.traceId
 }
 
 // SetTraceId set trace id of current event.
 func (event *eventZap) SetTraceId(id string) {
+	event.checkReleased()
+
 	event.traceId = id
 }
 
 // GetRequestId returns request id of current event.
 func (event *eventZap) GetRequestId() string {
+	event.checkReleased()
+
 	return event.requestId
 }
 
 // SetRequestId set request id of current event.
 func (event *eventZap) SetRequestId(id string) {
+	event.checkReleased()
+
 	event.requestId = id
 }
 
@@ -191,6 +287,8 @@ func (event *eventZap) SetRequestId(id string) {
 
 // AddErr function adds an error into event which could be printed with error.Error() function.
 func (event *eventZap) AddErr(err error) {
+	event.checkReleased()
+
 	if err == nil {
 		return
 	}
@@ -209,9 +307,18 @@ func (event *eventZap) AddErr(err error) {
 	}
 }
 
+// ListErrors returns errors and count of each error.
+func (event *eventZap) ListErrors() map[string]int64 {
+	event.checkReleased()
+
+	return toInt64Map(event.errors)
+}
+
 // GetErrCount returns error count.
 // We will use value of error.Error() as the key.
 func (event *eventZap) GetErrCount(err error) int64 {
+	event.checkReleased()
+
 	name := err.Error()
 
 	if len(name) < 1 {
@@ -230,33 +337,45 @@ func (event *eventZap) GetErrCount(err error) int64 {
 
 // GetOperation returns operation of current event.
 func (event *eventZap) GetOperation() string {
+	event.checkReleased()
+
 	return event.operation
 }
 
 // SetOperation sets operation of current event.
 func (event *eventZap) SetOperation(operation string) {
+	event.checkReleased()
+
 	event.operation = operation
 }
 
 // GetRemoteAddr returns remote address of current event.
 func (event *eventZap) GetRemoteAddr() string {
+	event.checkReleased()
+
 	return event.remoteAddr
 }
 
 // SetRemoteAddr sets remote address of current event, mainly used in RPC calls.
 // Default value of <localhost> would be assigned while creating event via EventFactory.
 func (event *eventZap) SetRemoteAddr(addr string) {
+	event.checkReleased()
+
 	event.remoteAddr = addr
 }
 
 // GetResCode returns response code of current event.
 // Mainly used in RPC calls.
 func (event *eventZap) GetResCode() string {
+	event.checkReleased()
+
 	return event.resCode
 }
 
 // SetResCode sets response code of current event.
 func (event *eventZap) SetResCode(resCode string) {
+	event.checkReleased()
+
 	event.resCode = resCode
 }
 
@@ -266,11 +385,15 @@ func (event *eventZap) SetResCode(resCode string) {
 // 2: InProgress
 // 3: Ended
 func (event *eventZap) GetEventStatus() eventStatus {
+	event.checkReleased()
+
 	return event.status
 }
 
 // StartTimer starts timer of current sub event.
 func (event *eventZap) StartTimer(name string) {
+	event.checkReleased()
+
 	if !event.inProgress() || len(name) < 1 {
 		return
 	}
@@ -293,6 +416,8 @@ func (event *eventZap) StartTimer(name string) {
 
 // EndTimer ends timer of current sub event.
 func (event *eventZap) EndTimer(name string) {
+	event.checkReleased()
+
 	if !event.inProgress() || len(name) < 1 {
 		return
 	}
@@ -314,6 +439,8 @@ func (event *eventZap) UpdateTimerMs(name string, elapsedMs int64) {
 
 // UpdateTimerMsWithSample updates timer of current sub event with time elapsed in milli seconds.
 func (event *eventZap) UpdateTimerMsWithSample(name string, elapsedMs, sample int64) {
+	event.checkReleased()
+
 	if !event.inProgress() || len(name) < 1 {
 		return
 	}
@@ -336,6 +463,8 @@ func (event *eventZap) UpdateTimerMsWithSample(name string, elapsedMs, sample in
 
 // GetTimeElapsedMs returns timer elapsed in milli seconds.
 func (event *eventZap) GetTimeElapsedMs(name string) int64 {
+	event.checkReleased()
+
 	timer, contains := event.tracker[name]
 	if !contains {
 		return -1
@@ -344,8 +473,25 @@ func (event *eventZap) GetTimeElapsedMs(name string) int64 {
 	return timer.GetElapsedMs()
 }
 
+// ListTimings returns timers flattened into keys like name.elapsedMs and name.count.
+func (event *eventZap) ListTimings() map[string]int64 {
+	event.checkReleased()
+
+	timings := timingsOf(event)
+	defer putTimings(timings)
+
+	res := make(map[string]int64, len(timings.timings))
+	for i := range timings.timings {
+		res[timings.timings[i].key] = timings.timings[i].value
+	}
+
+	return res
+}
+
 // GetValueFromPair returns value with key in pairs.
 func (event *eventZap) GetValueFromPair(key string) string {
+	event.checkReleased()
+
 	val, ok := event.pairs.Fields[key]
 	str := cast.ToString(val)
 
@@ -358,11 +504,27 @@ func (event *eventZap) GetValueFromPair(key string) string {
 
 // AddPair adds value with key in pairs.
 func (event *eventZap) AddPair(key, value string) {
+	event.checkReleased()
+
 	event.pairs.AddString(key, value)
 }
 
+// ListPairs returns pairs of current event.
+func (event *eventZap) ListPairs() map[string]string {
+	event.checkReleased()
+
+	res := make(map[string]string, len(event.pairs.Fields))
+	for k, v := range event.pairs.Fields {
+		res[k] = cast.ToString(v)
+	}
+
+	return res
+}
+
 // GetCounter returns counter of current event.
 func (event *eventZap) GetCounter(key string) int64 {
+	event.checkReleased()
+
 	val, ok := event.counters.Fields[key]
 
 	if ok {
@@ -372,13 +534,24 @@ func (event *eventZap) GetCounter(key string) int64 {
 	return -1
 }
 
+// ListCounters returns counters of current event.
+func (event *eventZap) ListCounters() map[string]int64 {
+	event.checkReleased()
+
+	return toInt64Map(event.counters)
+}
+
 // SetCounter sets counter of current event.
 func (event *eventZap) SetCounter(key string, value int64) {
+	event.checkReleased()
+
 	event.counters.AddInt64(key, value)
 }
 
 // IncCounter increases counter of current event.
 func (event *eventZap) IncCounter(key string, delta int64) {
+	event.checkReleased()
+
 	val, ok := event.counters.Fields[key]
 
 	if ok {
@@ -390,386 +563,91 @@ func (event *eventZap) IncCounter(key string, delta int64) {
 
 // Finish sets event status and flush to logger.
 func (event *eventZap) Finish() {
-	if event.quietMode {
-		return
-	}
+	event.checkReleased()
 
-	switch event.encoding {
-	case JSON:
-		event.logger.With(event.toJsonFormat()...).Info("")
-	case CONSOLE:
-		event.logger.Info(event.toConsoleFormat())
-	case FLATTEN:
-		event.logger.Info(event.toFlattenFormat())
-	default:
-		event.logger.Info(event.toConsoleFormat())
+	// children finished after will be written separately
+	if event.children != nil {
+		event.children.close()
 	}
 
-	// finish any Time Aggregators that may not be done
-	for _, v := range event.tracker {
-		v.Finish()
-	}
-}
-
-// Sync flushes logs in buffer, mainly used for external syncer
-func (event *eventZap) Sync() {
-	event.logger.Sync()
-}
-
-// ************* Internal *************
-
-// Marshal to FLATTEN format.
-func (event *eventZap) toFlattenFormat() string {
-	builder := &bytes.Buffer{}
-	writer := tabwriter.NewWriter(builder, 2, 0, 4, ' ', tabwriter.TabIndent|tabwriter.StripEscape)
-
-	// timestamp
-	fmt.Fprint(writer, fmt.Sprintf("%s", event.GetEndTime().Format("2006-01-02T15:04:05.000Z0700")))
-
-	// res code
-	fmt.Fprint(writer, fmt.Sprintf("\t[%s]", getDefaultIfEmptyString(event.resCode, "[X]")))
-
-	// elapsed
-	fmt.Fprint(writer, fmt.Sprintf("\t%dms", event.GetEndTime().Sub(event.GetStartTime()).Milliseconds()))
-
-	// API method
-	// distinguish restful API and gRPC
-	var grpcMethod, grpcServer, grpcType, apiPath, apiMethod, apiProtocol *zap.Field
-	for i := range event.payloads {
-		field := event.payloads[i]
-		switch field.Key {
-		case "grpcMethod":
-			grpcMethod = &field
-		case "grpcServer":
-			grpcServer = &field
-		case "grpcType":
-			grpcType = &field
-		case "apiPath":
-			apiPath = &field
-		case "apiMethod":
-			apiMethod = &field
-		case "apiProtocol":
-			apiProtocol = &field
-		}
-	}
-
-	method, operation, protocol := "", "", ""
-	if grpcMethod != nil && grpcServer != nil {
-		method = grpcServer.String
-		operation = grpcMethod.String
-		protocol = grpcType.String
-	} else if apiPath != nil && apiMethod != nil {
-		method = apiMethod.String
-		operation = apiPath.String
-		protocol = apiProtocol.String
-	} else {
-		operation = event.operation
-		method = event.entryName
-		protocol = event.entryKind
+	if !event.quietMode || event.otlpExporter != nil || event.rollupTo != nil || event.aggregator != nil {
+		event.flush()
 	}
 
-	// operation
-	fmt.Fprint(writer, fmt.Sprintf("\t%s", getDefaultIfEmptyString(operation, "[X]")))
-
-	// method
-	fmt.Fprint(writer, fmt.Sprintf("\t%s", getDefaultIfEmptyString(method, "[X]")))
-
-	// protocol
-	fmt.Fprint(writer, fmt.Sprintf("\t%s", getDefaultIfEmptyString(protocol, "[X]")))
-
-	// remote addr
-	fmt.Fprint(writer, fmt.Sprintf("\t%s", getDefaultIfEmptyString(event.remoteAddr, "[X]")))
-
-	// ids
-	ids := make([]string, 0)
-	if len(event.eventId) > 0 {
-		ids = append(ids, event.eventId)
+	if event.releaseOnFinish {
+		event.Release()
 	}
-	if len(event.traceId) > 0 {
-		ids = append(ids, event.traceId)
-	}
-	fmt.Fprint(writer, fmt.Sprintf("\t[%s]", getDefaultIfEmptyString(strings.Join(ids, ","), "[X]")))
-
-	writer.Flush()
-	return builder.String()
 }
 
-// Marshal to CONSOLE format.
-func (event *eventZap) toConsoleFormat() string {
-	builder := &bytes.Buffer{}
-
-	builder.WriteString(scopeDelimiter + "\n")
+// Write event into logger or parent event, aggregator and export it.
+func (event *eventZap) flush() {
+	event.setDefaultTime()
 
-	// We would expect bellow format of event data as RK format.
-	// ------------------------------------------------------------------------
-	// endTime=2021-06-13T00:24:20.256315+08:00
-	// startTime=2021-06-13T00:24:19.251056+08:00
-	// elapsedNano=1005258286
-	// timezone=CST
-	// ids={"eventId":"6a2f84a8-a09a-42dc-bc9e-cabc7977345d"}
-	// service={"serviceName":"serviceName","serviceVersion":"v0.0.1","entryName":"entry-example","entryKind":"example"}
-	// env={"arch":"amd64","hostname":"lark.local","localIP":"localhost","realm":"rk","region":"ap-guangzhou","az":"ap-guangzhou-1","domain":"beta","os":"darwin"}
-	// payloads={"f1":"f2","t2":"2021-06-13T00:24:20.256276+08:00"}
-	// error={"my error":1}
-	// counters={"count":1}
-	// pairs={"key":"value"}
-	// timing={"t1.count":1,"t1.elapsed_ms":1005}
-	// remoteAddr=localhost
-	// operation=op
-	// resCode=200
-	// eventStatus=Ended
-	// EOE
-
-	// ************* Time *************
-	// endTime
-	if event.GetEndTime().IsZero() {
-		event.SetEndTime(time.Now())
+	var view EventView = event
+	if event.redactor != nil {
+		view = event.redactor.view(event)
 	}
-	builder.WriteString(fmt.Sprintf("%s=%s\n", endTimeKey, event.GetEndTime().Format(time.RFC3339Nano)))
-	// startTime
-	if event.GetStartTime().IsZero() {
-		event.SetStartTime(time.Now())
-	}
-	builder.WriteString(fmt.Sprintf("%s=%s\n", startTimeKey, event.GetStartTime().Format(time.RFC3339Nano)))
-	// elapsedNano
-	builder.WriteString(fmt.Sprintf("%s=%d\n", elapsedKey, event.GetEndTime().Sub(event.GetStartTime()).Nanoseconds()))
-	// timeZone
-	builder.WriteString(fmt.Sprintf("%s=%s\n", timezoneKey, event.timeZone))
-
-	// ************* Ids *************
-	builder.WriteString(fmt.Sprintf("%s=%s\n", idsKey, event.marshalIds()))
-
-	// ************* Service *************
-	builder.WriteString(fmt.Sprintf("%s=%s\n", serviceKey, event.marshalService()))
-
-	// ************* Env *************
-	builder.WriteString(fmt.Sprintf("%s=%s\n", envKey, event.marshalEnv()))
-
-	// ************* Payloads *************
-	builder.WriteString(fmt.Sprintf("%s=%s\n", payloadsKey, event.marshalPayloads()))
-
-	// ************* Error *************
-	if len(event.errors.Fields) > 0 {
-		builder.WriteString(fmt.Sprintf("%s=%s\n", errKey, event.marshalEncoder(event.errors)))
-	}
-
-	// ************* Counter *************
-	builder.WriteString(fmt.Sprintf("%s=%s\n", countersKey, event.marshalEncoder(event.counters)))
-
-	// ************* Pairs *************
-	builder.WriteString(fmt.Sprintf("%s=%s\n", pairsKey, event.marshalEncoder(event.pairs)))
-
-	// ************* Timing *************
-	builder.WriteString(fmt.Sprintf("%s=%s\n", timingKey, event.marshalTiming()))
-
-	// ************* Event *************
-	// remote address
-	builder.WriteString(fmt.Sprintf("%s=%s\n", remoteAddrKey, event.GetRemoteAddr()))
-	// operation
-	builder.WriteString(fmt.Sprintf("%s=%s\n", operationKey, event.GetOperation()))
-	// resCode
-	if len(event.resCode) > 0 {
-		builder.WriteString(fmt.Sprintf("%s=%s\n", resCodeKey, event.resCode))
-	}
-	// status
-	builder.WriteString(fmt.Sprintf("%s=%s\n", eventStatusKey, event.GetEventStatus().String()))
-
-	builder.WriteString(eoe)
-	return builder.String()
-}
-
-// Marshal to JSON format.
-func (event *eventZap) toJsonFormat() []zap.Field {
-	fields := make([]zapcore.Field, 0)
-
-	// We would expect bellow format of event data as JSON format.
-	//{
-	//	"endTime":"2021-06-13T00:24:21.261+0800",
-	//	"startTime":"2021-06-13T00:24:20.257+0800",
-	//	"elapsedNano":1004326112,
-	//	"timezone":"CST",
-	//	"ids":{
-	//	    "eventId":"72a59682-230f-4ba2-a9fc-e99a031e4d8c",
-	//		"requestId":"",
-	//		"traceId":""
-	//  },
-	//	"service":{
-	//	    "serviceName":"serviceName",
-	//		"serviceVersion":"unknown",
-	//		"entryName":"unknown",
-	//		"entryKind":"unknown"
-	//  },
-	//	"env":{
-	//	    "arch":"amd64",
-	//		"hostname":"lark.local",
-	//      "localIP":"localhost"
-	//		"realm":"*",
-	//		"region":"*",
-	//		"az":"*",
-	//		"domain":"*",
-	//		"os":"darwin"
-	//  },
-	//	"payloads":{
-	//	    "f1":"f2",
-	//		"t2":"2021-06-13T00:24:21.261768+08:00"
-	//  },
-	//	"error":{
-	//	    "my error":1
-	//  },
-	//	"counters":{
-	//	    "count":1
-	//  },
-	//	"pairs":{
-	//	    "key":"value"
-	//  },
-	//	"timing":{
-	//	    "t1.count":1,
-	//		"t1.elapsed_ms":1004
-	//  },
-	//	"remoteAddr":"localhost",
-	//	"operation":"op",
-	//	"eventStatus":"Ended",
-	//	"resCode":"200"
-	//}
-
-	// endTime
-	if event.GetEndTime().IsZero() {
-		event.SetEndTime(time.Now())
-	}
-	// startTime
-	if event.GetStartTime().IsZero() {
-		event.SetStartTime(time.Now())
-	}
-	fields = append(fields,
-		zap.Time(endTimeKey, event.GetEndTime()),
-		zap.Time(startTimeKey, event.GetStartTime()),
-		zap.Int64(elapsedKey, event.GetEndTime().Sub(event.GetStartTime()).Nanoseconds()),
-		zap.String(timezoneKey, event.timeZone),
-		zap.Any(idsKey, event.idsToMapObjectEncoder().Fields),
-		zap.Any(serviceKey, event.serviceToMapObjectEncoder().Fields),
-		zap.Any(envKey, event.envToMapObjectEncoder().Fields),
-		zap.Any(payloadsKey, event.payloadsToMapObjectEncoder().Fields),
-		zap.Any(errKey, event.errors.Fields),
-		zap.Any(countersKey, event.counters.Fields),
-		zap.Any(pairsKey, event.pairs.Fields),
-		zap.Any(timingKey, event.timingToMapObjectEncoder().Fields),
-		zap.String(remoteAddrKey, event.GetRemoteAddr()),
-		zap.String(operationKey, event.GetOperation()),
-		zap.String(eventStatusKey, event.GetEventStatus().String()))
 
-	if len(event.errors.Fields) > 0 {
-		fields = append(fields, zap.Any(errKey, event.errors.Fields))
-	}
+	// child event is written separately if parent event was finished
+	rolledUp := event.rollupTo != nil && event.rollupTo.add(recordOf(view))
 
-	// resCode
-	if len(event.resCode) > 0 {
-		fields = append(fields, zap.String(resCodeKey, event.resCode))
+	if event.aggregator != nil {
+		event.aggregator.AddView(view)
 	}
 
-	return fields
-}
-
-// Construct payloads to zapcore.MapObjectEncoder
-func (event *eventZap) payloadsToMapObjectEncoder() *zapcore.MapObjectEncoder {
-	enc := zapcore.NewMapObjectEncoder()
-	for i := range event.payloads {
-		event.payloads[i].AddTo(enc)
+	// suppressed events do not consume quota of sampler
+	sampled := event.filter == nil || rolledUp || event.filter.allow(view)
+	if event.sampler != nil && !rolledUp && sampled {
+		decision := event.sampler.Sample(view)
+		sampled = decision.Sampled
+		view = sampledViewOf(view, decision)
 	}
 
-	return enc
-}
-
-// Marshal payloads.
-func (event *eventZap) marshalPayloads() string {
-	return event.marshalEncoder(event.payloadsToMapObjectEncoder())
-}
-
-// Construct env to zapcore.MapObjectEncoder
-func (event *eventZap) envToMapObjectEncoder() *zapcore.MapObjectEncoder {
-	enc := zapcore.NewMapObjectEncoder()
-	enc.AddString(hostnameKey, hostname)
-	enc.AddString(localIpKey, localIp)
-	enc.AddString(domainKey, domain)
-	enc.AddString(goosKey, goos)
-	enc.AddString(goArchKey, goArch)
-
-	return enc
-}
-
-// Marshal env.
-func (event *eventZap) marshalEnv() string {
-	return event.marshalEncoder(event.envToMapObjectEncoder())
-}
-
-// Construct ids to zapcore.MapObjectEncoder
-func (event *eventZap) idsToMapObjectEncoder() *zapcore.MapObjectEncoder {
-	enc := zapcore.NewMapObjectEncoder()
-	if len(event.eventId) > 0 {
-		enc.AddString(eventIdKey, event.eventId)
+	job := asyncJob{view: view, logger: event.logger}
+	if !event.quietMode && !rolledUp && sampled {
+		if event.sink != nil {
+			job.sink = event.sink
+		} else {
+			job.encoder = event.encoderOf()
+		}
 	}
-
-	if len(event.traceId) > 0 {
-		enc.AddString(traceIdKey, event.traceId)
+	if event.otlpExporter != nil && sampled {
+		job.exporter = event.otlpExporter
 	}
 
-	if len(event.requestId) > 0 {
-		enc.AddString(requestIdKey, event.requestId)
+	if job.encoder != nil || job.sink != nil || job.exporter != nil {
+		if event.async == nil || !event.async.enqueue(job) {
+			job.run()
+		}
 	}
 
-	return enc
-}
-
-// Marshal ids.
-func (event *eventZap) marshalIds() string {
-	return event.marshalEncoder(event.idsToMapObjectEncoder())
-}
-
-// Construct service to zapcore.MapObjectEncoder
-func (event *eventZap) serviceToMapObjectEncoder() *zapcore.MapObjectEncoder {
-	enc := zapcore.NewMapObjectEncoder()
-	enc.AddString(serviceNameKey, event.serviceName)
-	enc.AddString(serviceVersionKey, event.serviceVersion)
-	enc.AddString(entryNameKey, event.entryName)
-	enc.AddString(entryKindKey, event.entryKind)
-
-	return enc
-}
-
-// Marshal service.
-func (event *eventZap) marshalService() string {
-	return event.marshalEncoder(event.serviceToMapObjectEncoder())
-}
-
-// Construct timing to zapcore.MapObjectEncoder
-func (event *eventZap) timingToMapObjectEncoder() *zapcore.MapObjectEncoder {
-	enc := zapcore.NewMapObjectEncoder()
+	// finish any Time Aggregators that may not be done
 	for _, v := range event.tracker {
-		v.ToZapFields(enc)
+		v.Finish()
 	}
+}
 
-	return enc
+// Returns encoder of event.
+func (event *eventZap) encoderOf() Encoder {
+	return resolveEncoder(event.encoder, event.schema, event.encoding)
 }
 
-// Marshal timing.
-func (event *eventZap) marshalTiming() string {
-	return event.marshalEncoder(event.timingToMapObjectEncoder())
+// Sync flushes logs in buffer, mainly used for external syncer
+func (event *eventZap) Sync() {
+	event.logger.Sync()
 }
 
-// Marshal zapcore.MapObjectEncoder.
-func (event *eventZap) marshalEncoder(enc *zapcore.MapObjectEncoder) string {
-	builder := &bytes.Buffer{}
+// ************* Internal *************
 
-	bytes, err := json.Marshal(enc.Fields)
-	if err != nil {
-		builder.WriteString("{}")
-	} else {
-		_, err := builder.Write(bytes)
-		if err != nil {
-			builder.WriteString("{}")
-		}
+// Set default start and end time if missing.
+func (event *eventZap) setDefaultTime() {
+	if event.GetEndTime().IsZero() {
+		event.SetEndTime(time.Now())
 	}
 
-	return builder.String()
+	if event.GetStartTime().IsZero() {
+		event.SetStartTime(time.Now())
+	}
 }
 
 // Is Event in progress?
@@ -781,6 +659,16 @@ func (event *eventZap) inProgress() bool {
 	return true
 }
 
+// Convert fields in zapcore.MapObjectEncoder to int64.
+func toInt64Map(enc *zapcore.MapObjectEncoder) map[string]int64 {
+	res := make(map[string]int64, len(enc.Fields))
+	for k, v := range enc.Fields {
+		res[k] = cast.ToInt64(v)
+	}
+
+	return res
+}
+
 // Convert time.Time to milliseconds.
 func toMillisecond(curr time.Time) int64 {
 	return curr.UnixNano() / 1e6
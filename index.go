@@ -0,0 +1,523 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	indexHeader = "rkquery-index 1"
+	// bytes at the beginning of file used to detect rotation
+	fingerprintSize = 1024
+	indexFileTag    = "file"
+	indexIdTag      = "id"
+)
+
+// IndexLocation is the location of event in query log file.
+type IndexLocation struct {
+	Path   string
+	Offset int64
+}
+
+// Indexed query log file.
+type indexFile struct {
+	path string
+	// size of file at the last update
+	size int64
+	// offset where the next update continues from
+	resume int64
+	// crc32 of the first fingerprintLen bytes of file
+	fingerprint    uint32
+	fingerprintLen int64
+	entries        []*indexEntry
+}
+
+// Id of event at offset.
+type indexEntry struct {
+	id     string
+	offset int64
+}
+
+// Index maps eventId, traceId and requestId of events to file and byte offset of query log files.
+//
+// Index is stored as a text file, call Update() to index new events appended to files since the last update.
+// Rotated or truncated files are indexed from beginning and gzip compressed files are indexed as a whole.
+type Index struct {
+	path  string
+	files []*indexFile
+	ids   map[string][]*IndexLocation
+	lock  sync.RWMutex
+}
+
+// OpenIndex loads index stored at path, an empty index would be returned if file does not exist.
+func OpenIndex(path string) (*Index, error) {
+	index := &Index{
+		path:  path,
+		files: make([]*indexFile, 0),
+		ids:   make(map[string][]*IndexLocation),
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return index, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if err := index.load(file); err != nil {
+		return nil, fmt.Errorf("rkquery: invalid index %s: %v", path, err)
+	}
+
+	index.rebuild()
+	return index, nil
+}
+
+// Path returns path of index file.
+func (index *Index) Path() string {
+	return index.path
+}
+
+// Files returns paths of indexed query log files.
+func (index *Index) Files() []string {
+	index.lock.RLock()
+	defer index.lock.RUnlock()
+
+	res := make([]string, 0, len(index.files))
+	for i := range index.files {
+		res = append(res, index.files[i].path)
+	}
+
+	return res
+}
+
+// Update indexes events appended to files since the last update and saves index.
+// Malformed events are skipped.
+func (index *Index) Update(paths ...string) error {
+	index.lock.Lock()
+	defer index.lock.Unlock()
+
+	for _, path := range paths {
+		if err := index.updateFile(path); err != nil {
+			return err
+		}
+	}
+
+	index.rebuild()
+	return index.save()
+}
+
+// Locate returns locations of events whose eventId, traceId or requestId equals to id.
+func (index *Index) Locate(id string) []*IndexLocation {
+	index.lock.RLock()
+	defer index.lock.RUnlock()
+
+	res := make([]*IndexLocation, len(index.ids[id]))
+	copy(res, index.ids[id])
+	return res
+}
+
+// Lookup returns events whose eventId, traceId or requestId equals to id.
+//
+// Events which no longer exist at indexed location are skipped, call Update() to refresh index.
+func (index *Index) Lookup(id string) ([]*Record, error) {
+	res := make([]*Record, 0)
+
+	for _, loc := range index.Locate(id) {
+		records, err := readRecordsAt(loc.Path, loc.Offset)
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return res, err
+		}
+
+		for _, record := range records {
+			if record.EventId == id || record.TraceId == id || record.RequestId == id {
+				res = append(res, record)
+			}
+		}
+	}
+
+	return res, nil
+}
+
+// Index new events in file.
+func (index *Index) updateFile(path string) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	fingerprint, fingerprintLen, err := fingerprintOf(path)
+	if err != nil {
+		return err
+	}
+
+	var file *indexFile
+	for i := range index.files {
+		if index.files[i].path == path {
+			file = index.files[i]
+		}
+	}
+
+	if file == nil {
+		file = &indexFile{path: path}
+		index.files = append(index.files, file)
+	}
+
+	// start over if file was rotated, truncated or compressed file was changed
+	changed := stat.Size() < file.size ||
+		(isGzipFile(path) && stat.Size() != file.size) ||
+		file.fingerprintLen > fingerprintLen
+	if !changed && file.fingerprintLen > 0 {
+		prev, _, err := checksumOf(path, file.fingerprintLen)
+		if err != nil {
+			return err
+		}
+		changed = prev != file.fingerprint
+	}
+
+	if changed {
+		file.entries = nil
+		file.resume = 0
+	}
+
+	file.size = stat.Size()
+	file.fingerprint = fingerprint
+	file.fingerprintLen = fingerprintLen
+
+	if isGzipFile(path) && !changed && file.resume > 0 {
+		return nil
+	}
+
+	return index.scanFile(file)
+}
+
+// Scan file from resume offset and append entries.
+func (index *Index) scanFile(file *indexFile) error {
+	src, err := openAt(file.path, file.resume)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	var in io.Reader = src
+	if !isGzipFile(file.path) {
+		// stop at the last line feed since the last line may be partially written
+		in = io.LimitReader(src, lastLineEnd(file.path, file.resume, file.size)-file.resume)
+	}
+
+	reader := NewReader(in)
+	reader.offset = file.resume
+	resume := file.resume
+	// ids indexed at the same offset, OTLP line may contain multiple records
+	seen := make(map[string]bool)
+	last := int64(-1)
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		// event may be partially written, it will be scanned again in the next update
+		var parseErr *ParseError
+		if errors.As(err, &parseErr) {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		resume = reader.offset
+		if record.Offset != last {
+			seen = make(map[string]bool)
+			last = record.Offset
+		}
+
+		for _, id := range uniqueIds(record) {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			file.entries = append(file.entries, &indexEntry{id: id, offset: record.Offset})
+		}
+	}
+
+	if isGzipFile(file.path) {
+		// mark as indexed
+		resume = file.size
+	}
+
+	file.resume = resume
+	return nil
+}
+
+// Rebuild id map from entries of files.
+func (index *Index) rebuild() {
+	index.ids = make(map[string][]*IndexLocation)
+
+	for _, file := range index.files {
+		for _, entry := range file.entries {
+			index.ids[entry.id] = append(index.ids[entry.id], &IndexLocation{
+				Path:   file.path,
+				Offset: entry.offset,
+			})
+		}
+	}
+}
+
+// Save index into temp file and rename it to path of index.
+func (index *Index) save() error {
+	buf := &bytes.Buffer{}
+	buf.WriteString(indexHeader + "\n")
+
+	for i, file := range index.files {
+		fmt.Fprintf(buf, "%s\t%d\t%d\t%d\t%d\t%d\t%s\n",
+			indexFileTag, i, file.size, file.resume, file.fingerprint, file.fingerprintLen, strconv.Quote(file.path))
+		for _, entry := range file.entries {
+			fmt.Fprintf(buf, "%s\t%d\t%d\t%s\n", indexIdTag, i, entry.offset, strconv.Quote(entry.id))
+		}
+	}
+
+	tmp := index.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, index.path)
+}
+
+// Load index from reader.
+func (index *Index) load(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	if !scanner.Scan() || scanner.Text() != indexHeader {
+		return fmt.Errorf("missing header %q", indexHeader)
+	}
+
+	for line := 2; scanner.Scan(); line++ {
+		cols := strings.Split(scanner.Text(), "\t")
+		if err := index.loadLine(cols); err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// Load a single line of index.
+func (index *Index) loadLine(cols []string) error {
+	nums := make([]int64, 0)
+	for i := 1; i < len(cols)-1; i++ {
+		num, err := strconv.ParseInt(cols[i], 10, 64)
+		if err != nil {
+			return err
+		}
+		nums = append(nums, num)
+	}
+
+	str, err := strconv.Unquote(cols[len(cols)-1])
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case cols[0] == indexFileTag && len(nums) == 5 && nums[0] == int64(len(index.files)):
+		index.files = append(index.files, &indexFile{
+			path:           str,
+			size:           nums[1],
+			resume:         nums[2],
+			fingerprint:    uint32(nums[3]),
+			fingerprintLen: nums[4],
+		})
+	case cols[0] == indexIdTag && len(nums) == 2 && nums[0] >= 0 && nums[0] < int64(len(index.files)):
+		file := index.files[nums[0]]
+		file.entries = append(file.entries, &indexEntry{id: str, offset: nums[1]})
+	default:
+		return fmt.Errorf("malformed entry")
+	}
+
+	return nil
+}
+
+// Returns non empty ids of record without duplication.
+func uniqueIds(record *Record) []string {
+	res := make([]string, 0, 3)
+	for _, id := range []string{record.EventId, record.TraceId, record.RequestId} {
+		if len(id) < 1 {
+			continue
+		}
+
+		dup := false
+		for i := range res {
+			dup = dup || res[i] == id
+		}
+
+		if !dup {
+			res = append(res, id)
+		}
+	}
+
+	sort.Strings(res)
+	return res
+}
+
+// Read records at offset of file, multiple records would be returned if they were decoded from the same line.
+func readRecordsAt(path string, offset int64) ([]*Record, error) {
+	src, err := openAt(path, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	reader := NewReader(src)
+	reader.offset = offset
+
+	res := make([]*Record, 0)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return res, nil
+		}
+
+		// records after the requested ones are not our business
+		if err != nil && len(res) > 0 {
+			return res, nil
+		}
+
+		if err != nil {
+			return res, err
+		}
+
+		if record.Offset != offset {
+			return res, nil
+		}
+		res = append(res, record)
+	}
+}
+
+// Open file and skip to offset, offset of gzip compressed file is the offset in decompressed data.
+func openAt(path string, offset int64) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isGzipFile(path) {
+		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+			file.Close()
+			return nil, err
+		}
+		return file, nil
+	}
+
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if _, err := io.CopyN(ioutil.Discard, reader, offset); err != nil && err != io.EOF {
+		file.Close()
+		return nil, err
+	}
+
+	return &gzipFile{Reader: reader, file: file}, nil
+}
+
+// Closes both of gzip reader and file.
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+// Close gzip reader and underlying file.
+func (f *gzipFile) Close() error {
+	f.Reader.Close()
+	return f.file.Close()
+}
+
+// Is file compressed with gzip?
+func isGzipFile(path string) bool {
+	return strings.HasSuffix(path, ".gz")
+}
+
+// Returns crc32 of the first fingerprintSize bytes of file.
+func fingerprintOf(path string) (uint32, int64, error) {
+	return checksumOf(path, fingerprintSize)
+}
+
+// Returns crc32 of the first n bytes of file and number of bytes read.
+func checksumOf(path string, n int64) (uint32, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	hash := crc32.NewIEEE()
+	read, err := io.CopyN(hash, file, n)
+	if err != nil && err != io.EOF {
+		return 0, 0, err
+	}
+
+	return hash.Sum32(), read, nil
+}
+
+// Returns offset after the last line feed between from and to, from would be returned if there is none.
+func lastLineEnd(path string, from, to int64) int64 {
+	file, err := os.Open(path)
+	if err != nil {
+		return from
+	}
+	defer file.Close()
+
+	buf := make([]byte, 4096)
+	for end := to; end > from; {
+		start := end - int64(len(buf))
+		if start < from {
+			start = from
+		}
+
+		n, err := file.ReadAt(buf[:end-start], start)
+		if err != nil && err != io.EOF {
+			return from
+		}
+
+		if i := bytes.LastIndexByte(buf[:n], '\n'); i >= 0 {
+			return start + int64(i) + 1
+		}
+		end = start
+	}
+
+	return from
+}
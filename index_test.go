@@ -0,0 +1,206 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"bytes"
+	"compress/gzip"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Write a finished event with event id into file.
+func appendIndexEvent(t *testing.T, path string, ec Encoding, eventId string) {
+	buf := &bytes.Buffer{}
+	event := NewEventFactory(
+		WithZapLogger(newBufferLogger(buf, ec == JSON)),
+		WithEncoding(ec)).CreateEvent()
+	event.SetEventId(eventId)
+	event.SetTraceId("ut-trace")
+	event.SetOperation("ut-op")
+	event.Finish()
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	assert.Nil(t, err)
+	_, err = file.Write(buf.Bytes())
+	assert.Nil(t, err)
+	assert.Nil(t, file.Close())
+}
+
+// Returns event ids of records.
+func eventIdsOf(records []*Record) []string {
+	res := make([]string, 0)
+	for i := range records {
+		res = append(res, records[i].EventId)
+	}
+
+	return res
+}
+
+func TestOpenIndex_WithMissingFile(t *testing.T) {
+	index, err := OpenIndex(filepath.Join(t.TempDir(), "missing.idx"))
+	assert.Nil(t, err)
+	assert.Empty(t, index.Files())
+	assert.Empty(t, index.Locate("id"))
+}
+
+func TestOpenIndex_WithInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "invalid.idx")
+
+	assert.Nil(t, ioutil.WriteFile(path, []byte("invalid\n"), 0644))
+	_, err := OpenIndex(path)
+	assert.NotNil(t, err)
+
+	assert.Nil(t, ioutil.WriteFile(path, []byte(indexHeader+"\nid\t0\t1\t\"id\"\n"), 0644))
+	_, err = OpenIndex(path)
+	assert.NotNil(t, err)
+}
+
+func TestIndex_HappyCase(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "query.log")
+	indexPath := filepath.Join(dir, "query.idx")
+
+	appendIndexEvent(t, logPath, CONSOLE, "event-1")
+	appendIndexEvent(t, logPath, JSON, "event-2")
+	appendIndexEvent(t, logPath, FLATTEN, "event-3")
+
+	index, err := OpenIndex(indexPath)
+	assert.Nil(t, err)
+	assert.Nil(t, index.Update(logPath))
+
+	for _, id := range []string{"event-1", "event-2", "event-3"} {
+		records, err := index.Lookup(id)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{id}, eventIdsOf(records))
+	}
+
+	records, err := index.Lookup("ut-trace")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"event-1", "event-2", "event-3"}, eventIdsOf(records))
+
+	records, err = index.Lookup("missing")
+	assert.Nil(t, err)
+	assert.Empty(t, records)
+
+	// reopen
+	index, err = OpenIndex(indexPath)
+	assert.Nil(t, err)
+	assert.Len(t, index.Files(), 1)
+	assert.Len(t, index.Locate("ut-trace"), 3)
+	assert.Equal(t, indexPath, index.Path())
+}
+
+func TestIndex_Update_WithAppendedFile(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "query.log")
+
+	appendIndexEvent(t, logPath, JSON, "event-1")
+
+	index, err := OpenIndex(filepath.Join(dir, "query.idx"))
+	assert.Nil(t, err)
+	assert.Nil(t, index.Update(logPath))
+
+	// partially written line should not be indexed
+	file, err := os.OpenFile(logPath, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.Nil(t, err)
+	_, err = file.WriteString(`{"eventStatus":"Ended",`)
+	assert.Nil(t, err)
+	assert.Nil(t, file.Close())
+	assert.Nil(t, index.Update(logPath))
+	assert.Len(t, index.Locate("ut-trace"), 1)
+
+	assert.Nil(t, ioutil.WriteFile(logPath, nil, 0644))
+	appendIndexEvent(t, logPath, JSON, "event-1")
+	appendIndexEvent(t, logPath, JSON, "event-2")
+	assert.Nil(t, index.Update(logPath))
+
+	records, err := index.Lookup("ut-trace")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"event-1", "event-2"}, eventIdsOf(records))
+}
+
+func TestIndex_Update_WithRotatedFile(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "query.log")
+
+	appendIndexEvent(t, logPath, JSON, "event-1")
+
+	index, err := OpenIndex(filepath.Join(dir, "query.idx"))
+	assert.Nil(t, err)
+	assert.Nil(t, index.Update(logPath))
+
+	// rotated into a new file which is larger than the old one
+	assert.Nil(t, os.Remove(logPath))
+	appendIndexEvent(t, logPath, JSON, "event-2")
+	appendIndexEvent(t, logPath, JSON, "event-3")
+	assert.Nil(t, index.Update(logPath))
+
+	assert.Empty(t, index.Locate("event-1"))
+	records, err := index.Lookup("ut-trace")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"event-2", "event-3"}, eventIdsOf(records))
+}
+
+func TestIndex_Update_WithGzipFile(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "query.log")
+	gzPath := logPath + ".gz"
+
+	appendIndexEvent(t, logPath, CONSOLE, "event-1")
+	appendIndexEvent(t, logPath, CONSOLE, "event-2")
+
+	content, err := ioutil.ReadFile(logPath)
+	assert.Nil(t, err)
+	buf := &bytes.Buffer{}
+	writer := gzip.NewWriter(buf)
+	_, err = writer.Write(content)
+	assert.Nil(t, err)
+	assert.Nil(t, writer.Close())
+	assert.Nil(t, ioutil.WriteFile(gzPath, buf.Bytes(), 0644))
+
+	index, err := OpenIndex(filepath.Join(dir, "query.idx"))
+	assert.Nil(t, err)
+	assert.Nil(t, index.Update(gzPath))
+	assert.Nil(t, index.Update(gzPath))
+
+	records, err := index.Lookup("event-2")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"event-2"}, eventIdsOf(records))
+	assert.Len(t, index.Locate("ut-trace"), 2)
+}
+
+func TestIndex_Update_WithMissingFile(t *testing.T) {
+	index, err := OpenIndex(filepath.Join(t.TempDir(), "query.idx"))
+	assert.Nil(t, err)
+	assert.NotNil(t, index.Update("missing.log"))
+}
+
+func TestIndex_Lookup_WithRemovedFile(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "query.log")
+	appendIndexEvent(t, logPath, JSON, "event-1")
+
+	index, err := OpenIndex(filepath.Join(dir, "query.idx"))
+	assert.Nil(t, err)
+	assert.Nil(t, index.Update(logPath))
+	assert.Nil(t, os.Remove(logPath))
+
+	records, err := index.Lookup("event-1")
+	assert.Nil(t, err)
+	assert.Empty(t, records)
+}
+
+func TestUniqueIds(t *testing.T) {
+	record := newRecord()
+	record.EventId = "b"
+	record.TraceId = "a"
+	record.RequestId = "b"
+	assert.Equal(t, []string{"a", "b"}, uniqueIds(record))
+}
@@ -0,0 +1,151 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+const defaultOtlpBatchSize = 100
+
+// OtlpExporterOption will be passed into NewOtlpFileExporter to override default behavior of exporter.
+type OtlpExporterOption func(*OtlpFileExporter)
+
+// WithOtlpBatchSize overrides number of events written in one line, default is 100.
+func WithOtlpBatchSize(size int) OtlpExporterOption {
+	return func(exporter *OtlpFileExporter) {
+		if size > 0 {
+			exporter.batchSize = size
+		}
+	}
+}
+
+// OtlpFileExporter writes events in OTLP/JSON into file, each line is an ExportLogsServiceRequest
+// which could be ingested by otlpjsonfile receiver of OpenTelemetry collector.
+//
+// Events are buffered and grouped by resource, then written when batch is full or Flush() was called.
+// OtlpFileExporter is thread safe.
+type OtlpFileExporter struct {
+	file      *os.File
+	writer    *bufio.Writer
+	batchSize int
+	batch     []*otlpResourceLogs
+	// index of resource in batch
+	resources map[string]*otlpResourceLogs
+	size      int
+	lock      sync.Mutex
+}
+
+// NewOtlpFileExporter creates a new exporter which appends events to file at path.
+func NewOtlpFileExporter(path string, opts ...OtlpExporterOption) (*OtlpFileExporter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	exporter := &OtlpFileExporter{
+		file:      file,
+		writer:    bufio.NewWriter(file),
+		batchSize: defaultOtlpBatchSize,
+		resources: make(map[string]*otlpResourceLogs),
+	}
+
+	for i := range opts {
+		opts[i](exporter)
+	}
+
+	return exporter, nil
+}
+
+// Export adds event into batch, batch will be written if it is full.
+func (exporter *OtlpFileExporter) Export(view EventView) error {
+	if view == nil {
+		return nil
+	}
+
+	resource := otlpResourceOf(view)
+	record := otlpLogRecordOf(view)
+
+	exporter.lock.Lock()
+	defer exporter.lock.Unlock()
+
+	key := otlpResourceKey(resource)
+	logs, ok := exporter.resources[key]
+	if !ok {
+		logs = &otlpResourceLogs{
+			Resource: &otlpResource{Attributes: resource},
+			ScopeLogs: []*otlpScopeLogs{{
+				Scope: &otlpScope{Name: otlpScopeName},
+			}},
+		}
+		exporter.resources[key] = logs
+		exporter.batch = append(exporter.batch, logs)
+	}
+
+	logs.ScopeLogs[0].LogRecords = append(logs.ScopeLogs[0].LogRecords, record)
+	exporter.size++
+
+	if exporter.size >= exporter.batchSize {
+		return exporter.flush()
+	}
+
+	return nil
+}
+
+// Flush writes buffered events into file.
+// Error occurred while writing batch in Export() would be returned as well.
+func (exporter *OtlpFileExporter) Flush() error {
+	exporter.lock.Lock()
+	defer exporter.lock.Unlock()
+
+	return exporter.flush()
+}
+
+// Close flushes buffered events and closes file.
+func (exporter *OtlpFileExporter) Close() error {
+	exporter.lock.Lock()
+	defer exporter.lock.Unlock()
+
+	err := exporter.flush()
+	if closeErr := exporter.file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// Write batch as one line and flush writer into file.
+func (exporter *OtlpFileExporter) flush() error {
+	var err error
+
+	if exporter.size > 0 {
+		var bytes []byte
+		if bytes, err = json.Marshal(&otlpLogsData{ResourceLogs: exporter.batch}); err == nil {
+			exporter.writer.Write(bytes)
+			exporter.writer.WriteByte('\n')
+		}
+
+		exporter.batch = nil
+		exporter.resources = make(map[string]*otlpResourceLogs)
+		exporter.size = 0
+	}
+
+	// error of writer is sticky, so error occurred in Export() would be returned as well
+	if flushErr := exporter.writer.Flush(); err == nil {
+		err = flushErr
+	}
+
+	return err
+}
+
+// Returns key of resource attributes.
+func otlpResourceKey(attrs []*otlpKeyValue) string {
+	bytes, _ := json.Marshal(attrs)
+	return string(bytes)
+}
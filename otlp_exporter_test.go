@@ -0,0 +1,114 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Read lines of file as logs data.
+func readOtlpFile(t *testing.T, path string) []*otlpLogsData {
+	bytes, err := os.ReadFile(path)
+	assert.Nil(t, err)
+
+	res := make([]*otlpLogsData, 0)
+	for _, line := range strings.Split(strings.TrimSpace(string(bytes)), "\n") {
+		if len(line) < 1 {
+			continue
+		}
+
+		data := &otlpLogsData{}
+		assert.Nil(t, json.Unmarshal([]byte(line), data))
+		res = append(res, data)
+	}
+
+	return res
+}
+
+func TestNewOtlpFileExporter_WithInvalidPath(t *testing.T) {
+	exporter, err := NewOtlpFileExporter(filepath.Join(t.TempDir(), "not-exist", "ut.otlp"))
+	assert.Nil(t, exporter)
+	assert.NotNil(t, err)
+}
+
+func TestOtlpFileExporter_Export_WithBatchSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ut.otlp")
+	exporter, err := NewOtlpFileExporter(path, WithOtlpBatchSize(2))
+	assert.Nil(t, err)
+
+	record := newRecord()
+	record.ServiceName = "ut-service"
+
+	assert.Nil(t, exporter.Export(record))
+	assert.Empty(t, readOtlpFile(t, path))
+
+	// batch is full
+	assert.Nil(t, exporter.Export(record))
+	lines := readOtlpFile(t, path)
+	assert.Len(t, lines, 1)
+	// events with the same resource are grouped
+	assert.Len(t, lines[0].ResourceLogs, 1)
+	assert.Len(t, lines[0].ResourceLogs[0].ScopeLogs[0].LogRecords, 2)
+
+	assert.Nil(t, exporter.Export(nil))
+	assert.Nil(t, exporter.Close())
+	assert.Len(t, readOtlpFile(t, path), 1)
+}
+
+func TestOtlpFileExporter_Flush_WithMultipleResources(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ut.otlp")
+	exporter, err := NewOtlpFileExporter(path)
+	assert.Nil(t, err)
+
+	first, second := newRecord(), newRecord()
+	first.ServiceName = "ut-service-1"
+	second.ServiceName = "ut-service-2"
+
+	assert.Nil(t, exporter.Export(first))
+	assert.Nil(t, exporter.Export(second))
+	assert.Nil(t, exporter.Export(first))
+	assert.Nil(t, exporter.Flush())
+
+	lines := readOtlpFile(t, path)
+	assert.Len(t, lines, 1)
+	assert.Len(t, lines[0].ResourceLogs, 2)
+	assert.Len(t, lines[0].ResourceLogs[0].ScopeLogs[0].LogRecords, 2)
+	assert.Len(t, lines[0].ResourceLogs[1].ScopeLogs[0].LogRecords, 1)
+
+	// nothing to write
+	assert.Nil(t, exporter.Flush())
+	assert.Nil(t, exporter.Close())
+	assert.Len(t, readOtlpFile(t, path), 1)
+}
+
+func TestWithOtlpExporter_WithQuietMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ut.otlp")
+	exporter, err := NewOtlpFileExporter(path)
+	assert.Nil(t, err)
+
+	event := NewEventFactory(
+		WithQuietMode(true),
+		WithServiceName("ut-service"),
+		WithOtlpExporter(exporter)).CreateEvent()
+	event.SetOperation("ut-op")
+	event.Finish()
+	assert.Nil(t, exporter.Close())
+
+	file, err := os.Open(path)
+	assert.Nil(t, err)
+	defer file.Close()
+
+	records, err := ParseOtlp(file)
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, "ut-service", records[0].ServiceName)
+	assert.Equal(t, "ut-op", records[0].Operation)
+}
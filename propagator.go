@@ -0,0 +1,575 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/google/uuid"
+	"net/http"
+	"strings"
+)
+
+const (
+	// ************* W3C Trace Context *************
+	traceparentHeader = "traceparent"
+	tracestateHeader  = "tracestate"
+	traceparentLen    = 55
+	maxTracestateLen  = 512
+	// ************* B3 *************
+	b3SingleHeader       = "b3"
+	b3TraceIdHeader      = "X-B3-TraceId"
+	b3SpanIdHeader       = "X-B3-SpanId"
+	b3ParentSpanIdHeader = "X-B3-ParentSpanId"
+	b3SampledHeader      = "X-B3-Sampled"
+	b3FlagsHeader        = "X-B3-Flags"
+	// ************* Request id *************
+	defaultRequestIdHeader = "X-Request-Id"
+	maxRequestIdLen        = 128
+)
+
+// TextMapCarrier is the storage of propagated headers like http.Header and gRPC metadata.
+type TextMapCarrier interface {
+	// Get returns value of key, empty string would be returned if missing.
+	Get(key string) string
+
+	// Set sets value of key.
+	Set(key, value string)
+
+	// Keys returns all keys in carrier.
+	Keys() []string
+}
+
+// HeaderCarrier adapts http.Header to TextMapCarrier.
+type HeaderCarrier http.Header
+
+// Get returns value of key.
+func (carrier HeaderCarrier) Get(key string) string {
+	return http.Header(carrier).Get(key)
+}
+
+// Set sets value of key.
+func (carrier HeaderCarrier) Set(key, value string) {
+	http.Header(carrier).Set(key, value)
+}
+
+// Keys returns all keys in carrier.
+func (carrier HeaderCarrier) Keys() []string {
+	res := make([]string, 0, len(carrier))
+	for k := range carrier {
+		res = append(res, k)
+	}
+
+	return res
+}
+
+// MapCarrier adapts map[string]string to TextMapCarrier, keys are case insensitive and stored in lower case
+// like gRPC metadata.
+type MapCarrier map[string]string
+
+// Get returns value of key.
+func (carrier MapCarrier) Get(key string) string {
+	if value, ok := carrier[strings.ToLower(key)]; ok {
+		return value
+	}
+
+	for k, v := range carrier {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+
+	return ""
+}
+
+// Set sets value of key.
+func (carrier MapCarrier) Set(key, value string) {
+	carrier[strings.ToLower(key)] = value
+}
+
+// Keys returns all keys in carrier.
+func (carrier MapCarrier) Keys() []string {
+	res := make([]string, 0, len(carrier))
+	for k := range carrier {
+		res = append(res, k)
+	}
+
+	return res
+}
+
+// Propagator extracts ids from incoming carrier into event and injects ids of event into outgoing carrier.
+type Propagator interface {
+	// Extract ids from carrier into event. Ids missing in both of carrier and event are generated.
+	// Error would be returned if ids in carrier are invalid, ids are generated in event in that case.
+	Extract(TextMapCarrier, Event) error
+
+	// Inject ids of event into carrier. Ids missing in event are generated and set into event.
+	Inject(Event, TextMapCarrier)
+}
+
+// Sampling decision propagated with trace context.
+type traceSampling int8
+
+const (
+	// traceSamplingUnset means no decision was extracted, events are injected as sampled.
+	traceSamplingUnset traceSampling = iota
+	traceSampled
+	traceNotSampled
+)
+
+// Trace context extracted from carrier and injected as it is, it is not written into output of event.
+type traceContext struct {
+	// tracestate header of W3C Trace Context
+	state string
+	// sampling decision of upstream
+	sampling traceSampling
+	// span id of upstream, it is not the parent event id since upstream span is not an event
+	parentSpanId string
+}
+
+// Returns trace context of event.
+func traceContextOf(event Event) traceContext {
+	switch v := event.(type) {
+	case *eventZap:
+		return v.traceContext
+	case *eventThreadSafe:
+		v.lock.Lock()
+		defer v.lock.Unlock()
+		return v.delegate.traceContext
+	}
+
+	return traceContext{}
+}
+
+// Update trace context of event with fn.
+func updateTraceContext(event Event, fn func(*traceContext)) {
+	switch v := event.(type) {
+	case *eventZap:
+		fn(&v.traceContext)
+	case *eventThreadSafe:
+		v.lock.Lock()
+		defer v.lock.Unlock()
+		fn(&v.delegate.traceContext)
+	}
+}
+
+// ************* W3C Trace Context *************
+
+// NewW3CPropagator creates a Propagator of W3C Trace Context, https://www.w3.org/TR/trace-context/.
+//
+// Trace id of traceparent header is extracted as trace id. Parent id, sampled flag and tracestate header are kept
+// in event without being written, sampled flag and tracestate are injected as they are.
+// Span id injected is derived from event id.
+func NewW3CPropagator() Propagator {
+	return &w3cPropagator{}
+}
+
+// Propagator of W3C Trace Context.
+type w3cPropagator struct{}
+
+// Extract traceparent and tracestate headers into event.
+func (p *w3cPropagator) Extract(carrier TextMapCarrier, event Event) error {
+	defer ensureTraceId(event)
+
+	traceparent := strings.TrimSpace(carrier.Get(traceparentHeader))
+	if len(traceparent) < 1 {
+		return nil
+	}
+
+	traceId, parentId, flags, err := parseTraceparent(traceparent)
+	if err != nil {
+		return err
+	}
+
+	event.SetTraceId(traceId)
+
+	tracestate := strings.TrimSpace(carrier.Get(tracestateHeader))
+	if len(tracestate) > maxTracestateLen {
+		tracestate = ""
+	}
+
+	updateTraceContext(event, func(ctx *traceContext) {
+		ctx.parentSpanId = parentId
+		ctx.state = tracestate
+		ctx.sampling = traceNotSampled
+		if flags&0x01 != 0 {
+			ctx.sampling = traceSampled
+		}
+	})
+
+	return nil
+}
+
+// Inject traceparent and tracestate headers.
+func (p *w3cPropagator) Inject(event Event, carrier TextMapCarrier) {
+	ctx := traceContextOf(event)
+
+	flags := "01"
+	if ctx.sampling == traceNotSampled {
+		flags = "00"
+	}
+
+	carrier.Set(traceparentHeader, "00-"+ensureTraceId(event)+"-"+spanIdOf(event)+"-"+flags)
+
+	if len(ctx.state) > 0 {
+		carrier.Set(tracestateHeader, ctx.state)
+	}
+}
+
+// Parse traceparent header with form of version-traceId-parentId-flags.
+func parseTraceparent(traceparent string) (string, string, byte, error) {
+	if len(traceparent) < traceparentLen {
+		return "", "", 0, fmt.Errorf("invalid traceparent %q: too short", traceparent)
+	}
+
+	version := traceparent[:2]
+	if !isLowerHex(version) || version == "ff" {
+		return "", "", 0, fmt.Errorf("invalid traceparent %q: invalid version", traceparent)
+	}
+
+	// future versions may append fields after flags
+	if (version == "00" && len(traceparent) != traceparentLen) ||
+		(len(traceparent) > traceparentLen && traceparent[traceparentLen] != '-') {
+		return "", "", 0, fmt.Errorf("invalid traceparent %q: invalid length", traceparent)
+	}
+
+	if traceparent[2] != '-' || traceparent[35] != '-' || traceparent[52] != '-' {
+		return "", "", 0, fmt.Errorf("invalid traceparent %q: invalid delimiter", traceparent)
+	}
+
+	traceId, parentId, flags := traceparent[3:35], traceparent[36:52], traceparent[53:55]
+	if !isValidHexId(traceId) {
+		return "", "", 0, fmt.Errorf("invalid traceparent %q: invalid trace id", traceparent)
+	}
+
+	if !isValidHexId(parentId) {
+		return "", "", 0, fmt.Errorf("invalid traceparent %q: invalid parent id", traceparent)
+	}
+
+	flagBytes, err := hex.DecodeString(flags)
+	if err != nil || !isLowerHex(flags) {
+		return "", "", 0, fmt.Errorf("invalid traceparent %q: invalid flags", traceparent)
+	}
+
+	return traceId, parentId, flagBytes[0], nil
+}
+
+// ************* B3 *************
+
+// B3Option will be passed into NewB3Propagator to override default behavior of propagator.
+type B3Option func(*b3Propagator)
+
+// WithB3SingleHeader injects single b3 header instead of X-B3-* headers.
+func WithB3SingleHeader() B3Option {
+	return func(p *b3Propagator) {
+		p.singleHeader = true
+	}
+}
+
+// NewB3Propagator creates a Propagator of B3, https://github.com/openzipkin/b3-propagation.
+//
+// Both of single b3 header and X-B3-* headers are extracted, single header is preferred.
+// Trace id is extracted as trace id, 64 bits trace id is left padded with zeros. Span id and sampling state are
+// kept in event without being written, span id is injected as parent span id and sampling state is injected as it is.
+// X-B3-* headers are injected by default.
+func NewB3Propagator(opts ...B3Option) Propagator {
+	p := &b3Propagator{}
+	for i := range opts {
+		opts[i](p)
+	}
+
+	return p
+}
+
+// Propagator of B3.
+type b3Propagator struct {
+	singleHeader bool
+}
+
+// Extract b3 or X-B3-* headers into event.
+func (p *b3Propagator) Extract(carrier TextMapCarrier, event Event) error {
+	defer ensureTraceId(event)
+
+	var traceId, spanId, sampled string
+	if single := strings.TrimSpace(carrier.Get(b3SingleHeader)); len(single) > 0 {
+		parts := strings.Split(single, "-")
+
+		// sampling state only, like b3: 0
+		if len(parts) == 1 {
+			setB3Sampling(event, parts[0])
+			return nil
+		}
+
+		if len(parts) > 4 {
+			return fmt.Errorf("invalid b3 %q: too many fields", single)
+		}
+
+		traceId, spanId = parts[0], parts[1]
+		if len(parts) > 2 {
+			sampled = parts[2]
+		}
+	} else {
+		traceId, spanId = carrier.Get(b3TraceIdHeader), carrier.Get(b3SpanIdHeader)
+		sampled = carrier.Get(b3SampledHeader)
+		// debug flag implies sampled
+		if carrier.Get(b3FlagsHeader) == "1" {
+			sampled = "d"
+		}
+
+		if len(traceId) < 1 && len(spanId) < 1 {
+			setB3Sampling(event, sampled)
+			return nil
+		}
+	}
+
+	normalized := normalizeTraceId(traceId)
+	if len(normalized) < 1 || (len(traceId) != 16 && len(traceId) != 32) || !isLowerHex(traceId) {
+		return fmt.Errorf("invalid b3 trace id %q", traceId)
+	}
+
+	if len(spanId) != 16 || !isValidHexId(spanId) {
+		return fmt.Errorf("invalid b3 span id %q", spanId)
+	}
+
+	event.SetTraceId(normalized)
+	updateTraceContext(event, func(ctx *traceContext) {
+		ctx.parentSpanId = spanId
+	})
+	setB3Sampling(event, sampled)
+
+	return nil
+}
+
+// Set sampling state of B3 into event, unknown state is ignored.
+func setB3Sampling(event Event, sampled string) {
+	sampling := traceSamplingUnset
+	switch strings.ToLower(strings.TrimSpace(sampled)) {
+	case "1", "true", "d":
+		sampling = traceSampled
+	case "0", "false":
+		sampling = traceNotSampled
+	}
+
+	if sampling != traceSamplingUnset {
+		updateTraceContext(event, func(ctx *traceContext) {
+			ctx.sampling = sampling
+		})
+	}
+}
+
+// Inject b3 or X-B3-* headers.
+func (p *b3Propagator) Inject(event Event, carrier TextMapCarrier) {
+	traceId, spanId, ctx := ensureTraceId(event), spanIdOf(event), traceContextOf(event)
+
+	// parent event of child event, or upstream span extracted
+	parentSpanId := spanIdOfEventId(event.GetParentEventId())
+	if len(parentSpanId) < 1 {
+		parentSpanId = ctx.parentSpanId
+	}
+
+	sampled := "1"
+	if ctx.sampling == traceNotSampled {
+		sampled = "0"
+	}
+
+	if p.singleHeader {
+		value := traceId + "-" + spanId + "-" + sampled
+		if len(parentSpanId) > 0 {
+			value += "-" + parentSpanId
+		}
+		carrier.Set(b3SingleHeader, value)
+		return
+	}
+
+	carrier.Set(b3TraceIdHeader, traceId)
+	carrier.Set(b3SpanIdHeader, spanId)
+	carrier.Set(b3SampledHeader, sampled)
+	if len(parentSpanId) > 0 {
+		carrier.Set(b3ParentSpanIdHeader, parentSpanId)
+	}
+}
+
+// ************* Request id *************
+
+// NewRequestIdPropagator creates a Propagator of request id in header, default header is X-Request-Id.
+//
+// Request id should be printable ASCII and no longer than 128 characters.
+func NewRequestIdPropagator(header string) Propagator {
+	return &requestIdPropagator{header: getDefaultIfEmptyString(header, defaultRequestIdHeader)}
+}
+
+// Propagator of request id.
+type requestIdPropagator struct {
+	header string
+}
+
+// Extract request id into event.
+func (p *requestIdPropagator) Extract(carrier TextMapCarrier, event Event) error {
+	defer ensureRequestId(event)
+
+	requestId := strings.TrimSpace(carrier.Get(p.header))
+	if len(requestId) < 1 {
+		return nil
+	}
+
+	if len(requestId) > maxRequestIdLen || !isPrintableAscii(requestId) {
+		return fmt.Errorf("invalid request id %q", requestId)
+	}
+
+	event.SetRequestId(requestId)
+	return nil
+}
+
+// Inject request id.
+func (p *requestIdPropagator) Inject(event Event, carrier TextMapCarrier) {
+	carrier.Set(p.header, ensureRequestId(event))
+}
+
+// ************* Composite *************
+
+// NewCompositePropagator creates a Propagator which extracts and injects with propagators in order,
+// ids extracted by later propagators override earlier ones. The first error would be returned by Extract().
+func NewCompositePropagator(propagators ...Propagator) Propagator {
+	return compositePropagator(propagators)
+}
+
+// Propagators applied in order.
+type compositePropagator []Propagator
+
+// Extract ids with all propagators.
+func (p compositePropagator) Extract(carrier TextMapCarrier, event Event) error {
+	var res error
+	for i := range p {
+		if err := p[i].Extract(carrier, event); err != nil && res == nil {
+			res = err
+		}
+	}
+
+	return res
+}
+
+// Inject ids with all propagators.
+func (p compositePropagator) Inject(event Event, carrier TextMapCarrier) {
+	for i := range p {
+		p[i].Inject(event, carrier)
+	}
+}
+
+// ************* Ids *************
+
+// Returns trace id of event as 32 lower case hex, a new trace id would be generated and set into event if
+// trace id is missing.
+//
+// Trace id of event is never replaced, trace id which is not a hex id is propagated as the first 32 hex of
+// its sha256, so that the same trace id is always propagated as the same one.
+func ensureTraceId(event Event) string {
+	traceId := event.GetTraceId()
+	if len(traceId) < 1 {
+		traceId = randomHexId(16)
+		event.SetTraceId(traceId)
+		return traceId
+	}
+
+	if normalized := normalizeTraceId(traceId); len(normalized) > 0 {
+		return normalized
+	}
+
+	sum := sha256.Sum256([]byte(traceId))
+	return hex.EncodeToString(sum[:16])
+}
+
+// Returns request id of event, a new UUID would be generated and set into event if missing.
+func ensureRequestId(event Event) string {
+	if requestId := event.GetRequestId(); len(requestId) > 0 {
+		return requestId
+	}
+
+	requestId := generateEventId()
+	event.SetRequestId(requestId)
+	return requestId
+}
+
+// Returns span id of event as 16 lower case hex derived from event id, random id would be returned if
+// event id is not a hex id like UUID.
+func spanIdOf(event Event) string {
+	if id := spanIdOfEventId(event.GetEventId()); len(id) > 0 {
+		return id
+	}
+
+	return randomHexId(8)
+}
+
+// Returns the first 16 hex of event id as span id, dashes of UUID are removed.
+// Empty string would be returned if event id is not a hex id.
+func spanIdOfEventId(eventId string) string {
+	id := strings.ToLower(strings.ReplaceAll(eventId, "-", ""))
+	if len(id) >= 16 && isValidHexId(id[:16]) {
+		return id[:16]
+	}
+
+	return ""
+}
+
+// Returns trace id as 32 lower case hex, dashes of UUID are removed and 64 bits id is left padded with zeros.
+// Empty string would be returned if id is invalid.
+func normalizeTraceId(id string) string {
+	id = strings.ToLower(strings.ReplaceAll(id, "-", ""))
+	if len(id) == 16 {
+		id = strings.Repeat("0", 16) + id
+	}
+
+	if len(id) != 32 || !isValidHexId(id) {
+		return ""
+	}
+
+	return id
+}
+
+// Returns random id with size of bytes in lower case hex.
+func randomHexId(size int) string {
+	bytes := make([]byte, size)
+	if _, err := rand.Read(bytes); err != nil {
+		// fallback to uuid which never fails in practice
+		id, _ := uuid.NewRandom()
+		copy(bytes, id[:])
+	}
+
+	return hex.EncodeToString(bytes)
+}
+
+// Returns true if id is lower case hex and not all zero.
+func isValidHexId(id string) bool {
+	return isLowerHex(id) && strings.Count(id, "0") != len(id)
+}
+
+// Returns true if str is not empty and consists of lower case hex only.
+func isLowerHex(str string) bool {
+	if len(str) < 1 {
+		return false
+	}
+
+	for i := 0; i < len(str); i++ {
+		c := str[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Returns true if str consists of printable ASCII only.
+func isPrintableAscii(str string) bool {
+	for i := 0; i < len(str); i++ {
+		if str[i] < 0x20 || str[i] > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,367 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const (
+	utTraceId  = "4bf92f3577b34da6a3ce929d0e0e4736"
+	utParentId = "00f067aa0ba902b7"
+)
+
+func newPropagatorEvent() Event {
+	return NewEventFactory().CreateEvent(WithQuietMode(true))
+}
+
+func TestHeaderCarrier_HappyCase(t *testing.T) {
+	carrier := HeaderCarrier(http.Header{})
+	carrier.Set("x-ut-key", "ut-value")
+
+	assert.Equal(t, "ut-value", carrier.Get("X-Ut-Key"))
+	assert.Equal(t, []string{"X-Ut-Key"}, carrier.Keys())
+}
+
+func TestMapCarrier_HappyCase(t *testing.T) {
+	carrier := MapCarrier{"X-Mixed-Key": "ut-mixed"}
+	carrier.Set("X-Ut-Key", "ut-value")
+
+	assert.Equal(t, "ut-value", carrier.Get("x-ut-key"))
+	assert.Equal(t, "ut-mixed", carrier.Get("x-mixed-key"))
+	assert.Empty(t, carrier.Get("ut-missing"))
+	assert.ElementsMatch(t, []string{"X-Mixed-Key", "x-ut-key"}, carrier.Keys())
+}
+
+func TestW3CPropagator_Extract_HappyCase(t *testing.T) {
+	event := newPropagatorEvent()
+	header := http.Header{}
+	header.Set("traceparent", "00-"+utTraceId+"-"+utParentId+"-01")
+	header.Set("tracestate", "ut=value")
+
+	assert.Nil(t, NewW3CPropagator().Extract(HeaderCarrier(header), event))
+	assert.Equal(t, utTraceId, event.GetTraceId())
+	// upstream span is not an event
+	assert.Empty(t, event.GetParentEventId())
+	assert.Equal(t, traceContext{state: "ut=value", sampling: traceSampled, parentSpanId: utParentId}, traceContextOf(event))
+	// tracestate is not written as pair
+	assert.Empty(t, event.GetValueFromPair("tracestate"))
+}
+
+func TestW3CPropagator_WithNotSampled(t *testing.T) {
+	event := NewEventFactory().CreateEventThreadSafe(WithQuietMode(true))
+	carrier := MapCarrier{"traceparent": "00-" + utTraceId + "-" + utParentId + "-00"}
+
+	assert.Nil(t, NewW3CPropagator().Extract(carrier, event))
+	assert.Equal(t, traceNotSampled, traceContextOf(event).sampling)
+
+	out := MapCarrier{}
+	NewCompositePropagator(NewW3CPropagator(), NewB3Propagator()).Inject(event.StartChild("ut-child"), out)
+	assert.True(t, strings.HasSuffix(out.Get("traceparent"), "-00"))
+	assert.Equal(t, "0", out.Get("X-B3-Sampled"))
+}
+
+func TestW3CPropagator_Extract_WithFutureVersion(t *testing.T) {
+	event := newPropagatorEvent()
+	carrier := MapCarrier{"traceparent": "cc-" + utTraceId + "-" + utParentId + "-01-ut"}
+
+	assert.Nil(t, NewW3CPropagator().Extract(carrier, event))
+	assert.Equal(t, utTraceId, event.GetTraceId())
+}
+
+func TestW3CPropagator_Extract_WithMissingHeader(t *testing.T) {
+	event := newPropagatorEvent()
+
+	assert.Nil(t, NewW3CPropagator().Extract(MapCarrier{}, event))
+	assert.Len(t, event.GetTraceId(), 32)
+	assert.True(t, isValidHexId(event.GetTraceId()))
+	assert.Empty(t, event.GetParentEventId())
+}
+
+func TestW3CPropagator_Extract_WithInvalidHeader(t *testing.T) {
+	invalid := []string{
+		"00-" + utTraceId + "-" + utParentId,
+		"ff-" + utTraceId + "-" + utParentId + "-01",
+		"00-" + utTraceId + "-" + utParentId + "-01-ut",
+		"00_" + utTraceId + "_" + utParentId + "_01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-" + utParentId + "-01",
+		"00-00000000000000000000000000000000-" + utParentId + "-01",
+		"00-" + utTraceId + "-0000000000000000-01",
+		"00-" + utTraceId + "-" + utParentId + "-0x",
+	}
+
+	for i := range invalid {
+		event := newPropagatorEvent()
+		assert.NotNil(t, NewW3CPropagator().Extract(MapCarrier{"traceparent": invalid[i]}, event), invalid[i])
+		assert.NotEqual(t, utTraceId, event.GetTraceId(), invalid[i])
+		assert.Len(t, event.GetTraceId(), 32, invalid[i])
+		assert.Empty(t, event.GetParentEventId(), invalid[i])
+	}
+}
+
+func TestW3CPropagator_Inject_HappyCase(t *testing.T) {
+	event := newPropagatorEvent()
+	event.SetEventId("a1b2c3d4-e5f6-4789-abcd-ef0123456789")
+	event.SetTraceId(utTraceId)
+	updateTraceContext(event, func(ctx *traceContext) {
+		ctx.state = "ut=value"
+	})
+
+	carrier := MapCarrier{}
+	NewW3CPropagator().Inject(event, carrier)
+
+	assert.Equal(t, "00-"+utTraceId+"-a1b2c3d4e5f64789-01", carrier.Get("traceparent"))
+	assert.Equal(t, "ut=value", carrier.Get("tracestate"))
+}
+
+func TestW3CPropagator_Inject_WithMissingIds(t *testing.T) {
+	event := newPropagatorEvent()
+	event.SetEventId("ut-event")
+
+	carrier := MapCarrier{}
+	NewW3CPropagator().Inject(event, carrier)
+
+	traceId, parentId, flags, err := parseTraceparent(carrier.Get("traceparent"))
+	assert.Nil(t, err)
+	assert.Equal(t, byte(1), flags)
+	assert.Equal(t, event.GetTraceId(), traceId)
+	assert.Len(t, parentId, 16)
+	assert.Empty(t, carrier.Get("tracestate"))
+}
+
+func TestW3CPropagator_Inject_WithUUIDTraceId(t *testing.T) {
+	event := newPropagatorEvent()
+	event.SetTraceId("4BF92F35-77B3-4DA6-A3CE-929D0E0E4736")
+
+	carrier := MapCarrier{}
+	NewW3CPropagator().Inject(event, carrier)
+
+	traceId, _, _, err := parseTraceparent(carrier.Get("traceparent"))
+	assert.Nil(t, err)
+	assert.Equal(t, utTraceId, traceId)
+	// trace id of event is kept as it is
+	assert.Equal(t, "4BF92F35-77B3-4DA6-A3CE-929D0E0E4736", event.GetTraceId())
+}
+
+func TestW3CPropagator_Inject_WithCustomTraceId(t *testing.T) {
+	event := newPropagatorEvent()
+	event.SetTraceId("ut-custom-trace")
+
+	first, second := MapCarrier{}, MapCarrier{}
+	NewW3CPropagator().Inject(event, first)
+	NewW3CPropagator().Inject(event, second)
+
+	traceId, _, _, err := parseTraceparent(first.Get("traceparent"))
+	assert.Nil(t, err)
+	assert.Len(t, traceId, 32)
+	// derived from trace id of event which is never replaced
+	assert.Equal(t, first.Get("traceparent")[:35], second.Get("traceparent")[:35])
+	assert.Equal(t, "ut-custom-trace", event.GetTraceId())
+
+	// extract does not replace custom trace id if traceparent is missing
+	assert.Nil(t, NewW3CPropagator().Extract(MapCarrier{}, event))
+	assert.Equal(t, "ut-custom-trace", event.GetTraceId())
+}
+
+func TestB3Propagator_Extract_WithSingleHeader(t *testing.T) {
+	event := newPropagatorEvent()
+	carrier := MapCarrier{"b3": utTraceId + "-" + utParentId + "-1-" + "05e3ac9a4f6e3b90"}
+
+	assert.Nil(t, NewB3Propagator().Extract(carrier, event))
+	assert.Equal(t, utTraceId, event.GetTraceId())
+	assert.Empty(t, event.GetParentEventId())
+	assert.Equal(t, utParentId, traceContextOf(event).parentSpanId)
+}
+
+func TestB3Propagator_Extract_WithMultiHeaders(t *testing.T) {
+	event := newPropagatorEvent()
+	header := http.Header{}
+	header.Set("X-B3-TraceId", "a3ce929d0e0e4736")
+	header.Set("X-B3-SpanId", utParentId)
+	header.Set("X-B3-Sampled", "1")
+
+	assert.Nil(t, NewB3Propagator().Extract(HeaderCarrier(header), event))
+	assert.Equal(t, "0000000000000000a3ce929d0e0e4736", event.GetTraceId())
+	assert.Empty(t, event.GetParentEventId())
+	assert.Equal(t, utParentId, traceContextOf(event).parentSpanId)
+}
+
+func TestB3Propagator_Extract_WithSamplingOnly(t *testing.T) {
+	event := newPropagatorEvent()
+
+	assert.Nil(t, NewB3Propagator().Extract(MapCarrier{"b3": "0"}, event))
+	assert.Len(t, event.GetTraceId(), 32)
+	assert.Empty(t, event.GetParentEventId())
+	assert.Equal(t, traceNotSampled, traceContextOf(event).sampling)
+
+	carrier := MapCarrier{}
+	NewB3Propagator(WithB3SingleHeader()).Inject(event, carrier)
+	assert.True(t, strings.HasSuffix(carrier.Get("b3"), "-0"))
+}
+
+func TestB3Propagator_Extract_WithSampling(t *testing.T) {
+	cases := []struct {
+		carrier  MapCarrier
+		expected traceSampling
+	}{
+		{MapCarrier{"b3": utTraceId + "-" + utParentId + "-0"}, traceNotSampled},
+		{MapCarrier{"b3": utTraceId + "-" + utParentId + "-d"}, traceSampled},
+		{MapCarrier{"b3": utTraceId + "-" + utParentId}, traceSamplingUnset},
+		{MapCarrier{"x-b3-traceid": utTraceId, "x-b3-spanid": utParentId, "x-b3-sampled": "0"}, traceNotSampled},
+		{MapCarrier{"x-b3-traceid": utTraceId, "x-b3-spanid": utParentId, "x-b3-sampled": "true"}, traceSampled},
+		{MapCarrier{"x-b3-traceid": utTraceId, "x-b3-spanid": utParentId, "x-b3-flags": "1"}, traceSampled},
+	}
+
+	for i := range cases {
+		event := newPropagatorEvent()
+		assert.Nil(t, NewB3Propagator().Extract(cases[i].carrier, event), i)
+		assert.Equal(t, cases[i].expected, traceContextOf(event).sampling, i)
+	}
+}
+
+func TestB3Propagator_Extract_WithInvalidHeaders(t *testing.T) {
+	invalid := []MapCarrier{
+		{"b3": utTraceId + "-" + utParentId + "-1-05e3ac9a4f6e3b90-ut"},
+		{"b3": "ut-" + utParentId},
+		{"b3": utTraceId + "-ut"},
+		{"x-b3-traceid": utTraceId},
+		{"x-b3-traceid": "4bf92f3577b34da6a3", "x-b3-spanid": utParentId},
+		{"x-b3-traceid": utTraceId, "x-b3-spanid": "0000000000000000"},
+	}
+
+	for i := range invalid {
+		event := newPropagatorEvent()
+		assert.NotNil(t, NewB3Propagator().Extract(invalid[i], event), i)
+		assert.Len(t, event.GetTraceId(), 32, i)
+		assert.Empty(t, event.GetParentEventId(), i)
+		assert.Empty(t, traceContextOf(event).parentSpanId, i)
+	}
+}
+
+func TestB3Propagator_Inject_WithExtractedSpanAndChild(t *testing.T) {
+	event := newPropagatorEvent()
+	event.SetEventId("a1b2c3d4-e5f6-4789-abcd-ef0123456789")
+	assert.Nil(t, NewB3Propagator().Extract(MapCarrier{"b3": utTraceId + "-" + utParentId + "-1"}, event))
+
+	// upstream span is parent of event
+	header := http.Header{}
+	NewB3Propagator().Inject(event, HeaderCarrier(header))
+	assert.Equal(t, "a1b2c3d4e5f64789", header.Get("X-B3-SpanId"))
+	assert.Equal(t, utParentId, header.Get("X-B3-ParentSpanId"))
+
+	// event is parent of child, and child is joined to event by parent event id
+	child := event.StartChild("ut-child")
+	assert.Equal(t, event.GetEventId(), child.GetParentEventId())
+	assert.Empty(t, traceContextOf(child).parentSpanId)
+
+	header = http.Header{}
+	NewB3Propagator().Inject(child, HeaderCarrier(header))
+	assert.Equal(t, utTraceId, header.Get("X-B3-TraceId"))
+	assert.Equal(t, "a1b2c3d4e5f64789", header.Get("X-B3-ParentSpanId"))
+}
+
+func TestB3Propagator_Inject_WithMultiHeaders(t *testing.T) {
+	event := newPropagatorEvent()
+	event.SetEventId("a1b2c3d4-e5f6-4789-abcd-ef0123456789")
+	event.SetTraceId(utTraceId)
+	event.SetParentEventId(utParentId)
+
+	header := http.Header{}
+	NewB3Propagator().Inject(event, HeaderCarrier(header))
+
+	assert.Equal(t, utTraceId, header.Get("X-B3-TraceId"))
+	assert.Equal(t, "a1b2c3d4e5f64789", header.Get("X-B3-SpanId"))
+	assert.Equal(t, utParentId, header.Get("X-B3-ParentSpanId"))
+	assert.Equal(t, "1", header.Get("X-B3-Sampled"))
+	assert.Empty(t, header.Get("b3"))
+}
+
+func TestB3Propagator_Inject_WithSingleHeader(t *testing.T) {
+	event := newPropagatorEvent()
+	event.SetEventId("a1b2c3d4-e5f6-4789-abcd-ef0123456789")
+	event.SetTraceId(utTraceId)
+	event.SetParentEventId("ut-parent")
+
+	carrier := MapCarrier{}
+	NewB3Propagator(WithB3SingleHeader()).Inject(event, carrier)
+
+	assert.Equal(t, utTraceId+"-a1b2c3d4e5f64789-1", carrier.Get("b3"))
+	assert.Empty(t, carrier.Get("X-B3-TraceId"))
+}
+
+func TestRequestIdPropagator_HappyCase(t *testing.T) {
+	event := newPropagatorEvent()
+	propagator := NewRequestIdPropagator("")
+
+	assert.Nil(t, propagator.Extract(MapCarrier{"x-request-id": "ut-request"}, event))
+	assert.Equal(t, "ut-request", event.GetRequestId())
+
+	carrier := MapCarrier{}
+	propagator.Inject(event, carrier)
+	assert.Equal(t, "ut-request", carrier.Get("X-Request-Id"))
+}
+
+func TestRequestIdPropagator_WithMissingOrInvalidId(t *testing.T) {
+	event := newPropagatorEvent()
+	propagator := NewRequestIdPropagator("X-Ut-Request-Id")
+
+	assert.Nil(t, propagator.Extract(MapCarrier{}, event))
+	assert.NotEmpty(t, event.GetRequestId())
+
+	event = newPropagatorEvent()
+	assert.NotNil(t, propagator.Extract(MapCarrier{"x-ut-request-id": "ut\nrequest"}, event))
+	assert.NotEmpty(t, event.GetRequestId())
+	assert.NotEqual(t, "ut\nrequest", event.GetRequestId())
+
+	event = newPropagatorEvent()
+	carrier := MapCarrier{}
+	propagator.Inject(event, carrier)
+	assert.Equal(t, event.GetRequestId(), carrier.Get("X-Ut-Request-Id"))
+	assert.NotEmpty(t, event.GetRequestId())
+}
+
+func TestCompositePropagator_HappyCase(t *testing.T) {
+	propagator := NewCompositePropagator(NewW3CPropagator(), NewB3Propagator(), NewRequestIdPropagator(""))
+
+	// b3 overrides traceparent
+	event := newPropagatorEvent()
+	carrier := MapCarrier{
+		"traceparent":  "00-" + utTraceId + "-" + utParentId + "-01",
+		"b3":           "0000000000000000a3ce929d0e0e4736-05e3ac9a4f6e3b90",
+		"x-request-id": "ut-request",
+	}
+	assert.Nil(t, propagator.Extract(carrier, event))
+	assert.Equal(t, "0000000000000000a3ce929d0e0e4736", event.GetTraceId())
+	assert.Equal(t, "05e3ac9a4f6e3b90", traceContextOf(event).parentSpanId)
+	assert.Equal(t, "ut-request", event.GetRequestId())
+
+	// first error returned while rest of propagators applied
+	event = newPropagatorEvent()
+	assert.NotNil(t, propagator.Extract(MapCarrier{"traceparent": "ut", "x-request-id": "ut-request"}, event))
+	assert.Equal(t, "ut-request", event.GetRequestId())
+
+	out := MapCarrier{}
+	propagator.Inject(event, out)
+	assert.NotEmpty(t, out.Get("traceparent"))
+	assert.Equal(t, event.GetTraceId(), out.Get("X-B3-TraceId"))
+	assert.Equal(t, "ut-request", out.Get("X-Request-Id"))
+}
+
+func TestPropagator_WithThreadSafeAndNoopEvent(t *testing.T) {
+	event := NewEventFactory().CreateEventThreadSafe(WithQuietMode(true))
+	assert.Nil(t, NewW3CPropagator().Extract(MapCarrier{"traceparent": "00-" + utTraceId + "-" + utParentId + "-01"}, event))
+	assert.Equal(t, utTraceId, event.GetTraceId())
+
+	assert.NotPanics(t, func() {
+		noop := NewEventFactory().CreateEventNoop()
+		propagator := NewCompositePropagator(NewW3CPropagator(), NewB3Propagator(), NewRequestIdPropagator(""))
+		propagator.Extract(MapCarrier{}, noop)
+		propagator.Inject(noop, MapCarrier{})
+	})
+}
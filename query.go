@@ -0,0 +1,1093 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/spf13/cast"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// Columns selected by SELECT *
+	queryDefaultColumns = []string{startTimeKey, operationKey, resCodeKey, elapsedKey, eventIdKey, remoteAddrKey}
+)
+
+// Query is a parsed query which could be executed over records multiple times.
+//
+// Fields missing in record are NULL, NULL equals to NULL only and any other comparison with NULL is false.
+// Aggregate functions are count(), count(expr), sum(), avg(), min(), max() and percentiles like p50(), p99()
+// and p99.9(). Scalar functions are lower(), upper() and coalesce().
+type Query struct {
+	text    string
+	columns []*column
+	where   expr
+	groupBy []expr
+	having  expr
+	orderBy []*orderItem
+	limit   int
+	// number of columns returned to user, rest of columns are used by ORDER BY only
+	visible int
+	// aggregate functions in columns and HAVING
+	aggs []*callExpr
+}
+
+// Column in SELECT.
+type column struct {
+	expr  expr
+	alias string
+}
+
+// Name of column, alias will be used if exists.
+func (col *column) name() string {
+	if len(col.alias) > 0 {
+		return col.alias
+	}
+
+	return col.expr.String()
+}
+
+// Item in ORDER BY.
+type orderItem struct {
+	expr  expr
+	desc  bool
+	index int
+}
+
+// QueryResult is the result of query.
+type QueryResult struct {
+	Columns []string
+	Rows    [][]interface{}
+}
+
+// StringRows returns rows with values formatted as strings, NULL is formatted as empty string.
+func (res *QueryResult) StringRows() [][]string {
+	rows := make([][]string, len(res.Rows))
+	for i := range res.Rows {
+		rows[i] = make([]string, len(res.Rows[i]))
+		for j := range res.Rows[i] {
+			rows[i][j] = formatValue(res.Rows[i][j])
+		}
+	}
+
+	return rows
+}
+
+// String returns query text.
+func (query *Query) String() string {
+	return query.text
+}
+
+// Is query grouped by GROUP BY or aggregate functions?
+func (query *Query) grouped() bool {
+	return len(query.groupBy) > 0 || len(query.aggs) > 0
+}
+
+// Validate query, collect aggregate functions and resolve GROUP BY and ORDER BY items into columns.
+func (query *Query) compile() error {
+	query.visible = len(query.columns)
+
+	for i := range query.columns {
+		if err := query.collectAggs(query.columns[i].expr); err != nil {
+			return err
+		}
+	}
+
+	if query.where != nil {
+		if err := checkNoAggs(query.where, "WHERE"); err != nil {
+			return err
+		}
+	}
+
+	for i := range query.groupBy {
+		if err := query.resolveGroup(i); err != nil {
+			return err
+		}
+
+		if err := checkNoAggs(query.groupBy[i], "GROUP BY"); err != nil {
+			return err
+		}
+	}
+
+	if query.having != nil {
+		query.resolveAliases(query.having)
+		if err := query.collectAggs(query.having); err != nil {
+			return err
+		}
+	}
+
+	for _, item := range query.orderBy {
+		if err := query.resolveOrder(item); err != nil {
+			return err
+		}
+	}
+
+	if query.having != nil && !query.grouped() {
+		return fmt.Errorf("HAVING requires GROUP BY or aggregate functions")
+	}
+
+	if query.grouped() {
+		for i := range query.columns {
+			if err := query.checkGrouped(query.columns[i].expr); err != nil {
+				return err
+			}
+		}
+
+		if err := query.checkGrouped(query.having); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Resolve GROUP BY item with position or alias of column.
+func (query *Query) resolveGroup(i int) error {
+	switch v := query.groupBy[i].(type) {
+	case *literalExpr:
+		pos, ok := v.value.(int64)
+		if !ok || pos < 1 || int(pos) > query.visible {
+			return fmt.Errorf("invalid position %v in GROUP BY", v.value)
+		}
+
+		query.groupBy[i] = query.columns[pos-1].expr
+	case *fieldExpr:
+		for j := 0; j < query.visible; j++ {
+			if col := query.columns[j]; len(col.alias) > 0 && col.alias == v.path {
+				query.groupBy[i] = col.expr
+			}
+		}
+	}
+
+	return nil
+}
+
+// Returns error if expression refers to fields which are neither in GROUP BY nor in aggregate functions,
+// since value of them is from an arbitrary record of group.
+func (query *Query) checkGrouped(e expr) error {
+	var err error
+
+	walkExpr(e, func(node expr) bool {
+		if err != nil {
+			return false
+		}
+
+		for i := range query.groupBy {
+			if node.String() == query.groupBy[i].String() {
+				return false
+			}
+		}
+
+		switch v := node.(type) {
+		case *callExpr:
+			return !v.aggregate()
+		case *fieldExpr:
+			if v.alias != nil {
+				err = query.checkGrouped(v.alias)
+			} else {
+				err = fmt.Errorf("%s must appear in GROUP BY or be used in aggregate function", v.path)
+			}
+			return false
+		}
+
+		return true
+	})
+
+	return err
+}
+
+// Resolve fields in expression which refer to alias of columns.
+func (query *Query) resolveAliases(e expr) {
+	walkExpr(e, func(node expr) bool {
+		field, ok := node.(*fieldExpr)
+		if !ok {
+			return true
+		}
+
+		for i := 0; i < query.visible; i++ {
+			if col := query.columns[i]; len(col.alias) > 0 && col.alias == field.path {
+				field.alias = col.expr
+			}
+		}
+		return false
+	})
+}
+
+// Resolve ORDER BY item with position, alias or expression of column.
+// Expression which is not selected will be added as invisible column.
+func (query *Query) resolveOrder(item *orderItem) error {
+	if lit, ok := item.expr.(*literalExpr); ok {
+		pos, ok := lit.value.(int64)
+		if !ok || pos < 1 || int(pos) > query.visible {
+			return fmt.Errorf("invalid position %v in ORDER BY", lit.value)
+		}
+
+		item.index = int(pos) - 1
+		return nil
+	}
+
+	for i := 0; i < query.visible; i++ {
+		col := query.columns[i]
+		if field, ok := item.expr.(*fieldExpr); ok && field.path == col.alias {
+			item.index = i
+			return nil
+		}
+
+		if item.expr.String() == col.expr.String() {
+			item.index = i
+			return nil
+		}
+	}
+
+	if err := query.collectAggs(item.expr); err != nil {
+		return err
+	}
+
+	item.index = len(query.columns)
+	query.columns = append(query.columns, &column{expr: item.expr})
+	return nil
+}
+
+// Collect aggregate functions in expression.
+func (query *Query) collectAggs(e expr) error {
+	var err error
+
+	walkExpr(e, func(node expr) bool {
+		call, ok := node.(*callExpr)
+		if !ok || !call.aggregate() {
+			return true
+		}
+
+		for i := range call.args {
+			if err == nil {
+				err = checkNoAggs(call.args[i], call.name+"()")
+			}
+		}
+
+		call.aggIndex = len(query.aggs)
+		query.aggs = append(query.aggs, call)
+		return false
+	})
+
+	return err
+}
+
+// Returns error if expression contains aggregate functions.
+func checkNoAggs(e expr, clause string) error {
+	var err error
+
+	walkExpr(e, func(node expr) bool {
+		if call, ok := node.(*callExpr); ok && call.aggregate() && err == nil {
+			err = fmt.Errorf("aggregate function %s() is not allowed in %s", call.name, clause)
+		}
+		return err == nil
+	})
+
+	return err
+}
+
+// NewExecutor creates a new executor of query.
+func (query *Query) NewExecutor() *QueryExecutor {
+	return &QueryExecutor{
+		query:  query,
+		groups: make(map[string]*queryGroup),
+		rows:   make([][]interface{}, 0),
+	}
+}
+
+// Execute query over all records from reader, malformed events are skipped.
+func (query *Query) Execute(reader *Reader) (*QueryResult, error) {
+	executor := query.NewExecutor()
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return executor.Result(), nil
+		}
+
+		var parseErr *ParseError
+		if errors.As(err, &parseErr) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		executor.Add(record)
+	}
+}
+
+// ExecuteQuery parses query and executes it over all records from reader.
+func ExecuteQuery(str string, reader *Reader) (*QueryResult, error) {
+	query, err := ParseQuery(str)
+	if err != nil {
+		return nil, err
+	}
+
+	return query.Execute(reader)
+}
+
+// QueryExecutor executes query over records added one by one.
+//
+// QueryExecutor is not thread safe.
+type QueryExecutor struct {
+	query  *Query
+	groups map[string]*queryGroup
+	order  []*queryGroup
+	rows   [][]interface{}
+}
+
+// Records in the same group.
+type queryGroup struct {
+	first *Record
+	accs  []accumulator
+}
+
+// Add record into executor.
+func (executor *QueryExecutor) Add(record *Record) {
+	query := executor.query
+	if record == nil {
+		return
+	}
+
+	if query.where != nil && !truthy(query.where.eval(&evalContext{record: record})) {
+		return
+	}
+
+	if !query.grouped() {
+		// rows after limit are useless if not sorted
+		if len(query.orderBy) < 1 && query.limit >= 0 && len(executor.rows) >= query.limit {
+			return
+		}
+
+		executor.rows = append(executor.rows, evalColumns(query.columns, &evalContext{record: record}))
+		return
+	}
+
+	ctx := &evalContext{record: record}
+	keys := make([]string, len(query.groupBy))
+	for i := range query.groupBy {
+		keys[i] = groupKeyOf(query.groupBy[i].eval(ctx))
+	}
+	key := strings.Join(keys, "\x00")
+
+	group, ok := executor.groups[key]
+	if !ok {
+		group = &queryGroup{first: record, accs: make([]accumulator, len(query.aggs))}
+		for i := range query.aggs {
+			group.accs[i] = newAccumulator(query.aggs[i])
+		}
+		executor.groups[key] = group
+		executor.order = append(executor.order, group)
+	}
+
+	for i, call := range query.aggs {
+		if len(call.args) < 1 {
+			group.accs[i].add(true)
+			continue
+		}
+		group.accs[i].add(call.args[0].eval(ctx))
+	}
+}
+
+// Result returns result of records added so far.
+func (executor *QueryExecutor) Result() *QueryResult {
+	query := executor.query
+	rows := executor.rows
+
+	if query.grouped() {
+		rows = make([][]interface{}, 0, len(executor.order))
+		for _, group := range executor.order {
+			ctx := &evalContext{record: group.first, accs: group.accs}
+			if query.having != nil && !truthy(query.having.eval(ctx)) {
+				continue
+			}
+			rows = append(rows, evalColumns(query.columns, ctx))
+		}
+
+		// aggregate functions without GROUP BY returns one row even there is no records
+		if len(query.groupBy) < 1 && len(executor.order) < 1 {
+			accs := make([]accumulator, len(query.aggs))
+			for i := range query.aggs {
+				accs[i] = newAccumulator(query.aggs[i])
+			}
+			rows = append(rows, evalColumns(query.columns, &evalContext{record: newRecord(), accs: accs}))
+		}
+	}
+
+	if len(query.orderBy) > 0 {
+		sorted := make([][]interface{}, len(rows))
+		copy(sorted, rows)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			for _, item := range query.orderBy {
+				res := compareForSort(sorted[i][item.index], sorted[j][item.index])
+				if res == 0 {
+					continue
+				}
+				if item.desc {
+					return res > 0
+				}
+				return res < 0
+			}
+			return false
+		})
+		rows = sorted
+	}
+
+	if query.limit >= 0 && len(rows) > query.limit {
+		rows = rows[:query.limit]
+	}
+
+	res := &QueryResult{
+		Columns: make([]string, query.visible),
+		Rows:    make([][]interface{}, len(rows)),
+	}
+
+	for i := 0; i < query.visible; i++ {
+		res.Columns[i] = query.columns[i].name()
+	}
+
+	for i := range rows {
+		res.Rows[i] = rows[i][:query.visible]
+	}
+
+	return res
+}
+
+// Evaluate columns with context.
+func evalColumns(columns []*column, ctx *evalContext) []interface{} {
+	row := make([]interface{}, len(columns))
+	for i := range columns {
+		row[i] = columns[i].expr.eval(ctx)
+	}
+
+	return row
+}
+
+// Returns key of value used for grouping.
+func groupKeyOf(val interface{}) string {
+	if val == nil {
+		return "\x01"
+	}
+
+	return formatValue(val)
+}
+
+// ************* Expressions *************
+
+// Context of evaluation.
+type evalContext struct {
+	record *Record
+	accs   []accumulator
+}
+
+// Expression in query.
+type expr interface {
+	eval(ctx *evalContext) interface{}
+	String() string
+}
+
+// Walk expression tree, children will be skipped if fn returns false.
+func walkExpr(e expr, fn func(expr) bool) {
+	if e == nil || !fn(e) {
+		return
+	}
+
+	switch v := e.(type) {
+	case *logicExpr:
+		walkExpr(v.left, fn)
+		walkExpr(v.right, fn)
+	case *notExpr:
+		walkExpr(v.operand, fn)
+	case *compareExpr:
+		walkExpr(v.left, fn)
+		walkExpr(v.right, fn)
+	case *isNullExpr:
+		walkExpr(v.operand, fn)
+	case *likeExpr:
+		walkExpr(v.operand, fn)
+	case *inExpr:
+		walkExpr(v.operand, fn)
+		for i := range v.list {
+			walkExpr(v.list[i], fn)
+		}
+	case *callExpr:
+		for i := range v.args {
+			walkExpr(v.args[i], fn)
+		}
+	}
+}
+
+// Literal value.
+type literalExpr struct {
+	value interface{}
+}
+
+func (e *literalExpr) eval(*evalContext) interface{} {
+	return e.value
+}
+
+func (e *literalExpr) String() string {
+	if str, ok := e.value.(string); ok {
+		return strconv.Quote(str)
+	}
+
+	if e.value == nil {
+		return "NULL"
+	}
+
+	return formatValue(e.value)
+}
+
+// Field of record.
+type fieldExpr struct {
+	path string
+	// expression of column if path is an alias of column
+	alias expr
+}
+
+func (e *fieldExpr) eval(ctx *evalContext) interface{} {
+	if e.alias != nil {
+		return e.alias.eval(ctx)
+	}
+
+	if val, ok := ctx.record.Value(e.path); ok {
+		return val
+	}
+
+	return nil
+}
+
+func (e *fieldExpr) String() string {
+	return e.path
+}
+
+// AND and OR.
+type logicExpr struct {
+	op    string
+	left  expr
+	right expr
+}
+
+func (e *logicExpr) eval(ctx *evalContext) interface{} {
+	if e.op == "AND" {
+		return truthy(e.left.eval(ctx)) && truthy(e.right.eval(ctx))
+	}
+
+	return truthy(e.left.eval(ctx)) || truthy(e.right.eval(ctx))
+}
+
+func (e *logicExpr) String() string {
+	return "(" + e.left.String() + " " + e.op + " " + e.right.String() + ")"
+}
+
+// NOT.
+type notExpr struct {
+	operand expr
+}
+
+func (e *notExpr) eval(ctx *evalContext) interface{} {
+	return !truthy(e.operand.eval(ctx))
+}
+
+func (e *notExpr) String() string {
+	return "NOT " + e.operand.String()
+}
+
+// Comparison with =, !=, <, <=, > and >=.
+type compareExpr struct {
+	op    string
+	left  expr
+	right expr
+}
+
+func (e *compareExpr) eval(ctx *evalContext) interface{} {
+	left, right := e.left.eval(ctx), e.right.eval(ctx)
+
+	switch e.op {
+	case "=":
+		return valuesEqual(left, right)
+	case "!=":
+		return !valuesEqual(left, right)
+	}
+
+	res, ok := compareValues(left, right)
+	if !ok {
+		return false
+	}
+
+	switch e.op {
+	case "<":
+		return res < 0
+	case "<=":
+		return res <= 0
+	case ">":
+		return res > 0
+	default:
+		return res >= 0
+	}
+}
+
+func (e *compareExpr) String() string {
+	return e.left.String() + " " + e.op + " " + e.right.String()
+}
+
+// IS NULL and IS NOT NULL.
+type isNullExpr struct {
+	operand expr
+	not     bool
+}
+
+func (e *isNullExpr) eval(ctx *evalContext) interface{} {
+	return (e.operand.eval(ctx) == nil) != e.not
+}
+
+func (e *isNullExpr) String() string {
+	if e.not {
+		return e.operand.String() + " IS NOT NULL"
+	}
+
+	return e.operand.String() + " IS NULL"
+}
+
+// LIKE and NOT LIKE.
+type likeExpr struct {
+	operand expr
+	pattern *regexp.Regexp
+	not     bool
+	text    string
+}
+
+func (e *likeExpr) eval(ctx *evalContext) interface{} {
+	val := e.operand.eval(ctx)
+	if val == nil {
+		return false
+	}
+
+	return e.pattern.MatchString(formatValue(val)) != e.not
+}
+
+func (e *likeExpr) String() string {
+	if e.not {
+		return e.operand.String() + " NOT LIKE " + strconv.Quote(e.text)
+	}
+
+	return e.operand.String() + " LIKE " + strconv.Quote(e.text)
+}
+
+// IN and NOT IN.
+type inExpr struct {
+	operand expr
+	list    []expr
+	not     bool
+}
+
+func (e *inExpr) eval(ctx *evalContext) interface{} {
+	val := e.operand.eval(ctx)
+	if val == nil {
+		return false
+	}
+
+	for i := range e.list {
+		if valuesEqual(val, e.list[i].eval(ctx)) {
+			return !e.not
+		}
+	}
+
+	return e.not
+}
+
+func (e *inExpr) String() string {
+	items := make([]string, len(e.list))
+	for i := range e.list {
+		items[i] = e.list[i].String()
+	}
+
+	if e.not {
+		return e.operand.String() + " NOT IN (" + strings.Join(items, ", ") + ")"
+	}
+
+	return e.operand.String() + " IN (" + strings.Join(items, ", ") + ")"
+}
+
+// Function call.
+type callExpr struct {
+	name     string
+	args     []expr
+	aggIndex int
+}
+
+// Is function an aggregate function?
+func (e *callExpr) aggregate() bool {
+	switch e.name {
+	case "count", "sum", "avg", "min", "max":
+		return true
+	}
+
+	return percentileFunc.MatchString(e.name)
+}
+
+// Validate name and number of arguments.
+func (e *callExpr) validate() error {
+	switch {
+	case e.name == "count":
+		if len(e.args) > 1 {
+			return fmt.Errorf("count() accepts at most one argument")
+		}
+	case e.name == "coalesce":
+		if len(e.args) < 1 {
+			return fmt.Errorf("coalesce() requires arguments")
+		}
+	case e.aggregate(), e.name == "lower", e.name == "upper":
+		if len(e.args) != 1 {
+			return fmt.Errorf("%s() accepts exactly one argument", e.name)
+		}
+	default:
+		return fmt.Errorf("unknown function %s()", e.name)
+	}
+
+	return nil
+}
+
+func (e *callExpr) eval(ctx *evalContext) interface{} {
+	if e.aggIndex >= 0 {
+		return ctx.accs[e.aggIndex].result()
+	}
+
+	switch e.name {
+	case "lower", "upper":
+		val := e.args[0].eval(ctx)
+		if val == nil {
+			return nil
+		}
+		if e.name == "lower" {
+			return strings.ToLower(formatValue(val))
+		}
+		return strings.ToUpper(formatValue(val))
+	case "coalesce":
+		for i := range e.args {
+			if val := e.args[i].eval(ctx); val != nil {
+				return val
+			}
+		}
+	}
+
+	return nil
+}
+
+func (e *callExpr) String() string {
+	args := make([]string, len(e.args))
+	for i := range e.args {
+		args[i] = e.args[i].String()
+	}
+
+	return e.name + "(" + strings.Join(args, ", ") + ")"
+}
+
+// ************* Accumulators *************
+
+// Accumulator of aggregate function.
+type accumulator interface {
+	add(val interface{})
+	result() interface{}
+}
+
+// Create accumulator of aggregate function.
+func newAccumulator(call *callExpr) accumulator {
+	switch call.name {
+	case "count":
+		return &countAccumulator{}
+	case "sum":
+		return &sumAccumulator{allInt: true}
+	case "avg":
+		return &sumAccumulator{avg: true}
+	case "min":
+		return &extremeAccumulator{}
+	case "max":
+		return &extremeAccumulator{max: true}
+	}
+
+	p, _ := strconv.ParseFloat(strings.TrimPrefix(call.name, "p"), 64)
+	return &percentileAccumulator{p: p, allInt: true}
+}
+
+// count() counts non NULL values.
+type countAccumulator struct {
+	count int64
+}
+
+func (acc *countAccumulator) add(val interface{}) {
+	if val != nil {
+		acc.count++
+	}
+}
+
+func (acc *countAccumulator) result() interface{} {
+	return acc.count
+}
+
+// sum() and avg() of numeric values, sum() returns int64 if all values are integers.
+type sumAccumulator struct {
+	avg    bool
+	allInt bool
+	sumInt int64
+	sum    float64
+	count  int64
+}
+
+func (acc *sumAccumulator) add(val interface{}) {
+	f, ok := toNumber(val)
+	if !ok {
+		return
+	}
+
+	if i, ok := val.(int64); ok {
+		acc.sumInt += i
+	} else {
+		acc.allInt = false
+	}
+
+	acc.sum += f
+	acc.count++
+}
+
+func (acc *sumAccumulator) result() interface{} {
+	if acc.count < 1 {
+		return nil
+	}
+
+	if acc.avg {
+		return acc.sum / float64(acc.count)
+	}
+
+	if acc.allInt {
+		return acc.sumInt
+	}
+
+	return acc.sum
+}
+
+// min() and max() of any comparable values.
+type extremeAccumulator struct {
+	max bool
+	val interface{}
+}
+
+func (acc *extremeAccumulator) add(val interface{}) {
+	if val == nil {
+		return
+	}
+
+	if acc.val == nil {
+		acc.val = val
+		return
+	}
+
+	res, ok := compareValues(val, acc.val)
+	if ok && ((acc.max && res > 0) || (!acc.max && res < 0)) {
+		acc.val = val
+	}
+}
+
+func (acc *extremeAccumulator) result() interface{} {
+	return acc.val
+}
+
+// Percentile of numeric values with nearest-rank method.
+type percentileAccumulator struct {
+	p      float64
+	allInt bool
+	values []float64
+}
+
+func (acc *percentileAccumulator) add(val interface{}) {
+	f, ok := toNumber(val)
+	if !ok {
+		return
+	}
+
+	if _, ok := val.(int64); !ok {
+		acc.allInt = false
+	}
+
+	acc.values = append(acc.values, f)
+}
+
+func (acc *percentileAccumulator) result() interface{} {
+	if len(acc.values) < 1 {
+		return nil
+	}
+
+	sort.Float64s(acc.values)
+	rank := int(math.Ceil(acc.p / 100 * float64(len(acc.values))))
+	if rank < 1 {
+		rank = 1
+	}
+
+	res := acc.values[rank-1]
+	if acc.allInt {
+		return int64(res)
+	}
+
+	return res
+}
+
+// ************* Values *************
+
+// Is value true? NULL, false, zero and empty string are false.
+func truthy(val interface{}) bool {
+	switch v := val.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return len(v) > 0
+	}
+
+	if f, ok := toNumber(val); ok {
+		return f != 0
+	}
+
+	return true
+}
+
+// Convert numeric value into float64.
+func toNumber(val interface{}) (float64, bool) {
+	switch v := val.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	}
+
+	return 0, false
+}
+
+// NULL equals to NULL only.
+func valuesEqual(left, right interface{}) bool {
+	if left == nil || right == nil {
+		return left == nil && right == nil
+	}
+
+	res, ok := compareValues(left, right)
+	return ok && res == 0
+}
+
+// Compare values, false would be returned if values are not comparable.
+//
+// Numbers are compared numerically, string will be converted into number or time if compared with them.
+// Otherwise, values are compared as strings.
+func compareValues(left, right interface{}) (int, bool) {
+	if left == nil || right == nil {
+		return 0, false
+	}
+
+	lf, lok := toNumber(left)
+	rf, rok := toNumber(right)
+	if lok || rok {
+		if !lok {
+			f, err := strconv.ParseFloat(formatValue(left), 64)
+			lf, lok = f, err == nil
+		}
+		if !rok {
+			f, err := strconv.ParseFloat(formatValue(right), 64)
+			rf, rok = f, err == nil
+		}
+
+		if lok && rok {
+			return compareFloat(lf, rf), true
+		}
+	}
+
+	lt, lok := left.(time.Time)
+	rt, rok := right.(time.Time)
+	if lok || rok {
+		if !lok {
+			lt, lok = parseQueryTime(left)
+		}
+		if !rok {
+			rt, rok = parseQueryTime(right)
+		}
+
+		if lok && rok {
+			return compareFloat(float64(lt.UnixNano()), float64(rt.UnixNano())), true
+		}
+	}
+
+	return strings.Compare(formatValue(left), formatValue(right)), true
+}
+
+// Compare values for sorting, NULL is the smallest value.
+func compareForSort(left, right interface{}) int {
+	switch {
+	case left == nil && right == nil:
+		return 0
+	case left == nil:
+		return -1
+	case right == nil:
+		return 1
+	}
+
+	res, _ := compareValues(left, right)
+	return res
+}
+
+// Compare float values.
+func compareFloat(left, right float64) int {
+	switch {
+	case left < right:
+		return -1
+	case left > right:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Parse time in query with RFC3339 format.
+func parseQueryTime(val interface{}) (time.Time, bool) {
+	str, ok := val.(string)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, str)
+	return t, err == nil
+}
+
+// Format value as string.
+func formatValue(val interface{}) string {
+	switch v := val.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+
+	if str, err := cast.ToStringE(val); err == nil {
+		return str
+	}
+
+	// sections
+	bytes, _ := json.Marshal(val)
+	return string(bytes)
+}
@@ -0,0 +1,616 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"fmt"
+	"github.com/spf13/cast"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenSymbol
+)
+
+// Token of query.
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+// Is token the keyword? Keywords are case insensitive.
+func (tok token) is(keyword string) bool {
+	return tok.kind == tokenIdent && strings.EqualFold(tok.value, keyword)
+}
+
+// Is token the symbol?
+func (tok token) isSymbol(symbol string) bool {
+	return tok.kind == tokenSymbol && tok.value == symbol
+}
+
+var (
+	// Keywords which could not be used as field without quoting.
+	queryKeywords = map[string]bool{
+		"SELECT": true, "FROM": true, "WHERE": true, "GROUP": true, "BY": true, "HAVING": true,
+		"ORDER": true, "ASC": true, "DESC": true, "LIMIT": true, "AS": true, "AND": true,
+		"OR": true, "NOT": true, "LIKE": true, "IN": true, "IS": true, "NULL": true,
+		"TRUE": true, "FALSE": true,
+	}
+
+	// Symbols could be used in query.
+	querySymbols = map[string]bool{
+		"(": true, ")": true, ",": true, "*": true, "=": true, "!=": true,
+		"<>": true, "<": true, "<=": true, ">": true, ">=": true,
+	}
+
+	// Percentile functions like p50, p99 and p99.9
+	percentileFunc = regexp.MustCompile(`^p(\d{1,2}(\.\d+)?|100)$`)
+)
+
+// Split query into tokens.
+func tokenize(str string) ([]token, error) {
+	res := make([]token, 0)
+	runes := []rune(str)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '\'' || r == '"':
+			start := i
+			builder := strings.Builder{}
+			for i++; ; i++ {
+				if i >= len(runes) {
+					return nil, fmt.Errorf("unterminated string at %d", start)
+				}
+
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+					builder.WriteRune(runes[i])
+					continue
+				}
+
+				if runes[i] == r {
+					i++
+					break
+				}
+
+				builder.WriteRune(runes[i])
+			}
+			res = append(res, token{kind: tokenString, value: builder.String(), pos: start})
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1]) && !lastIsOperand(res)):
+			start := i
+			for i++; i < len(runes) && (unicode.IsDigit(runes[i]) || strings.ContainsRune(".eE", runes[i]) ||
+				(strings.ContainsRune("+-", runes[i]) && strings.ContainsRune("eE", runes[i-1]))); i++ {
+			}
+			res = append(res, token{kind: tokenNumber, value: string(runes[start:i]), pos: start})
+		case isIdentStart(r) || r == '`':
+			start := i
+			builder := strings.Builder{}
+			for i < len(runes) && (isIdentPart(runes[i]) || runes[i] == '`') {
+				if runes[i] != '`' {
+					builder.WriteRune(runes[i])
+					i++
+					continue
+				}
+
+				// quoted part of identifier could contain any characters
+				end := strings.IndexRune(string(runes[i+1:]), '`')
+				if end < 0 {
+					return nil, fmt.Errorf("unterminated identifier at %d", i)
+				}
+				quoted := []rune(string(runes[i+1:])[:end])
+				builder.WriteString(string(quoted))
+				i += len(quoted) + 2
+			}
+			res = append(res, token{kind: tokenIdent, value: builder.String(), pos: start})
+		default:
+			start := i
+			symbol := string(r)
+			if i+1 < len(runes) {
+				switch two := string(runes[i : i+2]); two {
+				case "!=", "<>", "<=", ">=":
+					symbol = two
+				}
+			}
+
+			if !querySymbols[symbol] {
+				return nil, fmt.Errorf("unexpected character %q at %d", r, start)
+			}
+
+			i += len([]rune(symbol))
+			res = append(res, token{kind: tokenSymbol, value: symbol, pos: start})
+		}
+	}
+
+	return append(res, token{kind: tokenEOF, pos: len(runes)}), nil
+}
+
+// Is last token an operand? Used to distinguish negative number from identifier with hyphen.
+func lastIsOperand(tokens []token) bool {
+	if len(tokens) < 1 {
+		return false
+	}
+
+	last := tokens[len(tokens)-1]
+	return last.kind == tokenNumber || last.kind == tokenString || last.isSymbol(")")
+}
+
+// Is rune the first character of identifier?
+func isIdentStart(r rune) bool {
+	return unicode.IsLetter(r) || r == '_' || r == '@'
+}
+
+// Is rune part of identifier? Hyphen, dot and slash are allowed in keys of sections.
+func isIdentPart(r rune) bool {
+	return isIdentStart(r) || unicode.IsDigit(r) || r == '-' || r == '.' || r == '/' || r == ':'
+}
+
+// Recursive descent parser of query.
+type queryParser struct {
+	tokens []token
+	pos    int
+}
+
+// ParseQuery parses query with SQL like syntax.
+//
+//	SELECT expr [AS alias], ... [WHERE expr] [GROUP BY expr, ...] [HAVING expr]
+//	[ORDER BY expr|alias|position [ASC|DESC], ...] [LIMIT n]
+//
+// Please refer Record.Value() for fields could be used in expression.
+func ParseQuery(str string) (*Query, error) {
+	tokens, err := tokenize(str)
+	if err != nil {
+		return nil, fmt.Errorf("rkquery: %v", err)
+	}
+
+	parser := &queryParser{tokens: tokens}
+	query, err := parser.parseQuery()
+	if err != nil {
+		return nil, fmt.Errorf("rkquery: %v", err)
+	}
+
+	query.text = str
+	if err := query.compile(); err != nil {
+		return nil, fmt.Errorf("rkquery: %v", err)
+	}
+
+	return query, nil
+}
+
+// Returns current token.
+func (parser *queryParser) peek() token {
+	return parser.tokens[parser.pos]
+}
+
+// Returns current token and move to next one.
+func (parser *queryParser) next() token {
+	tok := parser.tokens[parser.pos]
+	if tok.kind != tokenEOF {
+		parser.pos++
+	}
+
+	return tok
+}
+
+// Consume keyword if current token is the keyword.
+func (parser *queryParser) accept(keyword string) bool {
+	if parser.peek().is(keyword) {
+		parser.pos++
+		return true
+	}
+
+	return false
+}
+
+// Consume symbol if current token is the symbol.
+func (parser *queryParser) acceptSymbol(symbol string) bool {
+	if parser.peek().isSymbol(symbol) {
+		parser.pos++
+		return true
+	}
+
+	return false
+}
+
+// Consume keyword or returns error.
+func (parser *queryParser) expect(keyword string) error {
+	if !parser.accept(keyword) {
+		return parser.unexpected(keyword)
+	}
+
+	return nil
+}
+
+// Consume symbol or returns error.
+func (parser *queryParser) expectSymbol(symbol string) error {
+	if !parser.acceptSymbol(symbol) {
+		return parser.unexpected(symbol)
+	}
+
+	return nil
+}
+
+// Error of unexpected token.
+func (parser *queryParser) unexpected(expected string) error {
+	tok := parser.peek()
+	if tok.kind == tokenEOF {
+		return fmt.Errorf("expected %s but got end of query", expected)
+	}
+
+	return fmt.Errorf("expected %s but got %q at %d", expected, tok.value, tok.pos)
+}
+
+// query := SELECT items [FROM ident] [WHERE expr] [GROUP BY exprs] [HAVING expr] [ORDER BY orders] [LIMIT n]
+func (parser *queryParser) parseQuery() (*Query, error) {
+	query := &Query{limit: -1}
+
+	if err := parser.expect("SELECT"); err != nil {
+		return nil, err
+	}
+
+	if err := parser.parseSelect(query); err != nil {
+		return nil, err
+	}
+
+	// source is provided by caller, FROM is accepted for readability only
+	if parser.accept("FROM") {
+		if parser.next().kind != tokenIdent {
+			return nil, fmt.Errorf("expected source after FROM")
+		}
+	}
+
+	var err error
+	if parser.accept("WHERE") {
+		if query.where, err = parser.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+
+	if parser.accept("GROUP") {
+		if err := parser.expect("BY"); err != nil {
+			return nil, err
+		}
+
+		for {
+			expr, err := parser.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			query.groupBy = append(query.groupBy, expr)
+
+			if !parser.acceptSymbol(",") {
+				break
+			}
+		}
+	}
+
+	if parser.accept("HAVING") {
+		if query.having, err = parser.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+
+	if parser.accept("ORDER") {
+		if err := parser.expect("BY"); err != nil {
+			return nil, err
+		}
+
+		for {
+			expr, err := parser.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+
+			item := &orderItem{expr: expr}
+			if parser.accept("DESC") {
+				item.desc = true
+			} else {
+				parser.accept("ASC")
+			}
+			query.orderBy = append(query.orderBy, item)
+
+			if !parser.acceptSymbol(",") {
+				break
+			}
+		}
+	}
+
+	if parser.accept("LIMIT") {
+		tok := parser.next()
+		limit, err := strconv.Atoi(tok.value)
+		if tok.kind != tokenNumber || err != nil || limit < 0 {
+			return nil, fmt.Errorf("invalid limit %q", tok.value)
+		}
+		query.limit = limit
+	}
+
+	if parser.peek().kind != tokenEOF {
+		return nil, parser.unexpected("end of query")
+	}
+
+	return query, nil
+}
+
+// items := '*' | expr [AS alias] (',' expr [AS alias])*
+func (parser *queryParser) parseSelect(query *Query) error {
+	for {
+		if parser.acceptSymbol("*") {
+			for _, path := range queryDefaultColumns {
+				query.columns = append(query.columns, &column{expr: &fieldExpr{path: path}})
+			}
+		} else {
+			expr, err := parser.parseExpr()
+			if err != nil {
+				return err
+			}
+
+			col := &column{expr: expr}
+			if parser.accept("AS") {
+				tok := parser.next()
+				if tok.kind != tokenIdent && tok.kind != tokenString {
+					return fmt.Errorf("expected alias after AS")
+				}
+				col.alias = tok.value
+			}
+			query.columns = append(query.columns, col)
+		}
+
+		if !parser.acceptSymbol(",") {
+			return nil
+		}
+	}
+}
+
+// expr := and (OR and)*
+func (parser *queryParser) parseExpr() (expr, error) {
+	left, err := parser.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for parser.accept("OR") {
+		right, err := parser.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicExpr{op: "OR", left: left, right: right}
+	}
+
+	return left, nil
+}
+
+// and := not (AND not)*
+func (parser *queryParser) parseAnd() (expr, error) {
+	left, err := parser.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for parser.accept("AND") {
+		right, err := parser.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicExpr{op: "AND", left: left, right: right}
+	}
+
+	return left, nil
+}
+
+// not := NOT not | comparison
+func (parser *queryParser) parseNot() (expr, error) {
+	if parser.accept("NOT") {
+		operand, err := parser.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notExpr{operand: operand}, nil
+	}
+
+	return parser.parseComparison()
+}
+
+// comparison := primary [op primary | [NOT] LIKE primary | [NOT] IN (list) | IS [NOT] NULL]
+func (parser *queryParser) parseComparison() (expr, error) {
+	left, err := parser.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	tok := parser.peek()
+	if tok.kind == tokenSymbol {
+		switch tok.value {
+		case "=", "!=", "<>", "<", "<=", ">", ">=":
+			parser.next()
+			right, err := parser.parsePrimary()
+			if err != nil {
+				return nil, err
+			}
+
+			op := tok.value
+			if op == "<>" {
+				op = "!="
+			}
+			return &compareExpr{op: op, left: left, right: right}, nil
+		}
+	}
+
+	if parser.accept("IS") {
+		not := parser.accept("NOT")
+		if err := parser.expect("NULL"); err != nil {
+			return nil, err
+		}
+		return &isNullExpr{operand: left, not: not}, nil
+	}
+
+	not := parser.accept("NOT")
+	switch {
+	case parser.accept("LIKE"):
+		right, err := parser.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+
+		lit, ok := right.(*literalExpr)
+		if !ok {
+			return nil, fmt.Errorf("pattern of LIKE should be a string")
+		}
+
+		pattern, err := likeToRegexp(cast.ToString(lit.value))
+		if err != nil {
+			return nil, err
+		}
+
+		return &likeExpr{operand: left, pattern: pattern, not: not, text: cast.ToString(lit.value)}, nil
+	case parser.accept("IN"):
+		if err := parser.expectSymbol("("); err != nil {
+			return nil, err
+		}
+
+		in := &inExpr{operand: left, not: not}
+		for {
+			item, err := parser.parsePrimary()
+			if err != nil {
+				return nil, err
+			}
+			in.list = append(in.list, item)
+
+			if !parser.acceptSymbol(",") {
+				break
+			}
+		}
+
+		if err := parser.expectSymbol(")"); err != nil {
+			return nil, err
+		}
+
+		return in, nil
+	case not:
+		return nil, parser.unexpected("LIKE or IN")
+	}
+
+	return left, nil
+}
+
+// primary := literal | field | func '(' [args] ')' | '(' expr ')'
+func (parser *queryParser) parsePrimary() (expr, error) {
+	tok := parser.next()
+
+	switch tok.kind {
+	case tokenString:
+		return &literalExpr{value: tok.value}, nil
+	case tokenNumber:
+		if i, err := strconv.ParseInt(tok.value, 10, 64); err == nil {
+			return &literalExpr{value: i}, nil
+		}
+
+		f, err := strconv.ParseFloat(tok.value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at %d", tok.value, tok.pos)
+		}
+		return &literalExpr{value: f}, nil
+	case tokenSymbol:
+		if tok.value == "(" {
+			inner, err := parser.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+
+			if err := parser.expectSymbol(")"); err != nil {
+				return nil, err
+			}
+			return inner, nil
+		}
+	case tokenIdent:
+		switch {
+		case tok.is("NULL"):
+			return &literalExpr{value: nil}, nil
+		case tok.is("TRUE"):
+			return &literalExpr{value: true}, nil
+		case tok.is("FALSE"):
+			return &literalExpr{value: false}, nil
+		}
+
+		if parser.acceptSymbol("(") {
+			return parser.parseCall(tok)
+		}
+
+		if queryKeywords[strings.ToUpper(tok.value)] {
+			break
+		}
+
+		return &fieldExpr{path: tok.value}, nil
+	}
+
+	parser.pos--
+	return nil, parser.unexpected("expression")
+}
+
+// Parse arguments of function whose name and '(' were consumed.
+func (parser *queryParser) parseCall(name token) (expr, error) {
+	call := &callExpr{name: strings.ToLower(name.value), aggIndex: -1}
+
+	if !parser.acceptSymbol(")") {
+		for {
+			if parser.acceptSymbol("*") {
+				call.args = append(call.args, &literalExpr{value: true})
+			} else {
+				arg, err := parser.parseExpr()
+				if err != nil {
+					return nil, err
+				}
+				call.args = append(call.args, arg)
+			}
+
+			if !parser.acceptSymbol(",") {
+				break
+			}
+		}
+
+		if err := parser.expectSymbol(")"); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := call.validate(); err != nil {
+		return nil, fmt.Errorf("%v at %d", err, name.pos)
+	}
+
+	return call, nil
+}
+
+// Convert pattern of LIKE into regular expression, % matches any characters and _ matches one character.
+func likeToRegexp(pattern string) (*regexp.Regexp, error) {
+	builder := strings.Builder{}
+	builder.WriteString("^")
+
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			builder.WriteString(".*")
+		case '_':
+			builder.WriteString(".")
+		default:
+			builder.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+
+	builder.WriteString("$")
+	return regexp.Compile(builder.String())
+}
@@ -0,0 +1,142 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestTokenize_HappyCase(t *testing.T) {
+	tokens, err := tokenize(`SELECT a.b-c, 'str\'s', -1.5e3 FROM x WHERE d != "e" AND ` + "error.`my error` <= 1")
+	assert.Nil(t, err)
+
+	values := make([]string, 0)
+	for _, tok := range tokens {
+		values = append(values, tok.value)
+	}
+
+	assert.Equal(t, []string{
+		"SELECT", "a.b-c", ",", "str's", ",", "-1.5e3", "FROM", "x", "WHERE", "d", "!=", "e", "AND",
+		"error.my error", "<=", "1", ""}, values)
+	assert.Equal(t, tokenNumber, tokens[5].kind)
+	assert.Equal(t, tokenString, tokens[3].kind)
+	assert.Equal(t, tokenEOF, tokens[len(tokens)-1].kind)
+}
+
+func TestTokenize_WithInvalidInput(t *testing.T) {
+	_, err := tokenize(`'unterminated`)
+	assert.NotNil(t, err)
+
+	_, err = tokenize("`unterminated")
+	assert.NotNil(t, err)
+
+	_, err = tokenize(`a ; b`)
+	assert.NotNil(t, err)
+}
+
+func TestParseQuery_HappyCase(t *testing.T) {
+	query, err := ParseQuery(`SELECT operation, p99(elapsedNano), count() AS total
+		WHERE resCode != "OK" AND pairs.tenant = "acme" OR NOT (elapsedMs < 10) AND operation LIKE '/v1/%'
+		AND resCode NOT IN ("a", "b") AND error IS NOT NULL
+		GROUP BY operation HAVING count() > 1 ORDER BY 2 DESC, total LIMIT 10`)
+	assert.Nil(t, err)
+	assert.Len(t, query.columns, 3)
+	assert.Equal(t, "operation", query.columns[0].name())
+	assert.Equal(t, "p99(elapsedNano)", query.columns[1].name())
+	assert.Equal(t, "total", query.columns[2].name())
+	assert.Len(t, query.groupBy, 1)
+	assert.NotNil(t, query.having)
+	assert.Len(t, query.orderBy, 2)
+	assert.Equal(t, 1, query.orderBy[0].index)
+	assert.True(t, query.orderBy[0].desc)
+	assert.Equal(t, 2, query.orderBy[1].index)
+	assert.Equal(t, 10, query.limit)
+	assert.Len(t, query.aggs, 3)
+	assert.NotEmpty(t, query.String())
+}
+
+func TestParseQuery_WithStar(t *testing.T) {
+	query, err := ParseQuery(`select * from logs`)
+	assert.Nil(t, err)
+	assert.Len(t, query.columns, len(queryDefaultColumns))
+}
+
+func TestParseQuery_WithInvisibleOrderColumn(t *testing.T) {
+	query, err := ParseQuery(`SELECT operation ORDER BY elapsedNano DESC`)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, query.visible)
+	assert.Len(t, query.columns, 2)
+	assert.Equal(t, 1, query.orderBy[0].index)
+}
+
+func TestParseQuery_WithGroupBy(t *testing.T) {
+	query, err := ParseQuery(`SELECT operation, count() GROUP BY 1`)
+	assert.Nil(t, err)
+	assert.Equal(t, "operation", query.groupBy[0].String())
+
+	_, err = ParseQuery(`SELECT operation, resCode, count() GROUP BY operation`)
+	assert.EqualError(t, err, "rkquery: resCode must appear in GROUP BY or be used in aggregate function")
+
+	_, err = ParseQuery(`SELECT operation, count() GROUP BY 3`)
+	assert.EqualError(t, err, "rkquery: invalid position 3 in GROUP BY")
+}
+
+func TestParseQuery_WithInvalidQuery(t *testing.T) {
+	queries := []string{
+		``,
+		`operation`,
+		`SELECT`,
+		`SELECT operation WHERE`,
+		`SELECT operation FROM`,
+		`SELECT operation LIMIT x`,
+		`SELECT operation LIMIT -1`,
+		`SELECT operation extra`,
+		`SELECT unknown(operation)`,
+		`SELECT count(a, b)`,
+		`SELECT sum()`,
+		`SELECT coalesce()`,
+		`SELECT operation WHERE count() > 1`,
+		`SELECT count() GROUP BY count()`,
+		`SELECT count() GROUP BY 2`,
+		`SELECT operation, count() GROUP BY 0`,
+		`SELECT operation, count() GROUP BY "operation"`,
+		`SELECT operation, count() AS total GROUP BY total`,
+		`SELECT operation, resCode, count() GROUP BY operation`,
+		`SELECT lower(resCode), count() GROUP BY operation`,
+		`SELECT operation, count()`,
+		`SELECT operation, count() GROUP BY operation HAVING resCode = "OK"`,
+		`SELECT operation, count() GROUP BY operation ORDER BY elapsedNano`,
+		`SELECT sum(count())`,
+		`SELECT operation HAVING operation = "a"`,
+		`SELECT operation ORDER BY 2`,
+		`SELECT operation WHERE operation LIKE operation`,
+		`SELECT operation WHERE operation NOT = 1`,
+		`SELECT operation WHERE operation IS 1`,
+		`SELECT operation WHERE operation IN 1`,
+		`SELECT operation WHERE operation IN (1`,
+		`SELECT (operation`,
+		`SELECT operation AS`,
+		`SELECT operation GROUP operation`,
+		`SELECT operation ORDER operation`,
+		`SELECT 1.2.3`,
+		`SELECT select`,
+		`SELECT 'a`,
+	}
+
+	for _, str := range queries {
+		_, err := ParseQuery(str)
+		assert.NotNil(t, err, str)
+	}
+}
+
+func TestLikeToRegexp(t *testing.T) {
+	pattern, err := likeToRegexp("/v1/%_x.")
+	assert.Nil(t, err)
+	assert.True(t, pattern.MatchString("/v1/abcx."))
+	assert.False(t, pattern.MatchString("/v1/x."))
+	assert.False(t, pattern.MatchString("/v1/abcxy"))
+}
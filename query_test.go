@@ -0,0 +1,183 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// Create records used by query tests.
+func newQueryRecords() []*Record {
+	res := make([]*Record, 0)
+
+	for i := 1; i <= 10; i++ {
+		record := newAggregateRecord("/v1/a", "OK", time.Duration(i)*time.Millisecond)
+		record.Pairs["tenant"] = "acme"
+		res = append(res, record)
+	}
+
+	failed := newAggregateRecord("/v1/b", "Fail", time.Second)
+	failed.Pairs["tenant"] = "acme"
+	failed.Errors["ut-err"] = 1
+	res = append(res, failed, failed)
+
+	other := newAggregateRecord("/v2/c", "Fail", 2*time.Second)
+	other.Pairs["tenant"] = "other"
+	res = append(res, other)
+
+	return res
+}
+
+// Execute query over records.
+func executeRecords(t *testing.T, str string, records []*Record) *QueryResult {
+	query, err := ParseQuery(str)
+	assert.Nil(t, err)
+
+	executor := query.NewExecutor()
+	for i := range records {
+		executor.Add(records[i])
+	}
+
+	return executor.Result()
+}
+
+func TestQuery_WithGroupBy(t *testing.T) {
+	res := executeRecords(t, `SELECT operation, p99(elapsedNano), count()
+		WHERE resCode != "OK" AND pairs.tenant = "acme"
+		GROUP BY operation ORDER BY 2 DESC LIMIT 10`, newQueryRecords())
+
+	assert.Equal(t, []string{"operation", "p99(elapsedNano)", "count()"}, res.Columns)
+	assert.Equal(t, [][]interface{}{{"/v1/b", time.Second.Nanoseconds(), int64(2)}}, res.Rows)
+}
+
+func TestQuery_WithGroupByPositionAndAlias(t *testing.T) {
+	res := executeRecords(t, `SELECT operation, count() GROUP BY 1 ORDER BY 2 DESC`, newQueryRecords())
+	assert.Equal(t, [][]interface{}{
+		{"/v1/a", int64(10)},
+		{"/v1/b", int64(2)},
+		{"/v2/c", int64(1)},
+	}, res.Rows)
+
+	res = executeRecords(t, `SELECT upper(pairs.tenant) AS tenant, count() GROUP BY tenant ORDER BY tenant`, newQueryRecords())
+	assert.Equal(t, [][]interface{}{
+		{"ACME", int64(12)},
+		{"OTHER", int64(1)},
+	}, res.Rows)
+}
+
+func TestQuery_WithHaving(t *testing.T) {
+	res := executeRecords(t, `SELECT operation, count() AS total, max(elapsedMs)
+		GROUP BY operation HAVING total >= 2 ORDER BY total DESC`, newQueryRecords())
+
+	assert.Equal(t, [][]interface{}{
+		{"/v1/a", int64(10), int64(10)},
+		{"/v1/b", int64(2), int64(1000)},
+	}, res.Rows)
+}
+
+func TestQuery_WithoutGroup(t *testing.T) {
+	res := executeRecords(t, `SELECT operation, elapsedMs WHERE operation LIKE '/v1/%'
+		AND resCode IN ("OK", "Fail") AND error.ut-err IS NULL ORDER BY elapsedMs DESC LIMIT 3`, newQueryRecords())
+
+	assert.Equal(t, []string{"operation", "elapsedMs"}, res.Columns)
+	assert.Equal(t, [][]interface{}{
+		{"/v1/a", int64(10)},
+		{"/v1/a", int64(9)},
+		{"/v1/a", int64(8)},
+	}, res.Rows)
+}
+
+func TestQuery_WithInvisibleOrderColumn(t *testing.T) {
+	res := executeRecords(t, `SELECT operation ORDER BY elapsedNano DESC LIMIT 1`, newQueryRecords())
+	assert.Equal(t, []string{"operation"}, res.Columns)
+	assert.Equal(t, [][]interface{}{{"/v2/c"}}, res.Rows)
+}
+
+func TestQuery_WithLimitWithoutOrder(t *testing.T) {
+	res := executeRecords(t, `SELECT operation LIMIT 2`, newQueryRecords())
+	assert.Len(t, res.Rows, 2)
+
+	res = executeRecords(t, `SELECT operation LIMIT 0`, newQueryRecords())
+	assert.Empty(t, res.Rows)
+}
+
+func TestQuery_WithAggregatesOnEmptyInput(t *testing.T) {
+	res := executeRecords(t, `SELECT count(), sum(elapsedMs), avg(elapsedMs), p50(elapsedMs)`, nil)
+	assert.Equal(t, [][]interface{}{{int64(0), nil, nil, nil}}, res.Rows)
+
+	res = executeRecords(t, `SELECT operation, count() GROUP BY operation`, nil)
+	assert.Empty(t, res.Rows)
+}
+
+func TestQuery_WithNull(t *testing.T) {
+	res := executeRecords(t, `SELECT coalesce(pairs.tenant, "none") AS tenant, count(error.ut-err), upper(pairs.tenant)
+		GROUP BY pairs.tenant ORDER BY 3`, newQueryRecords())
+
+	assert.Equal(t, [][]interface{}{
+		{"acme", int64(2), "ACME"},
+		{"other", int64(0), "OTHER"},
+	}, res.Rows)
+
+	res = executeRecords(t, `SELECT count() WHERE pairs.missing = NULL AND pairs.missing != "x"`, newQueryRecords())
+	assert.Equal(t, [][]interface{}{{int64(13)}}, res.Rows)
+
+	res = executeRecords(t, `SELECT operation WHERE pairs.missing > 1 OR pairs.missing = ""`, newQueryRecords())
+	assert.Empty(t, res.Rows)
+
+	res = executeRecords(t, `SELECT count() WHERE pairs.missing IS NULL AND NOT (resCode = "OK")`, newQueryRecords())
+	assert.Equal(t, [][]interface{}{{int64(3)}}, res.Rows)
+}
+
+func TestQuery_Execute_HappyCase(t *testing.T) {
+	buf := &bytes.Buffer{}
+	writeFullEvent(buf, JSON)
+	buf.WriteString("{\"eventStatus\":\"Ended\",\"ids\":1}\n")
+	writeFullEvent(buf, FLATTEN)
+
+	res, err := ExecuteQuery(`SELECT lower(operation), count(), sum(timing.ut-timer.elapsedMs) GROUP BY operation`, NewReader(buf))
+	assert.Nil(t, err)
+	assert.Equal(t, [][]interface{}{{"ut-op", int64(2), int64(5)}}, res.Rows)
+}
+
+func TestQuery_Execute_WithReaderError(t *testing.T) {
+	_, err := ExecuteQuery(`SELECT *`, NewReader(&errReader{}))
+	assert.NotNil(t, err)
+
+	_, err = ExecuteQuery(`SELECT`, NewReader(&bytes.Buffer{}))
+	assert.NotNil(t, err)
+
+	// wrapped parse error is skipped
+	res, err := ExecuteQuery(`SELECT *`, NewReader(&wrappedParseErrReader{}))
+	assert.Nil(t, err)
+	assert.Empty(t, res.Rows)
+}
+
+func TestCompareValues(t *testing.T) {
+	res, ok := compareValues(int64(1), 2.5)
+	assert.True(t, ok)
+	assert.Equal(t, -1, res)
+
+	res, ok = compareValues("b", "a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, res)
+
+	_, ok = compareValues(nil, "a")
+	assert.False(t, ok)
+
+	assert.True(t, valuesEqual(nil, nil))
+	assert.False(t, valuesEqual(nil, ""))
+	assert.True(t, valuesEqual(int64(1), 1.0))
+}
+
+func TestFormatValue(t *testing.T) {
+	assert.Equal(t, "", formatValue(nil))
+	assert.Equal(t, "1", formatValue(int64(1)))
+	assert.Equal(t, "1.5", formatValue(1.5))
+	assert.Equal(t, `{"k":"v"}`, formatValue(map[string]string{"k": "v"}))
+}
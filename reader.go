@@ -0,0 +1,311 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ReaderOption will be passed into NewReader to override default behavior of Reader.
+type ReaderOption func(*Reader)
+
+// WithReaderEncoding restricts Reader to decode events with provided encodings only.
+// All of CONSOLE, JSON, ECS, LOGFMT and FLATTEN are accepted by default.
+func WithReaderEncoding(ec ...Encoding) ReaderOption {
+	return func(reader *Reader) {
+		if len(ec) < 1 {
+			return
+		}
+
+		reader.encodings = make(map[Encoding]bool)
+		for i := range ec {
+			reader.encodings[ec[i]] = true
+		}
+	}
+}
+
+// Reader reads query logs written by Event.Finish() and decodes them into Record.
+//
+// Encoding of each event is detected automatically, so files mixed with CONSOLE, JSON, ECS, LOGFMT, FLATTEN and OTLP
+// encoded events could be read with the same Reader. Lines which do not belong to any event are skipped,
+// so Reader could be used on log files shared with other loggers.
+//
+// Reader is not thread safe.
+type Reader struct {
+	reader    *bufio.Reader
+	encodings map[Encoding]bool
+	line      int64
+	pending   *string
+	// records decoded from the same line, OTLP line may contain multiple records
+	queue []*Record
+	// bytes consumed from underlying io.Reader
+	offset int64
+	// offset of the first byte of line returned by readLine()
+	lineOffset    int64
+	pendingOffset int64
+}
+
+// NewReader creates a new Reader which reads from r.
+func NewReader(r io.Reader, opts ...ReaderOption) *Reader {
+	reader := &Reader{
+		reader: bufio.NewReader(r),
+		encodings: map[Encoding]bool{
+			CONSOLE: true,
+			JSON:    true,
+			FLATTEN: true,
+			LOGFMT:  true,
+			ECS:     true,
+			OTLP:    true,
+		},
+	}
+
+	for i := range opts {
+		opts[i](reader)
+	}
+
+	return reader
+}
+
+// Read returns next Record.
+// io.EOF would be returned if there is no more records.
+//
+// Malformed event will be reported as error, user could call Read again to continue with the next event.
+func (reader *Reader) Read() (*Record, error) {
+	if len(reader.queue) > 0 {
+		record := reader.queue[0]
+		reader.queue = reader.queue[1:]
+		return record, nil
+	}
+
+	for {
+		line, err := reader.readLine()
+		if err != nil {
+			return nil, err
+		}
+
+		start := reader.lineOffset
+		var record *Record
+
+		switch {
+		case isConsoleStart(line):
+			if !reader.encodings[CONSOLE] {
+				continue
+			}
+			record, err = reader.readConsoleBlock()
+		case isOtlpLine(line):
+			if !reader.encodings[OTLP] {
+				continue
+			}
+
+			var records []*Record
+			if records, err = decodeOtlpLine(line); err == nil {
+				if len(records) < 1 {
+					continue
+				}
+
+				// records in the same line share the same offset
+				for i := range records {
+					records[i].Offset = start
+				}
+				record, reader.queue = records[0], records[1:]
+			}
+		case isJsonLine(line):
+			if !reader.encodings[JSON] {
+				continue
+			}
+			record, err = decodeJsonLine(line)
+		case isEcsLine(line):
+			if !reader.encodings[ECS] {
+				continue
+			}
+			record, err = decodeEcsLine(line)
+		case isLogfmtLine(line):
+			if !reader.encodings[LOGFMT] {
+				continue
+			}
+			record, err = decodeLogfmtLine(line)
+		case isFlattenLine(line):
+			if !reader.encodings[FLATTEN] {
+				continue
+			}
+			record, err = decodeFlattenLine(line)
+		default:
+			continue
+		}
+
+		if err != nil {
+			return nil, reader.errorf("%v", err)
+		}
+
+		record.Offset = start
+		return record, nil
+	}
+}
+
+// ReadAll reads all remaining records.
+// Reading will stop at the first error.
+func (reader *Reader) ReadAll() ([]*Record, error) {
+	res := make([]*Record, 0)
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return res, nil
+		}
+
+		if err != nil {
+			return res, err
+		}
+
+		res = append(res, record)
+	}
+}
+
+// Parse reads all records from r with encoding detected automatically.
+func Parse(r io.Reader) ([]*Record, error) {
+	return NewReader(r).ReadAll()
+}
+
+// ParseConsole reads all CONSOLE encoded records from r.
+func ParseConsole(r io.Reader) ([]*Record, error) {
+	return NewReader(r, WithReaderEncoding(CONSOLE)).ReadAll()
+}
+
+// ParseJson reads all JSON encoded records from r.
+func ParseJson(r io.Reader) ([]*Record, error) {
+	return NewReader(r, WithReaderEncoding(JSON)).ReadAll()
+}
+
+// ParseEcs reads all ECS encoded records from r.
+func ParseEcs(r io.Reader) ([]*Record, error) {
+	return NewReader(r, WithReaderEncoding(ECS)).ReadAll()
+}
+
+// ParseLogfmt reads all LOGFMT encoded records from r.
+func ParseLogfmt(r io.Reader) ([]*Record, error) {
+	return NewReader(r, WithReaderEncoding(LOGFMT)).ReadAll()
+}
+
+// ParseFlatten reads all FLATTEN encoded records from r.
+func ParseFlatten(r io.Reader) ([]*Record, error) {
+	return NewReader(r, WithReaderEncoding(FLATTEN)).ReadAll()
+}
+
+// ParseOtlp reads all OTLP encoded records from r.
+func ParseOtlp(r io.Reader) ([]*Record, error) {
+	return NewReader(r, WithReaderEncoding(OTLP)).ReadAll()
+}
+
+// Read next line without line feed.
+// io.EOF would be returned only if there is nothing left.
+func (reader *Reader) readLine() (string, error) {
+	if reader.pending != nil {
+		line := *reader.pending
+		reader.pending = nil
+		reader.lineOffset = reader.pendingOffset
+		return line, nil
+	}
+
+	line, err := reader.reader.ReadString('\n')
+	if len(line) < 1 && err != nil {
+		return "", err
+	}
+
+	reader.line++
+	reader.lineOffset = reader.offset
+	reader.offset += int64(len(line))
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// Push line back, it will be returned by the next readLine().
+func (reader *Reader) unreadLine(line string) {
+	reader.pending = &line
+	reader.pendingOffset = reader.lineOffset
+}
+
+// Construct error with current line number.
+func (reader *Reader) errorf(format string, args ...interface{}) error {
+	return &ParseError{
+		Line: reader.line,
+		Err:  fmt.Errorf(format, args...),
+	}
+}
+
+// ParseError is returned by Reader if event is malformed.
+//
+// Reading could be continued after ParseError, other errors are returned from underlying io.Reader.
+type ParseError struct {
+	Line int64
+	Err  error
+}
+
+// Error returns string value of error.
+func (err *ParseError) Error() string {
+	return fmt.Sprintf("rkquery: line %d: %v", err.Line, err.Err)
+}
+
+// Unwrap returns underlying error.
+func (err *ParseError) Unwrap() error {
+	return err.Err
+}
+
+// Decode JSON object into map with numbers normalized to int64 or float64.
+func decodeJsonObject(str string) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewBufferString(str))
+	decoder.UseNumber()
+
+	res := make(map[string]interface{})
+	if err := decoder.Decode(&res); err != nil {
+		return nil, err
+	}
+
+	for k, v := range res {
+		res[k] = normalizeJsonValue(v)
+	}
+
+	return res, nil
+}
+
+// Decode JSON array, numbers are converted into int64 or float64.
+func decodeJsonArray(str string) ([]interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewBufferString(str))
+	decoder.UseNumber()
+
+	res := make([]interface{}, 0)
+	if err := decoder.Decode(&res); err != nil {
+		return nil, err
+	}
+
+	return normalizeJsonValue(res).([]interface{}), nil
+}
+
+// Convert json.Number into int64 or float64 recursively.
+func normalizeJsonValue(val interface{}) interface{} {
+	switch v := val.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for k := range v {
+			v[k] = normalizeJsonValue(v[k])
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = normalizeJsonValue(v[i])
+		}
+	}
+
+	return val
+}
@@ -0,0 +1,166 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"fmt"
+	"github.com/spf13/cast"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Read rest of CONSOLE block whose first line was consumed already.
+//
+// Block starts with scopeDelimiter and ends with EOE, logger may add prefix before scopeDelimiter
+// and suffix after EOE which would be ignored.
+func (reader *Reader) readConsoleBlock() (*Record, error) {
+	record := newRecord()
+	record.Encoding = CONSOLE
+
+	for {
+		line, err := reader.readLine()
+		if err == io.EOF {
+			return nil, fmt.Errorf("unexpected end of input, missing %s", eoe)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		// a new block started before current one ends
+		if isConsoleStart(line) {
+			reader.unreadLine(line)
+			return nil, fmt.Errorf("missing %s", eoe)
+		}
+
+		if strings.HasPrefix(line, eoe) {
+			return record, nil
+		}
+
+		if err := decodeConsoleLine(record, line); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Is line the beginning of CONSOLE block?
+func isConsoleStart(line string) bool {
+	return strings.HasSuffix(strings.TrimRight(line, " \t"), scopeDelimiter)
+}
+
+// Decode line with form of key=value into record.
+// Unknown keys are ignored.
+func decodeConsoleLine(record *Record, line string) error {
+	index := strings.Index(line, "=")
+	if index < 0 {
+		return &decodeError{key: line, msg: "missing '='"}
+	}
+
+	key, value := line[:index], line[index+1:]
+
+	switch key {
+	// ************* Time *************
+	case endTimeKey:
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return &decodeError{key: key, msg: err.Error()}
+		}
+		record.EndTime = t
+	case startTimeKey:
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return &decodeError{key: key, msg: err.Error()}
+		}
+		record.StartTime = t
+	case elapsedKey:
+		elapsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return &decodeError{key: key, msg: err.Error()}
+		}
+		record.ElapsedNano = elapsed
+	case timezoneKey:
+		record.Timezone = value
+	// ************* Event *************
+	case remoteAddrKey:
+		record.RemoteAddr = value
+	case operationKey:
+		record.Operation = value
+	case resCodeKey:
+		record.ResCode = value
+	case eventStatusKey:
+		record.EventStatus = value
+	// ************* Sections *************
+	case idsKey, serviceKey, envKey, payloadsKey, errKey, countersKey, pairsKey, timingKey:
+		section, err := decodeJsonObject(value)
+		if err != nil {
+			return &decodeError{key: key, msg: err.Error()}
+		}
+		decodeSection(record, key, section)
+	case childrenKey:
+		children, err := decodeJsonArray(value)
+		if err != nil {
+			return &decodeError{key: key, msg: err.Error()}
+		}
+		if record.Children, err = decodeChildren(children); err != nil {
+			return &decodeError{key: key, msg: err.Error()}
+		}
+	}
+
+	return nil
+}
+
+// Copy decoded section into record.
+func decodeSection(record *Record, key string, section map[string]interface{}) {
+	switch key {
+	case idsKey:
+		record.EventId = cast.ToString(section[eventIdKey])
+		record.TraceId = cast.ToString(section[traceIdKey])
+		record.RequestId = cast.ToString(section[requestIdKey])
+		record.ParentEventId = cast.ToString(section[parentEventIdKey])
+	case serviceKey:
+		record.ServiceName = cast.ToString(section[serviceNameKey])
+		record.ServiceVersion = cast.ToString(section[serviceVersionKey])
+		record.EntryName = cast.ToString(section[entryNameKey])
+		record.EntryKind = cast.ToString(section[entryKindKey])
+	case envKey:
+		for k, v := range section {
+			record.Env[k] = cast.ToString(v)
+		}
+	case payloadsKey:
+		for k, v := range section {
+			record.Payloads[k] = v
+		}
+	case errKey:
+		for k, v := range section {
+			record.Errors[k] = cast.ToInt64(v)
+		}
+	case countersKey:
+		for k, v := range section {
+			record.Counters[k] = cast.ToInt64(v)
+		}
+	case pairsKey:
+		for k, v := range section {
+			record.Pairs[k] = cast.ToString(v)
+		}
+	case timingKey:
+		for k, v := range section {
+			record.Timing[k] = cast.ToInt64(v)
+		}
+	}
+}
+
+// Error occurs while decoding a single key.
+type decodeError struct {
+	key string
+	msg string
+}
+
+// Error returns string value of error.
+func (err *decodeError) Error() string {
+	return "invalid " + err.key + ": " + err.msg
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseConsole_HappyCase(t *testing.T) {
+	buf := &bytes.Buffer{}
+	event := writeFullEvent(buf, CONSOLE)
+
+	records, err := ParseConsole(buf)
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+
+	record := records[0]
+	assert.True(t, event.GetStartTime().Equal(record.StartTime))
+	assert.True(t, event.GetEndTime().Equal(record.EndTime))
+	assert.Equal(t, (10 * time.Millisecond).Nanoseconds(), record.ElapsedNano)
+	assert.Equal(t, event.GetEventId(), record.EventId)
+	assert.Equal(t, "ut-trace", record.TraceId)
+	assert.Equal(t, "ut-request", record.RequestId)
+	assert.Equal(t, "ut-service", record.ServiceName)
+	assert.Equal(t, "v0.0.1", record.ServiceVersion)
+	assert.Equal(t, "ut-entry", record.EntryName)
+	assert.Equal(t, "ut-kind", record.EntryKind)
+	assert.Equal(t, hostname, record.Env[hostnameKey])
+	assert.Equal(t, "v1", record.Payloads["f1"])
+	assert.Equal(t, int64(2), record.Payloads["f2"])
+	assert.Equal(t, int64(1), record.Errors["ut-err"])
+	assert.True(t, record.HasError())
+	assert.Equal(t, int64(3), record.Counters["ut-counter"])
+	assert.Equal(t, "ut-value", record.Pairs["ut-key"])
+	assert.Equal(t, int64(5), record.Timing["ut-timer.elapsedMs"])
+	assert.Equal(t, int64(1), record.Timing["ut-timer.count"])
+	assert.Equal(t, "10.0.0.1:1949", record.RemoteAddr)
+	assert.Equal(t, "ut-op", record.Operation)
+	assert.Equal(t, "OK", record.ResCode)
+	assert.Equal(t, Ended.String(), record.EventStatus)
+}
+
+func TestParseConsole_WithLoggerPrefixAndSuffix(t *testing.T) {
+	input := "2021-06-13T00:24:20.256+0800\tINFO\t" + scopeDelimiter + "\n" +
+		"operation=ut-op\n" +
+		eoe + "\t{\"field\":\"value\"}\n"
+
+	records, err := ParseConsole(strings.NewReader(input))
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, "ut-op", records[0].Operation)
+}
+
+func TestReader_Read_WithMissingEOE(t *testing.T) {
+	input := scopeDelimiter + "\n" +
+		"operation=first\n" +
+		scopeDelimiter + "\n" +
+		"operation=second\n" +
+		eoe + "\n"
+
+	reader := NewReader(strings.NewReader(input))
+
+	// first block is broken
+	record, err := reader.Read()
+	assert.Nil(t, record)
+	assert.NotNil(t, err)
+
+	// second block should be still readable
+	record, err = reader.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, "second", record.Operation)
+
+	_, err = reader.Read()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestReader_Read_WithUnexpectedEOF(t *testing.T) {
+	input := scopeDelimiter + "\noperation=ut-op\n"
+
+	record, err := NewReader(strings.NewReader(input)).Read()
+	assert.Nil(t, record)
+	assert.NotNil(t, err)
+}
+
+func TestDecodeConsoleLine_WithInvalidValues(t *testing.T) {
+	record := newRecord()
+
+	assert.NotNil(t, decodeConsoleLine(record, "no-equal-sign"))
+	assert.NotNil(t, decodeConsoleLine(record, endTimeKey+"=invalid"))
+	assert.NotNil(t, decodeConsoleLine(record, startTimeKey+"=invalid"))
+	assert.NotNil(t, decodeConsoleLine(record, elapsedKey+"=invalid"))
+	assert.NotNil(t, decodeConsoleLine(record, pairsKey+"={invalid"))
+}
+
+func TestDecodeConsoleLine_WithUnknownKey(t *testing.T) {
+	record := newRecord()
+	assert.Nil(t, decodeConsoleLine(record, "unknown=value"))
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"github.com/spf13/cast"
+	"strings"
+)
+
+// Is line an ECS encoded event?
+func isEcsLine(line string) bool {
+	index := strings.Index(line, "{")
+	if index < 0 {
+		return false
+	}
+
+	return strings.Contains(line[index:], `"`+ecsVersionKey+`"`) && strings.Contains(line[index:], `"`+ecsEventIdKey+`"`)
+}
+
+// Decode ECS encoded event in one line.
+//
+// HTTP fields like url.path are decoded into payloads as apiPath, apiMethod and userAgent.
+func decodeEcsLine(line string) (*Record, error) {
+	fields, err := decodeJsonObject(line[strings.Index(line, "{"):])
+	if err != nil {
+		return nil, err
+	}
+
+	record := newRecord()
+	record.Encoding = ECS
+
+	for key, val := range fields {
+		switch key {
+		// ************* Time *************
+		case ecsEventStartKey:
+			if record.StartTime, err = parseJsonTime(val); err != nil {
+				return nil, &decodeError{key: key, msg: err.Error()}
+			}
+		case ecsEventEndKey:
+			if record.EndTime, err = parseJsonTime(val); err != nil {
+				return nil, &decodeError{key: key, msg: err.Error()}
+			}
+		case ecsEventDurationKey:
+			if record.ElapsedNano, err = cast.ToInt64E(val); err != nil {
+				return nil, &decodeError{key: key, msg: err.Error()}
+			}
+		case ecsEventTimezoneKey:
+			record.Timezone = cast.ToString(val)
+		// ************* Ids *************
+		case ecsEventIdKey:
+			record.EventId = cast.ToString(val)
+		case ecsTraceIdKey:
+			record.TraceId = cast.ToString(val)
+		case ecsRequestIdKey:
+			record.RequestId = cast.ToString(val)
+		// ************* Service *************
+		case ecsServiceNameKey:
+			record.ServiceName = cast.ToString(val)
+		case ecsServiceVersionKey:
+			record.ServiceVersion = cast.ToString(val)
+		case ecsServiceTypeKey:
+			record.EntryKind = cast.ToString(val)
+		case ecsEntryNameKey:
+			record.EntryName = cast.ToString(val)
+		// ************* Env *************
+		case ecsHostHostnameKey:
+			record.Env[hostnameKey] = cast.ToString(val)
+		case ecsHostIpKey:
+			record.Env[localIpKey] = cast.ToString(val)
+		case ecsHostOsKey:
+			record.Env[goosKey] = cast.ToString(val)
+		case ecsHostArchKey:
+			record.Env[goArchKey] = cast.ToString(val)
+		// ************* Payloads *************
+		case ecsMethodKey:
+			record.Payloads["apiMethod"] = cast.ToString(val)
+		case ecsUrlPathKey:
+			record.Payloads["apiPath"] = cast.ToString(val)
+		case ecsUserAgentKey:
+			record.Payloads["userAgent"] = cast.ToString(val)
+		// ************* Event *************
+		case ecsEventActionKey:
+			record.Operation = cast.ToString(val)
+		case ecsClientAddressKey:
+			record.RemoteAddr = cast.ToString(val)
+		case ecsResCodeKey:
+			record.ResCode = cast.ToString(val)
+		case ecsStatusKey:
+			record.EventStatus = cast.ToString(val)
+		// ************* Sections *************
+		case ecsLabelsKey, ecsEnvKey, ecsPayloadsKey, ecsErrorsKey, ecsCountersKey, ecsTimingKey:
+			section, ok := val.(map[string]interface{})
+			if !ok && val != nil {
+				return nil, &decodeError{key: key, msg: "not an object"}
+			}
+			decodeSection(record, ecsSections[key], section)
+		}
+	}
+
+	return record, nil
+}
+
+// Sections in ECS and keys of sections in record.
+var ecsSections = map[string]string{
+	ecsLabelsKey:   pairsKey,
+	ecsEnvKey:      envKey,
+	ecsPayloadsKey: payloadsKey,
+	ecsErrorsKey:   errKey,
+	ecsCountersKey: countersKey,
+	ecsTimingKey:   timingKey,
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseEcs_HappyCase(t *testing.T) {
+	buf := &bytes.Buffer{}
+	event := writeFullEvent(buf, ECS)
+
+	records, err := ParseEcs(buf)
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+
+	record := records[0]
+	assert.Equal(t, ECS, record.Encoding)
+	assert.Equal(t, event.GetStartTime().UnixNano(), record.StartTime.UnixNano())
+	assert.Equal(t, (10 * time.Millisecond).Nanoseconds(), record.ElapsedNano)
+	assert.Equal(t, event.GetEventId(), record.EventId)
+	assert.Equal(t, "ut-trace", record.TraceId)
+	assert.Equal(t, "ut-request", record.RequestId)
+	assert.Equal(t, "ut-service", record.ServiceName)
+	assert.Equal(t, "ut-entry", record.EntryName)
+	assert.Equal(t, "ut-kind", record.EntryKind)
+	assert.Equal(t, hostname, record.Env[hostnameKey])
+	assert.Equal(t, "v1", record.Payloads["f1"])
+	assert.Equal(t, int64(1), record.Errors["ut-err"])
+	assert.Equal(t, int64(3), record.Counters["ut-counter"])
+	assert.Equal(t, "ut-value", record.Pairs["ut-key"])
+	assert.Equal(t, int64(5), record.Timing["ut-timer.elapsedMs"])
+	assert.Equal(t, "10.0.0.1:1949", record.RemoteAddr)
+	assert.Equal(t, "ut-op", record.Operation)
+	assert.Equal(t, "OK", record.ResCode)
+	assert.Equal(t, Ended.String(), record.EventStatus)
+}
+
+func TestParseEcs_WithHttpFields(t *testing.T) {
+	input := `{"ecs.version":"8.11.0","event.id":"ut-event","http.request.method":"GET","url.path":"/v1/a","user_agent.original":"curl"}`
+
+	records, err := ParseEcs(strings.NewReader(input))
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, "GET", records[0].Payloads["apiMethod"])
+	assert.Equal(t, "/v1/a", records[0].Payloads["apiPath"])
+	assert.Equal(t, "curl", records[0].Payloads["userAgent"])
+}
+
+func TestParseEcs_WithInvalidValues(t *testing.T) {
+	_, err := ParseEcs(strings.NewReader(`{"ecs.version":"8.11.0","event.id":"","event.start":"invalid"}`))
+	assert.NotNil(t, err)
+
+	_, err = ParseEcs(strings.NewReader(`{"ecs.version":"8.11.0","event.id":"","event.duration":"invalid"}`))
+	assert.NotNil(t, err)
+
+	_, err = ParseEcs(strings.NewReader(`{"ecs.version":"8.11.0","event.id":"","labels":"invalid"}`))
+	assert.NotNil(t, err)
+}
+
+func TestIsEcsLine(t *testing.T) {
+	assert.True(t, isEcsLine(`INFO {"ecs.version":"8.11.0","event.id":""}`))
+	assert.False(t, isEcsLine(`{"ecs.version":"8.11.0"}`))
+	assert.False(t, isEcsLine(`ecs.version event.id`))
+}
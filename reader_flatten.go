@@ -0,0 +1,110 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	flattenTimeLayout = "2006-01-02T15:04:05.000Z0700"
+	flattenEmpty      = "[X]"
+	flattenColumns    = 8
+)
+
+var (
+	// Columns are aligned with at least two spaces or tab.
+	flattenSeparator = regexp.MustCompile(`\t| {2,}`)
+)
+
+// Is line a FLATTEN encoded event?
+func isFlattenLine(line string) bool {
+	return splitFlattenLine(line) != nil
+}
+
+// Split FLATTEN line into columns, nil would be returned if line is not a FLATTEN encoded event.
+//
+// Line written by zap logger may start with prefix like timestamp and level, so that columns are located by
+// the first endTime column followed by [resCode] and elapsedMs.
+func splitFlattenLine(line string) []string {
+	columns := flattenSeparator.Split(strings.TrimSpace(line), -1)
+
+	for i := 0; i+flattenColumns <= len(columns); i++ {
+		if !strings.HasPrefix(columns[i+1], "[") || !strings.HasSuffix(columns[i+2], "ms") {
+			continue
+		}
+
+		if _, err := time.Parse(flattenTimeLayout, columns[i]); err == nil {
+			return columns[i : i+flattenColumns]
+		}
+	}
+
+	return nil
+}
+
+// Decode FLATTEN encoded event in one line.
+//
+// Columns are bellow:
+// endTime    [resCode]    elapsedMs    operation    method    protocol    remoteAddr    [eventId,traceId]
+//
+// FLATTEN drops most of fields, method and protocol will be decoded into EntryName and EntryKind.
+func decodeFlattenLine(line string) (*Record, error) {
+	columns := splitFlattenLine(line)
+	if columns == nil {
+		return nil, &decodeError{key: endTimeKey, msg: "not a FLATTEN encoded event"}
+	}
+
+	record := newRecord()
+	record.Encoding = FLATTEN
+
+	endTime, err := time.Parse(flattenTimeLayout, columns[0])
+	if err != nil {
+		return nil, &decodeError{key: endTimeKey, msg: err.Error()}
+	}
+	record.EndTime = endTime
+
+	record.ResCode = fromFlattenValue(trimBrackets(columns[1]))
+
+	elapsedMs, err := strconv.ParseInt(strings.TrimSuffix(columns[2], "ms"), 10, 64)
+	if err != nil {
+		return nil, &decodeError{key: elapsedKey, msg: err.Error()}
+	}
+	record.ElapsedNano = (time.Duration(elapsedMs) * time.Millisecond).Nanoseconds()
+	record.StartTime = endTime.Add(-record.Elapsed())
+
+	record.Operation = fromFlattenValue(columns[3])
+	record.EntryName = fromFlattenValue(columns[4])
+	record.EntryKind = fromFlattenValue(columns[5])
+	record.RemoteAddr = fromFlattenValue(columns[6])
+
+	ids := fromFlattenValue(trimBrackets(columns[7]))
+	if len(ids) > 0 {
+		tokens := strings.Split(ids, ",")
+		record.EventId = tokens[0]
+		if len(tokens) > 1 {
+			record.TraceId = tokens[1]
+		}
+	}
+
+	return record, nil
+}
+
+// Remove surrounding brackets.
+func trimBrackets(str string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(str, "["), "]")
+}
+
+// Return empty string if value is the placeholder of empty value.
+func fromFlattenValue(str string) string {
+	if str == flattenEmpty {
+		return ""
+	}
+
+	return str
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseFlatten_HappyCase(t *testing.T) {
+	buf := &bytes.Buffer{}
+	event := writeFullEvent(buf, FLATTEN)
+
+	records, err := ParseFlatten(buf)
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+
+	record := records[0]
+	assert.Equal(t, FLATTEN, record.Encoding)
+	assert.Equal(t, event.GetEndTime().UnixNano()/1e6, record.EndTime.UnixNano()/1e6)
+	assert.Equal(t, (10 * time.Millisecond).Nanoseconds(), record.ElapsedNano)
+	assert.Equal(t, "OK", record.ResCode)
+	assert.Equal(t, "ut-op", record.Operation)
+	assert.Equal(t, "ut-entry", record.EntryName)
+	assert.Equal(t, "ut-kind", record.EntryKind)
+	assert.Equal(t, "10.0.0.1:1949", record.RemoteAddr)
+	assert.Equal(t, event.GetEventId(), record.EventId)
+	assert.Equal(t, "ut-trace", record.TraceId)
+}
+
+func TestParseFlatten_WithEmptyValues(t *testing.T) {
+	input := "2022-03-04T02:29:53.478+0800    [[X]]    1002ms    [X]    [X]    [X]    [X]    [[X]]\n"
+
+	records, err := ParseFlatten(strings.NewReader(input))
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+
+	record := records[0]
+	assert.Empty(t, record.ResCode)
+	assert.Empty(t, record.Operation)
+	assert.Empty(t, record.RemoteAddr)
+	assert.Empty(t, record.EventId)
+	assert.Equal(t, int64(1002*time.Millisecond), record.ElapsedNano)
+	assert.True(t, record.EndTime.Add(-1002*time.Millisecond).Equal(record.StartTime))
+}
+
+func TestParseFlatten_WithLoggerPrefix(t *testing.T) {
+	buf := &bytes.Buffer{}
+	config := zap.NewProductionEncoderConfig()
+	config.EncodeTime = zapcore.ISO8601TimeEncoder
+	logger := zap.New(zapcore.NewCore(zapcore.NewConsoleEncoder(config), zapcore.AddSync(buf), zap.InfoLevel))
+
+	fac := NewEventFactory(WithZapLogger(logger), WithEncoding(FLATTEN))
+	for _, operation := range []string{"ut-first", "ut-second"} {
+		event := fac.CreateEvent()
+		event.SetOperation(operation)
+		event.SetResCode("OK")
+		event.Finish()
+	}
+
+	// timestamp and level written by logger
+	assert.True(t, strings.HasPrefix(strings.Split(buf.String(), "\t")[1], "info"))
+
+	records, err := ParseFlatten(buf)
+	assert.Nil(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, "ut-first", records[0].Operation)
+	assert.Equal(t, "OK", records[0].ResCode)
+	assert.Equal(t, "ut-second", records[1].Operation)
+}
+
+func TestIsFlattenLine(t *testing.T) {
+	assert.True(t, isFlattenLine("2022-03-04T02:29:53.478+0800    [200]    1002ms    op    entry    kind    localhost    [id]"))
+	assert.False(t, isFlattenLine("2022-03-04T02:29:53.478+0800    [200]    1002ms    op"))
+	assert.False(t, isFlattenLine("invalid    [200]    1002ms    op    entry    kind    localhost    [id]"))
+	assert.False(t, isFlattenLine("plain text"))
+	assert.True(t, isFlattenLine("2022-03-04T02:29:53.478+0800\tINFO\t2022-03-04T02:29:53.478+0800    [200]    1002ms    op    entry    kind    localhost    [id]"))
+}
+
+func TestParse_WithMixedEncodings(t *testing.T) {
+	buf := &bytes.Buffer{}
+	writeFullEvent(buf, CONSOLE)
+	writeFullEvent(buf, JSON)
+	writeFullEvent(buf, FLATTEN)
+
+	records, err := Parse(buf)
+	assert.Nil(t, err)
+	assert.Len(t, records, 3)
+	assert.Equal(t, CONSOLE, records[0].Encoding)
+	assert.Equal(t, JSON, records[1].Encoding)
+	assert.Equal(t, FLATTEN, records[2].Encoding)
+
+	for i := range records {
+		assert.Equal(t, "ut-op", records[i].Operation)
+		assert.Equal(t, "OK", records[i].ResCode)
+	}
+}
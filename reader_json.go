@@ -0,0 +1,125 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"fmt"
+	"github.com/spf13/cast"
+	"math"
+	"strings"
+	"time"
+)
+
+var (
+	// Layouts of time which could be produced by time encoders of zap.
+	jsonTimeLayouts = []string{
+		time.RFC3339Nano,
+		"2006-01-02T15:04:05.000Z0700",
+		"2006-01-02T15:04:05.999999999Z0700",
+		"2006-01-02 15:04:05.999999999Z07:00",
+	}
+)
+
+// Is line a JSON encoded event?
+//
+// Logger may add prefix before JSON object if console encoder was used in zap logger,
+// so we will look for the first object in the line.
+func isJsonLine(line string) bool {
+	index := strings.Index(line, "{")
+	if index < 0 {
+		return false
+	}
+
+	return strings.Contains(line[index:], `"`+eventStatusKey+`"`) && strings.Contains(line[index:], `"`+idsKey+`"`)
+}
+
+// Decode JSON encoded event in one line.
+func decodeJsonLine(line string) (*Record, error) {
+	fields, err := decodeJsonObject(line[strings.Index(line, "{"):])
+	if err != nil {
+		return nil, err
+	}
+
+	record := newRecord()
+	record.Encoding = JSON
+
+	for key, val := range fields {
+		switch key {
+		// ************* Time *************
+		case endTimeKey:
+			if record.EndTime, err = parseJsonTime(val); err != nil {
+				return nil, &decodeError{key: key, msg: err.Error()}
+			}
+		case startTimeKey:
+			if record.StartTime, err = parseJsonTime(val); err != nil {
+				return nil, &decodeError{key: key, msg: err.Error()}
+			}
+		case elapsedKey:
+			if record.ElapsedNano, err = cast.ToInt64E(val); err != nil {
+				return nil, &decodeError{key: key, msg: err.Error()}
+			}
+		case timezoneKey:
+			record.Timezone = cast.ToString(val)
+		// ************* Event *************
+		case remoteAddrKey:
+			record.RemoteAddr = cast.ToString(val)
+		case operationKey:
+			record.Operation = cast.ToString(val)
+		case resCodeKey:
+			record.ResCode = cast.ToString(val)
+		case eventStatusKey:
+			record.EventStatus = cast.ToString(val)
+		// ************* Sections *************
+		case idsKey, serviceKey, envKey, payloadsKey, errKey, countersKey, pairsKey, timingKey:
+			section, ok := val.(map[string]interface{})
+			if !ok && val != nil {
+				return nil, &decodeError{key: key, msg: "not an object"}
+			}
+			decodeSection(record, key, section)
+		case childrenKey:
+			if record.Children, err = decodeChildren(val); err != nil {
+				return nil, &decodeError{key: key, msg: err.Error()}
+			}
+		}
+	}
+
+	return record, nil
+}
+
+// Parse time encoded by zap time encoder.
+// Both of formatted string and epoch number are supported.
+func parseJsonTime(val interface{}) (time.Time, error) {
+	switch v := val.(type) {
+	case string:
+		for i := range jsonTimeLayouts {
+			if t, err := time.Parse(jsonTimeLayouts[i], v); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("unknown time layout of %s", v)
+	case int64:
+		return fromEpoch(float64(v)), nil
+	case float64:
+		return fromEpoch(v), nil
+	}
+
+	return time.Time{}, fmt.Errorf("unknown time type of %T", val)
+}
+
+// Convert epoch to time.Time, unit of epoch is guessed by magnitude.
+func fromEpoch(epoch float64) time.Time {
+	switch {
+	case math.Abs(epoch) >= 1e17:
+		return time.Unix(0, int64(epoch))
+	case math.Abs(epoch) >= 1e14:
+		return time.Unix(0, int64(epoch*1e3))
+	case math.Abs(epoch) >= 1e11:
+		return time.Unix(0, int64(epoch*1e6))
+	default:
+		sec, frac := math.Modf(epoch)
+		return time.Unix(int64(sec), int64(frac*1e9))
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseJson_HappyCase(t *testing.T) {
+	buf := &bytes.Buffer{}
+	event := writeFullEvent(buf, JSON)
+
+	records, err := ParseJson(buf)
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+
+	record := records[0]
+	assert.Equal(t, JSON, record.Encoding)
+	assert.Equal(t, event.GetStartTime().UnixNano()/1e6, record.StartTime.UnixNano()/1e6)
+	assert.Equal(t, event.GetEndTime().UnixNano()/1e6, record.EndTime.UnixNano()/1e6)
+	assert.Equal(t, (10 * time.Millisecond).Nanoseconds(), record.ElapsedNano)
+	assert.Equal(t, event.GetEventId(), record.EventId)
+	assert.Equal(t, "ut-trace", record.TraceId)
+	assert.Equal(t, "ut-service", record.ServiceName)
+	assert.Equal(t, "v1", record.Payloads["f1"])
+	assert.Equal(t, int64(1), record.Errors["ut-err"])
+	assert.Equal(t, int64(3), record.Counters["ut-counter"])
+	assert.Equal(t, "ut-value", record.Pairs["ut-key"])
+	assert.Equal(t, int64(5), record.Timing["ut-timer.elapsedMs"])
+	assert.Equal(t, "ut-op", record.Operation)
+	assert.Equal(t, "OK", record.ResCode)
+	assert.Equal(t, Ended.String(), record.EventStatus)
+}
+
+func TestParseJson_WithLoggerPrefix(t *testing.T) {
+	input := `2021-06-13T00:24:20.256+0800	INFO	{"ids":{"eventId":"ut-event"},"eventStatus":"Ended","operation":"ut-op"}`
+
+	records, err := ParseJson(strings.NewReader(input))
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, "ut-event", records[0].EventId)
+	assert.Equal(t, "ut-op", records[0].Operation)
+}
+
+func TestParseJson_WithInvalidValues(t *testing.T) {
+	_, err := ParseJson(strings.NewReader(`{"ids":{},"eventStatus":"Ended","endTime":"invalid"}`))
+	assert.NotNil(t, err)
+
+	_, err = ParseJson(strings.NewReader(`{"ids":"invalid","eventStatus":"Ended"}`))
+	assert.NotNil(t, err)
+
+	_, err = ParseJson(strings.NewReader(`{"ids":{},"eventStatus":"Ended"`))
+	assert.NotNil(t, err)
+}
+
+func TestIsJsonLine(t *testing.T) {
+	assert.True(t, isJsonLine(`{"ids":{},"eventStatus":"Ended"}`))
+	assert.False(t, isJsonLine(`{"level":"info","msg":"hello"}`))
+	assert.False(t, isJsonLine(`plain text`))
+}
+
+func TestParseJsonTime(t *testing.T) {
+	expected := time.Date(2021, 6, 13, 0, 24, 20, 256000000, time.UTC)
+
+	// iso8601
+	res, err := parseJsonTime("2021-06-13T00:24:20.256Z")
+	assert.Nil(t, err)
+	assert.True(t, expected.Equal(res))
+
+	// epoch seconds
+	res, err = parseJsonTime(float64(expected.UnixNano()) / 1e9)
+	assert.Nil(t, err)
+	assert.Equal(t, expected.UnixNano()/1e6, res.UnixNano()/1e6)
+
+	// epoch millis
+	res, err = parseJsonTime(expected.UnixNano() / 1e6)
+	assert.Nil(t, err)
+	assert.True(t, expected.Equal(res))
+
+	// epoch nanos
+	res, err = parseJsonTime(expected.UnixNano())
+	assert.Nil(t, err)
+	assert.True(t, expected.Equal(res))
+
+	_, err = parseJsonTime("invalid")
+	assert.NotNil(t, err)
+
+	_, err = parseJsonTime(true)
+	assert.NotNil(t, err)
+}
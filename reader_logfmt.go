@@ -0,0 +1,192 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"fmt"
+	"github.com/spf13/cast"
+	"strconv"
+	"strings"
+)
+
+// Is line a LOGFMT encoded event?
+func isLogfmtLine(line string) bool {
+	start := logfmtStart(line)
+	return start >= 0 && strings.Contains(line[start:], " "+eventStatusKey+"=")
+}
+
+// Returns index of the first key of LOGFMT event, logger may add prefix before it.
+func logfmtStart(line string) int {
+	prefix := endTimeKey + "="
+	for offset := 0; ; {
+		index := strings.Index(line[offset:], prefix)
+		if index < 0 {
+			return -1
+		}
+
+		index += offset
+		if index == 0 || line[index-1] == ' ' || line[index-1] == '\t' {
+			return index
+		}
+		offset = index + len(prefix)
+	}
+}
+
+// Decode LOGFMT encoded event in one line.
+//
+// Values of payloads are decoded as string unless they are unquoted numbers or booleans,
+// nested payloads are decoded as keys joined with dot.
+func decodeLogfmtLine(line string) (*Record, error) {
+	pairs, err := splitLogfmt(line[logfmtStart(line):])
+	if err != nil {
+		return nil, err
+	}
+
+	record := newRecord()
+	record.Encoding = LOGFMT
+
+	sections := make(map[string]map[string]interface{})
+	for _, pair := range pairs {
+		index := strings.Index(pair.key, ".")
+		if index < 0 {
+			if err := decodeConsoleLine(record, pair.key+"="+pair.value); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		section, key := pair.key[:index], pair.key[index+1:]
+		if _, ok := sections[section]; !ok {
+			sections[section] = make(map[string]interface{})
+		}
+
+		if section == payloadsKey && !pair.quoted {
+			sections[section][key] = parseLogfmtValue(pair.value)
+		} else {
+			sections[section][key] = pair.value
+		}
+	}
+
+	if errs, ok := sections[errKey]; ok {
+		sections[errKey] = decodeLogfmtErrors(errs)
+	}
+
+	for k, v := range sections {
+		decodeSection(record, k, v)
+	}
+
+	return record, nil
+}
+
+// Returns errors keyed by message decoded from error.N.message and error.N.count.
+// Keys of other formats are kept as they are.
+func decodeLogfmtErrors(section map[string]interface{}) map[string]interface{} {
+	res := make(map[string]interface{}, len(section))
+
+	for k, v := range section {
+		if index, ok := logfmtErrIndex(k, logfmtErrMessageSuffix); ok {
+			count, ok := section[index+logfmtErrCountSuffix]
+			if !ok {
+				count = 1
+			}
+			res[cast.ToString(v)] = count
+			continue
+		}
+
+		if _, ok := logfmtErrIndex(k, logfmtErrCountSuffix); ok {
+			continue
+		}
+
+		res[k] = v
+	}
+
+	return res
+}
+
+// Returns index of error if key is index with suffix.
+func logfmtErrIndex(key, suffix string) (string, bool) {
+	if !strings.HasSuffix(key, suffix) {
+		return "", false
+	}
+
+	index := strings.TrimSuffix(key, suffix)
+	if _, err := strconv.Atoi(index); err != nil {
+		return "", false
+	}
+
+	return index, true
+}
+
+// Pair of key and value in LOGFMT.
+type logfmtPair struct {
+	key    string
+	value  string
+	quoted bool
+}
+
+// Split line into key value pairs, keys without value are skipped.
+func splitLogfmt(line string) ([]*logfmtPair, error) {
+	res := make([]*logfmtPair, 0)
+
+	for i := 0; i < len(line); {
+		if line[i] == ' ' || line[i] == '\t' {
+			i++
+			continue
+		}
+
+		// key
+		start := i
+		for i < len(line) && line[i] != '=' && line[i] != ' ' && line[i] != '\t' {
+			i++
+		}
+		key := line[start:i]
+
+		if i >= len(line) || line[i] != '=' {
+			continue
+		}
+		i++
+
+		// value
+		pair := &logfmtPair{key: key}
+		if i < len(line) && line[i] == '"' {
+			quoted, err := strconv.QuotedPrefix(line[i:])
+			if err != nil {
+				return nil, &decodeError{key: key, msg: fmt.Sprintf("invalid quoted value: %v", err)}
+			}
+
+			pair.value, _ = strconv.Unquote(quoted)
+			pair.quoted = true
+			i += len(quoted)
+		} else {
+			start = i
+			for i < len(line) && line[i] != ' ' && line[i] != '\t' {
+				i++
+			}
+			pair.value = line[start:i]
+		}
+
+		res = append(res, pair)
+	}
+
+	return res, nil
+}
+
+// Parse unquoted value into int64, float64 or bool if possible.
+func parseLogfmtValue(value string) interface{} {
+	if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return i
+	}
+
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		return f
+	}
+
+	if b, err := strconv.ParseBool(value); err == nil && (value == "true" || value == "false") {
+		return b
+	}
+
+	return value
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLogfmt_WithErrorMessages(t *testing.T) {
+	record := newRecord()
+	record.StartTime = time.Date(2021, 6, 13, 0, 0, 0, 0, time.UTC)
+	record.EndTime = record.StartTime
+	record.Errors["boom \"q\"\nline2"] = 2
+	record.Errors["a=b"] = 1
+	record.EventStatus = Ended.String()
+
+	msg, _ := encodeLogfmt(record)
+	assert.Contains(t, msg, ` error.0.message="a=b" error.0.count=1 error.1.message="boom \"q\"\nline2" error.1.count=2 `)
+
+	records, err := ParseLogfmt(strings.NewReader(msg + "\n"))
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, record.Errors, records[0].Errors)
+
+	// errors written as keys are still read
+	records, err = ParseLogfmt(strings.NewReader(strings.Replace(msg, ` error.0.message="a=b" error.0.count=1`, " error.my_error=3", 1) + "\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int64{"my_error": 3, "boom \"q\"\nline2": 2}, records[0].Errors)
+}
+
+func TestParseLogfmt_HappyCase(t *testing.T) {
+	buf := &bytes.Buffer{}
+	event := writeFullEvent(buf, LOGFMT)
+	assert.Equal(t, 1, strings.Count(buf.String(), "\n"))
+
+	records, err := ParseLogfmt(buf)
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+
+	record := records[0]
+	assert.Equal(t, LOGFMT, record.Encoding)
+	assert.Equal(t, event.GetStartTime().UnixNano(), record.StartTime.UnixNano())
+	assert.Equal(t, (10 * time.Millisecond).Nanoseconds(), record.ElapsedNano)
+	assert.Equal(t, event.GetEventId(), record.EventId)
+	assert.Equal(t, "ut-trace", record.TraceId)
+	assert.Equal(t, "ut-request", record.RequestId)
+	assert.Equal(t, "ut-service", record.ServiceName)
+	assert.Equal(t, "ut-kind", record.EntryKind)
+	assert.Equal(t, "v1", record.Payloads["f1"])
+	assert.Equal(t, int64(2), record.Payloads["f2"])
+	assert.Equal(t, int64(1), record.Errors["ut-err"])
+	assert.Equal(t, int64(3), record.Counters["ut-counter"])
+	assert.Equal(t, "ut-value", record.Pairs["ut-key"])
+	assert.Equal(t, int64(5), record.Timing["ut-timer.elapsedMs"])
+	assert.Equal(t, "10.0.0.1:1949", record.RemoteAddr)
+	assert.Equal(t, "ut-op", record.Operation)
+	assert.Equal(t, "OK", record.ResCode)
+	assert.Equal(t, Ended.String(), record.EventStatus)
+}
+
+func TestParseLogfmt_WithLoggerPrefix(t *testing.T) {
+	input := "2021-06-13T00:24:20.256+0800\tINFO\tendTime=2021-06-13T00:00:01Z ids.eventId=ut-event " +
+		`payloads.msg="a b" payloads.code="1" payloads.ok=true payloads.rate=0.5 bare eventStatus=Ended`
+
+	records, err := ParseLogfmt(strings.NewReader(input))
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, "ut-event", records[0].EventId)
+	assert.Equal(t, "a b", records[0].Payloads["msg"])
+	assert.Equal(t, "1", records[0].Payloads["code"])
+	assert.Equal(t, true, records[0].Payloads["ok"])
+	assert.Equal(t, 0.5, records[0].Payloads["rate"])
+}
+
+func TestParseLogfmt_WithInvalidValues(t *testing.T) {
+	_, err := ParseLogfmt(strings.NewReader(`endTime=invalid eventStatus=Ended`))
+	assert.NotNil(t, err)
+
+	_, err = ParseLogfmt(strings.NewReader(`endTime=2021-06-13T00:00:01Z pairs.key="unterminated eventStatus=Ended`))
+	assert.NotNil(t, err)
+}
+
+func TestIsLogfmtLine(t *testing.T) {
+	assert.True(t, isLogfmtLine("endTime=x eventStatus=Ended"))
+	assert.True(t, isLogfmtLine("prefix endTime=x eventStatus=Ended"))
+	assert.False(t, isLogfmtLine("xendTime=x eventStatus=Ended"))
+	assert.False(t, isLogfmtLine("endTime=2021-06-13T00:00:01Z"))
+}
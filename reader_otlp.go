@@ -0,0 +1,221 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Is line an OTLP/JSON encoded logs?
+func isOtlpLine(line string) bool {
+	index := strings.Index(line, "{")
+	if index < 0 {
+		return false
+	}
+
+	return strings.Contains(line[index:], `"resourceLogs"`)
+}
+
+// Decode OTLP/JSON encoded logs in one line, each LogRecord is decoded into a Record.
+//
+// Attributes written by OTLP encoding are decoded back, LogRecord written by other producers is decoded
+// with time, body as operation and trace id only.
+func decodeOtlpLine(line string) ([]*Record, error) {
+	data := &otlpLogsData{}
+	if err := json.Unmarshal([]byte(line[strings.Index(line, "{"):]), data); err != nil {
+		return nil, err
+	}
+
+	res := make([]*Record, 0)
+	for _, logs := range data.ResourceLogs {
+		if logs == nil {
+			continue
+		}
+
+		for _, scope := range logs.ScopeLogs {
+			if scope == nil {
+				continue
+			}
+
+			for _, log := range scope.LogRecords {
+				if log == nil {
+					continue
+				}
+
+				record, err := decodeOtlpLogRecord(logs.Resource, log)
+				if err != nil {
+					return nil, err
+				}
+				res = append(res, record)
+			}
+		}
+	}
+
+	return res, nil
+}
+
+// Decode LogRecord with resource.
+func decodeOtlpLogRecord(resource *otlpResource, log *otlpLogRecord) (*Record, error) {
+	record := newRecord()
+	record.Encoding = OTLP
+
+	// ************* Resource *************
+	if resource != nil {
+		for _, attr := range resource.Attributes {
+			if attr == nil {
+				continue
+			}
+
+			value := otlpToString(attr.Value)
+			switch attr.Key {
+			case otlpServiceNameKey:
+				record.ServiceName = value
+			case otlpServiceVersionKey:
+				record.ServiceVersion = value
+			case otlpEntryNameKey:
+				record.EntryName = value
+			case otlpEntryKindKey:
+				record.EntryKind = value
+			case otlpHostNameKey:
+				record.Env[hostnameKey] = value
+			case otlpHostIpKey:
+				record.Env[localIpKey] = value
+			case otlpHostArchKey:
+				record.Env[goArchKey] = value
+			case otlpOsTypeKey:
+				record.Env[goosKey] = value
+			default:
+				if strings.HasPrefix(attr.Key, otlpEnvPrefix) {
+					record.Env[strings.TrimPrefix(attr.Key, otlpEnvPrefix)] = value
+				}
+			}
+		}
+	}
+
+	// ************* Time *************
+	var err error
+	if record.StartTime, err = otlpToTime(log.TimeUnixNano); err != nil {
+		return nil, &decodeError{key: "timeUnixNano", msg: err.Error()}
+	}
+	if record.EndTime, err = otlpToTime(log.ObservedTimeUnixNano); err != nil {
+		return nil, &decodeError{key: "observedTimeUnixNano", msg: err.Error()}
+	}
+	if !record.StartTime.IsZero() && !record.EndTime.IsZero() {
+		record.ElapsedNano = record.EndTime.Sub(record.StartTime).Nanoseconds()
+	}
+
+	record.Operation = otlpToString(log.Body)
+	record.TraceId = log.TraceId
+
+	// ************* Attributes *************
+	for _, attr := range log.Attributes {
+		if attr == nil {
+			continue
+		}
+
+		switch attr.Key {
+		case otlpEventIdKey:
+			record.EventId = otlpToString(attr.Value)
+		case otlpTraceIdKey:
+			record.TraceId = otlpToString(attr.Value)
+		case otlpRequestIdKey:
+			record.RequestId = otlpToString(attr.Value)
+		case otlpParentIdKey:
+			record.ParentEventId = otlpToString(attr.Value)
+		case otlpElapsedKey:
+			record.ElapsedNano, _ = strconv.ParseInt(otlpToString(attr.Value), 10, 64)
+		case otlpTimezoneKey:
+			record.Timezone = otlpToString(attr.Value)
+		case otlpOperationKey:
+			record.Operation = otlpToString(attr.Value)
+		case otlpClientAddrKey:
+			record.RemoteAddr = otlpToString(attr.Value)
+		case otlpResCodeKey:
+			record.ResCode = otlpToString(attr.Value)
+		case otlpStatusKey:
+			record.EventStatus = otlpToString(attr.Value)
+		case otlpPayloadsKey, otlpErrorsKey, otlpCountersKey, otlpPairsKey, otlpTimingKey:
+			section, _ := otlpToInterface(attr.Value).(map[string]interface{})
+			decodeSection(record, otlpSections[attr.Key], section)
+		}
+	}
+
+	return record, nil
+}
+
+// Sections in OTLP attributes and keys of sections in record.
+var otlpSections = map[string]string{
+	otlpPayloadsKey: payloadsKey,
+	otlpErrorsKey:   errKey,
+	otlpCountersKey: countersKey,
+	otlpPairsKey:    pairsKey,
+	otlpTimingKey:   timingKey,
+}
+
+// Parse unix nano in string, 0 is decoded as zero time.
+func otlpToTime(str string) (time.Time, error) {
+	if len(str) < 1 || str == "0" {
+		return time.Time{}, nil
+	}
+
+	nano, err := strconv.ParseInt(str, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Unix(0, nano), nil
+}
+
+// Convert value into string.
+func otlpToString(value *otlpAnyValue) string {
+	switch v := otlpToInterface(value).(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		return formatValue(v)
+	}
+}
+
+// Convert value into string, bool, int64, float64, []interface{} or map[string]interface{}.
+func otlpToInterface(value *otlpAnyValue) interface{} {
+	switch {
+	case value == nil:
+		return nil
+	case value.StringValue != nil:
+		return *value.StringValue
+	case value.BoolValue != nil:
+		return *value.BoolValue
+	case value.IntValue != nil:
+		i, err := strconv.ParseInt(*value.IntValue, 10, 64)
+		if err != nil {
+			return *value.IntValue
+		}
+		return i
+	case value.DoubleValue != nil:
+		return *value.DoubleValue
+	case value.ArrayValue != nil:
+		res := make([]interface{}, 0, len(value.ArrayValue.Values))
+		for i := range value.ArrayValue.Values {
+			res = append(res, otlpToInterface(value.ArrayValue.Values[i]))
+		}
+		return res
+	case value.KvlistValue != nil:
+		res := make(map[string]interface{}, len(value.KvlistValue.Values))
+		for _, kv := range value.KvlistValue.Values {
+			if kv != nil {
+				res[kv.Key] = otlpToInterface(kv.Value)
+			}
+		}
+		return res
+	}
+
+	return nil
+}
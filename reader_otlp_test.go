@@ -0,0 +1,87 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReader_Read_WithOtlp(t *testing.T) {
+	buf := &bytes.Buffer{}
+	buf.WriteString("other logs\n")
+	expected := writeFullEvent(buf, OTLP)
+
+	records, err := ParseOtlp(buf)
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+
+	record := records[0]
+	assert.Equal(t, OTLP, record.Encoding)
+	assert.Equal(t, int64(len("other logs\n")), record.Offset)
+	assert.Equal(t, expected.GetEventId(), record.EventId)
+	assert.Equal(t, "ut-trace", record.TraceId)
+	assert.Equal(t, "ut-service", record.ServiceName)
+	assert.Equal(t, "ut-entry", record.EntryName)
+	assert.Equal(t, "ut-op", record.Operation)
+	assert.Equal(t, "10.0.0.1:1949", record.RemoteAddr)
+	assert.Equal(t, int64(10e6), record.ElapsedNano)
+	assert.Equal(t, "v1", record.Payloads["f1"])
+	assert.Equal(t, int64(1), record.Errors["ut-err"])
+	assert.Equal(t, int64(3), record.Counters["ut-counter"])
+	assert.Equal(t, "ut-value", record.Pairs["ut-key"])
+	assert.Equal(t, int64(5), record.Timing["ut-timer.elapsedMs"])
+}
+
+func TestReader_Read_WithOtlpBatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ut.otlp")
+	exporter, err := NewOtlpFileExporter(path)
+	assert.Nil(t, err)
+
+	first, second := newRecord(), newRecord()
+	first.EventId, first.ServiceName = "ut-event-1", "ut-service-1"
+	second.EventId, second.ServiceName = "ut-event-2", "ut-service-2"
+	assert.Nil(t, exporter.Export(first))
+	assert.Nil(t, exporter.Export(second))
+	assert.Nil(t, exporter.Close())
+
+	// records in the same line share the same offset
+	records, err := readRecordsAt(path, 0)
+	assert.Nil(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, "ut-event-1", records[0].EventId)
+	assert.Equal(t, "ut-service-2", records[1].ServiceName)
+
+	index, err := OpenIndex(filepath.Join(t.TempDir(), "ut.idx"))
+	assert.Nil(t, err)
+	assert.Nil(t, index.Update(path))
+
+	found, err := index.Lookup("ut-event-2")
+	assert.Nil(t, err)
+	assert.Len(t, found, 1)
+	assert.Equal(t, "ut-service-2", found[0].ServiceName)
+}
+
+func TestReader_Read_WithForeignOtlp(t *testing.T) {
+	line := `{"resourceLogs":[{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"ut-service"}}]},` +
+		`"scopeLogs":[{"logRecords":[{"timeUnixNano":"1623542400000000000","body":{"stringValue":"ut-body"},` +
+		`"traceId":"0af7651916cd43dd8448eb211c80319c"}]}]}]}`
+
+	records, err := Parse(strings.NewReader(line))
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, "ut-service", records[0].ServiceName)
+	assert.Equal(t, "ut-body", records[0].Operation)
+	assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", records[0].TraceId)
+	assert.Equal(t, int64(1623542400000000000), records[0].StartTime.UnixNano())
+
+	// malformed line
+	_, err = Parse(strings.NewReader(`{"resourceLogs":[`))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Create a zap logger which writes message only into buffer.
+func newBufferLogger(buf *bytes.Buffer, json bool) *zap.Logger {
+	config := zapcore.EncoderConfig{MessageKey: "msg", TimeKey: "", LevelKey: "", EncodeTime: zapcore.ISO8601TimeEncoder}
+	encoder := zapcore.NewConsoleEncoder(config)
+	if json {
+		encoder = zapcore.NewJSONEncoder(config)
+	}
+
+	return zap.New(zapcore.NewCore(encoder, zapcore.Lock(zapcore.AddSync(buf)), zap.InfoLevel))
+}
+
+// Write a finished event with full sections into buffer.
+func writeFullEvent(buf *bytes.Buffer, ec Encoding) Event {
+	event := NewEventFactory(
+		WithZapLogger(newBufferLogger(buf, ec == JSON || ec == ECS)),
+		WithEncoding(ec),
+		WithServiceName("ut-service"),
+		WithServiceVersion("v0.0.1"),
+		WithEntryName("ut-entry"),
+		WithEntryKind("ut-kind")).CreateEvent()
+
+	start := time.Now()
+	event.SetStartTime(start)
+	event.SetOperation("ut-op")
+	event.SetTraceId("ut-trace")
+	event.SetRequestId("ut-request")
+	event.SetRemoteAddr("10.0.0.1:1949")
+	event.AddPayloads(zap.String("f1", "v1"), zap.Int("f2", 2))
+	event.AddErr(errors.New("ut-err"))
+	event.SetCounter("ut-counter", 3)
+	event.AddPair("ut-key", "ut-value")
+	event.UpdateTimerMs("ut-timer", 5)
+	event.SetResCode("OK")
+	event.SetEndTime(start.Add(10 * time.Millisecond))
+	event.Finish()
+
+	return event
+}
+
+func TestReader_Read_WithEmptyInput(t *testing.T) {
+	record, err := NewReader(strings.NewReader("")).Read()
+	assert.Nil(t, record)
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestReader_Read_WithUnrelatedLines(t *testing.T) {
+	record, err := NewReader(strings.NewReader("foo\nbar\n")).Read()
+	assert.Nil(t, record)
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestReader_ReadAll_HappyCase(t *testing.T) {
+	buf := &bytes.Buffer{}
+	writeFullEvent(buf, CONSOLE)
+	buf.WriteString("some other logs\n")
+	writeFullEvent(buf, CONSOLE)
+
+	records, err := NewReader(buf).ReadAll()
+	assert.Nil(t, err)
+	assert.Len(t, records, 2)
+}
+
+func TestReader_ReadAll_WithMalformedEvent(t *testing.T) {
+	buf := &bytes.Buffer{}
+	buf.WriteString(scopeDelimiter + "\nelapsedNano=abc\n" + eoe + "\n")
+
+	records, err := NewReader(buf).ReadAll()
+	assert.NotNil(t, err)
+	assert.Empty(t, records)
+}
+
+func TestDecodeJsonObject(t *testing.T) {
+	res, err := decodeJsonObject(`{"int":1,"float":1.5,"nested":{"int":2},"list":[3]}`)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), res["int"])
+	assert.Equal(t, 1.5, res["float"])
+	assert.Equal(t, int64(2), res["nested"].(map[string]interface{})["int"])
+	assert.Equal(t, int64(3), res["list"].([]interface{})[0])
+
+	_, err = decodeJsonObject(`{`)
+	assert.NotNil(t, err)
+}
+
+func TestWithReaderEncoding(t *testing.T) {
+	buf := &bytes.Buffer{}
+	writeFullEvent(buf, CONSOLE)
+	writeFullEvent(buf, JSON)
+
+	records, err := NewReader(buf, WithReaderEncoding(JSON)).ReadAll()
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, JSON, records[0].Encoding)
+
+	// empty encodings should be ignored
+	reader := NewReader(buf, WithReaderEncoding())
+	assert.Len(t, reader.encodings, 6)
+}
+
+func TestParseError(t *testing.T) {
+	err := NewReader(strings.NewReader("")).errorf("ut-%s", "err")
+	assert.Equal(t, "rkquery: line 0: ut-err", err.Error())
+
+	var parseErr *ParseError
+	assert.True(t, errors.As(err, &parseErr))
+	assert.Equal(t, "ut-err", errors.Unwrap(err).Error())
+}
+
+func TestReader_Read_WithOffset(t *testing.T) {
+	buf := &bytes.Buffer{}
+	buf.WriteString("other logs\n")
+	writeFullEvent(buf, CONSOLE)
+	second := buf.Len()
+	writeFullEvent(buf, JSON)
+	input := buf.String()
+
+	records, err := Parse(strings.NewReader(input))
+	assert.Nil(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, int64(len("other logs\n")), records[0].Offset)
+	assert.Equal(t, int64(second), records[1].Offset)
+}
@@ -0,0 +1,520 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Record is a query log event decoded from output written by Event.Finish().
+//
+// Sections which are missing in the original output are left as zero values.
+type Record struct {
+	// ************* Time *************
+	EndTime     time.Time
+	StartTime   time.Time
+	ElapsedNano int64
+	Timezone    string
+	// ************* Ids *************
+	EventId   string
+	TraceId   string
+	RequestId string
+	// ParentEventId is event id of parent event if record is a child event
+	ParentEventId string
+	// ************* Service *************
+	ServiceName    string
+	ServiceVersion string
+	EntryName      string
+	EntryKind      string
+	// ************* Env *************
+	Env map[string]string
+	// ************* Payloads *************
+	Payloads map[string]interface{}
+	// ************* Error *************
+	Errors map[string]int64
+	// ************* Counters *************
+	Counters map[string]int64
+	// ************* Pairs *************
+	Pairs map[string]string
+	// ************* Timing *************
+	Timing map[string]int64
+	// ************* Children *************
+	// Children rolled up into record, only summary of child is written, see WithChildRollup()
+	Children []*Record
+	// ************* Event *************
+	RemoteAddr  string
+	Operation   string
+	ResCode     string
+	EventStatus string
+	// Encoding of original output
+	Encoding Encoding
+	// Offset of the first byte of event in input of Reader
+	Offset int64
+}
+
+// Create a new Record with empty sections.
+func newRecord() *Record {
+	return &Record{
+		Env:      make(map[string]string),
+		Payloads: make(map[string]interface{}),
+		Errors:   make(map[string]int64),
+		Counters: make(map[string]int64),
+		Pairs:    make(map[string]string),
+		Timing:   make(map[string]int64),
+	}
+}
+
+// Elapsed returns elapsed time of current record.
+func (record *Record) Elapsed() time.Duration {
+	return time.Duration(record.ElapsedNano)
+}
+
+// Value returns value of field with path, false would be returned if field is missing.
+//
+// Path is the key of field in CONSOLE or JSON encoding, keys in sections are joined with dot like
+// ids.eventId, service.serviceName, env.hostname, payloads.key, error.message, counters.key, pairs.key
+// and timing.name.elapsedMs. Keys in ids and service section could be used without section name.
+// Nested payloads could be accessed with dot separated keys like payloads.request.id.
+//
+// Section name without key returns the whole section.
+func (record *Record) Value(path string) (interface{}, bool) {
+	section, key := path, ""
+	if index := strings.Index(path, "."); index > 0 {
+		section, key = path[:index], path[index+1:]
+	}
+
+	switch section {
+	// ************* Time *************
+	case endTimeKey:
+		return record.EndTime, !record.EndTime.IsZero()
+	case startTimeKey:
+		return record.StartTime, !record.StartTime.IsZero()
+	case elapsedKey:
+		return record.ElapsedNano, true
+	case elapsedMsKey:
+		return record.Elapsed().Milliseconds(), true
+	case timezoneKey:
+		return record.Timezone, len(record.Timezone) > 0
+	// ************* Ids *************
+	case eventIdKey:
+		return record.EventId, len(record.EventId) > 0
+	case traceIdKey:
+		return record.TraceId, len(record.TraceId) > 0
+	case requestIdKey:
+		return record.RequestId, len(record.RequestId) > 0
+	case parentEventIdKey:
+		return record.ParentEventId, len(record.ParentEventId) > 0
+	case idsKey:
+		if len(key) > 0 {
+			return record.Value(key)
+		}
+		return map[string]string{
+			eventIdKey:       record.EventId,
+			traceIdKey:       record.TraceId,
+			requestIdKey:     record.RequestId,
+			parentEventIdKey: record.ParentEventId,
+		}, true
+	case childrenKey:
+		return record.Children, len(record.Children) > 0
+	// ************* Service *************
+	case serviceNameKey:
+		return record.ServiceName, len(record.ServiceName) > 0
+	case serviceVersionKey:
+		return record.ServiceVersion, len(record.ServiceVersion) > 0
+	case entryNameKey:
+		return record.EntryName, len(record.EntryName) > 0
+	case entryKindKey:
+		return record.EntryKind, len(record.EntryKind) > 0
+	case serviceKey:
+		if len(key) > 0 {
+			return record.Value(key)
+		}
+		return map[string]string{
+			serviceNameKey:    record.ServiceName,
+			serviceVersionKey: record.ServiceVersion,
+			entryNameKey:      record.EntryName,
+			entryKindKey:      record.EntryKind,
+		}, true
+	// ************* Sections *************
+	case envKey:
+		if len(key) < 1 {
+			return record.Env, true
+		}
+		val, ok := record.Env[key]
+		return val, ok
+	case payloadsKey:
+		if len(key) < 1 {
+			return record.Payloads, true
+		}
+		return nestedValue(record.Payloads, key)
+	case errKey:
+		if len(key) < 1 {
+			return record.Errors, len(record.Errors) > 0
+		}
+		val, ok := record.Errors[key]
+		return val, ok
+	case countersKey:
+		if len(key) < 1 {
+			return record.Counters, true
+		}
+		val, ok := record.Counters[key]
+		return val, ok
+	case pairsKey:
+		if len(key) < 1 {
+			return record.Pairs, true
+		}
+		val, ok := record.Pairs[key]
+		return val, ok
+	case timingKey:
+		if len(key) < 1 {
+			return record.Timing, true
+		}
+		val, ok := record.Timing[key]
+		return val, ok
+	// ************* Event *************
+	case remoteAddrKey:
+		return record.RemoteAddr, len(record.RemoteAddr) > 0
+	case operationKey:
+		return record.Operation, len(record.Operation) > 0
+	case resCodeKey:
+		return record.ResCode, len(record.ResCode) > 0
+	case eventStatusKey:
+		return record.EventStatus, len(record.EventStatus) > 0
+	}
+
+	return nil, false
+}
+
+// HasError returns true if any error was recorded in current record.
+func (record *Record) HasError() bool {
+	return len(record.Errors) > 0
+}
+
+// SampleWeight returns number of events represented by current record, which is 1/sampleRate written by
+// WithSampler(). 1 would be returned if record was not sampled.
+func (record *Record) SampleWeight() float64 {
+	rate, err := strconv.ParseFloat(record.Pairs[sampleRateKey], 64)
+	if err != nil || !(rate > 0 && rate <= 1) {
+		return 1
+	}
+
+	return 1 / rate
+}
+
+// ************* EventView *************
+
+// GetStartTime returns start time of record.
+func (record *Record) GetStartTime() time.Time {
+	return record.StartTime
+}
+
+// GetEndTime returns end time of record.
+func (record *Record) GetEndTime() time.Time {
+	return record.EndTime
+}
+
+// GetTimeZone returns time zone of record.
+func (record *Record) GetTimeZone() string {
+	return record.Timezone
+}
+
+// GetEventId returns event id of record.
+func (record *Record) GetEventId() string {
+	return record.EventId
+}
+
+// GetTraceId returns trace id of record.
+func (record *Record) GetTraceId() string {
+	return record.TraceId
+}
+
+// GetRequestId returns request id of record.
+func (record *Record) GetRequestId() string {
+	return record.RequestId
+}
+
+// GetParentEventId returns event id of parent event.
+func (record *Record) GetParentEventId() string {
+	return record.ParentEventId
+}
+
+// GetServiceName returns service name of record.
+func (record *Record) GetServiceName() string {
+	return record.ServiceName
+}
+
+// GetServiceVersion returns service version of record.
+func (record *Record) GetServiceVersion() string {
+	return record.ServiceVersion
+}
+
+// GetEntryName returns entry name of record.
+func (record *Record) GetEntryName() string {
+	return record.EntryName
+}
+
+// GetEntryKind returns entry kind of record.
+func (record *Record) GetEntryKind() string {
+	return record.EntryKind
+}
+
+// ListEnv returns env of record.
+func (record *Record) ListEnv() map[string]string {
+	return record.Env
+}
+
+// ListPayloads returns payloads of record as zap.Field sorted by key.
+func (record *Record) ListPayloads() []zap.Field {
+	res := make([]zap.Field, 0, len(record.Payloads))
+	for _, k := range sortedKeys(record.Payloads) {
+		res = append(res, zap.Any(k, record.Payloads[k]))
+	}
+
+	return res
+}
+
+// ListErrors returns errors of record.
+func (record *Record) ListErrors() map[string]int64 {
+	return record.Errors
+}
+
+// ListCounters returns counters of record.
+func (record *Record) ListCounters() map[string]int64 {
+	return record.Counters
+}
+
+// ListPairs returns pairs of record.
+func (record *Record) ListPairs() map[string]string {
+	return record.Pairs
+}
+
+// ListTimings returns timing of record.
+func (record *Record) ListTimings() map[string]int64 {
+	return record.Timing
+}
+
+// ListChildren returns children rolled up into record.
+func (record *Record) ListChildren() []EventView {
+	if len(record.Children) < 1 {
+		return nil
+	}
+
+	res := make([]EventView, 0, len(record.Children))
+	for i := range record.Children {
+		res = append(res, record.Children[i])
+	}
+
+	return res
+}
+
+// GetOperation returns operation of record.
+func (record *Record) GetOperation() string {
+	return record.Operation
+}
+
+// GetRemoteAddr returns remote address of record.
+func (record *Record) GetRemoteAddr() string {
+	return record.RemoteAddr
+}
+
+// GetResCode returns response code of record.
+func (record *Record) GetResCode() string {
+	return record.ResCode
+}
+
+// GetEventStatus returns event status of record.
+func (record *Record) GetEventStatus() eventStatus {
+	return toEventStatus(record.EventStatus)
+}
+
+// Returns view as Record, maps of view are shared with returned Record which should not be modified.
+func recordOf(view EventView) *Record {
+	if record, ok := view.(*Record); ok {
+		return record
+	}
+
+	record := recordWithoutPayloadsOf(view)
+	record.Payloads = payloadsOf(view)
+
+	return record
+}
+
+// Returns view as Record without payloads, maps of view are shared with returned Record.
+func recordWithoutPayloadsOf(view EventView) *Record {
+	return &Record{
+		EndTime:        view.GetEndTime(),
+		StartTime:      view.GetStartTime(),
+		ElapsedNano:    view.GetEndTime().Sub(view.GetStartTime()).Nanoseconds(),
+		Timezone:       view.GetTimeZone(),
+		EventId:        view.GetEventId(),
+		TraceId:        view.GetTraceId(),
+		RequestId:      view.GetRequestId(),
+		ParentEventId:  view.GetParentEventId(),
+		ServiceName:    view.GetServiceName(),
+		ServiceVersion: view.GetServiceVersion(),
+		EntryName:      view.GetEntryName(),
+		EntryKind:      view.GetEntryKind(),
+		Env:            view.ListEnv(),
+		Errors:         view.ListErrors(),
+		Counters:       view.ListCounters(),
+		Pairs:          view.ListPairs(),
+		Timing:         view.ListTimings(),
+		Children:       recordsOf(view.ListChildren()),
+		RemoteAddr:     view.GetRemoteAddr(),
+		Operation:      view.GetOperation(),
+		ResCode:        view.GetResCode(),
+		EventStatus:    view.GetEventStatus().String(),
+	}
+}
+
+// Returns views as Records.
+func recordsOf(views []EventView) []*Record {
+	if len(views) < 1 {
+		return nil
+	}
+
+	res := make([]*Record, 0, len(views))
+	for i := range views {
+		res = append(res, recordOf(views[i]))
+	}
+
+	return res
+}
+
+// Override fields in event with values in record.
+func (event *eventZap) fromRecord(record *Record) {
+	// ************* Time *************
+	event.startTime = record.StartTime
+	event.endTime = record.EndTime
+	// times may have millisecond precision only, elapsed time is kept in nanoseconds
+	if record.ElapsedNano != 0 && !record.StartTime.IsZero() {
+		event.endTime = record.StartTime.Add(time.Duration(record.ElapsedNano))
+	}
+	event.timeZone = record.Timezone
+	// ************* Ids *************
+	event.eventId = record.EventId
+	event.traceId = record.TraceId
+	event.requestId = record.RequestId
+	event.parentEventId = record.ParentEventId
+	// ************* Service *************
+	event.serviceName = record.ServiceName
+	event.serviceVersion = record.ServiceVersion
+	event.entryName = record.EntryName
+	event.entryKind = record.EntryKind
+	// ************* Env *************
+	event.env = record.Env
+	// ************* Payloads *************
+	event.payloads = record.ListPayloads()
+	// ************* Error *************
+	event.errors = zapcore.NewMapObjectEncoder()
+	for k, v := range record.Errors {
+		event.errors.AddInt64(k, v)
+	}
+	// ************* Counters *************
+	event.counters = zapcore.NewMapObjectEncoder()
+	for k, v := range record.Counters {
+		event.counters.AddInt64(k, v)
+	}
+	// ************* Pairs *************
+	event.pairs = zapcore.NewMapObjectEncoder()
+	for k, v := range record.Pairs {
+		event.pairs.AddString(k, v)
+	}
+	// ************* Timing *************
+	// timing was flattened into keys with suffix of .elapsedMs and .count
+	event.tracker = make(map[string]*timeTracker)
+	for k, v := range record.Timing {
+		name, field := splitTimingKey(k)
+		if len(name) < 1 {
+			continue
+		}
+
+		tracker, ok := event.tracker[name]
+		if !ok {
+			tracker = newTimeTracker(name)
+			event.tracker[name] = tracker
+		}
+
+		switch field {
+		case timingElapsedMs:
+			tracker.elapsedTotalMs = v
+		case timingCount:
+			tracker.countTotal = v
+		}
+	}
+	// ************* Children *************
+	if len(record.Children) > 0 {
+		event.children = &eventChildren{views: record.ListChildren(), closed: true}
+	}
+	// ************* Event *************
+	event.remoteAddr = record.RemoteAddr
+	event.operation = record.Operation
+	event.resCode = record.ResCode
+	event.status = toEventStatus(record.EventStatus)
+}
+
+// Look up value in nested map with dot separated keys.
+// Keys containing dot are matched before nested keys.
+func nestedValue(m map[string]interface{}, path string) (interface{}, bool) {
+	if val, ok := m[path]; ok {
+		return val, true
+	}
+
+	index := strings.Index(path, ".")
+	for index > 0 {
+		if nested, ok := m[path[:index]].(map[string]interface{}); ok {
+			if val, ok := nestedValue(nested, path[index+1:]); ok {
+				return val, true
+			}
+		}
+
+		next := strings.Index(path[index+1:], ".")
+		if next < 0 {
+			break
+		}
+		index += next + 1
+	}
+
+	return nil, false
+}
+
+// Split flattened timing key like name.elapsedMs into timer name and field.
+func splitTimingKey(key string) (string, string) {
+	index := strings.LastIndex(key, ".")
+	if index < 1 {
+		return "", key
+	}
+
+	return key[:index], key[index+1:]
+}
+
+// Convert string value of event status back to eventStatus.
+func toEventStatus(str string) eventStatus {
+	switch str {
+	case InProgress.String():
+		return InProgress
+	case Ended.String():
+		return Ended
+	default:
+		return NotStarted
+	}
+}
+
+// Returns sorted keys of map.
+func sortedKeys(m map[string]interface{}) []string {
+	res := make([]string, 0, len(m))
+	for k := range m {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+
+	return res
+}
@@ -0,0 +1,153 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"testing"
+	"time"
+)
+
+func TestRecord_Elapsed(t *testing.T) {
+	record := newRecord()
+	record.ElapsedNano = time.Second.Nanoseconds()
+	assert.Equal(t, time.Second, record.Elapsed())
+}
+
+func TestRecord_HasError(t *testing.T) {
+	record := newRecord()
+	assert.False(t, record.HasError())
+
+	record.Errors["ut-err"] = 1
+	assert.True(t, record.HasError())
+}
+
+func TestEventFactory_CreateEventFromRecord_RoundTrip(t *testing.T) {
+	for _, ec := range []Encoding{CONSOLE, JSON, FLATTEN} {
+		buf := &bytes.Buffer{}
+		writeFullEvent(buf, ec)
+
+		records, err := Parse(buf)
+		assert.Nil(t, err)
+		assert.Len(t, records, 1)
+
+		// write decoded record again
+		NewEventFactory().CreateEventFromRecord(records[0],
+			WithZapLogger(newBufferLogger(buf, ec == JSON)),
+			WithEncoding(ec)).Finish()
+
+		again, err := Parse(buf)
+		assert.Nil(t, err)
+		assert.Len(t, again, 1)
+		assert.Equal(t, records[0].StartTime.UnixNano(), again[0].StartTime.UnixNano())
+		again[0].StartTime, again[0].EndTime = records[0].StartTime, records[0].EndTime
+		assert.Equal(t, records[0], again[0])
+	}
+}
+
+func TestEventFactory_CreateEventFromRecord_WithElapsedNano(t *testing.T) {
+	start := time.Date(2021, 6, 13, 0, 24, 20, 0, time.UTC)
+	record := newRecord()
+	record.StartTime = start
+	record.EndTime = start.Add(30 * time.Millisecond)
+	record.ElapsedNano = 30001143
+
+	for _, ec := range []Encoding{CONSOLE, JSON} {
+		buf := &bytes.Buffer{}
+		NewEventFactory().CreateEventFromRecord(record,
+			WithZapLogger(newBufferLogger(buf, ec == JSON)),
+			WithEncoding(ec)).Finish()
+
+		again, err := Parse(buf)
+		assert.Nil(t, err)
+		assert.Len(t, again, 1)
+		assert.Equal(t, int64(30001143), again[0].ElapsedNano, ec.String())
+	}
+}
+
+func TestToEventStatus(t *testing.T) {
+	assert.Equal(t, NotStarted, toEventStatus(NotStarted.String()))
+	assert.Equal(t, InProgress, toEventStatus(InProgress.String()))
+	assert.Equal(t, Ended, toEventStatus(Ended.String()))
+	assert.Equal(t, NotStarted, toEventStatus("unknown"))
+}
+
+func TestRecord_Value(t *testing.T) {
+	buf := &bytes.Buffer{}
+	event := writeFullEvent(buf, CONSOLE)
+	records, _ := Parse(buf)
+	record := records[0]
+	record.Payloads["nested"] = map[string]interface{}{"a": map[string]interface{}{"b": "c"}}
+	record.Payloads["dot.key"] = "dot"
+
+	cases := map[string]interface{}{
+		"elapsedNano":               (10 * time.Millisecond).Nanoseconds(),
+		"elapsedMs":                 int64(10),
+		"eventId":                   event.GetEventId(),
+		"ids.traceId":               "ut-trace",
+		"requestId":                 "ut-request",
+		"service.serviceName":       "ut-service",
+		"serviceVersion":            "v0.0.1",
+		"entryName":                 "ut-entry",
+		"service.entryKind":         "ut-kind",
+		"env.hostname":              hostname,
+		"payloads.f1":               "v1",
+		"payloads.nested.a.b":       "c",
+		"payloads.dot.key":          "dot",
+		"error.ut-err":              int64(1),
+		"counters.ut-counter":       int64(3),
+		"pairs.ut-key":              "ut-value",
+		"timing.ut-timer.elapsedMs": int64(5),
+		"remoteAddr":                "10.0.0.1:1949",
+		"operation":                 "ut-op",
+		"resCode":                   "OK",
+		"eventStatus":               "Ended",
+	}
+
+	for path, expected := range cases {
+		val, ok := record.Value(path)
+		assert.True(t, ok, path)
+		assert.Equal(t, expected, val, path)
+	}
+
+	for _, path := range []string{"unknown", "pairs.unknown", "payloads.nested.x", "error.unknown", "env.unknown", "counters.x", "timing.x"} {
+		_, ok := record.Value(path)
+		assert.False(t, ok, path)
+	}
+
+	for _, path := range []string{"ids", "service", "env", "payloads", "error", "counters", "pairs", "timing", "startTime", "endTime", "timezone"} {
+		_, ok := record.Value(path)
+		assert.True(t, ok, path)
+	}
+}
+
+func TestSplitTimingKey(t *testing.T) {
+	name, field := splitTimingKey("a.b.elapsedMs")
+	assert.Equal(t, "a.b", name)
+	assert.Equal(t, "elapsedMs", field)
+
+	name, _ = splitTimingKey("invalid")
+	assert.Empty(t, name)
+}
+
+func TestRecordOf_WithEvent(t *testing.T) {
+	event := NewEventFactory(WithServiceName("ut-service")).CreateEvent()
+	event.SetStartTime(time.Now())
+	event.SetOperation("ut-op")
+	event.AddPayloads(zap.String("f1", "v1"))
+	event.SetEndTime(event.GetStartTime().Add(time.Second))
+
+	record := recordOf(event.(*eventZap))
+	assert.Equal(t, "ut-service", record.ServiceName)
+	assert.Equal(t, "ut-op", record.Operation)
+	assert.Equal(t, "v1", record.Payloads["f1"])
+	assert.Equal(t, time.Second, record.Elapsed())
+
+	// record is returned as it is
+	assert.Same(t, record, recordOf(record))
+}
@@ -0,0 +1,392 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// RedactionStrategy decides how sensitive values are replaced, see WithRedaction().
+type RedactionStrategy string
+
+const (
+	// RedactMask replaces sensitive value with mask, it is the default strategy.
+	RedactMask RedactionStrategy = "mask"
+	// RedactHash replaces sensitive value with first 16 hex of sha256 of salt and value,
+	// so that the same values could still be correlated.
+	RedactHash RedactionStrategy = "hash"
+	// RedactDrop removes payload or pair with sensitive value, error message is masked instead
+	// in order to keep error count.
+	RedactDrop RedactionStrategy = "drop"
+	// RedactTruncate keeps leading characters of sensitive value followed by mask.
+	RedactTruncate RedactionStrategy = "truncate"
+)
+
+var (
+	// EmailPattern matches email addresses.
+	EmailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	// CardNumberPattern matches payment card numbers (PAN) of 13 to 19 digits, optionally separated by spaces or dashes.
+	// Matches are redacted only if they pass Luhn check, so that timestamps and numeric ids are kept mostly.
+	CardNumberPattern = regexp.MustCompile(`\b(?:\d[ \-]?){12,18}\d\b`)
+	// JwtPattern matches JSON web tokens.
+	JwtPattern = regexp.MustCompile(`eyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]*`)
+
+	// DefaultRedactionKeys are key patterns used if both of Redaction.Keys and Redaction.Patterns are empty.
+	DefaultRedactionKeys = []string{
+		"authorization", "proxy-authorization", "cookie", "set-cookie",
+		"*password*", "passwd", "*secret*", "*token*", "*api-key*", "*apikey*",
+	}
+	// DefaultRedactionPatterns are value patterns used if both of Redaction.Keys and Redaction.Patterns are empty.
+	DefaultRedactionPatterns = []*regexp.Regexp{EmailPattern, CardNumberPattern, JwtPattern}
+)
+
+// Redaction defines sensitive keys and values which should not be written, see WithRedaction().
+type Redaction struct {
+	// Keys are case insensitive key patterns of payloads and pairs, e.g. ["authorization", "*password*"].
+	// Patterns are matched with path.Match(). Whole value of matched key is redacted.
+	Keys []string
+	// Patterns of sensitive values in payloads, pairs and error messages, e.g. EmailPattern.
+	// Matched part of value is redacted.
+	Patterns []*regexp.Regexp
+	// Strategy of redaction, default is RedactMask.
+	Strategy RedactionStrategy
+	// Salt of RedactHash.
+	Salt string
+	// Mask of RedactMask and RedactTruncate, default is ***.
+	Mask string
+	// TruncateLen is the number of leading characters kept by RedactTruncate, default is 4.
+	// Value no longer than TruncateLen is masked.
+	TruncateLen int
+}
+
+// WithRedaction redacts sensitive payloads, pairs and error messages while finishing event.
+//
+// Redaction is applied to output of every encoding, encoder passed by WithEncoder(), OTLP exporter and
+// children rolled up into parent event. Data of event itself is untouched.
+// Number of redactions is written as counter with key redactions.
+//
+// DefaultRedactionKeys and DefaultRedactionPatterns are used if both of Keys and Patterns are empty.
+func WithRedaction(r Redaction) EventOption {
+	// compile once since options are applied to every event
+	compiled := newRedactor(r)
+
+	return func(event Event) {
+		switch v := event.(type) {
+		case *eventZap:
+			v.redactor = compiled
+		case *eventThreadSafe:
+			v.delegate.redactor = compiled
+		}
+	}
+}
+
+// Compiled redaction.
+type redactor struct {
+	keys        map[string]bool
+	globs       []string
+	patterns    []*regexp.Regexp
+	strategy    RedactionStrategy
+	salt        string
+	mask        string
+	truncateLen int
+}
+
+// Compile redaction, slices are copied so that redaction could be modified after.
+func newRedactor(r Redaction) *redactor {
+	res := &redactor{
+		keys:        make(map[string]bool),
+		strategy:    r.Strategy,
+		salt:        r.Salt,
+		mask:        getDefaultIfEmptyString(r.Mask, "***"),
+		truncateLen: r.TruncateLen,
+	}
+
+	keys, patterns := r.Keys, r.Patterns
+	if len(keys) < 1 && len(patterns) < 1 {
+		keys, patterns = DefaultRedactionKeys, DefaultRedactionPatterns
+	}
+
+	for i := range keys {
+		key := strings.ToLower(keys[i])
+		if _, err := path.Match(key, ""); err == nil && strings.ContainsAny(key, "*?[") {
+			res.globs = append(res.globs, key)
+		} else {
+			res.keys[key] = true
+		}
+	}
+
+	for i := range patterns {
+		if patterns[i] != nil {
+			res.patterns = append(res.patterns, patterns[i])
+		}
+	}
+
+	switch res.strategy {
+	case RedactMask, RedactHash, RedactDrop, RedactTruncate:
+	default:
+		res.strategy = RedactMask
+	}
+
+	if res.truncateLen < 1 {
+		res.truncateLen = 4
+	}
+
+	return res
+}
+
+// Returns view with sensitive values redacted.
+func (r *redactor) view(view EventView) EventView {
+	res := &redactedView{
+		EventView: view,
+		pairs:     make(map[string]string),
+		errors:    make(map[string]int64),
+	}
+
+	payloads := view.ListPayloads()
+	res.payloads = make([]zap.Field, 0, len(payloads))
+	for i := range payloads {
+		if field, keep := r.field(payloads[i], &res.count); keep {
+			res.payloads = append(res.payloads, field)
+		}
+	}
+
+	for k, v := range view.ListPairs() {
+		if value, keep := r.value(k, v, &res.count); keep {
+			res.pairs[k] = value
+		}
+	}
+
+	// error messages are never dropped in order to keep error count
+	replacer := r.apply
+	if r.strategy == RedactDrop {
+		replacer = func(string) string { return r.mask }
+	}
+	for k, v := range view.ListErrors() {
+		res.errors[r.replace(k, &res.count, replacer)] += v
+	}
+
+	res.counters = view.ListCounters()
+	if res.count > 0 {
+		if res.counters == nil {
+			res.counters = make(map[string]int64)
+		}
+		res.counters[redactionsKey] += res.count
+	}
+
+	return res
+}
+
+// Returns true if key is sensitive.
+func (r *redactor) matchKey(key string) bool {
+	key = strings.ToLower(key)
+	if r.keys[key] {
+		return true
+	}
+
+	for i := range r.globs {
+		if ok, _ := path.Match(r.globs[i], key); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Redact pair value, false would be returned if pair should be dropped.
+func (r *redactor) value(key, value string, count *int64) (string, bool) {
+	if r.matchKey(key) {
+		*count++
+		return r.apply(value), r.strategy != RedactDrop
+	}
+
+	dropped := false
+	res := r.replace(value, count, func(m string) string {
+		dropped = dropped || r.strategy == RedactDrop
+		return r.apply(m)
+	})
+
+	return res, !dropped
+}
+
+// Redact payload, false would be returned if payload should be dropped.
+func (r *redactor) field(field zap.Field, count *int64) (zap.Field, bool) {
+	if r.matchKey(field.Key) {
+		*count++
+		return zap.String(field.Key, r.apply(fieldValueString(field))), r.strategy != RedactDrop
+	}
+
+	switch field.Type {
+	case zapcore.StringType:
+		value, keep := r.value("", field.String, count)
+		if value != field.String {
+			field = zap.String(field.Key, value)
+		}
+		return field, keep
+	case zapcore.ByteStringType:
+		raw := string(field.Interface.([]byte))
+		value, keep := r.value("", raw, count)
+		if value != raw {
+			field = zap.String(field.Key, value)
+		}
+		return field, keep
+	case zapcore.ObjectMarshalerType, zapcore.ArrayMarshalerType, zapcore.ReflectType,
+		zapcore.StringerType, zapcore.ErrorType:
+		before := *count
+		dropped := false
+		value := r.nested(fieldValue(field), count, &dropped)
+		if dropped {
+			return field, false
+		}
+		if *count != before {
+			field = zap.Any(field.Key, value)
+		}
+		return field, true
+	}
+
+	return field, true
+}
+
+// Redact value of nested payload encoded by zapcore.MapObjectEncoder.
+func (r *redactor) nested(value interface{}, count *int64, dropped *bool) interface{} {
+	switch v := value.(type) {
+	case string:
+		res, keep := r.value("", v, count)
+		*dropped = *dropped || !keep
+		return res
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			if r.matchKey(k) {
+				*count++
+				if r.strategy != RedactDrop {
+					res[k] = r.apply(fmt.Sprint(item))
+				}
+				continue
+			}
+			res[k] = r.nested(item, count, dropped)
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, 0, len(v))
+		for i := range v {
+			res = append(res, r.nested(v[i], count, dropped))
+		}
+		return res
+	}
+
+	return value
+}
+
+// Replace parts of value matched by patterns.
+func (r *redactor) replace(value string, count *int64, replacer func(string) string) string {
+	for i := range r.patterns {
+		pattern := r.patterns[i]
+		value = pattern.ReplaceAllStringFunc(value, func(m string) string {
+			if pattern == CardNumberPattern && !isLuhnValid(m) {
+				return m
+			}
+
+			*count++
+			return replacer(m)
+		})
+	}
+
+	return value
+}
+
+// Returns true if digits in number pass Luhn check, spaces and dashes are ignored.
+func isLuhnValid(number string) bool {
+	sum, digits := 0, 0
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+
+		d := int(c - '0')
+		if digits%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		digits++
+	}
+
+	return digits > 0 && sum%10 == 0
+}
+
+// Apply strategy on sensitive value.
+func (r *redactor) apply(value string) string {
+	switch r.strategy {
+	case RedactHash:
+		sum := sha256.Sum256([]byte(r.salt + value))
+		return hex.EncodeToString(sum[:8])
+	case RedactTruncate:
+		runes := []rune(value)
+		if len(runes) <= r.truncateLen {
+			return r.mask
+		}
+		return string(runes[:r.truncateLen]) + r.mask
+	}
+
+	return r.mask
+}
+
+// Returns value of field encoded by zapcore.MapObjectEncoder.
+func fieldValue(field zap.Field) interface{} {
+	enc := zapcore.NewMapObjectEncoder()
+	field.AddTo(enc)
+
+	return enc.Fields[field.Key]
+}
+
+// Returns string value of field.
+func fieldValueString(field zap.Field) string {
+	if field.Type == zapcore.StringType {
+		return field.String
+	}
+
+	return fmt.Sprint(fieldValue(field))
+}
+
+// EventView with sensitive values redacted.
+type redactedView struct {
+	EventView
+	payloads []zap.Field
+	errors   map[string]int64
+	pairs    map[string]string
+	counters map[string]int64
+	count    int64
+}
+
+// ListPayloads returns redacted payloads.
+func (view *redactedView) ListPayloads() []zap.Field {
+	return view.payloads
+}
+
+// ListErrors returns errors with redacted messages.
+func (view *redactedView) ListErrors() map[string]int64 {
+	return view.errors
+}
+
+// ListPairs returns redacted pairs.
+func (view *redactedView) ListPairs() map[string]string {
+	return view.pairs
+}
+
+// ListCounters returns counters with number of redactions.
+func (view *redactedView) ListCounters() map[string]int64 {
+	return view.counters
+}
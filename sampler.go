@@ -0,0 +1,353 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"hash/fnv"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	sampleReasonRate    = "rate"
+	sampleReasonLimit   = "limit"
+	sampleReasonError   = "error"
+	sampleReasonResCode = "resCode"
+	sampleReasonSlow    = "slow"
+)
+
+// SamplingDecision is the decision made by Sampler while finishing event.
+type SamplingDecision struct {
+	// Sampled is true if event should be written.
+	Sampled bool
+	// Rate is the probability of event being sampled in range of (0, 1], 1 would be used if out of range.
+	// Count of sampled events multiplied by 1/Rate estimates count of all events.
+	Rate float64
+	// Reason of decision, rate, limit, error, resCode and slow are used by built-in samplers.
+	Reason string
+}
+
+// Sampler decides whether event should be written, see WithSampler().
+type Sampler interface {
+	// Sample is called while finishing event, end time of event was set already.
+	Sample(view EventView) SamplingDecision
+}
+
+// SamplerFunc is an adapter to allow the use of ordinary functions as Sampler.
+type SamplerFunc func(view EventView) SamplingDecision
+
+// Sample calls f(view).
+func (f SamplerFunc) Sample(view EventView) SamplingDecision {
+	return f(view)
+}
+
+// WithSampler samples events while finishing event, events not sampled are neither written into logger
+// nor exported into OTLP exporter.
+//
+// Rate and reason of decision are written as pairs with key sampleRate and sampleReason,
+// use Record.SampleWeight() to re-weight counts of sampled events.
+// Child events rolled up into parent event are not sampled, since they are part of parent event.
+func WithSampler(sampler Sampler) EventOption {
+	return func(event Event) {
+		switch v := event.(type) {
+		case *eventZap:
+			v.sampler = sampler
+		case *eventThreadSafe:
+			v.delegate.sampler = sampler
+		}
+	}
+}
+
+// ************* Head sampling *************
+
+// NewRateSampler creates a Sampler which samples events with fixed rate in range of [0, 1].
+//
+// Decision is made by hash of trace id, so that events with the same trace id are sampled together across
+// services with the same rate. Event id is used if trace id is missing.
+func NewRateSampler(rate float64) Sampler {
+	return rateSampler(clampRate(rate))
+}
+
+// Sampler with fixed rate.
+type rateSampler float64
+
+// Sample event by hash of trace id.
+func (s rateSampler) Sample(view EventView) SamplingDecision {
+	return SamplingDecision{
+		Sampled: sampledByTrace(view, float64(s)),
+		Rate:    float64(s),
+		Reason:  sampleReasonRate,
+	}
+}
+
+// NewOperationSampler creates a Sampler which samples events with rate of operation,
+// defaultRate is used for operations missing in rates. See NewRateSampler() for details.
+func NewOperationSampler(rates map[string]float64, defaultRate float64) Sampler {
+	res := &operationSampler{
+		rates:       make(map[string]float64),
+		defaultRate: clampRate(defaultRate),
+	}
+
+	for k, v := range rates {
+		res.rates[k] = clampRate(v)
+	}
+
+	return res
+}
+
+// Sampler with rate per operation.
+type operationSampler struct {
+	rates       map[string]float64
+	defaultRate float64
+}
+
+// Sample event by hash of trace id with rate of operation.
+func (s *operationSampler) Sample(view EventView) SamplingDecision {
+	rate, ok := s.rates[view.GetOperation()]
+	if !ok {
+		rate = s.defaultRate
+	}
+
+	return rateSampler(rate).Sample(view)
+}
+
+// NewRateLimitSampler creates a Sampler which limits events per second of each operation with token bucket,
+// defaultLimit is used for operations missing in limits. Zero or negative limit means unlimited.
+// Burst of bucket equals to limit and at least one.
+//
+// Rate of decision is estimated by limit divided by events per second of operation in the last second.
+// Buckets are kept for every operation, operations should be in low cardinality.
+func NewRateLimitSampler(limits map[string]float64, defaultLimit float64) Sampler {
+	res := &rateLimitSampler{
+		limits:       make(map[string]float64),
+		defaultLimit: defaultLimit,
+		buckets:      make(map[string]*tokenBucket),
+		now:          time.Now,
+	}
+
+	for k, v := range limits {
+		res.limits[k] = v
+	}
+
+	return res
+}
+
+// Sampler with token bucket per operation.
+type rateLimitSampler struct {
+	limits       map[string]float64
+	defaultLimit float64
+	buckets      map[string]*tokenBucket
+	now          func() time.Time
+	lock         sync.Mutex
+}
+
+// Token bucket with number of events in current and previous second.
+type tokenBucket struct {
+	tokens   float64
+	last     time.Time
+	window   time.Time
+	seen     int64
+	prevSeen int64
+}
+
+// Sample event with token bucket of operation.
+func (s *rateLimitSampler) Sample(view EventView) SamplingDecision {
+	op := view.GetOperation()
+	limit, ok := s.limits[op]
+	if !ok {
+		limit = s.defaultLimit
+	}
+
+	if limit <= 0 || math.IsNaN(limit) {
+		return SamplingDecision{Sampled: true, Rate: 1, Reason: sampleReasonLimit}
+	}
+
+	burst := math.Max(limit, 1)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := s.now()
+	bucket, ok := s.buckets[op]
+	if !ok {
+		bucket = &tokenBucket{tokens: burst, last: now, window: now}
+		s.buckets[op] = bucket
+	}
+
+	// refill tokens
+	bucket.tokens = math.Min(burst, bucket.tokens+now.Sub(bucket.last).Seconds()*limit)
+	bucket.last = now
+
+	// count events in window of one second
+	if elapsed := now.Sub(bucket.window); elapsed >= time.Second {
+		bucket.prevSeen = 0
+		if elapsed < 2*time.Second {
+			bucket.prevSeen = bucket.seen
+		}
+		bucket.seen = 0
+		bucket.window = now
+	}
+	bucket.seen++
+
+	res := SamplingDecision{Rate: 1, Reason: sampleReasonLimit}
+	if arrivals := math.Max(float64(bucket.prevSeen), float64(bucket.seen)); arrivals > limit {
+		res.Rate = limit / arrivals
+	}
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		res.Sampled = true
+	}
+
+	return res
+}
+
+// ************* Tail sampling *************
+
+// NewTailSampler creates a Sampler which always samples events with errors, non-OK res code, or elapsed time
+// no less than slowThreshold, other events are sampled by base. Zero slowThreshold disables slow events.
+// Events not matched are dropped if base is nil.
+//
+// Empty res code is always OK. If okResCodes is empty, OK, 0 and numeric res code less than 400 are OK,
+// case insensitive.
+func NewTailSampler(base Sampler, slowThreshold time.Duration, okResCodes ...string) Sampler {
+	res := &tailSampler{
+		base:          base,
+		slowThreshold: slowThreshold,
+	}
+
+	if len(okResCodes) > 0 {
+		res.okResCodes = make(map[string]bool)
+		for i := range okResCodes {
+			res.okResCodes[strings.ToLower(okResCodes[i])] = true
+		}
+	}
+
+	return res
+}
+
+// Sampler keeps failed and slow events.
+type tailSampler struct {
+	base          Sampler
+	slowThreshold time.Duration
+	okResCodes    map[string]bool
+}
+
+// Sample failed and slow events, delegate others to base.
+func (s *tailSampler) Sample(view EventView) SamplingDecision {
+	if reason := s.reasonOf(view); len(reason) > 0 {
+		return SamplingDecision{Sampled: true, Rate: 1, Reason: reason}
+	}
+
+	if s.base == nil {
+		return SamplingDecision{}
+	}
+
+	return s.base.Sample(view)
+}
+
+// Returns reason if event should be kept, empty string otherwise.
+func (s *tailSampler) reasonOf(view EventView) string {
+	if len(view.ListErrors()) > 0 {
+		return sampleReasonError
+	}
+
+	if !isOkResCode(view.GetResCode(), s.okResCodes) {
+		return sampleReasonResCode
+	}
+
+	if s.slowThreshold > 0 && view.GetEndTime().Sub(view.GetStartTime()) >= s.slowThreshold {
+		return sampleReasonSlow
+	}
+
+	return ""
+}
+
+// Returns true if res code is OK, see NewTailSampler().
+func isOkResCode(resCode string, okResCodes map[string]bool) bool {
+	if len(resCode) < 1 {
+		return true
+	}
+
+	if okResCodes != nil {
+		return okResCodes[strings.ToLower(resCode)]
+	}
+
+	if strings.EqualFold(resCode, "OK") {
+		return true
+	}
+
+	code, err := strconv.Atoi(resCode)
+	return err == nil && code >= 0 && code < 400
+}
+
+// ************* Helpers *************
+
+// Returns rate in range of [0, 1].
+func clampRate(rate float64) float64 {
+	if math.IsNaN(rate) || rate < 0 {
+		return 0
+	}
+
+	return math.Min(rate, 1)
+}
+
+// Returns true if hash of trace id is less than rate.
+func sampledByTrace(view EventView, rate float64) bool {
+	if rate >= 1 {
+		return true
+	}
+
+	if rate <= 0 {
+		return false
+	}
+
+	id := view.GetTraceId()
+	if len(id) < 1 {
+		id = view.GetEventId()
+	}
+
+	hash := fnv.New64a()
+	hash.Write([]byte(id))
+
+	// use 53 bits which float64 could represent exactly
+	return float64(hash.Sum64()>>11)/(1<<53) < rate
+}
+
+// EventView with rate and reason of sampling decision in pairs.
+type sampledView struct {
+	EventView
+	decision SamplingDecision
+}
+
+// Returns view with sampling decision, rate out of range is treated as 1.
+func sampledViewOf(view EventView, decision SamplingDecision) EventView {
+	if !(decision.Rate > 0 && decision.Rate <= 1) {
+		decision.Rate = 1
+	}
+
+	return &sampledView{EventView: view, decision: decision}
+}
+
+// ListPairs returns pairs with sampleRate and sampleReason.
+func (view *sampledView) ListPairs() map[string]string {
+	pairs := view.EventView.ListPairs()
+
+	res := make(map[string]string, len(pairs)+2)
+	for k, v := range pairs {
+		res[k] = v
+	}
+
+	res[sampleRateKey] = strconv.FormatFloat(view.decision.Rate, 'g', -1, 64)
+	if len(view.decision.Reason) > 0 {
+		res[sampleReasonKey] = view.decision.Reason
+	}
+
+	return res
+}
@@ -0,0 +1,226 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Create record with operation and trace id.
+func newSampleRecord(operation, traceId string) *Record {
+	record := newRecord()
+	record.Operation = operation
+	record.TraceId = traceId
+	record.StartTime = time.Now()
+	record.EndTime = record.StartTime
+
+	return record
+}
+
+func TestNewRateSampler_HappyCase(t *testing.T) {
+	sampler := NewRateSampler(0.25)
+
+	sampled := 0
+	for i := 0; i < 10000; i++ {
+		decision := sampler.Sample(newSampleRecord("ut", fmt.Sprintf("ut-trace-%d", i)))
+		assert.Equal(t, 0.25, decision.Rate)
+		assert.Equal(t, sampleReasonRate, decision.Reason)
+		if decision.Sampled {
+			sampled++
+		}
+	}
+
+	assert.InDelta(t, 2500, sampled, 250)
+}
+
+func TestNewRateSampler_WithSameTraceId(t *testing.T) {
+	sampler := NewRateSampler(0.5)
+
+	for i := 0; i < 100; i++ {
+		traceId := fmt.Sprintf("ut-trace-%d", i)
+		expected := sampler.Sample(newSampleRecord("ut-first", traceId)).Sampled
+		assert.Equal(t, expected, sampler.Sample(newSampleRecord("ut-second", traceId)).Sampled)
+	}
+}
+
+func TestNewRateSampler_WithBoundaries(t *testing.T) {
+	record := newSampleRecord("ut", "")
+	record.EventId = "ut-event"
+
+	assert.True(t, NewRateSampler(1).Sample(record).Sampled)
+	assert.True(t, NewRateSampler(2).Sample(record).Sampled)
+	assert.Equal(t, float64(1), NewRateSampler(2).Sample(record).Rate)
+	assert.False(t, NewRateSampler(0).Sample(record).Sampled)
+	assert.False(t, NewRateSampler(-1).Sample(record).Sampled)
+	assert.False(t, NewRateSampler(math.NaN()).Sample(record).Sampled)
+}
+
+func TestNewOperationSampler_HappyCase(t *testing.T) {
+	sampler := NewOperationSampler(map[string]float64{"ut-health": 0, "ut-login": 1}, 0.5)
+
+	assert.False(t, sampler.Sample(newSampleRecord("ut-health", "ut-trace")).Sampled)
+	assert.True(t, sampler.Sample(newSampleRecord("ut-login", "ut-trace")).Sampled)
+	assert.Equal(t, 0.5, sampler.Sample(newSampleRecord("ut-other", "ut-trace")).Rate)
+}
+
+func TestNewRateLimitSampler_HappyCase(t *testing.T) {
+	sampler := NewRateLimitSampler(map[string]float64{"ut-limited": 2}, 0).(*rateLimitSampler)
+	now := time.Now()
+	sampler.now = func() time.Time { return now }
+
+	// burst of 2 events, 3rd event is dropped
+	assert.True(t, sampler.Sample(newSampleRecord("ut-limited", "")).Sampled)
+	assert.True(t, sampler.Sample(newSampleRecord("ut-limited", "")).Sampled)
+	decision := sampler.Sample(newSampleRecord("ut-limited", ""))
+	assert.False(t, decision.Sampled)
+	assert.InDelta(t, 2.0/3, decision.Rate, 0.001)
+	assert.Equal(t, sampleReasonLimit, decision.Reason)
+
+	// buckets are per operation and unlimited by default
+	for i := 0; i < 10; i++ {
+		assert.True(t, sampler.Sample(newSampleRecord("ut-other", "")).Sampled)
+	}
+
+	// refilled after half of second
+	now = now.Add(500 * time.Millisecond)
+	assert.True(t, sampler.Sample(newSampleRecord("ut-limited", "")).Sampled)
+	assert.False(t, sampler.Sample(newSampleRecord("ut-limited", "")).Sampled)
+
+	// rate is estimated with events in previous second
+	now = now.Add(time.Second)
+	decision = sampler.Sample(newSampleRecord("ut-limited", ""))
+	assert.True(t, decision.Sampled)
+	assert.InDelta(t, 2.0/5, decision.Rate, 0.001)
+
+	now = now.Add(time.Hour)
+	decision = sampler.Sample(newSampleRecord("ut-limited", ""))
+	assert.True(t, decision.Sampled)
+	assert.Equal(t, float64(1), decision.Rate)
+}
+
+func TestNewTailSampler_HappyCase(t *testing.T) {
+	sampler := NewTailSampler(NewRateSampler(0), 100*time.Millisecond)
+
+	failed := newSampleRecord("ut", "ut-trace")
+	failed.Errors["ut-err"] = 1
+	assert.Equal(t, SamplingDecision{Sampled: true, Rate: 1, Reason: sampleReasonError}, sampler.Sample(failed))
+
+	for _, code := range []string{"500", "NotFound", "ut"} {
+		record := newSampleRecord("ut", "ut-trace")
+		record.ResCode = code
+		assert.Equal(t, sampleReasonResCode, sampler.Sample(record).Reason, code)
+	}
+
+	slow := newSampleRecord("ut", "ut-trace")
+	slow.EndTime = slow.StartTime.Add(time.Second)
+	assert.Equal(t, sampleReasonSlow, sampler.Sample(slow).Reason)
+
+	for _, code := range []string{"", "ok", "OK", "0", "200", "302"} {
+		record := newSampleRecord("ut", "ut-trace")
+		record.ResCode = code
+		decision := sampler.Sample(record)
+		assert.False(t, decision.Sampled, code)
+		assert.Equal(t, sampleReasonRate, decision.Reason, code)
+	}
+}
+
+func TestNewTailSampler_WithOkResCodesAndNilBase(t *testing.T) {
+	sampler := NewTailSampler(nil, 0, "Success")
+
+	record := newSampleRecord("ut", "ut-trace")
+	record.ResCode = "success"
+	record.EndTime = record.StartTime.Add(time.Hour)
+	assert.False(t, sampler.Sample(record).Sampled)
+
+	record.ResCode = "200"
+	assert.True(t, sampler.Sample(record).Sampled)
+}
+
+func TestWithSampler_HappyCase(t *testing.T) {
+	buf := &bytes.Buffer{}
+	fac := NewEventFactory(
+		WithZapLogger(newBufferLogger(buf, true)),
+		WithEncoding(JSON),
+		WithSampler(NewTailSampler(NewRateSampler(0), 0)))
+
+	// dropped
+	fac.CreateEvent().Finish()
+	assert.Empty(t, buf.String())
+
+	event := fac.CreateEvent()
+	event.AddErr(errors.New("ut-err"))
+	event.Finish()
+
+	record, err := NewReader(buf).Read()
+	assert.Nil(t, err)
+	assert.Equal(t, "1", record.Pairs[sampleRateKey])
+	assert.Equal(t, sampleReasonError, record.Pairs[sampleReasonKey])
+	assert.Equal(t, float64(1), record.SampleWeight())
+
+	// event itself is untouched
+	assert.Empty(t, event.GetValueFromPair(sampleRateKey))
+}
+
+func TestWithSampler_WithRateInOutput(t *testing.T) {
+	buf := &bytes.Buffer{}
+	event := NewEventFactory(
+		WithZapLogger(newBufferLogger(buf, false)),
+		WithSampler(SamplerFunc(func(EventView) SamplingDecision {
+			return SamplingDecision{Sampled: true, Rate: 0.1}
+		}))).CreateEvent()
+	event.Finish()
+
+	record, err := NewReader(buf).Read()
+	assert.Nil(t, err)
+	assert.Equal(t, "0.1", record.Pairs[sampleRateKey])
+	assert.NotContains(t, record.Pairs, sampleReasonKey)
+	assert.InDelta(t, 10, record.SampleWeight(), 0.0001)
+}
+
+func TestWithSampler_WithRollupAndExporter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	path := filepath.Join(t.TempDir(), "ut.otlp")
+	exporter, err := NewOtlpFileExporter(path)
+	assert.Nil(t, err)
+	sampler := NewRateSampler(0)
+
+	parent := newParentEvent(buf, JSON, WithChildRollup(true), WithSampler(sampler), WithOtlpExporter(exporter))
+	parent.StartChild("ut-child").Finish()
+	parent.Finish()
+
+	assert.Empty(t, buf.String())
+	assert.Nil(t, exporter.Flush())
+	exported, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	// rolled up child is not sampled
+	assert.Contains(t, string(exported), "ut-child")
+	assert.NotContains(t, string(exported), "ut-parent")
+	assert.Len(t, parent.(*eventZap).ListChildren(), 1)
+
+	threadSafe := NewEventFactory().CreateEventThreadSafe(WithSampler(sampler))
+	assert.Equal(t, sampler, threadSafe.(*eventThreadSafe).delegate.sampler)
+}
+
+func TestRecord_SampleWeight(t *testing.T) {
+	record := newRecord()
+	assert.Equal(t, float64(1), record.SampleWeight())
+
+	for _, rate := range []string{"ut", "0", "-1", "2", "NaN"} {
+		record.Pairs[sampleRateKey] = rate
+		assert.Equal(t, float64(1), record.SampleWeight(), rate)
+	}
+
+	record.Pairs[sampleRateKey] = "0.25"
+	assert.Equal(t, float64(4), record.SampleWeight())
+}
@@ -0,0 +1,207 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"strconv"
+	"time"
+)
+
+// DurationUnit is the unit of elapsed time written by CONSOLE and JSON encoding.
+type DurationUnit string
+
+const (
+	// DurationNano writes elapsed time as integer nanoseconds with key elapsedNano, it is the default unit.
+	DurationNano DurationUnit = "ns"
+	// DurationMicro writes elapsed time as integer microseconds with key elapsedMicro.
+	DurationMicro DurationUnit = "us"
+	// DurationMilli writes elapsed time as integer milliseconds with key elapsedMs.
+	DurationMilli DurationUnit = "ms"
+	// DurationSecond writes elapsed time as integer seconds with key elapsedSec.
+	DurationSecond DurationUnit = "s"
+	// DurationFloatSecond writes elapsed time as float seconds with key elapsedSeconds.
+	DurationFloatSecond DurationUnit = "float"
+)
+
+// Schema customizes keys and values written by CONSOLE and JSON encoding, see WithSchema().
+//
+// Keys are the default top level keys of CONSOLE and JSON encoding, including endTime, startTime, elapsedNano,
+// timezone, ids, service, env, payloads, error, counters, pairs, timing, remoteAddr, operation, resCode
+// and eventStatus. Key of elapsed time is always referred as elapsedNano regardless of duration unit.
+type Schema struct {
+	// Rename maps default key to new key, e.g. {"resCode": "status"}.
+	Rename map[string]string
+	// Drop keys or whole sections, e.g. ["env", "timezone"].
+	Drop []string
+	// DurationUnit of elapsed time, default is DurationNano.
+	DurationUnit DurationUnit
+	// TimeLayout of startTime and endTime, e.g. time.RFC3339.
+	// Default is RFC3339Nano for CONSOLE and time encoder of zap logger for JSON.
+	TimeLayout string
+	// UTC converts startTime and endTime into UTC.
+	UTC bool
+}
+
+// Default schema of CONSOLE and JSON encoding.
+var defaultSchema = newSchema(Schema{})
+
+// Compiled schema.
+type schema struct {
+	rename       map[string]string
+	drop         map[string]bool
+	durationUnit DurationUnit
+	timeLayout   string
+	utc          bool
+	console      Encoder // CONSOLE encoder with schema, created once
+	json         Encoder // JSON encoder with schema, created once
+}
+
+// Compile schema, maps are copied so that schema could be modified after.
+func newSchema(s Schema) *schema {
+	res := &schema{
+		rename:       make(map[string]string),
+		drop:         make(map[string]bool),
+		durationUnit: s.DurationUnit,
+		timeLayout:   s.TimeLayout,
+		utc:          s.UTC,
+	}
+
+	for k, v := range s.Rename {
+		if len(v) > 0 {
+			res.rename[k] = v
+		}
+	}
+
+	for i := range s.Drop {
+		res.drop[s.Drop[i]] = true
+	}
+
+	switch res.durationUnit {
+	case DurationNano, DurationMicro, DurationMilli, DurationSecond, DurationFloatSecond:
+	default:
+		res.durationUnit = DurationNano
+	}
+
+	res.console = EncoderFunc(func(view EventView) (string, []zap.Field) {
+		return encodeConsoleWithSchema(view, res)
+	})
+	res.json = EncoderFunc(func(view EventView) (string, []zap.Field) {
+		return encodeJsonWithSchema(view, res)
+	})
+
+	return res
+}
+
+// Returns Encoder of encoding with schema, nil would be returned if encoding does not support schema.
+func (s *schema) encoderOf(ec Encoding) Encoder {
+	switch ec {
+	case CONSOLE:
+		return s.console
+	case JSON:
+		return s.json
+	}
+
+	return nil
+}
+
+// Returns true if key should be written.
+func (s *schema) has(key string) bool {
+	return !s.drop[key]
+}
+
+// Returns renamed key.
+func (s *schema) key(key string) string {
+	if renamed, ok := s.rename[key]; ok {
+		return renamed
+	}
+
+	return key
+}
+
+// Returns key of elapsed time with duration unit.
+func (s *schema) elapsedKey() string {
+	// renamed with default key
+	if renamed, ok := s.rename[elapsedKey]; ok {
+		return renamed
+	}
+
+	switch s.durationUnit {
+	case DurationMicro:
+		return "elapsedMicro"
+	case DurationMilli:
+		return elapsedMsKey
+	case DurationSecond:
+		return "elapsedSec"
+	case DurationFloatSecond:
+		return "elapsedSeconds"
+	}
+
+	return elapsedKey
+}
+
+// Returns elapsed time in integer of duration unit, float seconds is not included.
+func (s *schema) elapsedInt(d time.Duration) int64 {
+	switch s.durationUnit {
+	case DurationMicro:
+		return d.Microseconds()
+	case DurationMilli:
+		return d.Milliseconds()
+	case DurationSecond:
+		return int64(d / time.Second)
+	}
+
+	return d.Nanoseconds()
+}
+
+// Append elapsed time in CONSOLE encoding.
+func (s *schema) appendElapsed(buf *buffer.Buffer, d time.Duration) {
+	if s.durationUnit == DurationFloatSecond {
+		var scratch [32]byte
+		buf.Write(strconv.AppendFloat(scratch[:0], d.Seconds(), 'g', -1, 64))
+		return
+	}
+
+	buf.AppendInt(s.elapsedInt(d))
+}
+
+// Returns elapsed time field in JSON encoding.
+func (s *schema) elapsedField(d time.Duration) zap.Field {
+	if s.durationUnit == DurationFloatSecond {
+		return zap.Float64(s.elapsedKey(), d.Seconds())
+	}
+
+	return zap.Int64(s.elapsedKey(), s.elapsedInt(d))
+}
+
+// Returns time in UTC if needed.
+func (s *schema) time(t time.Time) time.Time {
+	if s.utc {
+		return t.UTC()
+	}
+
+	return t
+}
+
+// Append formatted time in CONSOLE encoding.
+func (s *schema) appendTime(buf *buffer.Buffer, t time.Time) {
+	if len(s.timeLayout) > 0 {
+		buf.AppendTime(s.time(t), s.timeLayout)
+		return
+	}
+
+	buf.AppendTime(s.time(t), time.RFC3339Nano)
+}
+
+// Returns time field in JSON encoding, time encoder of logger would be used if time layout is empty.
+func (s *schema) timeField(key string, t time.Time) zap.Field {
+	if len(s.timeLayout) > 0 {
+		return zap.String(s.key(key), s.time(t).Format(s.timeLayout))
+	}
+
+	return zap.Time(s.key(key), s.time(t))
+}
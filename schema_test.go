@@ -0,0 +1,112 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zapcore"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Create a finished event with schema and returns output.
+func writeSchemaEvent(ec Encoding, s Schema) string {
+	buf := &bytes.Buffer{}
+	event := NewEventFactory(
+		WithZapLogger(newBufferLogger(buf, ec == JSON)),
+		WithSchema(s),
+		WithEncoding(ec)).CreateEvent()
+
+	start := time.Date(2021, 6, 13, 8, 0, 0, 0, time.FixedZone("CST", 8*3600))
+	event.SetStartTime(start)
+	event.SetOperation("ut-op")
+	event.SetResCode("OK")
+	event.AddPair("ut-key", "ut-value")
+	event.SetEndTime(start.Add(1500 * time.Millisecond))
+	event.Finish()
+
+	return buf.String()
+}
+
+func TestWithSchema_WithConsole(t *testing.T) {
+	out := writeSchemaEvent(CONSOLE, Schema{
+		Rename:       map[string]string{resCodeKey: "status", pairsKey: "labels", elapsedKey: "took"},
+		Drop:         []string{envKey, timezoneKey},
+		DurationUnit: DurationMilli,
+		TimeLayout:   time.RFC3339,
+		UTC:          true,
+	})
+
+	lines := strings.Split(out, "\n")
+	assert.Contains(t, lines, "startTime=2021-06-13T00:00:00Z")
+	assert.Contains(t, lines, "took=1500")
+	assert.Contains(t, lines, "status=OK")
+	assert.Contains(t, lines, `labels={"ut-key":"ut-value"}`)
+	assert.NotContains(t, out, envKey+"=")
+	assert.NotContains(t, out, timezoneKey+"=")
+}
+
+func TestWithSchema_WithJson(t *testing.T) {
+	out := writeSchemaEvent(JSON, Schema{
+		Rename:       map[string]string{operationKey: "action"},
+		Drop:         []string{envKey, serviceKey, endTimeKey},
+		DurationUnit: DurationFloatSecond,
+		TimeLayout:   "2006-01-02 15:04:05",
+	})
+
+	obj := make(map[string]interface{})
+	assert.Nil(t, json.Unmarshal([]byte(out), &obj))
+	assert.Equal(t, "ut-op", obj["action"])
+	assert.Equal(t, 1.5, obj["elapsedSeconds"])
+	assert.Equal(t, "2021-06-13 08:00:00", obj[startTimeKey])
+	assert.NotContains(t, obj, envKey)
+	assert.NotContains(t, obj, serviceKey)
+	assert.NotContains(t, obj, endTimeKey)
+	assert.NotContains(t, obj, elapsedKey)
+}
+
+func TestWithSchema_WithDefaultSchema(t *testing.T) {
+	// default schema writes the same output as encoding without schema
+	for _, ec := range []Encoding{CONSOLE, JSON} {
+		buf := &bytes.Buffer{}
+		expected := writeFullEvent(buf, ec)
+
+		msg, fields := newSchema(Schema{}).encoderOf(ec).Encode(expected.(*eventZap))
+		expectedMsg, expectedFields := encoderOf(ec).Encode(expected.(*eventZap))
+		assert.Equal(t, expectedMsg, msg, ec.String())
+		assert.Equal(t, expectedFields, fields, ec.String())
+	}
+}
+
+func TestWithSchema_WithOtherEncoding(t *testing.T) {
+	assert.Nil(t, newSchema(Schema{}).encoderOf(LOGFMT))
+
+	out := writeSchemaEvent(LOGFMT, Schema{Rename: map[string]string{resCodeKey: "status"}})
+	assert.Contains(t, out, "resCode=OK")
+}
+
+func TestSchema_Elapsed(t *testing.T) {
+	d := 2500 * time.Millisecond
+	cases := map[DurationUnit][]interface{}{
+		DurationNano:        {elapsedKey, d.Nanoseconds()},
+		DurationMicro:       {"elapsedMicro", d.Microseconds()},
+		DurationMilli:       {elapsedMsKey, int64(2500)},
+		DurationSecond:      {"elapsedSec", int64(2)},
+		DurationFloatSecond: {"elapsedSeconds", 2.5},
+		"unknown":           {elapsedKey, d.Nanoseconds()},
+	}
+
+	for unit, expected := range cases {
+		enc := zapcore.NewMapObjectEncoder()
+		field := newSchema(Schema{DurationUnit: unit}).elapsedField(d)
+		field.AddTo(enc)
+		assert.Equal(t, expected[0], field.Key, string(unit))
+		assert.Equal(t, expected[1], enc.Fields[field.Key], string(unit))
+	}
+}
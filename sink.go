@@ -0,0 +1,494 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrSinkClosed is returned by Sink.Write() if sink was closed.
+var ErrSinkClosed = errors.New("rkquery: sink is closed")
+
+// Sink is the destination of events, it encodes event with its own encoding and writes it, see WithSink().
+//
+// Implementations should be thread safe.
+type Sink interface {
+	// Write encodes event and writes it.
+	//
+	// Write is called by Event.Finish() or workers of AsyncQueue which could not return error, so implementations
+	// should keep errors themselves, like built-in sinks which return them by Flush().
+	Write(view EventView) error
+
+	// Flush writes buffered events.
+	Flush() error
+
+	// Close flushes buffered events and releases resources.
+	Close() error
+}
+
+// WithSink writes events into sink in Event.Finish() instead of zap logger with encoding of event.
+// Use NewFanoutSink() to write events into multiple sinks.
+//
+// Errors occurred in Event.Finish() would be returned by Flush() of built-in sinks.
+func WithSink(sink Sink) EventOption {
+	return func(event Event) {
+		switch v := event.(type) {
+		case *eventZap:
+			v.sink = sink
+		case *eventThreadSafe:
+			v.delegate.sink = sink
+		}
+	}
+}
+
+// ************* Options *************
+
+// SinkOption will be passed into built-in sinks to override default behavior of sink.
+type SinkOption func(*sinkConfig)
+
+// WithSinkEncoder overrides encoding of sink with Encoder.
+func WithSinkEncoder(encoder Encoder) SinkOption {
+	return func(config *sinkConfig) {
+		config.encoder = encoder
+	}
+}
+
+// WithSinkSchema customizes CONSOLE and JSON encoding of sink, see WithSchema().
+func WithSinkSchema(s Schema) SinkOption {
+	compiled := newSchema(s)
+
+	return func(config *sinkConfig) {
+		config.schema = compiled
+	}
+}
+
+// WithSinkFilter writes events into sink only if filter returns true.
+func WithSinkFilter(filter func(view EventView) bool) SinkOption {
+	return func(config *sinkConfig) {
+		config.filter = filter
+	}
+}
+
+// Encoding and filter of sink.
+type sinkConfig struct {
+	encoder Encoder
+	schema  *schema
+	filter  func(view EventView) bool
+}
+
+// Create config with encoding.
+func newSinkConfig(ec Encoding, opts []SinkOption) *sinkConfig {
+	config := &sinkConfig{}
+	for i := range opts {
+		opts[i](config)
+	}
+
+	config.encoder = resolveEncoder(config.encoder, config.schema, ec)
+	return config
+}
+
+// Returns true if event should be written.
+func (config *sinkConfig) accept(view EventView) bool {
+	return config.filter == nil || config.filter(view)
+}
+
+// Returns encoder, schema and encoding are used in order if missing, CONSOLE is the fallback.
+func resolveEncoder(encoder Encoder, s *schema, ec Encoding) Encoder {
+	if encoder == nil && s != nil {
+		encoder = s.encoderOf(ec)
+	}
+	if encoder == nil {
+		encoder = encoderOf(ec)
+	}
+	if encoder == nil {
+		encoder = encoderOf(CONSOLE)
+	}
+
+	return encoder
+}
+
+// Returns event as a line, message is written as it is and fields are written as JSON object.
+// Message would be added into JSON object with key msg if both of them exist.
+func appendLine(buf *buffer.Buffer, msg string, fields []zap.Field) error {
+	if len(fields) < 1 {
+		buf.AppendString(msg)
+		buf.AppendByte('\n')
+		return nil
+	}
+
+	if len(msg) > 0 {
+		fields = append([]zap.Field{zap.String("msg", msg)}, fields...)
+	}
+
+	line, err := lineEncoder.EncodeEntry(zapcore.Entry{}, fields)
+	if err != nil {
+		return err
+	}
+	buf.Write(line.Bytes())
+	line.Free()
+
+	return nil
+}
+
+// Encodes fields of JSON and ECS encoding without time, level and message.
+var lineEncoder = zapcore.NewJSONEncoder(zapcore.EncoderConfig{})
+
+// ************* Writer *************
+
+// NewWriterSink creates a Sink which writes events into writer with encoding, one event per line
+// except multi-line encodings like CONSOLE. Writes are serialized.
+//
+// Flush() calls Flush() of writer if exists, writer is not closed by Close().
+func NewWriterSink(writer io.Writer, ec Encoding, opts ...SinkOption) Sink {
+	return &writerSink{
+		config: newSinkConfig(ec, opts),
+		writer: writer,
+	}
+}
+
+// Sink of io.Writer.
+type writerSink struct {
+	config *sinkConfig
+	writer io.Writer
+	// closed by Close() if not nil
+	closer io.Closer
+	// error occurred in Write()
+	err    error
+	closed bool
+	lock   sync.Mutex
+}
+
+// Write encodes event and writes it into writer.
+func (sink *writerSink) Write(view EventView) error {
+	if !sink.config.accept(view) {
+		return nil
+	}
+
+	msg, fields := sink.config.encoder.Encode(view)
+
+	buf := bufferPool.Get()
+	defer buf.Free()
+
+	if err := appendLine(buf, msg, fields); err != nil {
+		return sink.fail(err)
+	}
+
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+
+	if sink.closed {
+		if sink.err == nil {
+			sink.err = ErrSinkClosed
+		}
+		return ErrSinkClosed
+	}
+
+	if _, err := sink.writer.Write(buf.Bytes()); err != nil {
+		if sink.err == nil {
+			sink.err = err
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Flush flushes writer, error occurred in Write() would be returned as well.
+func (sink *writerSink) Flush() error {
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+
+	return sink.flush()
+}
+
+// Close flushes writer, and closes file of NewFileSink().
+func (sink *writerSink) Close() error {
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+
+	if sink.closed {
+		return nil
+	}
+	sink.closed = true
+
+	err := sink.flush()
+	if sink.closer != nil {
+		if closeErr := sink.closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+
+	return err
+}
+
+// Flush writer and returns error occurred before.
+func (sink *writerSink) flush() error {
+	err := sink.err
+	sink.err = nil
+
+	if flusher, ok := sink.writer.(interface{ Flush() error }); ok {
+		if flushErr := flusher.Flush(); err == nil {
+			err = flushErr
+		}
+	}
+
+	return err
+}
+
+// Records error occurred while encoding.
+func (sink *writerSink) fail(err error) error {
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+
+	if sink.err == nil {
+		sink.err = err
+	}
+
+	return err
+}
+
+// ************* File *************
+
+// NewFileSink creates a Sink which appends events into file at path with encoding.
+// Events are buffered, Flush() writes buffered events into file.
+func NewFileSink(path string, ec Encoding, opts ...SinkOption) (Sink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &writerSink{
+		config: newSinkConfig(ec, opts),
+		writer: bufio.NewWriter(file),
+		closer: file,
+	}, nil
+}
+
+// ************* Logger *************
+
+// NewLoggerSink creates a Sink which writes events into zap logger with encoding.
+// Flush() and Close() call Sync() of logger.
+func NewLoggerSink(logger *zap.Logger, ec Encoding, opts ...SinkOption) Sink {
+	return &loggerSink{
+		config: newSinkConfig(ec, opts),
+		logger: logger,
+	}
+}
+
+// Sink of zap logger.
+type loggerSink struct {
+	config *sinkConfig
+	logger *zap.Logger
+}
+
+// Write encodes event and writes it into logger.
+func (sink *loggerSink) Write(view EventView) error {
+	if !sink.config.accept(view) {
+		return nil
+	}
+
+	msg, fields := sink.config.encoder.Encode(view)
+	sink.logger.Info(msg, fields...)
+
+	return nil
+}
+
+// Flush syncs logger.
+func (sink *loggerSink) Flush() error {
+	return sink.logger.Sync()
+}
+
+// Close syncs logger.
+func (sink *loggerSink) Close() error {
+	return sink.logger.Sync()
+}
+
+// ************* Channel *************
+
+// NewChannelSink creates a Sink which sends encoded events into channel with size as lines
+// without line ending, mainly used for tests and streaming events in process.
+//
+// Events are dropped if channel is full, channel is closed by Close().
+func NewChannelSink(size int, ec Encoding, opts ...SinkOption) *ChannelSink {
+	if size < 0 {
+		size = 0
+	}
+
+	return &ChannelSink{
+		config: newSinkConfig(ec, opts),
+		ch:     make(chan string, size),
+	}
+}
+
+// ChannelSink sends encoded events into channel.
+type ChannelSink struct {
+	// number of events dropped, kept as first field for 64 bits alignment of atomic operations
+	dropped int64
+	config  *sinkConfig
+	ch      chan string
+	closed  bool
+	lock    sync.RWMutex
+	// error occurred in Write()
+	err     error
+	errLock sync.Mutex
+}
+
+// C returns channel of encoded events.
+func (sink *ChannelSink) C() <-chan string {
+	return sink.ch
+}
+
+// Dropped returns number of events dropped since channel was full.
+func (sink *ChannelSink) Dropped() int64 {
+	return atomic.LoadInt64(&sink.dropped)
+}
+
+// Write encodes event and sends it into channel.
+func (sink *ChannelSink) Write(view EventView) error {
+	if !sink.config.accept(view) {
+		return nil
+	}
+
+	msg, fields := sink.config.encoder.Encode(view)
+
+	buf := bufferPool.Get()
+	defer buf.Free()
+
+	if err := appendLine(buf, msg, fields); err != nil {
+		return sink.fail(err)
+	}
+	buf.TrimNewline()
+
+	sink.lock.RLock()
+	defer sink.lock.RUnlock()
+
+	if sink.closed {
+		return sink.fail(ErrSinkClosed)
+	}
+
+	select {
+	case sink.ch <- buf.String():
+	default:
+		atomic.AddInt64(&sink.dropped, 1)
+	}
+
+	return nil
+}
+
+// Flush returns error occurred in Write() once, events are sent into channel in Write() already.
+func (sink *ChannelSink) Flush() error {
+	sink.errLock.Lock()
+	defer sink.errLock.Unlock()
+
+	err := sink.err
+	sink.err = nil
+
+	return err
+}
+
+// Records error occurred in Write().
+func (sink *ChannelSink) fail(err error) error {
+	sink.errLock.Lock()
+	defer sink.errLock.Unlock()
+
+	if sink.err == nil {
+		sink.err = err
+	}
+
+	return err
+}
+
+// Close closes channel.
+func (sink *ChannelSink) Close() error {
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+
+	if !sink.closed {
+		sink.closed = true
+		close(sink.ch)
+	}
+
+	return nil
+}
+
+// ************* Fan-out *************
+
+// NewFanoutSink creates a Sink which writes events into every sink, for example, CONSOLE into stdout
+// and JSON into file at the same time.
+//
+// Sinks are isolated, failure or panic of one sink does not affect others.
+// Error of the first failed sink would be returned with number of failed sinks.
+func NewFanoutSink(sinks ...Sink) Sink {
+	res := make(fanoutSink, 0, len(sinks))
+	for i := range sinks {
+		if sinks[i] != nil {
+			res = append(res, sinks[i])
+		}
+	}
+
+	return res
+}
+
+// Sinks written in order.
+type fanoutSink []Sink
+
+// Write event into every sink.
+func (sinks fanoutSink) Write(view EventView) error {
+	return sinks.each(func(sink Sink) error {
+		return sink.Write(view)
+	})
+}
+
+// Flush every sink.
+func (sinks fanoutSink) Flush() error {
+	return sinks.each(Sink.Flush)
+}
+
+// Close every sink.
+func (sinks fanoutSink) Close() error {
+	return sinks.each(Sink.Close)
+}
+
+// Call fn with every sink, panic is recovered as error.
+func (sinks fanoutSink) each(fn func(Sink) error) error {
+	var first error
+	failed := 0
+
+	for i := range sinks {
+		if err := callSink(sinks[i], fn); err != nil {
+			if first == nil {
+				first = err
+			}
+			failed++
+		}
+	}
+
+	if failed > 1 {
+		return fmt.Errorf("%d of %d sinks failed, first error: %w", failed, len(sinks), first)
+	}
+
+	return first
+}
+
+// Call fn with sink, panic is recovered as error.
+func callSink(sink Sink, fn func(Sink) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("sink panicked: %v", r)
+		}
+	}()
+
+	return fn(sink)
+}
@@ -0,0 +1,259 @@
+// Copyright (c) 2021 rookie-ninja
+//
+// Use of this source code is governed by an Apache-style
+// license that can be found in the LICENSE file.
+
+package rkquery
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Sink which fails or panics.
+type failingSink struct {
+	err   error
+	panic bool
+}
+
+func (sink *failingSink) Write(EventView) error {
+	if sink.panic {
+		panic("ut-panic")
+	}
+	return sink.err
+}
+
+func (sink *failingSink) Flush() error {
+	return sink.err
+}
+
+func (sink *failingSink) Close() error {
+	return sink.err
+}
+
+// Writer which always fails.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("ut-write-err")
+}
+
+// Finish event with operation and error if not nil.
+func finishSinkEvent(fac *EventFactory, operation string, err error) {
+	event := fac.CreateEvent()
+	event.SetOperation(operation)
+	event.SetStartTime(time.Unix(0, 0))
+	event.SetEndTime(time.Unix(1, 0))
+	event.AddPair("ut-key", "ut-value")
+	if err != nil {
+		event.AddErr(err)
+	}
+	event.Finish()
+}
+
+func TestNewWriterSink_HappyCase(t *testing.T) {
+	for _, ec := range []Encoding{CONSOLE, JSON, FLATTEN, LOGFMT} {
+		buf := &bytes.Buffer{}
+		sink := NewWriterSink(buf, ec)
+		fac := NewEventFactory(WithSink(sink))
+
+		finishSinkEvent(fac, "ut-first", nil)
+		finishSinkEvent(fac, "ut-second", nil)
+		assert.Nil(t, sink.Flush(), ec.String())
+
+		assert.True(t, strings.HasSuffix(buf.String(), "\n"), ec.String())
+		assert.Contains(t, buf.String(), "ut-first", ec.String())
+		assert.Contains(t, buf.String(), "ut-second", ec.String())
+		assert.Nil(t, sink.Close(), ec.String())
+	}
+}
+
+func TestNewWriterSink_WithJsonReadable(t *testing.T) {
+	buf := &bytes.Buffer{}
+	fac := NewEventFactory(WithSink(NewWriterSink(buf, JSON)))
+	finishSinkEvent(fac, "ut-first", nil)
+	finishSinkEvent(fac, "ut-second", nil)
+
+	records, err := Parse(buf)
+	assert.Nil(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, "ut-second", records[1].Operation)
+	assert.Equal(t, "ut-value", records[1].Pairs["ut-key"])
+}
+
+func TestNewWriterSink_WithEncoderAndFilter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	sink := NewWriterSink(buf, JSON,
+		WithSinkEncoder(EncoderFunc(func(view EventView) (string, []zap.Field) {
+			return "ut-msg", []zap.Field{zap.String("op", view.GetOperation())}
+		})),
+		WithSinkFilter(func(view EventView) bool {
+			return view.GetOperation() != "ut-skipped"
+		}))
+	fac := NewEventFactory(WithSink(sink))
+
+	finishSinkEvent(fac, "ut-skipped", nil)
+	finishSinkEvent(fac, "ut-written", nil)
+
+	assert.Equal(t, "{\"msg\":\"ut-msg\",\"op\":\"ut-written\"}\n", buf.String())
+}
+
+func TestNewWriterSink_WithWriteError(t *testing.T) {
+	sink := NewWriterSink(failingWriter{}, CONSOLE)
+	finishSinkEvent(NewEventFactory(WithSink(sink)), "ut", nil)
+
+	assert.EqualError(t, sink.Flush(), "ut-write-err")
+	// error is returned once
+	assert.Nil(t, sink.Flush())
+	assert.Nil(t, sink.Close())
+	assert.Equal(t, ErrSinkClosed, sink.Write(newRecord()))
+}
+
+func TestNewFileSink_HappyCase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ut.log")
+	sink, err := NewFileSink(path, JSON)
+	assert.Nil(t, err)
+
+	finishSinkEvent(NewEventFactory(WithSink(sink)), "ut-first", nil)
+	assert.Nil(t, sink.Close())
+
+	// appended
+	sink, err = NewFileSink(path, JSON)
+	assert.Nil(t, err)
+	finishSinkEvent(NewEventFactory(WithSink(sink)), "ut-second", nil)
+	assert.Nil(t, sink.Flush())
+
+	bytes, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	records, err := Parse(strings.NewReader(string(bytes)))
+	assert.Nil(t, err)
+	assert.Len(t, records, 2)
+	assert.Nil(t, sink.Close())
+}
+
+func TestNewFileSink_WithInvalidPath(t *testing.T) {
+	sink, err := NewFileSink(filepath.Join(t.TempDir(), "ut-missing", "ut.log"), JSON)
+	assert.Nil(t, sink)
+	assert.NotNil(t, err)
+}
+
+func TestNewLoggerSink_HappyCase(t *testing.T) {
+	buf := &bytes.Buffer{}
+	sink := NewLoggerSink(newBufferLogger(buf, true), JSON)
+	finishSinkEvent(NewEventFactory(WithSink(sink)), "ut-operation", nil)
+
+	record, err := NewReader(buf).Read()
+	assert.Nil(t, err)
+	assert.Equal(t, "ut-operation", record.Operation)
+}
+
+func TestNewChannelSink_HappyCase(t *testing.T) {
+	sink := NewChannelSink(1, LOGFMT)
+	fac := NewEventFactory(WithSink(sink))
+
+	finishSinkEvent(fac, "ut-first", nil)
+	finishSinkEvent(fac, "ut-second", nil)
+	assert.Equal(t, int64(1), sink.Dropped())
+
+	line := <-sink.C()
+	assert.Contains(t, line, "ut-first")
+	assert.False(t, strings.HasSuffix(line, "\n"))
+
+	assert.Nil(t, sink.Close())
+	assert.Nil(t, sink.Close())
+	_, ok := <-sink.C()
+	assert.False(t, ok)
+	assert.Equal(t, ErrSinkClosed, sink.Write(newRecord()))
+}
+
+func TestNewFanoutSink_HappyCase(t *testing.T) {
+	console, json := &bytes.Buffer{}, &bytes.Buffer{}
+	errorsOnly := NewChannelSink(10, JSON, WithSinkFilter(func(view EventView) bool {
+		return len(view.ListErrors()) > 0
+	}))
+	fac := NewEventFactory(WithSink(NewFanoutSink(
+		NewWriterSink(console, CONSOLE),
+		NewWriterSink(json, JSON),
+		errorsOnly,
+		nil)))
+
+	finishSinkEvent(fac, "ut-ok", nil)
+	finishSinkEvent(fac, "ut-failed", errors.New("ut-err"))
+
+	assert.Contains(t, console.String(), "operation=ut-failed")
+	records, err := Parse(json)
+	assert.Nil(t, err)
+	assert.Len(t, records, 2)
+
+	assert.Nil(t, errorsOnly.Close())
+	lines := []string{}
+	for line := range errorsOnly.C() {
+		lines = append(lines, line)
+	}
+	assert.Len(t, lines, 1)
+	assert.Contains(t, lines[0], "ut-failed")
+}
+
+func TestSink_WithEventFinishedAfterClose(t *testing.T) {
+	sinks := []Sink{NewChannelSink(10, JSON), NewWriterSink(&bytes.Buffer{}, JSON)}
+
+	for _, sink := range sinks {
+		assert.Nil(t, sink.Close())
+
+		// error of Write() in Event.Finish() is kept by sink
+		finishSinkEvent(NewEventFactory(WithSink(sink)), "ut", nil)
+		assert.Equal(t, ErrSinkClosed, sink.Flush())
+		// error is returned once
+		assert.Nil(t, sink.Flush())
+	}
+}
+
+func TestNewFanoutSink_WithFailedSinks(t *testing.T) {
+	buf := &bytes.Buffer{}
+	sink := NewFanoutSink(
+		&failingSink{err: errors.New("ut-first-err")},
+		&failingSink{panic: true},
+		NewWriterSink(buf, CONSOLE))
+
+	// failed sinks do not affect others
+	err := sink.Write(newRecord())
+	assert.EqualError(t, err, "2 of 3 sinks failed, first error: ut-first-err")
+	assert.NotEmpty(t, buf.String())
+
+	assert.EqualError(t, NewFanoutSink(&failingSink{panic: true}).Write(newRecord()), "sink panicked: ut-panic")
+	assert.EqualError(t, sink.Flush(), "ut-first-err")
+	assert.EqualError(t, sink.Close(), "ut-first-err")
+	assert.Nil(t, NewFanoutSink().Write(newRecord()))
+}
+
+func TestWithSink_WithQuietModeAndAsync(t *testing.T) {
+	// quiet mode suppresses sink
+	sink := NewChannelSink(10, JSON)
+	finishSinkEvent(NewEventFactory(WithSink(sink), WithQuietMode(true)), "ut", nil)
+	assert.Len(t, sink.C(), 0)
+
+	queue := NewAsyncQueue()
+	finishSinkEvent(NewEventFactory(WithSink(sink), WithAsync(queue)), "ut-async", nil)
+	assert.Nil(t, queue.Flush(context.Background()))
+	assert.Contains(t, <-sink.C(), "ut-async")
+	assert.Nil(t, queue.Close())
+}
+
+func TestWithSink_WithThreadSafeAndChild(t *testing.T) {
+	sink := NewChannelSink(10, JSON)
+
+	event := NewEventFactory().CreateEventThreadSafe(WithSink(sink))
+	assert.Equal(t, sink, event.(*eventThreadSafe).delegate.sink)
+
+	parent := NewEventFactory(WithSink(sink)).CreateEvent()
+	parent.StartChild("ut-child").Finish()
+	assert.Contains(t, <-sink.C(), "ut-child")
+}
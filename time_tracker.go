@@ -19,6 +19,8 @@ type timeTracker struct {
 	countTotal      int64
 	elapsedTotalMs  int64
 	isFinished      bool
+	elapsedMsKey    string // Key of elapsed time in timing section, created on first use
+	countKey        string // Key of count in timing section, created on first use
 }
 
 // Create a new timeTracker with name.
@@ -121,6 +123,25 @@ func (tracker *timeTracker) Finish() {
 	tracker.indexCurr = 0
 }
 
+// Append elapsed time and count of timeTracker into timings with the same keys and values as ToZapFields().
+func (tracker *timeTracker) appendTimings(timings []timing) []timing {
+	if tracker.indexCurr == 0 {
+		if len(tracker.countKey) < 1 {
+			tracker.elapsedMsKey = tracker.name + ".elapsedMs"
+			tracker.countKey = tracker.name + ".count"
+		}
+
+		return append(timings,
+			timing{key: tracker.elapsedMsKey, value: tracker.elapsedTotalMs},
+			timing{key: tracker.countKey, value: tracker.countTotal})
+	}
+
+	prefix := tracker.name + openMarker + strconv.FormatInt(tracker.indexCurr, 10)
+	return append(timings,
+		timing{key: prefix + ".elapsedMs", value: tracker.elapsedTotalMs},
+		timing{key: prefix + ".count", value: tracker.countTotal})
+}
+
 // ToZapFields convert to zap fields.
 func (tracker *timeTracker) ToZapFields(enc *zapcore.MapObjectEncoder) []zap.Field {
 	if tracker.indexCurr == 0 {